@@ -1,8 +1,11 @@
 package main
 
 import (
+	"expvar"
+	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
 	"syscall"
 
 	"booking_client/internal/config"
@@ -46,19 +49,50 @@ func main() {
 		log.Fatal().Err(err).Msg("Failed to initialize Telegram bot")
 	}
 
+	// Expose worker queue depths for monitoring
+	expvar.Publish("telegram_update_queues", expvar.Func(func() any {
+		return bot.QueueStats()
+	}))
+	if cfg.MetricsPort != 0 {
+		startMetricsServer(cfg.MetricsPort)
+	}
+
 	// Initialize handlers
 	handler, err := handlers.NewHandler(bot, cfg, &log.Logger)
 	if err != nil {
 		log.Fatal().Err(err).Msg("Failed to initialize handlers")
 	}
+	expvar.Publish("telegram_callbacks", expvar.Func(func() any {
+		return handler.CallbackStats()
+	}))
+	expvar.Publish("telegram_callback_routes", expvar.Func(func() any {
+		return handler.CallbackRoutes()
+	}))
+	expvar.Publish("booking_api_breakers", expvar.Func(func() any {
+		return handler.APIBreakerStats()
+	}))
+	expvar.Publish("booking_api_cache", expvar.Func(func() any {
+		return handler.APICacheStats()
+	}))
 
-	// Register command handlers
-	handler.RegisterHandlers()
+	// Route incoming updates to the handlers
+	bot.SetUpdateHandler(handler)
 
-	log.Info().Msg("Starting Telegram bot...")
+	log.Info().Str("mode", cfg.UpdateMode).Msg("Starting Telegram bot...")
 
-	// Start the bot
-	if err := bot.Start(); err != nil {
+	// Start the bot in the configured update delivery mode
+	if cfg.UpdateMode == config.UpdateModeWebhook {
+		err = bot.StartWebhook(telegram.WebhookConfig{
+			URL:                cfg.WebhookURL,
+			Port:               cfg.Port,
+			SecretToken:        cfg.WebhookSecret,
+			RemoveOnStop:       cfg.WebhookRemoveOnStop,
+			DropPendingUpdates: cfg.WebhookDropPendingUpdates,
+		})
+	} else {
+		err = bot.Start()
+	}
+	if err != nil {
 		log.Fatal().Err(err).Msg("Failed to start bot")
 	}
 
@@ -69,4 +103,22 @@ func main() {
 
 	log.Info().Msg("Shutting down bot...")
 	bot.Stop()
+
+	if err := handler.Close(); err != nil {
+		log.Error().Err(err).Msg("Failed to close handlers")
+	}
+}
+
+// startMetricsServer serves expvar metrics on /debug/vars
+func startMetricsServer(port int) {
+	mux := http.NewServeMux()
+	mux.Handle("/debug/vars", expvar.Handler())
+
+	go func() {
+		addr := ":" + strconv.Itoa(port)
+		log.Info().Str("addr", addr).Msg("Metrics server listening")
+		if err := http.ListenAndServe(addr, mux); err != nil {
+			log.Error().Err(err).Msg("Metrics server stopped")
+		}
+	}()
 }
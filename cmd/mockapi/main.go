@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
+
+	"booking_client/internal/mockapi"
+	"booking_client/internal/token"
+	"booking_client/internal/util"
+
+	"github.com/caarlos0/env/v11"
+	"github.com/joho/godotenv"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+// mockConfig holds the configuration of the mock booking API
+type mockConfig struct {
+	// Port the mock API listens on (matches the default API_BASE_URL)
+	Port int `env:"MOCK_API_PORT" envDefault:"8080"`
+
+	// JWTSecret must match the bot's JWT_SECRET; JWTKeyID its JWT_KEY_ID
+	JWTSecret string `env:"JWT_SECRET" envDefault:""`
+	JWTKeyID  string `env:"JWT_KEY_ID" envDefault:""`
+
+	// JWTPublicKeyFiles verifies RS256/EdDSA tokens: comma separated kid=path of PEM public keys
+	JWTPublicKeyFiles map[string]string `env:"JWT_PUBLIC_KEY_FILES" envSeparator:"," envKeyValSeparator:"="`
+
+	// Seed creates demo professionals on startup
+	Seed bool `env:"MOCK_API_SEED" envDefault:"true"`
+
+	// Debug logs every request
+	Debug bool `env:"DEBUG" envDefault:"false"`
+}
+
+// demoPassword is the sign-in password of the seeded professionals
+const demoPassword = "password"
+
+func main() {
+	if err := godotenv.Load(); err != nil {
+		log.Warn().Msg("No .env file found, using system environment variables")
+	}
+
+	cfg := &mockConfig{}
+	if err := env.Parse(cfg); err != nil {
+		log.Fatal().Err(err).Msg("Failed to parse environment variables")
+	}
+
+	if cfg.Debug {
+		zerolog.SetGlobalLevel(zerolog.DebugLevel)
+	} else {
+		zerolog.SetGlobalLevel(zerolog.InfoLevel)
+	}
+	log.Logger = log.Output(zerolog.ConsoleWriter{Out: os.Stdout, TimeFormat: time.Kitchen})
+
+	if err := util.InitTimezone(); err != nil {
+		log.Warn().Err(err).Msg("Failed to load timezone, falling back to local timezone")
+	}
+
+	var keys []*token.Key
+	if cfg.JWTSecret != "" {
+		key, err := token.NewHMACKey(cfg.JWTKeyID, cfg.JWTSecret)
+		if err != nil {
+			log.Fatal().Err(err).Msg("Invalid JWT_SECRET")
+		}
+		keys = append(keys, key)
+	}
+	for kid, path := range cfg.JWTPublicKeyFiles {
+		key, err := token.LoadKeyFile(kid, path)
+		if err != nil {
+			log.Fatal().Err(err).Str("kid", kid).Msg("Invalid JWT_PUBLIC_KEY_FILES")
+		}
+		keys = append(keys, key)
+	}
+	tokenMaker, err := token.NewJWTMakerWithOptions(token.JWTOptions{VerifyKeys: keys})
+	if err != nil {
+		log.Fatal().Err(err).Msg("Invalid JWT keys")
+	}
+
+	api := mockapi.NewServer(tokenMaker, &log.Logger)
+	if cfg.Seed {
+		for _, p := range []struct{ username, firstName, lastName string }{
+			{"anna", "Anna", "Smith"},
+			{"mark", "Mark", "Brown"},
+		} {
+			api.AddProfessional(p.username, demoPassword, p.firstName, p.lastName)
+			log.Info().
+				Str("username", p.username).
+				Str("password", demoPassword).
+				Msg("Seeded professional")
+		}
+	}
+
+	server := &http.Server{
+		Addr:              ":" + strconv.Itoa(cfg.Port),
+		Handler:           api,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		log.Info().Str("addr", server.Addr).Msg("Mock booking API listening")
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatal().Err(err).Msg("Mock booking API stopped")
+		}
+	}()
+
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	<-quit
+
+	log.Info().Msg("Shutting down mock booking API...")
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		log.Error().Err(err).Msg("Failed to shut down mock booking API")
+	}
+}
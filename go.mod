@@ -9,6 +9,8 @@ require (
 	github.com/google/uuid v1.6.0
 	github.com/joho/godotenv v1.5.1
 	github.com/rs/zerolog v1.34.0
+	go.etcd.io/bbolt v1.3.10
+	golang.org/x/sync v0.11.0
 )
 
 require (
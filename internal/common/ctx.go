@@ -10,8 +10,11 @@ import (
 )
 
 const (
-	RequestIDKey string = "request_id"
-	LoggerKey    string = "logger"
+	RequestIDKey       string = "request_id"
+	LoggerKey          string = "logger"
+	ChatIDKey          string = "chat_id"
+	ServiceIdentityKey string = "service_identity"
+	RetryCallbackKey   string = "retry_callback"
 )
 
 // Error messages
@@ -41,15 +44,48 @@ func WithLogger(ctx context.Context, logger zerolog.Logger) context.Context {
 	return context.WithValue(ctx, LoggerKey, logger)
 }
 
-// GetUserOrSendError retrieves user from repository or sends error message
-func GetUserOrSendError(userRepo *repository.UserRepository, bot *telegram.Bot, logger zerolog.Logger, chatID int64) (*models.User, bool) {
-	user, exists := userRepo.GetUser(chatID)
-	if !exists || user == nil {
+// GetChatID returns the chat whose update is being handled
+func GetChatID(ctx context.Context) (int64, bool) {
+	chatID, ok := ctx.Value(ChatIDKey).(int64)
+	return chatID, ok
+}
+
+// WithChatID marks the context as handling an update of the chat, so API calls act for its user
+func WithChatID(ctx context.Context, chatID int64) context.Context {
+	return context.WithValue(ctx, ChatIDKey, chatID)
+}
+
+// IsServiceIdentity reports whether API calls must be made as the bot service
+func IsServiceIdentity(ctx context.Context) bool {
+	service, _ := ctx.Value(ServiceIdentityKey).(bool)
+	return service
+}
+
+// WithServiceIdentity makes API calls as the bot service even inside a user's update
+func WithServiceIdentity(ctx context.Context) context.Context {
+	return context.WithValue(ctx, ServiceIdentityKey, true)
+}
+
+// GetRetryCallback returns the callback data that repeats the action being handled
+func GetRetryCallback(ctx context.Context) (string, bool) {
+	data, ok := ctx.Value(RetryCallbackKey).(string)
+	return data, ok && data != ""
+}
+
+// WithRetryCallback records the data of the pressed button, so a failed action can be offered again
+func WithRetryCallback(ctx context.Context, data string) context.Context {
+	return context.WithValue(ctx, RetryCallbackKey, data)
+}
+
+// GetSessionOrSendError retrieves the chat session from repository or sends error message
+func GetSessionOrSendError(userRepo repository.UserRepository, bot telegram.Messenger, logger zerolog.Logger, chatID int64) (*models.Session, bool) {
+	session, exists := userRepo.GetSession(chatID)
+	if !exists || session == nil {
 		text := "❌ User session not found. Please use /start to begin."
 		if err := bot.SendMessage(chatID, text); err != nil {
 			logger.Error().Err(err).Msg("Failed to send user not found message")
 		}
 		return nil, false
 	}
-	return user, true
+	return session, true
 }
@@ -2,6 +2,8 @@ package config
 
 import (
 	"fmt"
+	"net/url"
+	"time"
 
 	"github.com/caarlos0/env/v11"
 )
@@ -11,23 +13,92 @@ type Config struct {
 	// Telegram Bot config
 	TelegramToken string `env:"TELEGRAM_BOT_TOKEN" envDefault:""`
 
-	// API config
-	APIBaseURL string `env:"API_BASE_URL" envDefault:"http://localhost:8080"`
+	// API config (the timeout applies to each attempt; only idempotent calls are retried)
+	APIBaseURL        string        `env:"API_BASE_URL" envDefault:"http://localhost:8080"`
+	APITimeout        time.Duration `env:"API_TIMEOUT" envDefault:"30s"`
+	APIMaxRetries     int           `env:"API_MAX_RETRIES" envDefault:"2"`
+	APIRetryBaseDelay time.Duration `env:"API_RETRY_BASE_DELAY" envDefault:"200ms"`
+	APIRetryMaxDelay  time.Duration `env:"API_RETRY_MAX_DELAY" envDefault:"2s"`
 
-	// JWT config
-	JWTSecret string `env:"JWT_SECRET" envDefault:""`
+	// API circuit breaker (consecutive failures open it for the duration; 0 failures disables it)
+	APIBreakerFailures     int           `env:"API_BREAKER_FAILURES" envDefault:"5"`
+	APIBreakerOpenDuration time.Duration `env:"API_BREAKER_OPEN_DURATION" envDefault:"30s"`
+
+	// API response cache (TTL per endpoint, 0 disables caching of the endpoint)
+	APICacheProfessionalsTTL    time.Duration `env:"API_CACHE_PROFESSIONALS_TTL" envDefault:"5m"`
+	APICacheAvailabilityTTL     time.Duration `env:"API_CACHE_AVAILABILITY_TTL" envDefault:"30s"`
+	APICacheAppointmentDatesTTL time.Duration `env:"API_CACHE_APPOINTMENT_DATES_TTL" envDefault:"1m"`
+	APICacheMaxEntries          int           `env:"API_CACHE_MAX_ENTRIES" envDefault:"10000"`
+
+	// Days of a booking calendar whose availability is requested at once
+	APIAvailabilityConcurrency int `env:"API_AVAILABILITY_CONCURRENCY" envDefault:"4"`
+
+	// JWT config (a private key file signs with RS256/EdDSA instead of the shared secret)
+	JWTSecret         string        `env:"JWT_SECRET" envDefault:""`
+	JWTPrivateKeyFile string        `env:"JWT_PRIVATE_KEY_FILE" envDefault:""`
+	JWTKeyID          string        `env:"JWT_KEY_ID" envDefault:""`
+	JWTIssuer         string        `env:"JWT_ISSUER" envDefault:"booking_client"`
+	JWTAudience       string        `env:"JWT_AUDIENCE" envDefault:""`
+	JWTTTL            time.Duration `env:"JWT_TTL" envDefault:"1h"`
+	JWTUserTTL        time.Duration `env:"JWT_USER_TTL" envDefault:"5m"`
+
+	// Callback data signing config (secret defaults to JWT_SECRET, 0 TTL never expires)
+	CallbackSecret string        `env:"CALLBACK_SECRET" envDefault:""`
+	CallbackTTL    time.Duration `env:"CALLBACK_TTL" envDefault:"72h"`
+
+	// Professional self-registration config (empty invite code leaves registration open)
+	ProfessionalInviteCode string `env:"PROFESSIONAL_INVITE_CODE" envDefault:""`
+
+	// Sign-in lockout config (0 attempts disables)
+	SignInMaxAttempts         int           `env:"SIGN_IN_MAX_ATTEMPTS" envDefault:"5"`
+	SignInMaxUsernameAttempts int           `env:"SIGN_IN_MAX_USERNAME_ATTEMPTS" envDefault:"50"`
+	SignInLockout             time.Duration `env:"SIGN_IN_LOCKOUT" envDefault:"15m"`
+
+	// Admin config (chats allowed to use admin commands such as /revoke)
+	AdminChatIDs []int64 `env:"ADMIN_CHAT_IDS" envSeparator:","`
+
+	// Telegram update delivery config
+	UpdateMode                string `env:"TELEGRAM_UPDATE_MODE" envDefault:"polling"` // "polling" or "webhook"
+	WebhookURL                string `env:"TELEGRAM_WEBHOOK_URL" envDefault:""`
+	WebhookSecret             string `env:"TELEGRAM_WEBHOOK_SECRET" envDefault:""`
+	WebhookRemoveOnStop       bool   `env:"TELEGRAM_WEBHOOK_REMOVE_ON_STOP" envDefault:"true"`
+	WebhookDropPendingUpdates bool   `env:"TELEGRAM_WEBHOOK_DROP_PENDING_UPDATES" envDefault:"false"`
+
+	// Session store config
+	SessionStore     string `env:"SESSION_STORE" envDefault:"memory"` // "memory" or "bolt"
+	SessionStorePath string `env:"SESSION_STORE_PATH" envDefault:"data/sessions.db"`
+
+	// Session expiry config (0 disables)
+	SessionIdleTTL       time.Duration `env:"SESSION_IDLE_TTL" envDefault:"30m"`
+	SessionMaxEntries    int           `env:"SESSION_MAX_ENTRIES" envDefault:"10000"`
+	SessionSweepInterval time.Duration `env:"SESSION_SWEEP_INTERVAL" envDefault:"1m"`
 
 	// Debug config
 	Debug bool `env:"DEBUG" envDefault:"false"`
 
-	// Port config (for webhook if needed)
+	// Port config (webhook HTTP server)
 	Port int `env:"PORT" envDefault:"8081"`
 
+	// Metrics config (expvar at /debug/vars, 0 disables)
+	MetricsPort int `env:"METRICS_PORT" envDefault:"0"`
+
 	// Log config
 	LogLevel  string `env:"LOG_LEVEL" envDefault:"info"`
 	LogFormat string `env:"LOG_FORMAT" envDefault:"json"`
 }
 
+// Update delivery modes
+const (
+	UpdateModePolling = "polling"
+	UpdateModeWebhook = "webhook"
+)
+
+// Session stores
+const (
+	SessionStoreMemory = "memory"
+	SessionStoreBolt   = "bolt"
+)
+
 // Load loads configuration from environment variables
 func Load() (*Config, error) {
 	cfg := &Config{}
@@ -41,8 +112,74 @@ func Load() (*Config, error) {
 		return nil, fmt.Errorf("TELEGRAM_BOT_TOKEN environment variable is required")
 	}
 
-	if cfg.JWTSecret == "" {
-		return nil, fmt.Errorf("JWT_SECRET environment variable is required")
+	if cfg.JWTSecret == "" && cfg.JWTPrivateKeyFile == "" {
+		return nil, fmt.Errorf("JWT_SECRET or JWT_PRIVATE_KEY_FILE environment variable is required")
+	}
+	if cfg.APITimeout <= 0 {
+		return nil, fmt.Errorf("API_TIMEOUT must be positive")
+	}
+	if cfg.APIMaxRetries < 0 || cfg.APIRetryBaseDelay < 0 || cfg.APIRetryMaxDelay < 0 {
+		return nil, fmt.Errorf("API_MAX_RETRIES, API_RETRY_BASE_DELAY and API_RETRY_MAX_DELAY must not be negative")
+	}
+	if cfg.APIBreakerFailures < 0 || cfg.APIBreakerOpenDuration < 0 {
+		return nil, fmt.Errorf("API_BREAKER_FAILURES and API_BREAKER_OPEN_DURATION must not be negative")
+	}
+	if cfg.APICacheProfessionalsTTL < 0 || cfg.APICacheAvailabilityTTL < 0 || cfg.APICacheAppointmentDatesTTL < 0 || cfg.APICacheMaxEntries < 0 {
+		return nil, fmt.Errorf("API_CACHE_* settings must not be negative")
+	}
+	if cfg.APIAvailabilityConcurrency <= 0 {
+		return nil, fmt.Errorf("API_AVAILABILITY_CONCURRENCY must be positive")
+	}
+
+	if cfg.JWTTTL <= 0 || cfg.JWTUserTTL <= 0 {
+		return nil, fmt.Errorf("JWT_TTL and JWT_USER_TTL must be positive")
+	}
+
+	if cfg.CallbackSecret == "" {
+		cfg.CallbackSecret = cfg.JWTSecret
+	}
+	if cfg.CallbackSecret == "" {
+		return nil, fmt.Errorf("CALLBACK_SECRET environment variable is required without JWT_SECRET")
+	}
+	if cfg.CallbackTTL < 0 {
+		return nil, fmt.Errorf("CALLBACK_TTL must not be negative")
+	}
+
+	if cfg.SignInMaxAttempts < 0 || cfg.SignInMaxUsernameAttempts < 0 || cfg.SignInLockout < 0 {
+		return nil, fmt.Errorf("SIGN_IN_MAX_ATTEMPTS, SIGN_IN_MAX_USERNAME_ATTEMPTS and SIGN_IN_LOCKOUT must not be negative")
+	}
+	if cfg.SignInMaxUsernameAttempts > 0 && cfg.SignInMaxUsernameAttempts < cfg.SignInMaxAttempts {
+		return nil, fmt.Errorf("SIGN_IN_MAX_USERNAME_ATTEMPTS must not be below SIGN_IN_MAX_ATTEMPTS")
+	}
+
+	switch cfg.UpdateMode {
+	case UpdateModePolling:
+	case UpdateModeWebhook:
+		if cfg.WebhookURL == "" {
+			return nil, fmt.Errorf("TELEGRAM_WEBHOOK_URL environment variable is required in webhook mode")
+		}
+		if _, err := url.ParseRequestURI(cfg.WebhookURL); err != nil {
+			return nil, fmt.Errorf("invalid TELEGRAM_WEBHOOK_URL: %w", err)
+		}
+		if cfg.WebhookSecret == "" {
+			return nil, fmt.Errorf("TELEGRAM_WEBHOOK_SECRET environment variable is required in webhook mode")
+		}
+	default:
+		return nil, fmt.Errorf("invalid TELEGRAM_UPDATE_MODE %q: must be %q or %q", cfg.UpdateMode, UpdateModePolling, UpdateModeWebhook)
+	}
+
+	switch cfg.SessionStore {
+	case SessionStoreMemory:
+	case SessionStoreBolt:
+		if cfg.SessionStorePath == "" {
+			return nil, fmt.Errorf("SESSION_STORE_PATH environment variable is required for the bolt session store")
+		}
+	default:
+		return nil, fmt.Errorf("invalid SESSION_STORE %q: must be %q or %q", cfg.SessionStore, SessionStoreMemory, SessionStoreBolt)
+	}
+
+	if cfg.SessionIdleTTL < 0 || cfg.SessionMaxEntries < 0 || cfg.SessionSweepInterval < 0 {
+		return nil, fmt.Errorf("SESSION_IDLE_TTL, SESSION_MAX_ENTRIES and SESSION_SWEEP_INTERVAL must not be negative")
 	}
 
 	return cfg, nil
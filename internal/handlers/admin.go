@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"booking_client/internal/common"
+	handlersCommon "booking_client/internal/handlers/common"
+	"booking_client/internal/models"
+	apiService "booking_client/internal/services/api_service"
+)
+
+// handleRevoke signs a professional out of the chat given as argument, on behalf of an admin
+func (h *Handler) handleRevoke(ctx context.Context, adminChatID int64, args string) {
+	chatID, err := strconv.ParseInt(args, 10, 64)
+	if err != nil {
+		h.sendMessage(ctx, adminChatID, handlersCommon.UIMsgRevokeUsage)
+		return
+	}
+
+	// The admin acts for no user of their own; the API sees the bot service
+	ctx = common.WithServiceIdentity(ctx)
+	user, err := h.apiService.GetUserByChatID(ctx, chatID)
+	if err != nil && !errors.Is(err, apiService.ErrNotFound) {
+		logger := common.GetLogger(ctx)
+		logger.Error().Err(err).Int64("chat_id", chatID).Msg("Failed to look up the chat to revoke")
+		h.sendMessage(ctx, adminChatID, fmt.Sprintf(handlersCommon.ErrorMsgRevokeLookupFailed, chatID, apiService.FormatErrorForUser(err)))
+		return
+	}
+	if err != nil || user == nil || user.Role != models.RoleProfessional {
+		h.sendMessage(ctx, adminChatID, fmt.Sprintf(handlersCommon.ErrorMsgNotAProfessionalChat, chatID))
+		return
+	}
+
+	err = h.professionalHandler.RevokeSession(ctx, chatID)
+
+	logger := common.GetLogger(ctx)
+	logger.Info().
+		Int64("admin_chat_id", adminChatID).
+		Int64("chat_id", chatID).
+		Str("user_id", user.ID).
+		Msg("Revoked professional session")
+
+	// The revoked chat is back to the menu of chats without a profile
+	h.commandRouter.SyncMenu(ctx, chatID)
+	h.sendMessage(ctx, adminChatID, fmt.Sprintf(handlersCommon.SuccessMsgSessionRevoked, chatID))
+	if err != nil {
+		logger.Warn().Err(err).Int64("chat_id", chatID).Msg("Revoked locally, but the API did not unbind the chat")
+		h.sendMessage(ctx, adminChatID, fmt.Sprintf(handlersCommon.WarningMsgRevokeNotSynced, apiService.FormatErrorForUser(err)))
+	}
+}
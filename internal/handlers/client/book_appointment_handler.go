@@ -2,8 +2,11 @@ package client
 
 import (
 	"context"
+	"errors"
 
+	"booking_client/internal/common"
 	handlersCommon "booking_client/internal/handlers/common"
+	"booking_client/internal/handlers/keyboards"
 	"booking_client/internal/models"
 	"booking_client/internal/schemas"
 	apiService "booking_client/internal/services/api_service"
@@ -14,11 +17,8 @@ import (
 
 // HandleBookAppointment starts the appointment booking process
 func (h *ClientHandler) HandleBookAppointment(ctx context.Context, chatID int64, messageID int) {
-	// Validate user state - only allow if not in any specific state
-	user, valid := h.validateUserState(ctx, chatID, []string{
-		models.StateNone,
-	})
-	if !valid {
+	session, ok := handlersCommon.GetSessionOrSendError(h.apiService.GetUserRepository(), h.bot, h.logger, chatID)
+	if !ok {
 		return
 	}
 	// Delete message for dashboard
@@ -27,90 +27,139 @@ func (h *ClientHandler) HandleBookAppointment(ctx context.Context, chatID int64,
 		h.bot.DeleteMessage(chatID, messageID)
 	}()
 
-	// Set booking state
-	user.State = models.StateWaitingForProfessionalSelection
-	h.apiService.GetUserRepository().SetUser(chatID, user)
-
-	// Get professionals
+	// Get professionals before starting the flow, so a failure leaves the chat where it was
 	professionals, err := h.apiService.GetProfessionals(ctx)
 	if err != nil {
 		h.sendError(ctx, chatID, handlersCommon.ErrorMsgFailedToLoadProfessionals, err)
 		return
 	}
 
+	// Start booking flow
+	if !h.startFlow(chatID, session, models.FlowBooking) {
+		return
+	}
+	h.apiService.GetUserRepository().SetSession(chatID, session)
+
 	if len(professionals.Professionals) == 0 {
 		h.sendMessage(chatID, handlersCommon.ErrorMsgNoProfessionals)
 		h.ShowDashboard(ctx, chatID, messageID)
 		return
 	}
 
-	keyboard := h.createProfessionalsKeyboard(professionals.Professionals)
+	keyboard := h.createProfessionalsKeyboard(chatID, professionals.Professionals)
 	err = h.bot.SendMessageWithKeyboard(chatID, handlersCommon.UIMsgSelectProfessional, keyboard)
 	if err != nil {
 		h.sendError(ctx, chatID, handlersCommon.ErrorMsgFailedToSendMessage, err)
 		return
 	}
-	user.LastMessageID = &messageID
-	user.MessagesToDelete = append(user.MessagesToDelete, &messageID)
-	h.apiService.GetUserRepository().SetUser(chatID, user)
+	session.TrackMessage(messageID)
+	h.apiService.GetUserRepository().SetSession(chatID, session)
+}
+
+// StartBookingWithProfessional starts the booking flow with the professional already selected,
+// as for clients arriving from a professional's link
+func (h *ClientHandler) StartBookingWithProfessional(ctx context.Context, chatID int64, professionalID string, messageID int) {
+	session, ok := handlersCommon.GetSessionOrSendError(h.apiService.GetUserRepository(), h.bot, h.logger, chatID)
+	if !ok {
+		return
+	}
+
+	professionals, err := h.apiService.GetProfessionals(ctx)
+	if err != nil {
+		h.sendError(ctx, chatID, handlersCommon.ErrorMsgFailedToLoadProfessionals, err)
+		return
+	}
+	if !hasProfessional(professionals.Professionals, professionalID) {
+		h.sendMessage(chatID, handlersCommon.ErrorMsgReferralProfessionalNotFound)
+		h.ShowDashboard(ctx, chatID, messageID)
+		return
+	}
+
+	if !h.startFlow(chatID, session, models.FlowBooking) {
+		return
+	}
+	h.apiService.GetUserRepository().SetSession(chatID, session)
+	h.HandleProfessionalSelection(ctx, chatID, professionalID, messageID)
 }
 
 // HandleProfessionalSelection handles when user selects a professional
 func (h *ClientHandler) HandleProfessionalSelection(ctx context.Context, chatID int64, professionalID string, messageID int) {
-	user, ok := handlersCommon.GetUserOrSendError(h.apiService.GetUserRepository(), h.bot, h.logger, chatID)
+	session, ok := handlersCommon.GetSessionOrSendError(h.apiService.GetUserRepository(), h.bot, h.logger, chatID)
 	if !ok {
 		return
 	}
 
-	user.State = models.StateWaitingForDateSelection
-	user.SelectedProfessionalID = professionalID
-	user.LastMessageID = &messageID
-	user.MessagesToDelete = append(user.MessagesToDelete, &messageID)
-	h.apiService.GetUserRepository().SetUser(chatID, user)
+	if !h.transition(chatID, session, models.StateWaitingForDateSelection) {
+		return
+	}
+	session.Conversation.Booking.ProfessionalID = professionalID
+	session.TrackMessage(messageID)
+	h.apiService.GetUserRepository().SetSession(chatID, session)
 	// Show current month dates
-	h.showDateSelection(ctx, user, time.Now())
+	h.showDateSelection(ctx, chatID, professionalID, time.Now())
 }
 
-// showDateSelection shows available dates for the current month
-func (h *ClientHandler) showDateSelection(ctx context.Context, user *models.User, currentDate time.Time) {
+// showDateSelection shows the dates of a month with the professional's free slots
+func (h *ClientHandler) showDateSelection(ctx context.Context, chatID int64, professionalID string, currentDate time.Time) {
 	text := fmt.Sprintf(handlersCommon.UIMsgSelectDate, currentDate.Month(), currentDate.Year())
-	keyboard := h.createDateKeyboard(currentDate)
-	_, err := h.bot.SendMessageWithKeyboardAndID(*user.ChatID, text, keyboard)
+	keyboard := h.createDateKeyboard(chatID, currentDate, h.loadFreeSlots(ctx, professionalID, currentDate))
+	_, err := h.bot.SendMessageWithKeyboardAndID(chatID, text, keyboard)
 	if err != nil {
-		h.sendError(ctx, *user.ChatID, handlersCommon.ErrorMsgFailedToSendMessage, err)
-		return
+		h.sendError(ctx, chatID, handlersCommon.ErrorMsgFailedToSendMessage, err)
 	}
+}
 
-	h.apiService.GetUserRepository().SetUser(*user.ChatID, user)
+// loadFreeSlots counts the free slots of the professional on every bookable day of the month.
+// Days whose availability can't be loaded are left out, so they are shown as plain days.
+func (h *ClientHandler) loadFreeSlots(ctx context.Context, professionalID string, month time.Time) map[string]int {
+	dates := keyboards.BookableDates(month)
+	if len(dates) == 0 {
+		return nil
+	}
+	availability, err := h.apiService.GetProfessionalAvailabilityForDates(ctx, professionalID, dates)
+	if err != nil {
+		logger := common.GetLogger(ctx)
+		logger.Warn().Err(err).Str("professional_id", professionalID).
+			Int("failed_days", len(dates)-len(availability)).
+			Msg("Failed to load the availability of some days, showing them as plain days")
+	}
+
+	now := time.Now()
+	freeSlots := make(map[string]int, len(availability))
+	for date, day := range availability {
+		freeSlots[date] = countFreeSlots(day, now)
+	}
+	return freeSlots
 }
 
 // HandleDateSelection handles when user selects a date
 func (h *ClientHandler) HandleDateSelection(ctx context.Context, chatID int64, date string, messageID int) {
-	user, ok := handlersCommon.GetUserOrSendError(h.apiService.GetUserRepository(), h.bot, h.logger, chatID)
+	session, ok := handlersCommon.GetSessionOrSendError(h.apiService.GetUserRepository(), h.bot, h.logger, chatID)
 	if !ok {
 		return
 	}
 
-	user.State = models.StateWaitingForTimeSelection
-	user.SelectedDate = date
-	user.LastMessageID = &messageID
-	user.MessagesToDelete = append(user.MessagesToDelete, &messageID)
-	h.apiService.GetUserRepository().SetUser(chatID, user)
-
-	// Get availability for selected date
-	professionalID := user.SelectedProfessionalID
+	// Get availability for selected date; on failure the date may be picked again
+	professionalID := session.Conversation.Booking.ProfessionalID
 	availability, err := h.apiService.GetProfessionalAvailability(ctx, professionalID, date)
 	if err != nil {
 		h.sendError(ctx, chatID, handlersCommon.ErrorMsgFailedToLoadAvailability, err)
 		return
 	}
 
+	if !h.transition(chatID, session, models.StateWaitingForTimeSelection) {
+		return
+	}
+	session.Conversation.Booking.Date = date
+	session.TrackMessage(messageID)
+	h.apiService.GetUserRepository().SetSession(chatID, session)
+
 	h.showTimeSelection(ctx, chatID, availability)
 }
 
 // HandleUpcomingAppointmentsMonthNavigation handles month navigation for upcoming appointments
 func (h *ClientHandler) HandleBookAppointmentsMonthNavigation(ctx context.Context, chatID int64, monthStr string, direction string, messageID int) {
-	user, ok := handlersCommon.GetUserOrSendError(h.apiService.GetUserRepository(), h.bot, h.logger, chatID)
+	session, ok := handlersCommon.GetSessionOrSendError(h.apiService.GetUserRepository(), h.bot, h.logger, chatID)
 	if !ok {
 		return
 	}
@@ -137,33 +186,26 @@ func (h *ClientHandler) HandleBookAppointmentsMonthNavigation(ctx context.Contex
 		h.sendError(ctx, chatID, handlersCommon.ErrorMsgInvalidDateFormat, err)
 		return
 	}
-	h.showDateSelection(ctx, user, newMonthTime)
+	h.showDateSelection(ctx, chatID, session.Conversation.Booking.ProfessionalID, newMonthTime)
 }
 
 // showTimeSelection shows available time slots
 func (h *ClientHandler) showTimeSelection(ctx context.Context, chatID int64, availability *schemas.ProfessionalAvailabilityResponse) {
 	text := fmt.Sprintf(handlersCommon.UIMsgSelectTime, availability.Date)
-	keyboard := h.createTimeKeyboard(availability)
+	keyboard := h.createTimeKeyboard(chatID, availability)
 	_, err := h.sendMessageWithKeyboardAndID(chatID, text, keyboard)
 	if err != nil {
 		h.sendError(ctx, chatID, handlersCommon.ErrorMsgFailedToSendMessage, err)
-		return
 	}
-
-	user, ok := handlersCommon.GetUserOrSendError(h.apiService.GetUserRepository(), h.bot, h.logger, chatID)
-	if !ok {
-		return
-	}
-	user.State = models.StateWaitingForTimeSelection
-	h.apiService.GetUserRepository().SetUser(chatID, user)
 }
 
 // HandleTimeSelection handles when user selects a time slot
 func (h *ClientHandler) HandleTimeSelection(ctx context.Context, chatID int64, startTime string, messageID int) {
-	user, ok := handlersCommon.GetUserOrSendError(h.apiService.GetUserRepository(), h.bot, h.logger, chatID)
+	session, ok := handlersCommon.GetSessionOrSendError(h.apiService.GetUserRepository(), h.bot, h.logger, chatID)
 	if !ok {
 		return
 	}
+	booking := session.Conversation.Booking
 
 	// Parse start time and calculate end time (1 hour later)
 	h.logger.Debug().Str("startTime", startTime).Msg("Parsing start time")
@@ -175,7 +217,7 @@ func (h *ClientHandler) HandleTimeSelection(ctx context.Context, chatID int64, s
 	}
 
 	end := start.Add(time.Hour)
-	date := user.SelectedDate
+	date := booking.Date
 
 	// Create proper RFC3339 format datetime strings
 	// Parse the date and combine with time
@@ -199,23 +241,33 @@ func (h *ClientHandler) HandleTimeSelection(ctx context.Context, chatID int64, s
 
 	// Create appointment with RFC3339 format
 	req := &apiService.CreateAppointmentRequest{
-		ClientID:       user.ID,
-		ProfessionalID: user.SelectedProfessionalID,
+		ClientID:       session.Profile.ID,
+		ProfessionalID: booking.ProfessionalID,
 		StartTime:      startDateTime.Format(time.RFC3339),
 		EndTime:        endDateTime.Format(time.RFC3339),
 	}
 
 	appointment, err := h.apiService.CreateAppointment(ctx, req)
+	if errors.Is(err, apiService.ErrConflict) {
+		// Someone else booked the slot meanwhile; offer the slots that are still free
+		h.sendMessage(chatID, handlersCommon.ErrorMsgSlotTaken)
+		availability, err := h.apiService.GetProfessionalAvailability(ctx, booking.ProfessionalID, date)
+		if err != nil {
+			h.sendError(ctx, chatID, handlersCommon.ErrorMsgFailedToLoadAvailability, err)
+			return
+		}
+		h.showTimeSelection(ctx, chatID, availability)
+		return
+	}
 	if err != nil {
 		h.sendError(ctx, chatID, handlersCommon.ErrorMsgFailedToCreateAppointment, err)
 		return
 	}
 
 	// Clear state and show success
-	h.clearBookingState(user)
-	user.LastMessageID = &messageID
-	user.MessagesToDelete = append(user.MessagesToDelete, &messageID)
-	h.apiService.GetUserRepository().SetUser(chatID, user)
+	session.Conversation.Clear()
+	session.TrackMessage(messageID)
+	h.apiService.GetUserRepository().SetSession(chatID, session)
 
 	text := fmt.Sprintf(handlersCommon.SuccessMsgAppointmentBooked,
 		date, startTime, end.Format("15:04"),
@@ -230,27 +282,20 @@ func (h *ClientHandler) HandleTimeSelection(ctx context.Context, chatID int64, s
 
 // HandleCancelBooking cancels the current booking process and returns to dashboard
 func (h *ClientHandler) HandleCancelBooking(ctx context.Context, chatID int64, messageID int) {
-	// Validate user state - only allow if in booking process
-	user, valid := h.validateUserState(ctx, chatID, []string{
-		models.StateWaitingForProfessionalSelection,
-		models.StateWaitingForDateSelection,
-		models.StateWaitingForTimeSelection,
-		models.StateBookingAppointment,
-	})
-	if !valid {
+	session, ok := handlersCommon.GetSessionOrSendError(h.apiService.GetUserRepository(), h.bot, h.logger, chatID)
+	if !ok {
 		return
 	}
 
-	// Clear all booking-related state
-	h.clearBookingState(user)
-	h.apiService.GetUserRepository().SetUser(chatID, user)
+	// Drop the booking flow and its data
+	session.Conversation.Clear()
+	h.apiService.GetUserRepository().SetSession(chatID, session)
 	id, err := h.bot.SendMessageWithID(chatID, handlersCommon.ErrorMsgBookingCancelled)
 	if err != nil {
 		h.sendError(ctx, chatID, handlersCommon.ErrorMsgFailedToSendMessage, err)
 		return
 	}
-	user.LastMessageID = &id
-	user.MessagesToDelete = append(user.MessagesToDelete, &id, &messageID)
-	h.apiService.GetUserRepository().SetUser(chatID, user)
+	session.TrackMessage(messageID, id)
+	h.apiService.GetUserRepository().SetSession(chatID, session)
 	h.ShowDashboard(ctx, chatID, messageID)
 }
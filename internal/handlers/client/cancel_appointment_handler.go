@@ -2,6 +2,7 @@ package client
 
 import (
 	"context"
+	"errors"
 
 	"booking_client/internal/handlers/common"
 	"booking_client/internal/models"
@@ -12,50 +13,55 @@ import (
 // HandleCancelAppointment starts the appointment cancellation process
 func (h *ClientHandler) HandleCancelAppointment(ctx context.Context, chatID int64, appointmentID string, messageID int) {
 	// Store appointment ID and ask for cancellation reason
-	user, ok := common.GetUserOrSendError(h.apiService.GetUserRepository(), h.bot, h.logger, chatID)
+	session, ok := common.GetSessionOrSendError(h.apiService.GetUserRepository(), h.bot, h.logger, chatID)
 	if !ok {
 		return
 	}
-	user.State = models.StateWaitingForCancellationReason
-	user.SelectedAppointmentID = appointmentID
-	user.LastMessageID = &messageID
-	user.MessagesToDelete = append(user.MessagesToDelete, &messageID)
+	if !h.startFlow(chatID, session, models.FlowCancellation) {
+		return
+	}
+	session.Conversation.Cancellation.AppointmentID = appointmentID
+	session.TrackMessage(messageID)
 
 	id, err := h.bot.SendMessageWithID(chatID, common.UIMsgCancellationReason)
 	if err != nil {
 		h.sendError(ctx, chatID, common.ErrorMsgFailedToSendMessage, err)
 		return
 	}
-	user.LastMessageID = &id
-	user.MessagesToDelete = append(user.MessagesToDelete, &id)
-	h.apiService.GetUserRepository().SetUser(chatID, user)
+	session.TrackMessage(id)
+	h.apiService.GetUserRepository().SetSession(chatID, session)
 }
 
 // HandleCancellationReason handles the cancellation reason input
 func (h *ClientHandler) HandleCancellationReason(ctx context.Context, chatID int64, reason string, messageID int) {
-	user, ok := common.GetUserOrSendError(h.apiService.GetUserRepository(), h.bot, h.logger, chatID)
+	session, ok := common.GetSessionOrSendError(h.apiService.GetUserRepository(), h.bot, h.logger, chatID)
 	if !ok {
 		return
 	}
-	appointmentID := user.SelectedAppointmentID
+	appointmentID := session.Conversation.Cancellation.AppointmentID
 
 	// Cancel the appointment
 	req := &apiService.CancelAppointmentRequest{
 		CancellationReason: reason,
 	}
 
-	response, err := h.apiService.CancelClientAppointment(ctx, user.ID, appointmentID, req)
+	response, err := h.apiService.CancelClientAppointment(ctx, session.Profile.ID, appointmentID, req)
+	if errors.Is(err, apiService.ErrNotFound) || errors.Is(err, apiService.ErrConflict) {
+		session.Conversation.Clear()
+		h.apiService.GetUserRepository().SetSession(chatID, session)
+		h.sendMessage(chatID, common.ErrorMsgAppointmentNoLongerActive)
+		h.ShowDashboard(ctx, chatID, 0)
+		return
+	}
 	if err != nil {
 		h.sendError(ctx, chatID, common.ErrorMsgFailedToCancelAppointment, err)
 		return
 	}
 
 	// Clear state
-	user.State = models.StateNone
-	user.SelectedAppointmentID = ""
-	user.LastMessageID = &messageID
-	user.MessagesToDelete = append(user.MessagesToDelete, &messageID)
-	h.apiService.GetUserRepository().SetUser(chatID, user)
+	session.Conversation.Clear()
+	session.TrackMessage(messageID)
+	h.apiService.GetUserRepository().SetSession(chatID, session)
 
 	date, startTime, endTime := common.FormatAppointmentTime(response.Appointment.StartTime, response.Appointment.EndTime)
 	text := fmt.Sprintf(common.SuccessMsgAppointmentCancelled,
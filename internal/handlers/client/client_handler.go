@@ -6,8 +6,8 @@ import (
 	"time"
 
 	"booking_client/internal/handlers/common"
+	"booking_client/internal/handlers/fsm"
 	"booking_client/internal/handlers/keyboards"
-	"booking_client/internal/models"
 	apiService "booking_client/internal/services/api_service"
 	"booking_client/pkg/telegram"
 
@@ -16,37 +16,39 @@ import (
 
 // ClientHandler handles all client-related operations
 type ClientHandler struct {
-	bot                 *telegram.Bot
+	bot                 telegram.Messenger
 	logger              *zerolog.Logger
 	apiService          *apiService.APIService
 	notificationService *common.NotificationService
 	keyboards           *keyboards.ClientKeyboards
+	machine             *fsm.Machine
 }
 
 // NewClientHandler creates a new client handler
-func NewClientHandler(bot *telegram.Bot, logger *zerolog.Logger, apiService *apiService.APIService) *ClientHandler {
+func NewClientHandler(bot telegram.Messenger, logger *zerolog.Logger, apiService *apiService.APIService, machine *fsm.Machine, codec *common.CallbackCodec) *ClientHandler {
 	return &ClientHandler{
 		bot:                 bot,
 		logger:              logger,
 		apiService:          apiService,
-		notificationService: common.NewNotificationService(bot, logger, apiService),
-		keyboards:           keyboards.NewClientKeyboards(logger),
+		notificationService: common.NewNotificationService(bot, logger, apiService, codec),
+		keyboards:           keyboards.NewClientKeyboards(logger, codec),
+		machine:             machine,
 	}
 }
 
 // ShowDashboard shows the client dashboard with appointment options
 func (h *ClientHandler) ShowDashboard(ctx context.Context, chatID int64, messageID int) {
-	user, ok := common.GetUserOrSendError(h.apiService.GetUserRepository(), h.bot, h.logger, chatID)
+	session, ok := common.GetSessionOrSendError(h.apiService.GetUserRepository(), h.bot, h.logger, chatID)
 	if !ok {
 		return
 	}
-	user.LastMessageID = &messageID
-	user.MessagesToDelete = append(user.MessagesToDelete, &messageID)
-	user.State = models.StateNone
-	messageIDs := append([]*int{}, user.MessagesToDelete...)
-	h.apiService.GetUserRepository().SetUser(chatID, user)
+	session.TrackMessage(messageID)
+	session.Conversation.Clear()
+	messageIDs := append([]*int{}, session.MessagesToDelete...)
+	session.MessagesToDelete = nil
+	h.apiService.GetUserRepository().SetSession(chatID, session)
 
-	text := fmt.Sprintf(common.UIMsgWelcomeBack, user.FirstName, user.Role)
+	text := fmt.Sprintf(common.UIMsgWelcomeBack, session.Profile.FirstName, session.Profile.Role)
 	keyboard := h.createDashboardKeyboard()
 	go func() {
 		time.Sleep(3 * time.Second)
@@ -61,7 +63,6 @@ func (h *ClientHandler) ShowDashboard(ctx context.Context, chatID int64, message
 		h.sendError(ctx, chatID, common.ErrorMsgFailedToSendMessage, err)
 		return
 	}
-	user.MessagesToDelete = append(user.MessagesToDelete, &id)
-	user.LastMessageID = &id
-	h.apiService.GetUserRepository().SetUser(chatID, user)
+	session.TrackMessage(id)
+	h.apiService.GetUserRepository().SetSession(chatID, session)
 }
@@ -2,10 +2,13 @@ package client
 
 import (
 	"booking_client/internal/common"
+	handlersCommon "booking_client/internal/handlers/common"
 	"booking_client/internal/models"
 	"booking_client/internal/schemas"
+	apiService "booking_client/internal/services/api_service"
 	"context"
 	"fmt"
+	"strings"
 	"time"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
@@ -13,9 +16,26 @@ import (
 
 // sendError sends an error message to the user
 func (h *ClientHandler) sendError(ctx context.Context, chatID int64, message string, err error) {
-	text := fmt.Sprintf(message, err.Error())
+	logger := common.GetLogger(ctx)
+	if err != nil {
+		logger.Error().Err(err).Int64("chat_id", chatID).Msg("Request failed")
+	}
+
+	// The raw error goes to the logs only; users see a description of its kind
+	text := message
+	if strings.Contains(message, "%s") {
+		text = fmt.Sprintf(message, apiService.FormatErrorForUser(err))
+	}
+
+	// Outages get a button to try again: the pressed button once more, or /start after typed input
+	retryData, _ := common.GetRetryCallback(ctx)
+	if keyboard, ok := handlersCommon.RetryKeyboard(err, retryData); ok {
+		if err := h.bot.SendMessageWithKeyboard(chatID, text, keyboard); err != nil {
+			logger.Error().Err(err).Msg("Failed to send error message")
+		}
+		return
+	}
 	if err := h.bot.SendMessage(chatID, text); err != nil {
-		logger := common.GetLogger(ctx)
 		logger.Error().Err(err).Msg("Failed to send error message")
 	}
 }
@@ -58,49 +78,41 @@ func (h *ClientHandler) editMessageWithKeyboard(chatID int64, messageID int, tex
 	}
 }
 
-// validateUserState checks if user is in a valid state for the given action
-func (h *ClientHandler) validateUserState(ctx context.Context, chatID int64, allowedStates []string) (*models.User, bool) {
-	user, ok := common.GetUserOrSendError(h.apiService.GetUserRepository(), h.bot, common.GetLogger(ctx), chatID)
-	if !ok {
-		return nil, false
+// startFlow starts a conversation flow, telling the user if that fails
+func (h *ClientHandler) startFlow(chatID int64, session *models.Session, flow string) bool {
+	if err := h.machine.Start(session, flow); err != nil {
+		h.logger.Error().Err(err).Int64("chat_id", chatID).Msg("Failed to start conversation flow")
+		h.sendMessage(chatID, common.ErrorMsgInvalidState)
+		return false
 	}
-
-	// Check if user is in an allowed state
-	for _, allowedState := range allowedStates {
-		if user.State == allowedState {
-			return user, true
-		}
-	}
-
-	// User is not in an allowed state
-	h.sendMessage(chatID, common.ErrorMsgInvalidState)
-	return nil, false
+	return true
 }
 
-// clearBookingState clears all booking-related state from user
-func (h *ClientHandler) clearBookingState(user *models.User) {
-	user.State = models.StateNone
-	user.SelectedProfessionalID = ""
-	user.SelectedDate = ""
-	user.SelectedTime = ""
-	user.SelectedAppointmentID = ""
+// transition moves the session to the next state of its flow, telling the user if that is not allowed
+func (h *ClientHandler) transition(chatID int64, session *models.Session, state string) bool {
+	if err := h.machine.Transition(session, state); err != nil {
+		h.logger.Error().Err(err).Int64("chat_id", chatID).Msg("Invalid conversation transition")
+		h.sendMessage(chatID, common.ErrorMsgInvalidState)
+		return false
+	}
+	return true
 }
 
 // Keyboard wrapper methods for backward compatibility
-func (h *ClientHandler) createDateKeyboard(currentDate time.Time) tgbotapi.InlineKeyboardMarkup {
-	return h.keyboards.CreateDateKeyboard(currentDate)
+func (h *ClientHandler) createDateKeyboard(chatID int64, currentDate time.Time, freeSlots map[string]int) tgbotapi.InlineKeyboardMarkup {
+	return h.keyboards.CreateDateKeyboard(chatID, currentDate, freeSlots)
 }
 
-func (h *ClientHandler) createTimeKeyboard(availability *schemas.ProfessionalAvailabilityResponse) tgbotapi.InlineKeyboardMarkup {
-	return h.keyboards.CreateTimeKeyboard(availability)
+func (h *ClientHandler) createTimeKeyboard(chatID int64, availability *schemas.ProfessionalAvailabilityResponse) tgbotapi.InlineKeyboardMarkup {
+	return h.keyboards.CreateTimeKeyboard(chatID, availability)
 }
 
-func (h *ClientHandler) createProfessionalsKeyboard(professionals []models.User) tgbotapi.InlineKeyboardMarkup {
-	return h.keyboards.CreateProfessionalsKeyboard(professionals)
+func (h *ClientHandler) createProfessionalsKeyboard(chatID int64, professionals []models.User) tgbotapi.InlineKeyboardMarkup {
+	return h.keyboards.CreateProfessionalsKeyboard(chatID, professionals)
 }
 
-func (h *ClientHandler) createAppointmentsKeyboard(appointments []schemas.ClientAppointment, buttonPrefix string) tgbotapi.InlineKeyboardMarkup {
-	return h.keyboards.CreateAppointmentsKeyboard(appointments, buttonPrefix)
+func (h *ClientHandler) createAppointmentsKeyboard(chatID int64, appointments []schemas.ClientAppointment, buttonPrefix string) tgbotapi.InlineKeyboardMarkup {
+	return h.keyboards.CreateAppointmentsKeyboard(chatID, appointments, buttonPrefix)
 }
 
 func (h *ClientHandler) createDashboardKeyboard() tgbotapi.InlineKeyboardMarkup {
@@ -110,3 +122,29 @@ func (h *ClientHandler) createDashboardKeyboard() tgbotapi.InlineKeyboardMarkup
 func (h *ClientHandler) createRegistrationSuccessKeyboard() tgbotapi.InlineKeyboardMarkup {
 	return h.keyboards.CreateRegistrationSuccessKeyboard()
 }
+
+// hasProfessional reports whether professionals contains the professional with the ID
+func hasProfessional(professionals []models.User, professionalID string) bool {
+	for _, professional := range professionals {
+		if professional.ID == professionalID {
+			return true
+		}
+	}
+	return false
+}
+
+// countFreeSlots counts the available slots of a day that have not started yet
+func countFreeSlots(availability *schemas.ProfessionalAvailabilityResponse, now time.Time) int {
+	free := 0
+	for _, slot := range availability.Slots {
+		if !slot.Available {
+			continue
+		}
+		start, err := time.Parse(time.RFC3339, slot.StartTime)
+		if err != nil || !start.After(now) {
+			continue
+		}
+		free++
+	}
+	return free
+}
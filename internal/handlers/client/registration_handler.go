@@ -2,6 +2,8 @@ package client
 
 import (
 	"context"
+	"errors"
+	"time"
 
 	"booking_client/internal/handlers/common"
 	"booking_client/internal/models"
@@ -10,11 +12,13 @@ import (
 
 // StartRegistration starts the client registration process
 func (h *ClientHandler) StartRegistration(ctx context.Context, chatID int64, messageID int) {
-	// Create a temporary user with state
-	tempUser := &models.User{
-		ChatID: &chatID,
-		Role:   "client",
-		State:  models.StateWaitingForFirstName,
+	// Create a fresh session in the registration flow, keeping the deep link the chat started from
+	session := models.NewSession(chatID)
+	if previous, exists := h.apiService.GetUserRepository().GetSession(chatID); exists && previous != nil && !previous.IsRegistered() {
+		session.Referral = previous.Referral
+	}
+	if !h.startFlow(chatID, session, models.FlowRegistration) {
+		return
 	}
 
 	id, err := h.bot.SendMessageWithID(chatID, common.UIMsgClientRegistration)
@@ -23,57 +27,59 @@ func (h *ClientHandler) StartRegistration(ctx context.Context, chatID int64, mes
 		return
 	}
 
-	tempUser.LastMessageID = &id
-	tempUser.MessagesToDelete = append(tempUser.MessagesToDelete, &id, &messageID)
-	h.apiService.GetUserRepository().SetUser(chatID, tempUser)
+	session.TrackMessage(messageID, id)
+	h.apiService.GetUserRepository().SetSession(chatID, session)
 }
 
 // HandleFirstNameInput handles first name input for client registration
 func (h *ClientHandler) HandleFirstNameInput(ctx context.Context, chatID int64, firstName string, messageID int) {
-	user, ok := common.GetUserOrSendError(h.apiService.GetUserRepository(), h.bot, h.logger, chatID)
+	session, ok := common.GetSessionOrSendError(h.apiService.GetUserRepository(), h.bot, h.logger, chatID)
 	if !ok {
 		return
 	}
-	user.FirstName = firstName
-	user.State = models.StateWaitingForLastName
-	h.apiService.GetUserRepository().SetUser(chatID, user)
+	session.Conversation.Registration.FirstName = firstName
+	if !h.transition(chatID, session, models.StateWaitingForLastName) {
+		return
+	}
+	h.apiService.GetUserRepository().SetSession(chatID, session)
 
 	id, err := h.bot.SendMessageWithID(chatID, common.SuccessMsgFirstNameSaved)
 	if err != nil {
 		h.sendError(ctx, chatID, common.ErrorMsgFailedToSendMessage, err)
 		return
 	}
-	user.LastMessageID = &id
-	user.MessagesToDelete = append(user.MessagesToDelete, &id, &messageID)
-	h.apiService.GetUserRepository().SetUser(chatID, user)
+	session.TrackMessage(messageID, id)
+	h.apiService.GetUserRepository().SetSession(chatID, session)
 }
 
 // HandleLastNameInput handles last name input for client registration
 func (h *ClientHandler) HandleLastNameInput(ctx context.Context, chatID int64, lastName string, messageID int) {
-	user, ok := common.GetUserOrSendError(h.apiService.GetUserRepository(), h.bot, h.logger, chatID)
+	session, ok := common.GetSessionOrSendError(h.apiService.GetUserRepository(), h.bot, h.logger, chatID)
 	if !ok {
 		return
 	}
-	user.LastName = lastName
-	user.State = models.StateWaitingForPhone
-	h.apiService.GetUserRepository().SetUser(chatID, user)
+	session.Conversation.Registration.LastName = lastName
+	if !h.transition(chatID, session, models.StateWaitingForPhone) {
+		return
+	}
+	h.apiService.GetUserRepository().SetSession(chatID, session)
 
 	id, err := h.bot.SendMessageWithID(chatID, common.SuccessMsgLastNameSaved)
 	if err != nil {
 		h.sendError(ctx, chatID, common.ErrorMsgFailedToSendMessage, err)
 		return
 	}
-	user.LastMessageID = &id
-	user.MessagesToDelete = append(user.MessagesToDelete, &id, &messageID)
-	h.apiService.GetUserRepository().SetUser(chatID, user)
+	session.TrackMessage(messageID, id)
+	h.apiService.GetUserRepository().SetSession(chatID, session)
 }
 
 // HandlePhoneInput handles phone number input for client registration
 func (h *ClientHandler) HandlePhoneInput(ctx context.Context, chatID int64, phone string, messageID int) {
-	user, ok := common.GetUserOrSendError(h.apiService.GetUserRepository(), h.bot, h.logger, chatID)
+	session, ok := common.GetSessionOrSendError(h.apiService.GetUserRepository(), h.bot, h.logger, chatID)
 	if !ok {
 		return
 	}
+	registration := session.Conversation.Registration
 
 	var phoneNumber *string
 	if phone != "skip" && phone != "" {
@@ -82,30 +88,42 @@ func (h *ClientHandler) HandlePhoneInput(ctx context.Context, chatID int64, phon
 
 	// Register the client
 	req := &apiService.RegisterRequest{
-		FirstName:   user.FirstName,
-		LastName:    user.LastName,
+		FirstName:   registration.FirstName,
+		LastName:    registration.LastName,
 		ChatID:      chatID,
 		PhoneNumber: phoneNumber,
 		Role:        "client",
 	}
+	referral := session.Referral
+	if referral != nil && referral.Campaign != "" {
+		req.Campaign = &referral.Campaign
+	}
 
 	response, err := h.apiService.RegisterClient(ctx, req)
+	if errors.Is(err, apiService.ErrConflict) {
+		h.apiService.GetUserRepository().DeleteSession(chatID)
+		h.sendMessage(chatID, common.ErrorMsgAlreadyRegistered)
+		return
+	}
 	if err != nil {
-		h.apiService.GetUserRepository().DeleteUser(chatID)
+		h.apiService.GetUserRepository().DeleteSession(chatID)
 		h.sendError(ctx, chatID, common.ErrorMsgRegistrationFailed, err)
 		return
 	}
-	user.ID = response.ID
-	user.FirstName = response.FirstName
-	user.LastName = response.LastName
-	user.Role = response.Role
-	user.PhoneNumber = response.PhoneNumber
-	user.ChatID = response.ChatID
-	user.CreatedAt = response.CreatedAt
-	user.UpdatedAt = response.UpdatedAt
+	session.SetProfile(models.User{
+		ID:          response.ID,
+		ChatID:      response.ChatID,
+		FirstName:   response.FirstName,
+		LastName:    response.LastName,
+		Role:        response.Role,
+		PhoneNumber: response.PhoneNumber,
+		CreatedAt:   response.CreatedAt,
+		UpdatedAt:   response.UpdatedAt,
+	}, time.Now())
 	// Clear state
-	user.State = models.StateNone
-	h.apiService.GetUserRepository().SetUser(chatID, user)
+	session.Conversation.Clear()
+	session.Referral = nil
+	h.apiService.GetUserRepository().SetSession(chatID, session)
 
 	// Build success message
 	text := common.NewSuccessMessage("registration_success").
@@ -121,7 +139,11 @@ func (h *ClientHandler) HandlePhoneInput(ctx context.Context, chatID int64, phon
 		h.sendError(ctx, chatID, common.ErrorMsgFailedToSendMessage, err)
 		return
 	}
-	user.LastMessageID = &id
-	user.MessagesToDelete = append(user.MessagesToDelete, &id, &messageID)
-	h.apiService.GetUserRepository().SetUser(chatID, user)
+	session.TrackMessage(messageID, id)
+	h.apiService.GetUserRepository().SetSession(chatID, session)
+
+	// Clients arriving from a professional's link go straight to booking with them
+	if referral != nil && referral.ProfessionalID != "" {
+		h.StartBookingWithProfessional(ctx, chatID, referral.ProfessionalID, 0)
+	}
 }
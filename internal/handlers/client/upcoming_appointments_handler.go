@@ -9,7 +9,7 @@ import (
 
 // HandleUpcomingAppointments shows upcoming appointments
 func (h *ClientHandler) HandleUpcomingAppointments(ctx context.Context, chatID int64, messageID int) {
-	user, ok := common.GetUserOrSendError(h.apiService.GetUserRepository(), h.bot, h.logger, chatID)
+	session, ok := common.GetSessionOrSendError(h.apiService.GetUserRepository(), h.bot, h.logger, chatID)
 	if !ok {
 		return
 	}
@@ -19,7 +19,7 @@ func (h *ClientHandler) HandleUpcomingAppointments(ctx context.Context, chatID i
 		h.bot.DeleteMessage(chatID, messageID)
 	}()
 
-	appointments, err := h.apiService.GetClientAppointments(ctx, user.ID, "confirmed")
+	appointments, err := h.apiService.GetClientAppointments(ctx, session.Profile.ID, "confirmed")
 	if err != nil {
 		h.sendError(ctx, chatID, common.ErrorMsgFailedToLoadUpcomingAppointments, err)
 		return
@@ -31,9 +31,8 @@ func (h *ClientHandler) HandleUpcomingAppointments(ctx context.Context, chatID i
 			h.sendError(ctx, chatID, common.ErrorMsgFailedToSendMessage, err)
 			return
 		}
-		user.LastMessageID = &id
-		user.MessagesToDelete = append(user.MessagesToDelete, &id)
-		h.apiService.GetUserRepository().SetUser(chatID, user)
+		session.TrackMessage(id)
+		h.apiService.GetUserRepository().SetSession(chatID, session)
 		h.ShowDashboard(ctx, chatID, 0)
 		return
 	}
@@ -43,7 +42,7 @@ func (h *ClientHandler) HandleUpcomingAppointments(ctx context.Context, chatID i
 		text += common.FormatAppointmentDetails(&apt, index)
 	}
 
-	keyboard := h.createAppointmentsKeyboard(appointments.Appointments, common.BtnCancelAppointment)
+	keyboard := h.createAppointmentsKeyboard(chatID, appointments.Appointments, common.BtnCancelAppointment)
 	err = h.bot.SendMessageWithKeyboard(chatID, text, keyboard)
 	if err != nil {
 		h.sendError(ctx, chatID, common.ErrorMsgFailedToSendMessage, err)
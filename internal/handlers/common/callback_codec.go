@@ -0,0 +1,192 @@
+package common
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// MaxCallbackDataLength is the limit Telegram puts on callback_data
+const MaxCallbackDataLength = 64
+
+// Signed callback data layout: "~" + action code + "|" + param + "|" + issued at + "|" + signature.
+// The signature also covers the chat the button was sent to, which is not part of the data.
+const (
+	signedCallbackMarker = "~"
+	signedCallbackSep    = "|"
+	compactUUIDMarker    = "!"
+	callbackSignatureLen = 8 // Bytes of the HMAC kept in the callback data
+	callbackKeyContext   = "booking_client/callback_data"
+)
+
+// signedCallbackPrefixes are the parameterised callbacks, signed by their index.
+// Buttons stay in chats after a deploy, so only ever append to this list.
+var signedCallbackPrefixes = []string{
+	CallbackPrefixPrevTimetableDay,
+	CallbackPrefixNextTimetableDay,
+	CallbackPrefixPrevUpcomingMonth,
+	CallbackPrefixNextUpcomingMonth,
+	CallbackPrefixSelectUpcomingDate,
+	CallbackPrefixPrevMonth,
+	CallbackPrefixNextMonth,
+	CallbackPrefixSelectProfessional,
+	CallbackPrefixSelectDate,
+	CallbackPrefixSelectTime,
+	CallbackPrefixCancelAppointment,
+	CallbackPrefixConfirmAppointment,
+	CallbackPrefixCancelProfAppt,
+	CallbackPrefixSelectUnavailableDate,
+	CallbackPrefixSelectUnavailableStart,
+	CallbackPrefixSelectUnavailableEnd,
+	CallbackPrefixPrevUnavailableMonth,
+	CallbackPrefixNextUnavailableMonth,
+	CallbackPrefixSelectClient,
+	CallbackPrefixPrevPreviousMonth,
+	CallbackPrefixNextPreviousMonth,
+}
+
+// actionCodeAlphabet maps the index of a signed prefix to its one character action code
+const actionCodeAlphabet = "0123456789abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
+
+// Callback data verification errors
+var (
+	ErrCallbackMalformed = errors.New("callback data is malformed")
+	ErrCallbackTampered  = errors.New("callback data signature is invalid")
+	ErrCallbackExpired   = errors.New("callback data has expired")
+	ErrCallbackUnsigned  = errors.New("callback data is not signed")
+	ErrCallbackTooLong   = errors.New("callback data exceeds the Telegram limit")
+)
+
+// CallbackCodec packs a callback prefix and its parameter into compact callback data
+// signed with a server key for one chat, so handlers can trust the IDs they receive
+// and buttons can't be replayed from another chat
+type CallbackCodec struct {
+	key   []byte
+	ttl   time.Duration
+	codes map[string]byte
+	now   func() time.Time
+}
+
+// NewCallbackCodec creates a codec signing with a key derived from secret.
+// Signed data older than ttl is rejected; a zero ttl never expires.
+func NewCallbackCodec(secret string, ttl time.Duration) (*CallbackCodec, error) {
+	if secret == "" {
+		return nil, fmt.Errorf("callback signing secret is required")
+	}
+	if len(signedCallbackPrefixes) > len(actionCodeAlphabet) {
+		return nil, fmt.Errorf("too many signed callback prefixes: %d", len(signedCallbackPrefixes))
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(callbackKeyContext))
+
+	codes := make(map[string]byte, len(signedCallbackPrefixes))
+	for i, prefix := range signedCallbackPrefixes {
+		codes[prefix] = actionCodeAlphabet[i]
+	}
+
+	return &CallbackCodec{
+		key:   mac.Sum(nil),
+		ttl:   ttl,
+		codes: codes,
+		now:   time.Now,
+	}, nil
+}
+
+// Encode signs a parameterised callback for a button sent to chatID.
+// UUID parameters are packed into 22 characters to stay well under the Telegram limit.
+func (c *CallbackCodec) Encode(chatID int64, prefix, param string) (string, error) {
+	code, exists := c.codes[prefix]
+	if !exists {
+		return "", fmt.Errorf("callback prefix %q cannot be signed", prefix)
+	}
+	if strings.Contains(param, signedCallbackSep) {
+		return "", fmt.Errorf("callback parameter %q contains %q", param, signedCallbackSep)
+	}
+
+	payload := string(code) + signedCallbackSep + packParam(param) + signedCallbackSep +
+		strconv.FormatInt(c.now().Unix(), 36)
+	data := signedCallbackMarker + payload + signedCallbackSep + c.sign(chatID, payload)
+	if len(data) > MaxCallbackDataLength {
+		return "", fmt.Errorf("%w: %q is %d bytes", ErrCallbackTooLong, prefix+param, len(data))
+	}
+	return data, nil
+}
+
+// IsSigned reports whether the callback data was produced by Encode
+func (c *CallbackCodec) IsSigned(data string) bool {
+	return strings.HasPrefix(data, signedCallbackMarker)
+}
+
+// Decode verifies signed callback data pressed in chatID and returns the plain prefix and parameter
+func (c *CallbackCodec) Decode(chatID int64, data string) (string, error) {
+	if !c.IsSigned(data) {
+		return "", ErrCallbackUnsigned
+	}
+
+	fields := strings.Split(strings.TrimPrefix(data, signedCallbackMarker), signedCallbackSep)
+	if len(fields) != 4 || len(fields[0]) != 1 {
+		return "", ErrCallbackMalformed
+	}
+
+	payload := strings.Join(fields[:3], signedCallbackSep)
+	if !hmac.Equal([]byte(fields[3]), []byte(c.sign(chatID, payload))) {
+		return "", ErrCallbackTampered
+	}
+
+	index := strings.IndexByte(actionCodeAlphabet, fields[0][0])
+	if index < 0 || index >= len(signedCallbackPrefixes) {
+		return "", ErrCallbackMalformed
+	}
+	issuedAt, err := strconv.ParseInt(fields[2], 36, 64)
+	if err != nil {
+		return "", ErrCallbackMalformed
+	}
+	if c.ttl > 0 && c.now().Sub(time.Unix(issuedAt, 0)) > c.ttl {
+		return "", ErrCallbackExpired
+	}
+	param, err := unpackParam(fields[1])
+	if err != nil {
+		return "", ErrCallbackMalformed
+	}
+
+	return signedCallbackPrefixes[index] + param, nil
+}
+
+// sign returns the truncated signature of a payload for a chat
+func (c *CallbackCodec) sign(chatID int64, payload string) string {
+	mac := hmac.New(sha256.New, c.key)
+	mac.Write([]byte(strconv.FormatInt(chatID, 10) + signedCallbackSep + payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil)[:callbackSignatureLen])
+}
+
+// packParam shortens canonical UUIDs, leaving other parameters as they are
+func packParam(param string) string {
+	if id, err := uuid.Parse(param); err == nil && id.String() == param {
+		return compactUUIDMarker + base64.RawURLEncoding.EncodeToString(id[:])
+	}
+	return param
+}
+
+// unpackParam reverses packParam
+func unpackParam(packed string) (string, error) {
+	if !strings.HasPrefix(packed, compactUUIDMarker) {
+		return packed, nil
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(strings.TrimPrefix(packed, compactUUIDMarker))
+	if err != nil {
+		return "", err
+	}
+	id, err := uuid.FromBytes(raw)
+	if err != nil {
+		return "", err
+	}
+	return id.String(), nil
+}
@@ -9,14 +9,16 @@ const (
 	// ========================================
 
 	// Initial selection
-	CallbackClient       = "client"
-	CallbackProfessional = "professional"
+	CallbackClient             = "client"
+	CallbackProfessional       = "professional"
+	CallbackProfessionalSignUp = "professional_sign_up"
 
 	// Client callbacks
 	CallbackBookAppointment      = "book_appointment"
 	CallbackPendingAppointments  = "pending_appointments"
 	CallbackUpcomingAppointments = "upcoming_appointments"
 	CallbackCancelBooking        = "cancel_booking"
+	CallbackDateFullyBooked      = "date_fully_booked"
 
 	// Professional callbacks
 	CallbackProfessionalPendingAppointments  = "professional_pending_appointments"
@@ -39,6 +41,7 @@ const (
 
 	// Common
 	CallbackBackToDashboard = "back_to_dashboard"
+	CallbackRetryStart      = "retry_start"
 
 	// ========================================
 	// PREFIX CALLBACKS (with parameters)
@@ -15,7 +15,18 @@ const (
 	ErrorMsgFailedToCancelAppointment        = "❌ Failed to cancel appointment: %s"
 	ErrorMsgInvalidState                     = "❌ This action is not available in your current state. Please use /start to begin a new session."
 	ErrorMsgBookingCancelled                 = "❌ Booking cancelled. Returning to dashboard."
-	ErrorMsgFailedToSendMessage              = "❌ Failed to send message: %w"
+	ErrorMsgFailedToSendMessage              = "❌ Failed to send message: %s"
+	ErrorMsgBookingAbandoned                 = "⌛ Your booking was abandoned because of inactivity. Use /start to continue."
+	ErrorMsgConversationAbandoned            = "⌛ Your unfinished action was cancelled because of inactivity. Use /start to continue."
+	ErrorMsgInvalidCallback                  = "❌ This button is not valid. Please use /dashboard to continue."
+	ErrorMsgExpiredCallback                  = "⌛ This button has expired. Please use /dashboard to continue."
+	ErrorMsgFinishCurrentAction              = "✋ Please finish the current action first, or use /cancel to stop it."
+	ErrorMsgReferralProfessionalNotFound     = "❌ The professional from this link is not available. Please choose another one from your dashboard."
+	ErrorMsgSlotTaken                        = "❌ This time slot was just taken. Please choose another time."
+	ErrorMsgAppointmentNoLongerActive        = "ℹ️ This appointment was already cancelled or changed in the meantime."
+	ErrorMsgAlreadyRegistered                = "ℹ️ This chat is already registered. Use /start to open your dashboard."
+	ErrorMsgServiceUnavailable               = "⚠️ Sorry, %s."
+	ErrorMsgBookingServiceDown               = "⚠️ The booking service is temporarily unavailable. Please try again in a moment."
 )
 
 // Success messages
@@ -42,6 +53,9 @@ const (
 	UIMsgCancellationReason             = "Please provide a reason for cancelling this appointment:"
 	UIMsgNewAppointmentRequest          = "🔔 New Appointment Request!\n\n👤 Client: %s %s\n📅 Date: %s\n🕐 Time: %s - %s\n📝 Description: %s\n\nPlease confirm or cancel this appointment."
 	UIMsgAppointmentCancelled           = "🔔 Appointment Cancelled\n\n👤 Client: %s %s\n📅 Date: %s\n🕐 Time: %s - %s\n📝 Reason: %s"
+	UIMsgActionCancelled                = "❌ Action cancelled."
+	UIMsgNothingToCancel                = "ℹ️ There is nothing to cancel."
+	UIMsgHelp                           = "ℹ️ Available commands:\n\n"
 )
 
 // Button texts
@@ -58,11 +72,24 @@ const (
 	BtnNextMonth                = "Next ➡️"
 	BtnConfirmAppointment       = "✅ Confirm"
 	BtnCancelAppointmentConfirm = "❌ Cancel"
+	BtnRetry                    = "🔄 Try Again"
+	BtnDateWithFreeSlots        = "%d (%d)"
+	BtnDateFullyBooked          = "%d ✖"
 )
 
 // Professional-specific error messages
 const (
 	ErrorMsgSignInFailed                         = "❌ Sign in failed: %s"
+	ErrorMsgWrongCredentials                     = "❌ Sign in failed: wrong username or password."
+	ErrorMsgUsernameTaken                        = "❌ This username is already taken. Please choose another one:"
+	ErrorMsgInvalidInviteCode                    = "❌ Invalid invite code. Please ask the administrator for a new one."
+	ErrorMsgInvalidUsername                      = "❌ Usernames are 3-32 letters, digits, dots or underscores. Please try again:"
+	ErrorMsgPasswordTooShort                     = "❌ Passwords need at least 8 characters. Please try again:"
+	ErrorMsgPasswordMismatch                     = "❌ Passwords do not match. Please choose your password again:"
+	ErrorMsgSignInLocked                         = "🔒 Too many failed sign-in attempts. Please try again in %s."
+	ErrorMsgInviteCodeLocked                     = "🔒 Too many wrong invite codes. Please try again in %s."
+	ErrorMsgWrongPassword                        = "❌ Wrong password. Your password was not changed."
+	ErrorMsgFailedToChangePassword               = "❌ Failed to change password: %s"
 	ErrorMsgFailedToConfirmAppointment           = "❌ Failed to confirm appointment: %s"
 	ErrorMsgFailedToLoadAppointments             = "❌ Failed to load appointments: %s"
 	ErrorMsgFailedToCreateUnavailableAppointment = "❌ Failed to create unavailable appointment: %s"
@@ -72,6 +99,11 @@ const (
 // Professional-specific success messages
 const (
 	SuccessMsgUsernameSaved        = "✅ Username saved!\n\nPlease enter your password:"
+	SuccessMsgInviteCodeAccepted   = "✅ Invite code accepted!\n\nPlease enter your first name:"
+	SuccessMsgNewUsernameSaved     = "✅ Username saved!\n\nPlease choose a password (at least 8 characters):"
+	SuccessMsgPasswordVerified     = "✅ Password verified!\n\nPlease choose a new password (at least 8 characters):"
+	SuccessMsgPasswordChanged      = "✅ Your password has been changed."
+	SuccessMsgPhoneSaved           = "✅ Phone number saved!\n\nPlease choose a username (3-32 letters, digits, dots or underscores):"
 	SuccessMsgSignInSuccessful     = "✅ Sign in successful!\n\nWelcome back, %s %s!\nRole: %s\nUsername: %s\nChat ID: %d"
 	SuccessMsgAppointmentConfirmed = "✅ Appointment confirmed successfully!\n\n📅 Date: %s\n🕐 Time: %s - %s\n👤 Client: %s %s"
 	SuccessMsgUnavailablePeriodSet = "✅ Unavailable period set successfully!\n\n📅 Date: %s\n🕐 Time: %s - %s\n📝 Description: %s"
@@ -80,6 +112,12 @@ const (
 // Professional-specific UI messages
 const (
 	UIMsgProfessionalSignIn                 = "👨‍💼 Professional Sign In\n\nPlease enter your username:"
+	UIMsgProfessionalSignUp                 = "🆕 Professional Registration\n\nPlease enter your first name:"
+	UIMsgProfessionalSignUpInvite           = "🆕 Professional Registration\n\nPlease enter your invite code:"
+	UIMsgChangePassword                     = "🔑 Change Password\n\nPlease enter your current password:"
+	UIMsgSignedOut                          = "👋 You have been signed out. Use /start to sign in again, with this or another account."
+	UIMsgSessionRevoked                     = "🔒 Your session was ended by an administrator. Use /start to sign in again."
+	UIMsgConfirmPassword                    = "🔁 Please enter the password again to confirm it:"
 	UIMsgWelcomeBackProfessional            = "👋 Welcome back, %s!\n\nYou are registered as a %s.\n\nWhat would you like to do?"
 	UIMsgSelectUnavailableDate              = "📅 Select a date for unavailable time (%s %d):"
 	UIMsgSelectUnavailableStartTime         = "🕐 Select start time for unavailable period on %s:"
@@ -97,6 +135,7 @@ const (
 
 // Professional-specific button texts
 const (
+	BtnProfessionalSignUp       = "🆕 Create a Professional Account"
 	BtnPendingAppointments      = "⏳ Pending Appointments"
 	BtnUpcomingAppointments     = "📋 Upcoming Appointments"
 	BtnSetUnavailable           = "🚫 Set Unavailable"
@@ -124,8 +163,29 @@ const (
 	TimeSlotsPerRow = 3
 )
 
+// Admin messages
+const (
+	UIMsgRevokeUsage             = "ℹ️ Usage: /revoke <chat id>"
+	SuccessMsgSessionRevoked     = "✅ Professional session of chat %d revoked."
+	ErrorMsgNotAProfessionalChat = "❌ Chat %d is not signed in as a professional."
+	ErrorMsgRevokeLookupFailed   = "❌ Could not look up chat %d, nothing was revoked: %s"
+	WarningMsgRevokeNotSynced    = "⚠️ The booking API could not be told (%s), so /start may find the chat signed in until it is."
+)
+
 // Additional error messages
 const (
 	ErrorMsgFailedToRetrieveClients      = "❌ Failed to retrieve clients: %s"
 	ErrorMsgFailedToRetrieveAppointments = "❌ Failed to retrieve appointments: %s"
 )
+
+// Callback alert messages
+const (
+	AlertMsgSignInRequired     = "Please use /start to register or sign in first."
+	AlertMsgClientsOnly        = "This action is only available to clients."
+	AlertMsgProfessionalsOnly  = "This action is only available to professionals."
+	AlertMsgActionUnavailable  = "This action is not available right now."
+	AlertMsgSomethingWentWrong = "Something went wrong. Please try again."
+	AlertMsgServiceUnavailable = "The booking service is temporarily unavailable."
+	AlertMsgInvalidState       = "This button is no longer valid here. Please use /start to begin again."
+	AlertMsgNoFreeSlots        = "There are no free time slots on this day. Please choose another one."
+)
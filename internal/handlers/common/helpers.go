@@ -1,11 +1,15 @@
 package common
 
 import (
+	"errors"
+
 	"booking_client/internal/models"
 	"booking_client/internal/repository"
 	"booking_client/internal/schemas"
+	apiService "booking_client/internal/services/api_service"
 	"booking_client/pkg/telegram"
 
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 	"github.com/rs/zerolog"
 )
 
@@ -23,17 +27,17 @@ func FormatAppointmentDetails(apt *schemas.ClientAppointment, index int) string
 	return NewClientAppointmentMessage(apt, index).ForClient()
 }
 
-// GetUserOrSendError retrieves user from repository or sends error message
-func GetUserOrSendError(userRepo *repository.UserRepository, bot *telegram.Bot, logger *zerolog.Logger, chatID int64) (*models.User, bool) {
-	user, exists := userRepo.GetUser(chatID)
-	if !exists || user == nil {
+// GetSessionOrSendError retrieves the chat session from repository or sends error message
+func GetSessionOrSendError(userRepo repository.UserRepository, bot telegram.Messenger, logger *zerolog.Logger, chatID int64) (*models.Session, bool) {
+	session, exists := userRepo.GetSession(chatID)
+	if !exists || session == nil {
 		text := "❌ User session not found. Please use /start to begin."
 		if err := bot.SendMessage(chatID, text); err != nil {
 			logger.Error().Err(err).Msg("Failed to send user not found message")
 		}
 		return nil, false
 	}
-	return user, true
+	return session, true
 }
 
 // FormatProfessionalAppointmentDetails formats appointment details for professional display
@@ -41,3 +45,18 @@ func GetUserOrSendError(userRepo *repository.UserRepository, bot *telegram.Bot,
 func FormatProfessionalAppointmentDetails(apt *schemas.ProfessionalAppointment, index int) string {
 	return NewProfessionalAppointmentMessage(apt, index).ForProfessional()
 }
+
+// RetryKeyboard offers to repeat an action that failed because the booking service is down or slow.
+// retryData is the data of the button that was pressed; without one the button starts over with /start.
+// Returns false for other failures, which trying again would not fix.
+func RetryKeyboard(err error, retryData string) (tgbotapi.InlineKeyboardMarkup, bool) {
+	if !errors.Is(err, apiService.ErrUnavailable) && !errors.Is(err, apiService.ErrTimeout) {
+		return tgbotapi.InlineKeyboardMarkup{}, false
+	}
+	if retryData == "" {
+		retryData = CallbackRetryStart
+	}
+	return tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData(BtnRetry, retryData)),
+	), true
+}
@@ -13,25 +13,26 @@ import (
 
 // NotificationService handles all notification-related operations
 type NotificationService struct {
-	bot        *telegram.Bot
+	bot        telegram.Messenger
 	logger     *zerolog.Logger
 	apiService *apiService.APIService
+	codec      *CallbackCodec
 }
 
 // NewNotificationService creates a new notification service
-func NewNotificationService(bot *telegram.Bot, logger *zerolog.Logger, apiService *apiService.APIService) *NotificationService {
+func NewNotificationService(bot telegram.Messenger, logger *zerolog.Logger, apiService *apiService.APIService, codec *CallbackCodec) *NotificationService {
 	return &NotificationService{
 		bot:        bot,
 		logger:     logger,
 		apiService: apiService,
+		codec:      codec,
 	}
 }
 
 // NotifyProfessionalNewAppointment sends notification to professional about new appointment
 func (ns *NotificationService) NotifyProfessionalNewAppointment(appointment *schemas.CreateAppointmentResponse) {
-	currentUser, ok := GetUserOrSendError(ns.apiService.GetUserRepository(), ns.bot, ns.logger, appointment.Professional.ChatID)
-	if !ok {
-		return
+	if appointment.Professional.ChatID == 0 {
+		return // No chat ID for professional
 	}
 
 	date, startTime, endTime := FormatAppointmentTime(appointment.Appointment.StartTime, appointment.Appointment.EndTime)
@@ -43,14 +44,17 @@ func (ns *NotificationService) NotifyProfessionalNewAppointment(appointment *sch
 
 	keyboard := tgbotapi.NewInlineKeyboardMarkup(
 		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData(BtnConfirmAppointment, fmt.Sprintf("confirm_appointment_%s", appointment.Appointment.ID)),
-			tgbotapi.NewInlineKeyboardButtonData(BtnCancelAppointmentConfirm, fmt.Sprintf("cancel_appointment_%s", appointment.Appointment.ID)),
+			tgbotapi.NewInlineKeyboardButtonData(BtnConfirmAppointment, ns.callback(appointment.Professional.ChatID, CallbackPrefixConfirmAppointment, appointment.Appointment.ID)),
+			tgbotapi.NewInlineKeyboardButtonData(BtnCancelAppointmentConfirm, ns.callback(appointment.Professional.ChatID, CallbackPrefixCancelAppointment, appointment.Appointment.ID)),
 			tgbotapi.NewInlineKeyboardButtonData(BtnBackToDashboard, "back_to_dashboard"),
 		),
 	)
-	ns.bot.SendMessageWithKeyboard(appointment.Professional.ChatID, text, keyboard)
 
-	ns.apiService.GetUserRepository().SetUser(appointment.Professional.ChatID, currentUser)
+	// The bot's send scheduler queues and retries rate-limited sends,
+	// so a burst of bookings delays notifications instead of dropping them
+	if err := ns.bot.SendMessageWithKeyboard(appointment.Professional.ChatID, text, keyboard); err != nil {
+		ns.logger.Error().Err(err).Msg("Failed to send professional new appointment notification")
+	}
 }
 
 // NotifyProfessionalCancellation sends notification to professional about appointment cancellation
@@ -105,3 +109,13 @@ func (ns *NotificationService) NotifyClientProfessionalCancellation(response *sc
 		ns.logger.Error().Err(err).Msg("Failed to send client cancellation notification")
 	}
 }
+
+// callback signs the callback data of a notification button sent to chatID
+func (ns *NotificationService) callback(chatID int64, prefix, param string) string {
+	data, err := ns.codec.Encode(chatID, prefix, param)
+	if err != nil {
+		ns.logger.Error().Err(err).Str("prefix", prefix).Str("param", param).Msg("Failed to sign callback data")
+		return BuildCallback(prefix, param)
+	}
+	return data
+}
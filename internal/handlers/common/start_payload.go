@@ -0,0 +1,39 @@
+package common
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+
+	"booking_client/internal/models"
+)
+
+// StartPayloadProfessionalPrefix starts payloads linking to a professional: p_<professional id>
+const StartPayloadProfessionalPrefix = "p_"
+
+// startPayloadPattern is what Telegram accepts as a deep link payload
+var startPayloadPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// ErrStartPayloadInvalid is returned for payloads Telegram would never send
+var ErrStartPayloadInvalid = errors.New("start payload is invalid")
+
+// ParseStartPayload parses the payload of a t.me/<bot>?start=<payload> link.
+// p_<id> links to a professional; any other payload is a campaign kept for attribution.
+// Returns nil for an empty payload.
+func ParseStartPayload(payload string) (*models.Referral, error) {
+	if payload == "" {
+		return nil, nil
+	}
+	if !startPayloadPattern.MatchString(payload) {
+		return nil, ErrStartPayloadInvalid
+	}
+
+	if strings.HasPrefix(payload, StartPayloadProfessionalPrefix) {
+		professionalID := strings.TrimPrefix(payload, StartPayloadProfessionalPrefix)
+		if professionalID == "" {
+			return nil, ErrStartPayloadInvalid
+		}
+		return &models.Referral{ProfessionalID: professionalID}, nil
+	}
+	return &models.Referral{Campaign: payload}, nil
+}
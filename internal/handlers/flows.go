@@ -0,0 +1,283 @@
+package handlers
+
+import (
+	"context"
+	"time"
+
+	handlersCommon "booking_client/internal/handlers/common"
+	"booking_client/internal/handlers/fsm"
+	"booking_client/internal/models"
+)
+
+// Conversation timeouts
+const (
+	registrationTimeout = 30 * time.Minute
+	signInTimeout       = 10 * time.Minute
+	bookingTimeout      = 30 * time.Minute
+	cancellationTimeout = 15 * time.Minute
+	unavailableTimeout  = 30 * time.Minute
+)
+
+// dashboardCallbacks start actions from the client and professional dashboards
+// and from notifications; they are accepted whenever no flow is in progress
+var dashboardCallbacks = []string{
+	handlersCommon.CallbackBookAppointment,
+	handlersCommon.CallbackPendingAppointments,
+	handlersCommon.CallbackUpcomingAppointments,
+	handlersCommon.CallbackProfessionalPendingAppointments,
+	handlersCommon.CallbackProfessionalUpcomingAppointments,
+	handlersCommon.CallbackProfessionalTimetable,
+	handlersCommon.CallbackSetUnavailable,
+	handlersCommon.CallbackProfessionalPreviousAppointments,
+	handlersCommon.CallbackPrefixPrevTimetableDay,
+	handlersCommon.CallbackPrefixNextTimetableDay,
+	handlersCommon.CallbackPrefixPrevUpcomingMonth,
+	handlersCommon.CallbackPrefixNextUpcomingMonth,
+	handlersCommon.CallbackPrefixSelectUpcomingDate,
+	handlersCommon.CallbackPrefixSelectClient,
+	handlersCommon.CallbackPrefixCancelAppointment,
+	handlersCommon.CallbackPrefixConfirmAppointment,
+	handlersCommon.CallbackPrefixCancelProfAppt,
+}
+
+// setupFlows declares every conversation flow with the state machine.
+// Callbacks not declared by any state (role selection, back to dashboard) are always accepted.
+func (h *Handler) setupFlows() error {
+	h.machine.SetIdle(dashboardCallbacks...)
+
+	flows := []fsm.Flow{
+		{
+			Name:    models.FlowRegistration,
+			Initial: models.StateWaitingForFirstName,
+			Timeout: registrationTimeout,
+			States: []fsm.State{
+				{
+					Name: models.StateWaitingForFirstName,
+					Text: h.clientHandler.HandleFirstNameInput,
+					Next: []string{models.StateWaitingForLastName},
+				},
+				{
+					Name: models.StateWaitingForLastName,
+					Text: h.clientHandler.HandleLastNameInput,
+					Next: []string{models.StateWaitingForPhone},
+				},
+				{
+					Name:  models.StateWaitingForPhone,
+					Text:  h.clientHandler.HandlePhoneInput,
+					Input: fsm.InputPersonal,
+				},
+			},
+		},
+		{
+			Name:    models.FlowSignIn,
+			Initial: models.StateWaitingForUsername,
+			Timeout: signInTimeout,
+			States: []fsm.State{
+				{
+					Name: models.StateWaitingForUsername,
+					Text: h.professionalHandler.HandleUsernameInput,
+					Next: []string{models.StateWaitingForPassword},
+				},
+				{
+					Name:  models.StateWaitingForPassword,
+					Text:  h.professionalHandler.HandlePasswordInput,
+					Input: fsm.InputSecret,
+				},
+			},
+		},
+		{
+			Name:    models.FlowProfessionalSignUp,
+			Initial: models.StateWaitingForInviteCode,
+			Timeout: registrationTimeout,
+			States: []fsm.State{
+				{
+					Name:  models.StateWaitingForInviteCode,
+					Text:  h.professionalHandler.HandleInviteCodeInput,
+					Input: fsm.InputSecret,
+					Next:  []string{models.StateWaitingForProfessionalFirstName},
+				},
+				{
+					Name: models.StateWaitingForProfessionalFirstName,
+					Text: h.professionalHandler.HandleSignUpFirstNameInput,
+					Next: []string{models.StateWaitingForProfessionalLastName},
+				},
+				{
+					Name: models.StateWaitingForProfessionalLastName,
+					Text: h.professionalHandler.HandleSignUpLastNameInput,
+					Next: []string{models.StateWaitingForProfessionalPhone},
+				},
+				{
+					Name:  models.StateWaitingForProfessionalPhone,
+					Text:  h.professionalHandler.HandleSignUpPhoneInput,
+					Input: fsm.InputPersonal,
+					Next:  []string{models.StateWaitingForNewUsername},
+				},
+				{
+					Name: models.StateWaitingForNewUsername,
+					Text: h.professionalHandler.HandleNewUsernameInput,
+					Next: []string{models.StateWaitingForNewPassword},
+				},
+				{
+					Name:  models.StateWaitingForNewPassword,
+					Text:  h.professionalHandler.HandleNewPasswordInput,
+					Input: fsm.InputSecret,
+					Next:  []string{models.StateWaitingForPasswordConfirmation},
+				},
+				{
+					// A mismatched confirmation goes back to choosing the password, a taken username to choosing one
+					Name:  models.StateWaitingForPasswordConfirmation,
+					Text:  h.professionalHandler.HandlePasswordConfirmationInput,
+					Input: fsm.InputSecret,
+					Next:  []string{models.StateWaitingForNewPassword, models.StateWaitingForNewUsername},
+				},
+			},
+		},
+		{
+			Name:    models.FlowChangePassword,
+			Initial: models.StateWaitingForCurrentPassword,
+			Timeout: signInTimeout,
+			States: []fsm.State{
+				{
+					Name:  models.StateWaitingForCurrentPassword,
+					Text:  h.professionalHandler.HandleCurrentPasswordInput,
+					Input: fsm.InputSecret,
+					Next:  []string{models.StateWaitingForChangedPassword},
+				},
+				{
+					Name:  models.StateWaitingForChangedPassword,
+					Text:  h.professionalHandler.HandleChangedPasswordInput,
+					Input: fsm.InputSecret,
+					Next:  []string{models.StateWaitingForChangedPasswordConfirmation},
+				},
+				{
+					// A mismatched confirmation goes back to choosing the password
+					Name:  models.StateWaitingForChangedPasswordConfirmation,
+					Text:  h.professionalHandler.HandleChangedPasswordConfirmation,
+					Input: fsm.InputSecret,
+					Next:  []string{models.StateWaitingForChangedPassword},
+				},
+			},
+		},
+		{
+			Name:           models.FlowBooking,
+			Initial:        models.StateWaitingForProfessionalSelection,
+			Timeout:        bookingTimeout,
+			TimeoutMessage: handlersCommon.ErrorMsgBookingAbandoned,
+			CancelCallback: handlersCommon.CallbackCancelBooking,
+			Cancel:         h.clientHandler.HandleCancelBooking,
+			States: []fsm.State{
+				{
+					Name:      models.StateWaitingForProfessionalSelection,
+					Callbacks: []string{handlersCommon.CallbackPrefixSelectProfessional},
+					Next:      []string{models.StateWaitingForDateSelection},
+				},
+				{
+					Name: models.StateWaitingForDateSelection,
+					Callbacks: []string{
+						handlersCommon.CallbackPrefixSelectDate,
+						handlersCommon.CallbackPrefixPrevMonth,
+						handlersCommon.CallbackPrefixNextMonth,
+					},
+					Next: []string{models.StateWaitingForTimeSelection},
+				},
+				{
+					Name:      models.StateWaitingForTimeSelection,
+					Callbacks: []string{handlersCommon.CallbackPrefixSelectTime},
+				},
+				{
+					// Sessions stored before the booking steps had their own states
+					Name: models.StateBookingAppointment,
+					Callbacks: []string{
+						handlersCommon.CallbackPrefixSelectProfessional,
+						handlersCommon.CallbackPrefixSelectDate,
+						handlersCommon.CallbackPrefixPrevMonth,
+						handlersCommon.CallbackPrefixNextMonth,
+						handlersCommon.CallbackPrefixSelectTime,
+					},
+					Next: []string{models.StateWaitingForDateSelection, models.StateWaitingForTimeSelection},
+				},
+			},
+		},
+		{
+			Name:    models.FlowCancellation,
+			Initial: models.StateWaitingForCancellationReason,
+			Timeout: cancellationTimeout,
+			States: []fsm.State{
+				{
+					Name:  models.StateWaitingForCancellationReason,
+					Text:  h.handleCancellationReason,
+					Input: fsm.InputPersonal,
+				},
+			},
+		},
+		{
+			Name:           models.FlowUnavailable,
+			Initial:        models.StateWaitingForUnavailableDateSelection,
+			Timeout:        unavailableTimeout,
+			CancelCallback: handlersCommon.CallbackCancelUnavailable,
+			Cancel:         h.professionalHandler.HandleCancelUnavailable,
+			States: []fsm.State{
+				{
+					Name: models.StateWaitingForUnavailableDateSelection,
+					Callbacks: []string{
+						handlersCommon.CallbackPrefixSelectUnavailableDate,
+						handlersCommon.CallbackPrefixPrevUnavailableMonth,
+						handlersCommon.CallbackPrefixNextUnavailableMonth,
+					},
+					Next: []string{models.StateWaitingForUnavailableStartTime},
+				},
+				{
+					Name:      models.StateWaitingForUnavailableStartTime,
+					Callbacks: []string{handlersCommon.CallbackPrefixSelectUnavailableStart},
+					Next:      []string{models.StateWaitingForUnavailableEndTime},
+				},
+				{
+					Name:      models.StateWaitingForUnavailableEndTime,
+					Callbacks: []string{handlersCommon.CallbackPrefixSelectUnavailableEnd},
+					Next:      []string{models.StateWaitingForUnavailableDescription},
+				},
+				{
+					Name: models.StateWaitingForUnavailableDescription,
+					Text: h.professionalHandler.HandleUnavailableDescription,
+				},
+			},
+		},
+		{
+			// Browsing keeps the dashboard usable, so it never times out
+			Name:    models.FlowPreviousAppointments,
+			Initial: models.StateBrowsingPreviousAppointments,
+			Passive: true,
+			States: []fsm.State{
+				{
+					Name: models.StateBrowsingPreviousAppointments,
+					Callbacks: append([]string{
+						handlersCommon.CallbackPrefixPrevPreviousMonth,
+						handlersCommon.CallbackPrefixNextPreviousMonth,
+					}, dashboardCallbacks...),
+				},
+			},
+		},
+	}
+
+	for _, flow := range flows {
+		if err := h.machine.Register(flow); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// handleCancellationReason passes the cancellation reason to the handler of the user's role
+func (h *Handler) handleCancellationReason(ctx context.Context, chatID int64, text string, messageID int) {
+	session, exists := h.apiService.GetUserRepository().GetSession(chatID)
+	if !exists || session == nil {
+		h.sendUnknownCommand(ctx, chatID)
+		return
+	}
+
+	if session.Profile.Role == "professional" {
+		h.professionalHandler.HandleCancellationReason(ctx, chatID, text, messageID)
+	} else {
+		h.clientHandler.HandleCancellationReason(ctx, chatID, text, messageID)
+	}
+}
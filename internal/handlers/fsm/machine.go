@@ -0,0 +1,360 @@
+package fsm
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"booking_client/internal/common"
+	handlersCommon "booking_client/internal/handlers/common"
+	"booking_client/internal/models"
+	"booking_client/internal/repository"
+	"booking_client/pkg/telegram"
+
+	"github.com/rs/zerolog"
+)
+
+// TextHandler handles free text typed by the user in a state
+type TextHandler func(ctx context.Context, chatID int64, text string, messageID int)
+
+// CancelHandler ends the flow of a chat early and tells the user about it
+type CancelHandler func(ctx context.Context, chatID int64, messageID int)
+
+// Sensitivity says how text typed in a state is treated
+type Sensitivity int
+
+const (
+	InputPlain    Sensitivity = iota // Logged as typed
+	InputPersonal                    // Personal data such as phone numbers and reasons, redacted in logs
+	InputSecret                      // Credentials, deleted from the chat at once and never logged
+)
+
+// redactedText replaces sensitive text in logs
+const redactedText = "[REDACTED]"
+
+// Redact returns the text as it may appear in logs
+func (s Sensitivity) Redact(text string) string {
+	if s == InputPlain {
+		return text
+	}
+	return redactedText
+}
+
+// State declares the inputs a conversation state accepts and where it may go next.
+// Callback keys match callback data exactly, or by prefix when they end with "_".
+type State struct {
+	Name      string
+	Text      TextHandler   // Handles text typed in this state, nil if text is not expected
+	Callbacks []string      // Callback keys accepted in this state
+	Next      []string      // States reachable from this one
+	Timeout   time.Duration // Overrides the flow timeout when set
+	Input     Sensitivity   // How text typed in this state is treated
+}
+
+// Flow declares a multi-step conversation
+type Flow struct {
+	Name           string
+	Initial        string // State entered by Start
+	States         []State
+	Timeout        time.Duration // How long a state may stay idle, zero for no limit
+	TimeoutMessage string        // Sent when a state times out, defaults to the abandoned conversation message
+	CancelCallback string        // Callback key that cancels the flow, accepted in every state
+	Cancel         CancelHandler // Runs on cancel, nil to drop the conversation and confirm it
+	Passive        bool          // Browsing flows that commands may interrupt
+}
+
+// boundState is a registered state together with its flow
+type boundState struct {
+	State
+	flow *Flow
+}
+
+// Machine validates every text and callback input against the declared flows,
+// so handlers only ever see inputs that are valid in the chat's current state
+type Machine struct {
+	flows    map[string]*Flow
+	states   map[string]*boundState
+	userRepo repository.UserRepository
+	bot      telegram.Messenger
+	logger   *zerolog.Logger
+	now      func() time.Time
+}
+
+// NewMachine creates a machine with only the idle state, which accepts nothing
+func NewMachine(userRepo repository.UserRepository, bot telegram.Messenger, logger *zerolog.Logger) *Machine {
+	m := &Machine{
+		flows:    make(map[string]*Flow),
+		states:   make(map[string]*boundState),
+		userRepo: userRepo,
+		bot:      bot,
+		logger:   logger,
+		now:      time.Now,
+	}
+	idle := &Flow{Name: models.FlowNone, States: []State{{Name: models.StateNone}}}
+	m.flows[idle.Name] = idle
+	m.states[models.StateNone] = &boundState{State: idle.States[0], flow: idle}
+	return m
+}
+
+// SetIdle declares the callbacks accepted outside of any flow
+func (m *Machine) SetIdle(callbacks ...string) {
+	m.states[models.StateNone].Callbacks = callbacks
+}
+
+// Register adds a flow, rejecting states that are declared twice or unknown transitions
+func (m *Machine) Register(flow Flow) error {
+	if _, exists := m.flows[flow.Name]; exists {
+		return fmt.Errorf("flow %q registered twice", flow.Name)
+	}
+
+	registered := &flow
+	declared := make(map[string]bool, len(flow.States))
+	for _, state := range flow.States {
+		if _, exists := m.states[state.Name]; exists || declared[state.Name] {
+			return fmt.Errorf("flow %q: state %q already declared", flow.Name, state.Name)
+		}
+		declared[state.Name] = true
+	}
+	if !declared[flow.Initial] {
+		return fmt.Errorf("flow %q: initial state %q is not declared", flow.Name, flow.Initial)
+	}
+	for _, state := range flow.States {
+		for _, next := range state.Next {
+			if !declared[next] && next != models.StateNone {
+				return fmt.Errorf("flow %q: state %q leads to undeclared state %q", flow.Name, state.Name, next)
+			}
+		}
+	}
+
+	m.flows[flow.Name] = registered
+	for _, state := range flow.States {
+		m.states[state.Name] = &boundState{State: state, flow: registered}
+	}
+	m.logger.Debug().Str("flow", flow.Name).Int("states", len(flow.States)).Msg("Registered conversation flow")
+	return nil
+}
+
+// Start puts the session into the initial state of the flow, dropping any other flow
+func (m *Machine) Start(session *models.Session, flowName string) error {
+	flow, exists := m.flows[flowName]
+	if !exists || flowName == models.FlowNone {
+		return fmt.Errorf("unknown flow %q", flowName)
+	}
+	session.Conversation.Start(flow.Name, flow.Initial)
+	session.Conversation.EnteredAt = m.now()
+	return nil
+}
+
+// Transition moves the session to the next state of its flow.
+// Moving to StateNone ends the flow.
+func (m *Machine) Transition(session *models.Session, to string) error {
+	current := m.state(session)
+	if current == nil {
+		return fmt.Errorf("session is in unknown state %q", session.Conversation.State)
+	}
+	if to == models.StateNone {
+		session.Conversation.Clear()
+		return nil
+	}
+	if !contains(current.Next, to) {
+		return fmt.Errorf("transition from %q to %q is not declared", current.Name, to)
+	}
+	session.Conversation.State = to
+	session.Conversation.EnteredAt = m.now()
+	return nil
+}
+
+// HandleText passes text to the handler of the chat's current state.
+// Returns false if the state does not expect text.
+func (m *Machine) HandleText(ctx context.Context, chatID int64, text string, messageID int) bool {
+	session, exists := m.userRepo.GetSession(chatID)
+	if !exists || session == nil {
+		return false
+	}
+	if m.expire(ctx, chatID, session) {
+		return true
+	}
+
+	current := m.state(session)
+	if current == nil || current.Text == nil {
+		return false
+	}
+
+	// Secrets are removed from the chat before anything else; handlers get no message to track
+	if current.Input == InputSecret {
+		if err := m.bot.DeleteMessage(chatID, messageID); err != nil {
+			logger := common.GetLogger(ctx)
+			logger.Error().Err(err).Int64("chat_id", chatID).Msg("Failed to delete sensitive message")
+		}
+		messageID = 0
+	}
+	current.Text(ctx, chatID, text, messageID)
+	return true
+}
+
+// Sensitivity returns how text typed in the chat's current state is treated
+func (m *Machine) Sensitivity(chatID int64) Sensitivity {
+	session, exists := m.userRepo.GetSession(chatID)
+	if !exists || session == nil {
+		return InputPlain
+	}
+	if current := m.state(session); current != nil {
+		return current.Input
+	}
+	return InputPlain
+}
+
+// AcceptCallback reports whether the callback may be routed in the chat's current state.
+// Callbacks declared by any state are accepted only in the states declaring them;
+// the caller answers rejected callbacks.
+func (m *Machine) AcceptCallback(ctx context.Context, chatID int64, data string) bool {
+	state := models.StateNone
+	if session, exists := m.userRepo.GetSession(chatID); exists && session != nil {
+		if !m.expire(ctx, chatID, session) {
+			state = session.Conversation.State
+		}
+	}
+
+	if !m.bound(data) || m.accepts(m.states[state], data) {
+		return true
+	}
+
+	logger := common.GetLogger(ctx)
+	logger.Info().
+		Int64("chat_id", chatID).
+		Str("state", state).
+		Str("callback_data", data).
+		Msg("Rejected callback outside of its conversation state")
+	return false
+}
+
+// Cancel ends the flow the chat is in. Returns false if the chat is not in a flow.
+func (m *Machine) Cancel(ctx context.Context, chatID int64, messageID int) bool {
+	session, exists := m.userRepo.GetSession(chatID)
+	if !exists || session == nil || !session.InConversation() {
+		return false
+	}
+
+	current := m.state(session)
+	if current != nil && current.flow.Cancel != nil {
+		current.flow.Cancel(ctx, chatID, messageID)
+		return true
+	}
+
+	m.Drop(chatID, session)
+	if err := m.bot.SendMessage(chatID, handlersCommon.UIMsgActionCancelled); err != nil {
+		logger := common.GetLogger(ctx)
+		logger.Error().Err(err).Msg("Failed to send action cancelled message")
+	}
+	return true
+}
+
+// Busy reports whether the session is in a flow that is not passive
+func (m *Machine) Busy(session *models.Session) bool {
+	if !session.InConversation() {
+		return false
+	}
+	current := m.state(session)
+	return current == nil || !current.flow.Passive
+}
+
+// Stats returns the number of registered flows and states, the idle state included
+func (m *Machine) Stats() (flowCount int, stateCount int) {
+	return len(m.flows), len(m.states)
+}
+
+// expire cancels the flow of a session whose state outlived its timeout.
+// Reports whether the flow was cancelled.
+func (m *Machine) expire(ctx context.Context, chatID int64, session *models.Session) bool {
+	current := m.state(session)
+	if current == nil || session.Conversation.EnteredAt.IsZero() {
+		return false
+	}
+
+	timeout := current.Timeout
+	if timeout == 0 {
+		timeout = current.flow.Timeout
+	}
+	if timeout == 0 || m.now().Sub(session.Conversation.EnteredAt) < timeout {
+		return false
+	}
+
+	logger := common.GetLogger(ctx)
+	logger.Info().
+		Int64("chat_id", chatID).
+		Str("state", current.Name).
+		Dur("timeout", timeout).
+		Msg("Conversation state timed out")
+
+	m.Drop(chatID, session)
+
+	text := current.flow.TimeoutMessage
+	if text == "" {
+		text = handlersCommon.ErrorMsgConversationAbandoned
+	}
+	if err := m.bot.SendMessage(chatID, text); err != nil {
+		logger.Error().Err(err).Msg("Failed to send abandoned conversation message")
+	}
+	return true
+}
+
+// Drop ends the conversation of a session without telling the user
+func (m *Machine) Drop(chatID int64, session *models.Session) {
+	// Sessions without a profile never finished registration, nothing to keep
+	if !session.IsRegistered() {
+		m.userRepo.DeleteSession(chatID)
+		return
+	}
+	session.Conversation.Clear()
+	m.userRepo.SetSession(chatID, session)
+}
+
+// state returns the declared state of the session, nil if it is unknown
+func (m *Machine) state(session *models.Session) *boundState {
+	return m.states[session.Conversation.State]
+}
+
+// accepts reports whether the state accepts the callback
+func (m *Machine) accepts(state *boundState, data string) bool {
+	if state == nil {
+		return false
+	}
+	if state.flow.CancelCallback != "" && matches(state.flow.CancelCallback, data) {
+		return true
+	}
+	for _, key := range state.Callbacks {
+		if matches(key, data) {
+			return true
+		}
+	}
+	return false
+}
+
+// bound reports whether any state declares the callback
+func (m *Machine) bound(data string) bool {
+	for _, state := range m.states {
+		if m.accepts(state, data) {
+			return true
+		}
+	}
+	return false
+}
+
+// matches reports whether callback data matches a callback key
+func matches(key, data string) bool {
+	if strings.HasSuffix(key, "_") {
+		return strings.HasPrefix(data, key)
+	}
+	return data == key
+}
+
+// contains reports whether states contains state
+func contains(states []string, state string) bool {
+	for _, s := range states {
+		if s == state {
+			return true
+		}
+	}
+	return false
+}
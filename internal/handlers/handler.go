@@ -2,15 +2,20 @@ package handlers
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"time"
 
 	"booking_client/internal/common"
 	"booking_client/internal/config"
 	"booking_client/internal/handlers/client"
+	handlersCommon "booking_client/internal/handlers/common"
+	"booking_client/internal/handlers/fsm"
 	"booking_client/internal/handlers/professional"
 	"booking_client/internal/handlers/router"
 	"booking_client/internal/middleware"
 	"booking_client/internal/models"
+	"booking_client/internal/repository"
 	apiService "booking_client/internal/services/api_service"
 	"booking_client/pkg/telegram"
 
@@ -21,48 +26,142 @@ import (
 
 // Handler manages all bot command handlers
 type Handler struct {
-	bot                 *telegram.Bot
+	bot                 telegram.Messenger
 	config              *config.Config
 	logger              *zerolog.Logger
 	apiService          *apiService.APIService
 	clientHandler       *client.ClientHandler
 	professionalHandler *professional.ProfessionalHandler
 	callbackRouter      *router.CallbackRouter
+	commandRouter       *router.CommandRouter
+	machine             *fsm.Machine
+	codec               *handlersCommon.CallbackCodec
+	callbackMetrics     *router.Metrics
 }
 
 // NewHandler creates a new handler instance
-func NewHandler(bot *telegram.Bot, config *config.Config, logger *zerolog.Logger) (*Handler, error) {
+func NewHandler(bot telegram.Messenger, config *config.Config, logger *zerolog.Logger) (*Handler, error) {
 	apiService, err := apiService.NewAPIService(config, logger)
 	if err != nil {
 		return nil, err
 	}
 
+	codec, err := handlersCommon.NewCallbackCodec(config.CallbackSecret, config.CallbackTTL)
+	if err != nil {
+		apiService.Close()
+		return nil, err
+	}
+
+	machine := fsm.NewMachine(apiService.GetUserRepository(), bot, logger)
+
 	h := &Handler{
-		bot:                 bot,
-		config:              config,
-		logger:              logger,
-		apiService:          apiService,
-		clientHandler:       client.NewClientHandler(bot, logger, apiService),
-		professionalHandler: professional.NewProfessionalHandler(bot, logger, apiService),
-		callbackRouter:      router.NewCallbackRouter(logger, bot),
+		bot:           bot,
+		config:        config,
+		logger:        logger,
+		apiService:    apiService,
+		clientHandler: client.NewClientHandler(bot, logger, apiService, machine, codec),
+		professionalHandler: professional.NewProfessionalHandler(bot, logger, apiService, machine, codec, config.ProfessionalInviteCode,
+			professional.NewSignInLockout(config.SignInMaxAttempts, config.SignInMaxUsernameAttempts, config.SignInLockout)),
+		callbackRouter:  router.NewCallbackRouter(logger, bot, codec),
+		commandRouter:   router.NewCommandRouter(logger, bot, apiService.GetUserRepository()),
+		machine:         machine,
+		codec:           codec,
+		callbackMetrics: router.NewMetrics(),
 	}
 
-	// Setup callback routes
+	// Verified callbacks pass role checks, then the conversation state check, before dispatch;
+	// while the booking API is down they are answered at once instead
+	h.callbackRouter.Use(
+		router.Logging(),
+		h.callbackMetrics.Middleware(),
+		router.Recover(),
+		router.LoadSession(apiService.GetUserRepository()),
+		router.Authorize(),
+		h.conversationGuard,
+		h.apiHealthGuard,
+	)
+
+	// Setup callback routes, commands and conversation flows, failing on conflicting routes
 	h.setupRoutes()
+	if err := h.callbackRouter.Validate(); err != nil {
+		apiService.Close()
+		return nil, fmt.Errorf("invalid callback routes: %w", err)
+	}
+	h.setupCommands()
+	if err := h.commandRouter.Validate(); err != nil {
+		apiService.Close()
+		return nil, fmt.Errorf("invalid commands: %w", err)
+	}
+	if err := h.setupFlows(); err != nil {
+		apiService.Close()
+		return nil, err
+	}
+	h.commandRouter.SetBusy(machine.Busy)
+	h.commandRouter.SetAdmins(config.AdminChatIDs)
+
+	// Chats without a profile see the default menu; registered chats get their role's menu on first use
+	if err := h.commandRouter.PublishDefaultMenu(); err != nil {
+		logger.Error().Err(err).Msg("Failed to publish default command menu")
+	}
+
+	// Tell users when their unfinished conversation is dropped
+	apiService.OnSessionEvicted(h.handleSessionEvicted)
 
 	exactCount, prefixCount := h.callbackRouter.GetStats()
 	logger.Info().
 		Int("exact_handlers", exactCount).
 		Int("prefix_handlers", prefixCount).
 		Msg("Callback router initialized")
+	for _, route := range h.callbackRouter.Routes() {
+		logger.Debug().
+			Str("pattern", route.Pattern).
+			Bool("prefix", route.Prefix).
+			Str("role", route.Role).
+			Msg("Callback route")
+	}
+
+	flowCount, stateCount := h.machine.Stats()
+	logger.Info().
+		Int("flows", flowCount).
+		Int("states", stateCount).
+		Msg("Conversation state machine initialized")
 
 	return h, nil
 }
 
-// RegisterHandlers registers all command handlers
-func (h *Handler) RegisterHandlers() {
-	// Set this handler as the update handler for the bot
-	h.bot.SetUpdateHandler(h)
+// UserRepository returns the session store shared by all handlers
+func (h *Handler) UserRepository() repository.UserRepository {
+	return h.apiService.GetUserRepository()
+}
+
+// CallbackCodec returns the codec signing callback data
+func (h *Handler) CallbackCodec() *handlersCommon.CallbackCodec {
+	return h.codec
+}
+
+// CallbackRoutes returns the callback route table, for debugging
+func (h *Handler) CallbackRoutes() []router.Route {
+	return h.callbackRouter.Routes()
+}
+
+// CallbackStats returns the routed and rejected callback counters
+func (h *Handler) CallbackStats() map[string]map[string]int64 {
+	return h.callbackMetrics.Snapshot()
+}
+
+// APIBreakerStats returns the state of the booking API circuit breakers by endpoint
+func (h *Handler) APIBreakerStats() map[string]apiService.BreakerStatus {
+	return h.apiService.BreakerStats()
+}
+
+// APICacheStats returns the hits, misses and coalesced requests of the cached API endpoints
+func (h *Handler) APICacheStats() map[string]apiService.CacheStats {
+	return h.apiService.CacheStats()
+}
+
+// Close releases the resources held by the handlers
+func (h *Handler) Close() error {
+	return h.apiService.Close()
 }
 
 // HandleUpdate processes incoming updates (implements UpdateHandler interface)
@@ -118,22 +217,34 @@ func (h *Handler) HandleUpdate(update tgbotapi.Update) {
 	chatID := message.Chat.ID
 	userID := message.From.ID
 	text := message.Text
+	// API calls made for this update act on behalf of the chat's user
+	ctx = common.WithChatID(ctx, chatID)
 
+	// Text typed in states expecting credentials or personal data never reaches the logs
+	sensitivity := h.machine.Sensitivity(chatID)
 	logger.Info().
 		Int64("user_id", userID).
-		Str("message", text).
+		Str("message", sensitivity.Redact(text)).
 		Msg("Received message from user")
 
-	// Handle different commands and states
-	switch text {
-	case "/start":
-		h.handleStart(ctx, chatID, message.MessageID)
-	case "/dashboard":
-		h.handleDashboard(ctx, chatID)
-	default:
+	// Commands never reach the text handlers of conversation states. While a password or
+	// an invite code is expected only the commands leaving the flow are commands; anything
+	// else starting with "/" is the secret and is deleted from the chat like any other.
+	isCommand := router.IsCommand(text)
+	if isCommand && sensitivity == fsm.InputSecret {
+		isCommand = h.commandRouter.IsGlobalCommand(text)
+	}
+	if isCommand {
+		if !h.commandRouter.Route(ctx, chatID, text, message.MessageID) {
+			h.sendUnknownCommand(ctx, chatID)
+		}
+	} else {
 		h.handleUserInput(ctx, chatID, text, message.MessageID)
 	}
 
+	// Registration and sign-in change the commands the chat may use
+	h.commandRouter.SyncMenu(ctx, chatID)
+
 	latency := time.Since(start)
 
 	logger.Info().
@@ -144,44 +255,99 @@ func (h *Handler) HandleUpdate(update tgbotapi.Update) {
 // handleCallbackQuery handles inline keyboard button presses
 func (h *Handler) handleCallbackQuery(ctx context.Context, callback *tgbotapi.CallbackQuery) {
 	chatID := callback.Message.Chat.ID
-	data := callback.Data
-	messageID := callback.Message.MessageID
+	ctx = common.WithChatID(ctx, chatID)
+	// A failed action can be offered again with the same button
+	ctx = common.WithRetryCallback(ctx, callback.Data)
 
 	// Use logger from context
 	logger := common.GetLogger(ctx)
 	logger.Info().
 		Int64("user_id", callback.From.ID).
-		Str("callback_data", data).
+		Str("callback_data", callback.Data).
 		Msg("Received callback query")
 
-	// Answer the callback query to remove loading state
-	callbackConfig := tgbotapi.NewCallback(callback.ID, "")
-	if _, err := h.bot.GetAPI().Request(callbackConfig); err != nil {
-		logger := common.GetLogger(ctx)
-		logger.Error().Err(err).Msg("Failed to answer callback query")
-	}
-
-	// Route the callback to the appropriate handler
-	if !h.callbackRouter.Route(ctx, chatID, data, messageID) {
+	// Verify, authorize and route the callback; the router answers the query
+	if !h.callbackRouter.Route(ctx, callback) {
 		// No handler found - send unknown command message
 		h.sendUnknownCommand(ctx, chatID)
 	}
+
+	h.commandRouter.SyncMenu(ctx, chatID)
+}
+
+// conversationGuard rejects callbacks that are not valid in the chat's conversation state
+func (h *Handler) conversationGuard(next router.Next) router.Next {
+	return func(ctx context.Context, call *router.Call) {
+		if !h.machine.AcceptCallback(ctx, call.ChatID, call.Data) {
+			if err := call.Reject(router.RejectedState, handlersCommon.AlertMsgInvalidState); err != nil {
+				logger := common.GetLogger(ctx)
+				logger.Error().Err(err).Msg("Failed to answer callback query")
+			}
+			return
+		}
+		next(ctx, call)
+	}
+}
+
+// apiHealthGuard answers callbacks at once while the booking API's breaker is open,
+// offering to press the button again rather than waiting on a call bound to fail
+func (h *Handler) apiHealthGuard(next router.Next) router.Next {
+	return func(ctx context.Context, call *router.Call) {
+		if h.apiService.Available() {
+			next(ctx, call)
+			return
+		}
+		call.Rejection = router.RejectedUnavailable
+		logger := common.GetLogger(ctx)
+		if err := call.Answer(handlersCommon.AlertMsgServiceUnavailable, false); err != nil {
+			logger.Error().Err(err).Msg("Failed to answer callback query")
+		}
+		h.sendServiceDown(ctx, call.ChatID, handlersCommon.ErrorMsgBookingServiceDown, apiService.ErrCircuitOpen)
+	}
 }
 
-// handleStart handles the /start command
-func (h *Handler) handleStart(ctx context.Context, chatID int64, messageID int) {
+// handleStart handles the /start command, with the payload of the deep link it came from
+func (h *Handler) handleStart(ctx context.Context, chatID int64, payload string, messageID int) {
+	referral := h.parseReferral(ctx, chatID, payload)
+
 	// Check if user is already registered
 	user, err := h.apiService.GetUserByChatID(ctx, chatID)
+	if err != nil && !errors.Is(err, apiService.ErrNotFound) {
+		logger := common.GetLogger(ctx)
+		logger.Error().Err(err).Int64("chat_id", chatID).Msg("Failed to look up user")
+		h.sendServiceDown(ctx, chatID, fmt.Sprintf(handlersCommon.ErrorMsgServiceUnavailable, apiService.FormatErrorForUser(err)), err)
+		return
+	}
 	if err == nil && user != nil {
 		// User is already registered, show appropriate dashboard
-		if user.Role == "professional" {
-			h.professionalHandler.ShowDashboard(ctx, chatID, user, messageID)
-		} else {
+		switch {
+		case user.Role == "professional":
+			h.professionalHandler.ShowDashboard(ctx, chatID, messageID)
+		case referral != nil && referral.ProfessionalID != "":
+			h.clientHandler.StartBookingWithProfessional(ctx, chatID, referral.ProfessionalID, messageID)
+		default:
 			h.clientHandler.ShowDashboard(ctx, chatID, messageID)
 		}
 		return
 	}
 
+	// Keep the referral until registration completes
+	if referral != nil {
+		userRepo := h.apiService.GetUserRepository()
+		session, exists := userRepo.GetSession(chatID)
+		if !exists || session == nil {
+			session = models.NewSession(chatID)
+		}
+		session.Referral = referral
+		userRepo.SetSession(chatID, session)
+
+		// Links to a professional are for clients, so skip the role selection
+		if referral.ProfessionalID != "" {
+			h.clientHandler.StartRegistration(ctx, chatID, messageID)
+			return
+		}
+	}
+
 	// User is not registered, ask for role selection
 	welcomeText := `👋 Welcome to the Booking Bot!
 
@@ -200,10 +366,29 @@ Please choose how you want to continue:`
 	}
 }
 
-// handleStart handles the /dashboard command
+// parseReferral parses a /start payload, recording it for attribution.
+// Invalid payloads are logged and ignored.
+func (h *Handler) parseReferral(ctx context.Context, chatID int64, payload string) *models.Referral {
+	logger := common.GetLogger(ctx)
+	referral, err := handlersCommon.ParseStartPayload(payload)
+	if err != nil {
+		logger.Warn().Err(err).Int64("chat_id", chatID).Str("payload", payload).Msg("Ignored start payload")
+		return nil
+	}
+	if referral != nil {
+		logger.Info().
+			Int64("chat_id", chatID).
+			Str("professional_id", referral.ProfessionalID).
+			Str("campaign", referral.Campaign).
+			Msg("Chat started from a deep link")
+	}
+	return referral
+}
+
+// handleDashboard handles the /dashboard command
 func (h *Handler) handleDashboard(ctx context.Context, chatID int64) {
-	user, exists := h.apiService.GetUserRepository().GetUser(chatID)
-	if !exists || user == nil {
+	session, exists := h.apiService.GetUserRepository().GetSession(chatID)
+	if !exists || session == nil {
 		text := "❌ User session not found. Please use /start to begin."
 		if err := h.bot.SendMessage(chatID, text); err != nil {
 			// Use base logger for system errors in callback registration
@@ -212,50 +397,39 @@ func (h *Handler) handleDashboard(ctx context.Context, chatID int64) {
 		return
 	}
 	// Show appropriate dashboard based on user role
-	if user.Role == "professional" {
-		h.professionalHandler.ShowDashboard(ctx, chatID, user, 0)
+	if session.Profile.Role == "professional" {
+		h.professionalHandler.ShowDashboard(ctx, chatID, 0)
 	} else {
 		h.clientHandler.ShowDashboard(ctx, chatID, 0)
 	}
 }
 
-// handleUserInput handles user input based on their current state
+// handleUserInput passes user input to the text handler of their current conversation state
 func (h *Handler) handleUserInput(ctx context.Context, chatID int64, text string, messageID int) {
-	// Get user from memory to check state
-	user, exists := h.apiService.GetUserRepository().GetUser(chatID)
-	if !exists {
+	if !h.machine.HandleText(ctx, chatID, text, messageID) {
 		h.sendUnknownCommand(ctx, chatID)
-		return
 	}
+}
 
-	switch user.State {
-	case models.StateWaitingForFirstName:
-		h.clientHandler.HandleFirstNameInput(ctx, chatID, text, messageID)
-	case models.StateWaitingForLastName:
-		h.clientHandler.HandleLastNameInput(ctx, chatID, text, messageID)
-	case models.StateWaitingForPhone:
-		h.clientHandler.HandlePhoneInput(ctx, chatID, text, messageID)
-	case models.StateWaitingForUsername:
-		h.professionalHandler.HandleUsernameInput(ctx, chatID, text, messageID)
-	case models.StateWaitingForPassword:
-		h.professionalHandler.HandlePasswordInput(ctx, chatID, text, messageID)
-	case models.StateWaitingForCancellationReason:
-		// Check if user is professional or client to handle cancellation appropriately
-		user, exists := h.apiService.GetUserRepository().GetUser(chatID)
-		if !exists || user == nil {
-			h.sendUnknownCommand(ctx, chatID)
-			return
-		}
+// sendMessage sends a plain message, logging failures
+func (h *Handler) sendMessage(ctx context.Context, chatID int64, text string) {
+	if err := h.bot.SendMessage(chatID, text); err != nil {
+		logger := common.GetLogger(ctx)
+		logger.Error().Err(err).Msg("Failed to send message")
+	}
+}
 
-		if user.Role == "professional" {
-			h.professionalHandler.HandleCancellationReason(ctx, chatID, text, messageID)
-		} else {
-			h.clientHandler.HandleCancellationReason(ctx, chatID, text, messageID)
-		}
-	case models.StateWaitingForUnavailableDescription:
-		h.professionalHandler.HandleUnavailableDescription(ctx, chatID, text, messageID)
-	default:
-		h.sendUnknownCommand(ctx, chatID)
+// sendServiceDown sends a message about a failed API call, with a retry button when trying again may help
+func (h *Handler) sendServiceDown(ctx context.Context, chatID int64, text string, err error) {
+	retryData, _ := common.GetRetryCallback(ctx)
+	keyboard, ok := handlersCommon.RetryKeyboard(err, retryData)
+	if !ok {
+		h.sendMessage(ctx, chatID, text)
+		return
+	}
+	if err := h.bot.SendMessageWithKeyboard(chatID, text, keyboard); err != nil {
+		logger := common.GetLogger(ctx)
+		logger.Error().Err(err).Msg("Failed to send message")
 	}
 }
 
@@ -270,3 +444,25 @@ Please use /start to begin.`
 		logger.Error().Err(err).Msg("Failed to send unknown command message")
 	}
 }
+
+// handleSessionEvicted tells the user that their unfinished flow was abandoned
+func (h *Handler) handleSessionEvicted(chatID int64, session *models.Session, reason repository.EvictionReason) {
+	if session == nil || !session.InConversation() {
+		return
+	}
+
+	text := handlersCommon.ErrorMsgConversationAbandoned
+	if session.Conversation.Flow == models.FlowBooking {
+		text = handlersCommon.ErrorMsgBookingAbandoned
+	}
+
+	h.logger.Info().
+		Int64("chat_id", chatID).
+		Str("state", session.Conversation.State).
+		Str("reason", string(reason)).
+		Msg("Notifying user about abandoned conversation")
+
+	if err := h.bot.SendMessage(chatID, text); err != nil {
+		h.logger.Error().Err(err).Int64("chat_id", chatID).Msg("Failed to send abandoned conversation message")
+	}
+}
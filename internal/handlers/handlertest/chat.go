@@ -0,0 +1,340 @@
+package handlertest
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"booking_client/internal/handlers/common"
+	"booking_client/internal/models"
+	"booking_client/pkg/telegram/telegramtest"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// maxCalendarPages bounds how far PressDate pages through a calendar
+const maxCalendarPages = 12
+
+// Chat is a scripted conversation of one Telegram chat.
+// Actions (Send, Press) feed updates to the handler; expectations check the
+// replies that arrived in the chat since the last matched reply, in order.
+type Chat struct {
+	h  *Harness
+	ID int64
+
+	// read is the index of the first chat message not yet skipped by expectations
+	read int
+	// matched is the reply found by the last ExpectReply
+	matched *telegramtest.Message
+	// nextMessageID numbers the messages sent by the user
+	nextMessageID int
+	// lastCallbackID is the ID of the last callback query sent by the user
+	lastCallbackID string
+}
+
+// Send delivers a text message (or command) from the user
+func (c *Chat) Send(text string) *Chat {
+	c.h.t.Helper()
+	c.skipToEnd()
+
+	c.nextMessageID++
+	c.h.nextUpdateID++
+	c.h.Handler.HandleUpdate(tgbotapi.Update{
+		UpdateID: c.h.nextUpdateID,
+		Message: &tgbotapi.Message{
+			MessageID: c.nextMessageID,
+			From:      c.user(),
+			Chat:      c.chat(),
+			Date:      int(time.Now().Unix()),
+			Text:      text,
+		},
+	})
+	return c
+}
+
+// Press taps the button with the given text on the most recent message showing it
+func (c *Chat) Press(buttonText string) *Chat {
+	c.h.t.Helper()
+
+	messages := c.replies()
+	for i := len(messages) - 1; i >= 0; i-- {
+		if data, ok := messages[i].CallbackData(buttonText); ok {
+			return c.PressData(data, messages[i].MessageID)
+		}
+	}
+	c.h.t.Fatalf("chat %d: no button %q found\n%s", c.ID, buttonText, describe(messages))
+	return c
+}
+
+// PressData sends a callback query with raw data, as if from the given message
+func (c *Chat) PressData(data string, messageID int) *Chat {
+	c.h.t.Helper()
+	c.skipToEnd()
+
+	c.h.nextUpdateID++
+	c.h.nextCallbackID++
+	c.lastCallbackID = strconv.Itoa(c.h.nextCallbackID)
+	c.h.Handler.HandleUpdate(tgbotapi.Update{
+		UpdateID: c.h.nextUpdateID,
+		CallbackQuery: &tgbotapi.CallbackQuery{
+			ID:   c.lastCallbackID,
+			From: c.user(),
+			Message: &tgbotapi.Message{
+				MessageID: messageID,
+				Chat:      c.chat(),
+			},
+			Data: data,
+		},
+	})
+	return c
+}
+
+// PressDate picks a day on the most recent calendar, paging forward month by month as needed
+func (c *Chat) PressDate(day time.Time) *Chat {
+	c.h.t.Helper()
+
+	header := fmt.Sprintf("(%s %d)", day.Month(), day.Year())
+	for i := 0; i < maxCalendarPages; i++ {
+		messages := c.replies()
+		for j := len(messages) - 1; j >= 0; j-- {
+			if messages[j].Keyboard == nil {
+				continue
+			}
+			if strings.Contains(messages[j].Text, header) {
+				return c.Press(dayButton(messages[j], day.Day()))
+			}
+			break
+		}
+		c.Press(common.BtnNextMonth)
+	}
+	c.h.t.Fatalf("chat %d: calendar for %s not reached", c.ID, header)
+	return c
+}
+
+// dayButton returns the text of a day's button on a calendar, which may show its free slots as "12 (5)"
+func dayButton(msg telegramtest.Message, day int) string {
+	number := strconv.Itoa(day)
+	for _, text := range msg.ButtonTexts() {
+		if text == number || strings.HasPrefix(text, number+" (") {
+			return text
+		}
+	}
+	return number
+}
+
+// ExpectReply asserts that a new message or edit containing text arrived.
+// Earlier replies are skipped, so consecutive calls assert order; the matched
+// reply stays current, so further calls may assert more of its text.
+func (c *Chat) ExpectReply(text string) *Chat {
+	c.h.t.Helper()
+
+	messages := c.replies()
+	for i := c.read; i < len(messages); i++ {
+		if strings.Contains(messages[i].Text, text) {
+			c.read = i
+			c.matched = &messages[i]
+			return c
+		}
+	}
+	c.h.t.Fatalf("chat %d: expected a reply containing %q\n%s", c.ID, text, describe(messages[c.read:]))
+	return c
+}
+
+// ExpectSentDeleted asserts that the last message sent by the user was deleted from the chat
+func (c *Chat) ExpectSentDeleted() *Chat {
+	c.h.t.Helper()
+
+	deletes := c.h.Messenger.MessagesForChat(c.ID, telegramtest.KindDelete)
+	for _, msg := range deletes {
+		if msg.MessageID == c.nextMessageID {
+			return c
+		}
+	}
+	c.h.t.Fatalf("chat %d: expected message #%d to be deleted\n%s", c.ID, c.nextMessageID, describe(deletes))
+	return c
+}
+
+// ExpectNoReply asserts that no new message or edit arrived (after the matched one)
+func (c *Chat) ExpectNoReply() *Chat {
+	c.h.t.Helper()
+
+	start := c.read
+	if c.matched != nil {
+		start++
+	}
+	if messages := c.replies(); len(messages) > start {
+		c.h.t.Fatalf("chat %d: expected no reply\n%s", c.ID, describe(messages[start:]))
+	}
+	return c
+}
+
+// ExpectButtons asserts that the last matched reply shows the given buttons
+func (c *Chat) ExpectButtons(texts ...string) *Chat {
+	c.h.t.Helper()
+
+	msg := c.lastMatched()
+	for _, text := range texts {
+		if _, ok := msg.CallbackData(text); !ok {
+			c.h.t.Fatalf("chat %d: expected button %q, got %q", c.ID, text, msg.ButtonTexts())
+		}
+	}
+	return c
+}
+
+// ExpectNoButtons asserts that the last matched reply does not show the given buttons
+func (c *Chat) ExpectNoButtons(texts ...string) *Chat {
+	c.h.t.Helper()
+
+	msg := c.lastMatched()
+	for _, text := range texts {
+		if _, ok := msg.CallbackData(text); ok {
+			c.h.t.Fatalf("chat %d: unexpected button %q in %q", c.ID, text, msg.ButtonTexts())
+		}
+	}
+	return c
+}
+
+// ExpectCallbackData asserts the callback data of a button on the last matched reply.
+// Signed callback data is verified and compared in its plain form.
+func (c *Chat) ExpectCallbackData(buttonText, data string) *Chat {
+	c.h.t.Helper()
+
+	msg := c.lastMatched()
+	got, ok := msg.CallbackData(buttonText)
+	if !ok {
+		c.h.t.Fatalf("chat %d: expected button %q, got %q", c.ID, buttonText, msg.ButtonTexts())
+	}
+	if codec := c.h.Handler.CallbackCodec(); codec.IsSigned(got) {
+		decoded, err := codec.Decode(c.ID, got)
+		if err != nil {
+			c.h.t.Fatalf("chat %d: button %q has invalid callback data %q: %v", c.ID, buttonText, got, err)
+		}
+		got = decoded
+	}
+	if got != data {
+		c.h.t.Fatalf("chat %d: button %q has callback data %q, want %q", c.ID, buttonText, got, data)
+	}
+	return c
+}
+
+// ExpectAlert asserts that the last pressed button was answered with an alert containing text
+func (c *Chat) ExpectAlert(text string) *Chat {
+	c.h.t.Helper()
+
+	for _, answer := range c.h.Messenger.CallbackAnswers() {
+		if answer.CallbackQueryID != c.lastCallbackID {
+			continue
+		}
+		if !answer.ShowAlert || !strings.Contains(answer.Text, text) {
+			c.h.t.Fatalf("chat %d: callback answered with %q (alert %v), want alert %q", c.ID, answer.Text, answer.ShowAlert, text)
+		}
+		return c
+	}
+	c.h.t.Fatalf("chat %d: callback query %q was not answered", c.ID, c.lastCallbackID)
+	return c
+}
+
+// ExpectState asserts the conversation state stored in the chat's session
+func (c *Chat) ExpectState(state string) *Chat {
+	c.h.t.Helper()
+
+	if got := c.Session().Conversation.State; got != state {
+		c.h.t.Fatalf("chat %d: state is %q, want %q", c.ID, got, state)
+	}
+	return c
+}
+
+// ExpectSession runs check against the chat's session
+func (c *Chat) ExpectSession(check func(session *models.Session) error) *Chat {
+	c.h.t.Helper()
+
+	if err := check(c.Session()); err != nil {
+		c.h.t.Fatalf("chat %d: unexpected session: %v", c.ID, err)
+	}
+	return c
+}
+
+// ExpectNoSession asserts that the chat has no stored session
+func (c *Chat) ExpectNoSession() *Chat {
+	c.h.t.Helper()
+
+	if session, exists := c.h.Session(c.ID); exists {
+		c.h.t.Fatalf("chat %d: expected no session, got state %q", c.ID, session.Conversation.State)
+	}
+	return c
+}
+
+// ExpectSignedOut asserts that the chat has no profile and no conversation; the session may
+// remain to remember the menu published to the chat
+func (c *Chat) ExpectSignedOut() *Chat {
+	c.h.t.Helper()
+
+	if session, exists := c.h.Session(c.ID); exists && (session.IsRegistered() || session.InConversation()) {
+		c.h.t.Fatalf("chat %d: expected to be signed out, got profile %q in state %q", c.ID, session.Profile.ID, session.Conversation.State)
+	}
+	return c
+}
+
+// Session returns the chat's stored session, failing the test if there is none
+func (c *Chat) Session() *models.Session {
+	c.h.t.Helper()
+
+	session, exists := c.h.Session(c.ID)
+	if !exists || session == nil {
+		c.h.t.Fatalf("chat %d: no session stored", c.ID)
+	}
+	return session
+}
+
+// Replies returns all messages and edits received by the chat
+func (c *Chat) Replies() []telegramtest.Message {
+	return c.replies()
+}
+
+// replies returns the sends and edits of the chat
+func (c *Chat) replies() []telegramtest.Message {
+	return c.h.Messenger.MessagesForChat(c.ID, telegramtest.KindSend, telegramtest.KindEdit)
+}
+
+// skipToEnd marks all replies received so far as read before the next action
+func (c *Chat) skipToEnd() {
+	c.read = len(c.replies())
+	c.matched = nil
+}
+
+// lastMatched returns the reply found by the last ExpectReply
+func (c *Chat) lastMatched() telegramtest.Message {
+	c.h.t.Helper()
+
+	if c.matched == nil {
+		c.h.t.Fatalf("chat %d: no reply matched yet, call ExpectReply first", c.ID)
+	}
+	return *c.matched
+}
+
+// user returns the Telegram user behind the chat (private chats share the ID)
+func (c *Chat) user() *tgbotapi.User {
+	return &tgbotapi.User{ID: c.ID, FirstName: fmt.Sprintf("user%d", c.ID)}
+}
+
+// chat returns the Telegram chat
+func (c *Chat) chat() *tgbotapi.Chat {
+	return &tgbotapi.Chat{ID: c.ID, Type: "private"}
+}
+
+// describe formats messages for failure output
+func describe(messages []telegramtest.Message) string {
+	if len(messages) == 0 {
+		return "  (no replies)"
+	}
+	var b strings.Builder
+	for _, msg := range messages {
+		fmt.Fprintf(&b, "  [%s #%d] %q", msg.Kind, msg.MessageID, msg.Text)
+		if texts := msg.ButtonTexts(); len(texts) > 0 {
+			fmt.Fprintf(&b, " buttons=%q", texts)
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
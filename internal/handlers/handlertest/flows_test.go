@@ -0,0 +1,1109 @@
+package handlertest_test
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"booking_client/internal/config"
+	"booking_client/internal/handlers/common"
+	"booking_client/internal/handlers/handlertest"
+	"booking_client/internal/mockapi"
+	"booking_client/internal/models"
+	"booking_client/internal/util"
+)
+
+const (
+	clientChatID       int64 = 100
+	professionalChatID int64 = 200
+)
+
+// bookingDay returns a day whose slots are always in the future
+func bookingDay() time.Time {
+	return util.NowInAppTimezone().AddDate(0, 0, 1)
+}
+
+func TestClientRegistrationAndBooking(t *testing.T) {
+	h := handlertest.New(t)
+	profID := h.API.AddProfessional("anna", "secret", "Anna", "Smith")
+	professional := h.Chat(professionalChatID)
+	professional.Send("/start").Press("👨‍💼 Professional")
+	professional.Send("anna").Send("secret").ExpectReply("Sign in successful")
+
+	client := h.Chat(clientChatID)
+
+	client.Send("/start").
+		ExpectReply("Welcome to the Booking Bot").
+		ExpectButtons("👤 Client", "👨‍💼 Professional").
+		ExpectCallbackData("👤 Client", common.CallbackClient)
+
+	client.Press("👤 Client").
+		ExpectReply("Client Registration").
+		ExpectState(models.StateWaitingForFirstName)
+
+	client.Send("John").
+		ExpectReply("First name saved").
+		ExpectState(models.StateWaitingForLastName)
+
+	client.Send("Doe").
+		ExpectReply("Last name saved").
+		ExpectState(models.StateWaitingForPhone)
+
+	client.Send("skip").
+		ExpectReply("Registration successful").
+		ExpectButtons(common.BtnGoToDashboard).
+		ExpectState(models.StateNone).
+		ExpectSession(func(session *models.Session) error {
+			user := session.Profile
+			if user.ID == "" || user.FirstName != "John" || user.LastName != "Doe" || user.PhoneNumber != nil {
+				return fmt.Errorf("registered user not stored: %+v", user)
+			}
+			return nil
+		})
+
+	client.Press(common.BtnGoToDashboard).
+		ExpectReply("Welcome back, John").
+		ExpectButtons(common.BtnBookAppointment, common.BtnMyPendingAppointments, common.BtnMyUpcomingAppointments)
+
+	client.Press(common.BtnBookAppointment).
+		ExpectReply(common.UIMsgSelectProfessional).
+		ExpectCallbackData("👨‍💼 Anna Smith", common.CallbackPrefixSelectProfessional+profID).
+		ExpectState(models.StateWaitingForProfessionalSelection)
+
+	client.Press("👨‍💼 Anna Smith").
+		ExpectReply("Select a date").
+		ExpectButtons(common.BtnNextMonth, common.BtnCancelBooking).
+		ExpectState(models.StateWaitingForDateSelection)
+
+	day := bookingDay()
+	client.PressDate(day).
+		ExpectReply("Select a time slot for "+day.Format("2006-01-02")).
+		ExpectButtons("09:00", "10:00", "16:00").
+		ExpectState(models.StateWaitingForTimeSelection).
+		ExpectSession(func(session *models.Session) error {
+			booking := session.Conversation.Booking
+			if booking == nil || booking.ProfessionalID != profID || booking.Date != day.Format("2006-01-02") {
+				return fmt.Errorf("booking selection not stored: %+v", booking)
+			}
+			return nil
+		})
+
+	client.Press("10:00").
+		ExpectReply("Appointment booked successfully").
+		ExpectReply("Welcome back, John").
+		ExpectState(models.StateNone).
+		ExpectSession(func(session *models.Session) error {
+			if session.Conversation.Booking != nil {
+				return errors.New("booking selection not cleared")
+			}
+			return nil
+		})
+
+	appointments := h.API.Appointments()
+	if len(appointments) != 1 {
+		t.Fatalf("expected 1 appointment, got %d", len(appointments))
+	}
+	apt := appointments[0]
+	if apt.Status != mockapi.StatusPending || apt.ProfessionalID != profID {
+		t.Fatalf("unexpected appointment: %+v", apt)
+	}
+	if got := apt.StartTime.In(util.GetAppTimezone()).Format("15:04"); got != "10:00" {
+		t.Fatalf("appointment starts at %s, want 10:00", got)
+	}
+
+	professional.
+		ExpectReply("New Appointment Request").
+		ExpectReply("John Doe").
+		ExpectCallbackData(common.BtnConfirmAppointment, common.CallbackPrefixConfirmAppointment+apt.ID)
+
+	// A booked slot is no longer offered
+	client.Press(common.BtnBookAppointment).
+		Press("👨‍💼 Anna Smith").
+		PressDate(day).
+		ExpectReply("Select a time slot").
+		ExpectButtons("09:00", "11:00").
+		ExpectNoButtons("10:00")
+
+	client.Press(common.BtnCancelBooking).
+		ExpectReply(common.ErrorMsgBookingCancelled).
+		ExpectState(models.StateNone)
+}
+
+func TestProfessionalSignInAndConfirm(t *testing.T) {
+	h := handlertest.New(t)
+	profID := h.API.AddProfessional("anna", "secret", "Anna", "Smith")
+	h.API.AddClient(clientChatID, "John", "Doe")
+	bookAs(t, h, clientChatID, "11:00")
+
+	professional := h.Chat(professionalChatID)
+
+	professional.Send("/start").
+		ExpectReply("Welcome to the Booking Bot")
+
+	professional.Press("👨‍💼 Professional").
+		ExpectReply("Professional Sign In").
+		ExpectState(models.StateWaitingForUsername)
+
+	professional.Send("anna").
+		ExpectReply("Username saved").
+		ExpectState(models.StateWaitingForPassword)
+
+	professional.Send("secret").
+		ExpectReply("Sign in successful").
+		ExpectReply("Welcome back, Smith").
+		ExpectButtons(common.BtnPendingAppointments, common.BtnUpcomingAppointments).
+		ExpectSession(func(session *models.Session) error {
+			if user := session.Profile; user.ID != profID || user.Role != "professional" {
+				return fmt.Errorf("signed in professional not stored: %+v", user)
+			}
+			return nil
+		})
+
+	apt := h.API.Appointments()[0]
+	professional.Press(common.BtnPendingAppointments).
+		ExpectReply("Pending Appointments").
+		ExpectCallbackData("✅ Confirm Appointment #1", common.CallbackPrefixConfirmAppointment+apt.ID).
+		ExpectCallbackData("❌ Cancel Appointment #1", common.CallbackPrefixCancelProfAppt+apt.ID)
+
+	professional.Press("✅ Confirm Appointment #1").
+		ExpectReply("Appointment confirmed").
+		ExpectReply("Welcome back, Smith")
+
+	if got := h.API.Appointments()[0].Status; got != mockapi.StatusConfirmed {
+		t.Fatalf("appointment status is %q, want confirmed", got)
+	}
+
+	h.Chat(clientChatID).
+		ExpectReply("Appointment Confirmed!").
+		ExpectReply("Anna Smith")
+}
+
+func TestProfessionalCancelsWithReason(t *testing.T) {
+	h := handlertest.New(t)
+	h.API.AddProfessional("anna", "secret", "Anna", "Smith")
+	h.API.AddClient(clientChatID, "John", "Doe")
+	bookAs(t, h, clientChatID, "12:00")
+
+	professional := h.Chat(professionalChatID)
+	professional.Send("/start").Press("👨‍💼 Professional").Send("anna").Send("secret")
+
+	professional.Press(common.BtnPendingAppointments).
+		Press("❌ Cancel Appointment #1").
+		ExpectReply(common.UIMsgCancellationReason).
+		ExpectState(models.StateWaitingForCancellationReason)
+
+	professional.Send("Sick leave").
+		ExpectReply("Appointment cancelled").
+		ExpectReply("Reason: Sick leave").
+		ExpectState(models.StateNone).
+		ExpectSession(func(session *models.Session) error {
+			if session.Conversation.Cancellation != nil {
+				return errors.New("selected appointment not cleared")
+			}
+			return nil
+		})
+
+	apt := h.API.Appointments()[0]
+	if apt.Status != mockapi.StatusCancelled || apt.CancellationReason != "Sick leave" || apt.CancelledBy != "professional" {
+		t.Fatalf("unexpected appointment after cancel: %+v", apt)
+	}
+
+	h.Chat(clientChatID).
+		ExpectReply("Appointment Cancelled by Professional").
+		ExpectReply("Sick leave")
+}
+
+func TestProfessionalSignUp(t *testing.T) {
+	h := handlertest.New(t, func(cfg *config.Config) {
+		cfg.ProfessionalInviteCode = "welcome"
+	})
+
+	professional := h.Chat(professionalChatID)
+	professional.Send("/start").
+		Press("👨‍💼 Professional").
+		ExpectReply("Professional Sign In").
+		ExpectCallbackData(common.BtnProfessionalSignUp, common.CallbackProfessionalSignUp)
+
+	professional.Press(common.BtnProfessionalSignUp).
+		ExpectReply(common.UIMsgProfessionalSignUpInvite).
+		ExpectState(models.StateWaitingForInviteCode)
+
+	professional.Send("welcome").ExpectReply("Invite code accepted").
+		Send("Anna").ExpectReply("First name saved").
+		Send("Smith").ExpectReply("Last name saved").
+		Send("skip").ExpectReply("choose a username").
+		ExpectState(models.StateWaitingForNewUsername)
+
+	professional.Send("a").
+		ExpectReply(common.ErrorMsgInvalidUsername).
+		ExpectState(models.StateWaitingForNewUsername)
+
+	professional.Send("anna").ExpectReply("choose a password").
+		Send("short").
+		ExpectReply(common.ErrorMsgPasswordTooShort).
+		ExpectState(models.StateWaitingForNewPassword)
+
+	professional.Send("secret-password").
+		ExpectReply(common.UIMsgConfirmPassword).
+		ExpectSession(func(session *models.Session) error {
+			if strings.Contains(fmt.Sprintf("%+v", *session.Conversation.ProfessionalSignUp), "secret-password") {
+				return errors.New("password stored in the session")
+			}
+			return nil
+		})
+
+	professional.Send("other-password").
+		ExpectReply(common.ErrorMsgPasswordMismatch).
+		ExpectState(models.StateWaitingForNewPassword)
+
+	professional.Send("secret-password").
+		Send("secret-password").
+		ExpectReply("Registration successful").
+		ExpectReply("Welcome back").
+		ExpectButtons(common.BtnPendingAppointments).
+		ExpectState(models.StateNone).
+		ExpectSession(func(session *models.Session) error {
+			if session.Profile.Role != models.RoleProfessional || session.Profile.Username != "anna" {
+				return fmt.Errorf("registered professional not stored: %+v", session.Profile)
+			}
+			return nil
+		})
+
+	// The new account can sign in from another chat
+	h.Chat(professionalChatID + 1).
+		Send("/start").
+		Press("👨‍💼 Professional").
+		Send("anna").
+		Send("secret-password").
+		ExpectReply("Sign in successful")
+}
+
+func TestProfessionalSignUpWithWrongInviteCode(t *testing.T) {
+	h := handlertest.New(t, func(cfg *config.Config) {
+		cfg.ProfessionalInviteCode = "welcome"
+		cfg.SignInMaxAttempts = 2
+		cfg.SignInLockout = time.Minute
+	})
+
+	professional := h.Chat(professionalChatID)
+	professional.Send("/start").
+		Press("👨‍💼 Professional").
+		Press(common.BtnProfessionalSignUp).
+		Send("guess").
+		ExpectReply(common.ErrorMsgInvalidInviteCode).
+		ExpectNoSession()
+
+	// Guessing is locked out like passwords, even for the right code
+	professional.Press(common.BtnProfessionalSignUp).
+		Send("guess-again").
+		ExpectReply(common.ErrorMsgInvalidInviteCode).
+		ExpectReply("Too many wrong invite codes")
+	professional.Press(common.BtnProfessionalSignUp).
+		Send("welcome").
+		ExpectReply("Too many wrong invite codes").
+		ExpectNoSession()
+}
+
+func TestSignInFromARegisteredChatKeepsItsProfile(t *testing.T) {
+	h := handlertest.New(t)
+	h.API.AddProfessional("anna", "secret", "Anna", "Smith")
+	h.API.AddClient(clientChatID, "John", "Doe")
+
+	client := h.Chat(clientChatID)
+	client.Send("/start").ExpectReply("Welcome back, John")
+
+	client.PressData(common.CallbackProfessional, 0).
+		ExpectReply("Professional Sign In").
+		Send("anna").
+		Send("wrong").
+		ExpectReply(common.ErrorMsgWrongCredentials).
+		ExpectState(models.StateNone).
+		ExpectSession(func(session *models.Session) error {
+			if session.Profile.Role != models.RoleClient || session.Profile.FirstName != "John" {
+				return fmt.Errorf("client profile lost: %+v", session.Profile)
+			}
+			return nil
+		})
+}
+
+func TestProfessionalLogoutAndPasswordChange(t *testing.T) {
+	h := handlertest.New(t)
+	h.API.AddProfessional("anna", "secret", "Anna", "Smith")
+
+	professional := h.Chat(professionalChatID)
+	professional.Send("/start").Press("👨‍💼 Professional").Send("anna").Send("secret").
+		ExpectReply("Sign in successful")
+
+	professional.Send("/password").
+		ExpectReply(common.UIMsgChangePassword).
+		ExpectState(models.StateWaitingForCurrentPassword)
+	professional.Send("wrong").
+		ExpectReply(common.ErrorMsgWrongPassword).
+		ExpectState(models.StateNone)
+
+	// The current password is verified without signing in again
+	signIns := 0
+	for _, req := range h.API.Requests() {
+		if req.Path == "/api/professionals/sign_in" && req.Method == http.MethodPost {
+			signIns++
+		}
+	}
+	if signIns != 1 {
+		t.Fatalf("sign-in requests = %d, want only the first one", signIns)
+	}
+
+	professional.Send("/password").Send("secret").
+		ExpectReply("Password verified").
+		ExpectState(models.StateWaitingForChangedPassword)
+	professional.Send("new-secret").Send("typo-secret").
+		ExpectReply(common.ErrorMsgPasswordMismatch).
+		ExpectState(models.StateWaitingForChangedPassword)
+	professional.Send("new-secret").Send("new-secret").
+		ExpectReply(common.SuccessMsgPasswordChanged).
+		ExpectReply("Welcome back").
+		ExpectState(models.StateNone)
+
+	// Logging out mid-flow drops the flow, and /start no longer finds the account
+	professional.Send("/password").
+		Send("/logout").
+		ExpectReply(common.UIMsgSignedOut).
+		ExpectSignedOut()
+	if got, _ := h.Messenger.Commands(professionalChatID); containsString(got, "logout") {
+		t.Fatalf("signed out menu = %v, want no logout", got)
+	}
+
+	professional.Send("/start").
+		ExpectReply("Welcome to the Booking Bot").
+		Press("👨‍💼 Professional").Send("anna").Send("secret").
+		ExpectReply("Sign in failed")
+
+	professional.Send("/start").Press("👨‍💼 Professional").Send("anna").Send("new-secret").
+		ExpectReply("Sign in successful")
+}
+
+func TestAdminRevokesProfessionalSession(t *testing.T) {
+	const adminChatID int64 = 900
+	h := handlertest.New(t, func(cfg *config.Config) {
+		cfg.AdminChatIDs = []int64{adminChatID}
+	})
+	h.API.AddProfessional("anna", "secret", "Anna", "Smith")
+
+	professional := h.Chat(professionalChatID)
+	professional.Send("/start").Press("👨‍💼 Professional").Send("anna").Send("secret").
+		ExpectReply("Sign in successful")
+
+	// Admin commands are unknown to everyone else
+	professional.Send(fmt.Sprintf("/revoke %d", professionalChatID)).
+		ExpectReply("Unknown command").
+		ExpectState(models.StateNone)
+
+	admin := h.Chat(adminChatID)
+	admin.Send("/revoke").
+		ExpectReply(common.UIMsgRevokeUsage)
+	admin.Send(fmt.Sprintf("/revoke %d", clientChatID)).
+		ExpectReply(fmt.Sprintf(common.ErrorMsgNotAProfessionalChat, clientChatID))
+
+	// An outage is not mistaken for a chat without a professional
+	h.API.FailNext(http.StatusServiceUnavailable)
+	admin.Send(fmt.Sprintf("/revoke %d", professionalChatID+1)).
+		ExpectReply(fmt.Sprintf("Could not look up chat %d", professionalChatID+1))
+	admin.Send(fmt.Sprintf("/revoke %d", professionalChatID)).
+		ExpectReply(fmt.Sprintf(common.SuccessMsgSessionRevoked, professionalChatID))
+
+	professional.ExpectReply(common.UIMsgSessionRevoked).
+		ExpectSignedOut()
+	professional.Send("/dashboard").
+		ExpectReply(common.AlertMsgSignInRequired)
+}
+
+func TestSignOutWhileTheAPIIsDown(t *testing.T) {
+	const adminChatID int64 = 900
+	h := handlertest.New(t, func(cfg *config.Config) {
+		cfg.AdminChatIDs = []int64{adminChatID}
+	})
+	h.API.AddProfessional("anna", "secret", "Anna", "Smith")
+	h.API.AddProfessional("mark", "secret", "Mark", "Jones")
+
+	anna := h.Chat(professionalChatID)
+	anna.Send("/start").Press("👨‍💼 Professional").Send("anna").Send("secret").
+		ExpectReply("Sign in successful")
+	mark := h.Chat(professionalChatID + 1)
+	mark.Send("/start").Press("👨‍💼 Professional").Send("mark").Send("secret").
+		ExpectReply("Sign in successful")
+
+	h.API.FailNext(http.StatusInternalServerError)
+	anna.Send("/password").
+		Send("/logout").
+		ExpectReply(common.UIMsgSignedOut).
+		ExpectSignedOut()
+
+	admin := h.Chat(adminChatID)
+	h.API.FailNext(http.StatusInternalServerError)
+	admin.Send(fmt.Sprintf("/revoke %d", professionalChatID+1)).
+		ExpectReply(fmt.Sprintf(common.SuccessMsgSessionRevoked, professionalChatID+1)).
+		ExpectReply("could not be told")
+	mark.ExpectReply(common.UIMsgSessionRevoked).
+		ExpectSignedOut()
+}
+
+func TestProfessionalSignInWithWrongPassword(t *testing.T) {
+	h := handlertest.New(t)
+	h.API.AddProfessional("anna", "secret", "Anna", "Smith")
+
+	professional := h.Chat(professionalChatID)
+	professional.Send("/start").Press("👨‍💼 Professional").Send("anna")
+
+	professional.Send("wrong").
+		ExpectReply("Sign in failed").
+		ExpectNoReply().
+		ExpectNoSession()
+
+	professional.Send("secret").
+		ExpectReply("Unknown command")
+}
+
+func TestProfessionalPasswordIsDeletedAtOnce(t *testing.T) {
+	h := handlertest.New(t)
+	h.API.AddProfessional("anna", "secret", "Anna", "Smith")
+
+	professional := h.Chat(professionalChatID)
+	professional.Send("/start").Press("👨‍💼 Professional").Send("anna")
+
+	professional.Send("secret").
+		ExpectSentDeleted().
+		ExpectReply("Sign in successful").
+		ExpectSession(func(session *models.Session) error {
+			for _, id := range session.MessagesToDelete {
+				if *id == 3 {
+					return errors.New("password message is still tracked for deletion")
+				}
+			}
+			return nil
+		})
+}
+
+func TestPasswordStartingWithASlashIsNotACommand(t *testing.T) {
+	h := handlertest.New(t)
+	h.API.AddProfessional("anna", "/abc123", "Anna", "Smith")
+
+	professional := h.Chat(professionalChatID)
+	professional.Send("/start").Press("👨‍💼 Professional").Send("anna")
+
+	professional.Send("/abc123").
+		ExpectSentDeleted().
+		ExpectReply("Sign in successful")
+
+	// Commands leaving the flow still work while a password is expected
+	professional.Send("/logout").Send("/start").Press("👨‍💼 Professional").Send("anna")
+	professional.Send("/cancel").
+		ExpectNoSession()
+}
+
+func TestProfessionalSignInLockout(t *testing.T) {
+	h := handlertest.New(t, func(cfg *config.Config) {
+		cfg.SignInMaxAttempts = 2
+		cfg.SignInMaxUsernameAttempts = 3
+		cfg.SignInLockout = time.Minute
+	})
+	h.API.AddProfessional("anna", "secret", "Anna", "Smith")
+
+	professional := h.Chat(professionalChatID)
+	professional.Send("/start").Press("👨‍💼 Professional").Send("anna")
+	professional.Send("wrong").
+		ExpectReply("Sign in failed").
+		ExpectNoReply()
+
+	professional.Press("👨‍💼 Professional").Send("anna")
+	professional.Send("wrong again").
+		ExpectReply("Sign in failed").
+		ExpectReply("Too many failed sign-in attempts").
+		ExpectReply("try again in 1 minute.")
+
+	// The right password is refused while locked
+	professional.Press("👨‍💼 Professional").Send("anna")
+	professional.Send("secret").
+		ExpectSentDeleted().
+		ExpectReply("Too many failed sign-in attempts").
+		ExpectNoSession()
+
+	// Failures from one chat don't lock the account out of another
+	other := h.Chat(professionalChatID + 1)
+	other.Send("/start").Press("👨‍💼 Professional").Send("anna")
+	other.Send("secret").
+		ExpectReply("Sign in successful")
+}
+
+func TestProfessionalSignInLockoutPerUsername(t *testing.T) {
+	h := handlertest.New(t, func(cfg *config.Config) {
+		cfg.SignInMaxAttempts = 2
+		cfg.SignInMaxUsernameAttempts = 3
+		cfg.SignInLockout = time.Minute
+	})
+	h.API.AddProfessional("anna", "secret", "Anna", "Smith")
+
+	// Guessing spread over chats locks the username for every chat once its limit is reached
+	for i := int64(0); i < 3; i++ {
+		guesser := h.Chat(professionalChatID + 10 + i)
+		guesser.Send("/start").Press("👨‍💼 Professional").Send("anna")
+		guesser.Send("wrong").
+			ExpectReply("Sign in failed")
+	}
+
+	professional := h.Chat(professionalChatID)
+	professional.Send("/start").Press("👨‍💼 Professional").Send("anna")
+	professional.Send("secret").
+		ExpectReply("Too many failed sign-in attempts").
+		ExpectNoSession()
+}
+
+func TestStaleBookingCallbackAfterCancelIsRejected(t *testing.T) {
+	h := handlertest.New(t)
+	h.API.AddProfessional("anna", "secret", "Anna", "Smith")
+	h.API.AddClient(clientChatID, "John", "Doe")
+
+	client := h.Chat(clientChatID)
+	client.Send("/start").
+		Press(common.BtnBookAppointment).
+		Press("👨‍💼 Anna Smith").
+		PressDate(bookingDay()).
+		ExpectReply("Select a time slot").
+		ExpectButtons("10:00")
+
+	client.Press(common.BtnCancelBooking).
+		ExpectReply(common.ErrorMsgBookingCancelled).
+		ExpectState(models.StateNone)
+
+	client.Press("10:00").
+		ExpectAlert(common.AlertMsgInvalidState).
+		ExpectNoReply()
+
+	if got := len(h.API.Appointments()); got != 0 {
+		t.Fatalf("stale callback booked %d appointments", got)
+	}
+}
+
+func TestTakenSlotOffersRemainingTimes(t *testing.T) {
+	h := handlertest.New(t)
+	profID := h.API.AddProfessional("anna", "secret", "Anna", "Smith")
+	h.API.AddClient(clientChatID, "John", "Doe")
+	otherID := h.API.AddClient(clientChatID+1, "Jane", "Roe")
+
+	client := h.Chat(clientChatID)
+	client.Send("/start").
+		Press(common.BtnBookAppointment).
+		Press("👨‍💼 Anna Smith").
+		PressDate(bookingDay()).
+		ExpectReply("Select a time slot").
+		ExpectButtons("10:00")
+
+	// Another client books the slot while the list is open
+	day := bookingDay()
+	start := time.Date(day.Year(), day.Month(), day.Day(), 10, 0, 0, 0, util.GetAppTimezone())
+	h.API.AddAppointment(mockapi.Appointment{
+		ClientID:       otherID,
+		ProfessionalID: profID,
+		StartTime:      start,
+		EndTime:        start.Add(time.Hour),
+		Status:         mockapi.StatusPending,
+	})
+
+	client.Press("10:00").
+		ExpectReply(common.ErrorMsgSlotTaken).
+		ExpectReply("Select a time slot").
+		ExpectButtons("11:00").
+		ExpectNoButtons("10:00").
+		ExpectState(models.StateWaitingForTimeSelection)
+
+	client.Press("11:00").
+		ExpectReply("Appointment booked successfully")
+}
+
+func TestStartWhileTheAPIIsDown(t *testing.T) {
+	h := handlertest.New(t)
+
+	h.API.FailNext(http.StatusServiceUnavailable)
+	h.Chat(clientChatID).Send("/start").
+		ExpectReply("temporarily unavailable").
+		ExpectCallbackData(common.BtnRetry, common.CallbackRetryStart).
+		ExpectNoReply().
+		ExpectNoSession()
+
+	// A missing user is not a failure: the chat is offered registration
+	h.Chat(clientChatID).Press(common.BtnRetry).
+		ExpectReply("Welcome to the Booking Bot")
+}
+
+// withRetries enables quick API retries in a harness
+func withRetries(cfg *config.Config) {
+	cfg.APIMaxRetries = 2
+	cfg.APIRetryBaseDelay = time.Millisecond
+	cfg.APIRetryMaxDelay = 5 * time.Millisecond
+}
+
+func TestBookingIsRetriedOnceWithIdempotencyKey(t *testing.T) {
+	h := handlertest.New(t, withRetries)
+	h.API.AddProfessional("anna", "secret", "Anna", "Smith")
+	h.API.AddClient(clientChatID, "John", "Doe")
+
+	client := h.Chat(clientChatID)
+	client.Send("/start").
+		Press(common.BtnBookAppointment).
+		Press("👨‍💼 Anna Smith").
+		PressDate(bookingDay()).
+		ExpectReply("Select a time slot")
+
+	// The appointment is created, but the gateway times out before the reply arrives
+	h.API.LoseNextReply(http.StatusGatewayTimeout)
+	client.Press("10:00").
+		ExpectReply("Appointment booked successfully")
+
+	if got := len(h.API.Appointments()); got != 1 {
+		t.Fatalf("got %d appointments, want 1", got)
+	}
+	var keys []string
+	for _, req := range h.API.Requests() {
+		if req.Method == http.MethodPost && req.Path == "/api/appointments" {
+			keys = append(keys, req.IdempotencyKey)
+		}
+	}
+	if len(keys) != 2 || keys[0] == "" || keys[0] != keys[1] {
+		t.Fatalf("booking attempts sent idempotency keys %q, want the same key twice", keys)
+	}
+}
+
+func TestOnlyIdempotentRequestsAreRetried(t *testing.T) {
+	h := handlertest.New(t, withRetries)
+	h.API.AddProfessional("anna", "secret", "Anna", "Smith")
+	h.API.AddClient(clientChatID, "John", "Doe")
+
+	h.API.FailNext(http.StatusServiceUnavailable)
+	h.Chat(clientChatID).Send("/start").
+		ExpectReply("Welcome back")
+
+	// Signing in is a POST without an idempotency key: one failure is final
+	professional := h.Chat(professionalChatID)
+	professional.Send("/start").Press("👨‍💼 Professional").Send("anna")
+	h.API.FailNext(http.StatusServiceUnavailable)
+	professional.Send("secret").
+		ExpectReply("temporarily unavailable")
+
+	signIns := 0
+	for _, req := range h.API.Requests() {
+		if req.Path == "/api/professionals/sign_in" {
+			signIns++
+		}
+	}
+	if signIns != 1 {
+		t.Fatalf("sign in was sent %d times, want once", signIns)
+	}
+}
+
+func TestOpenBreakerAnswersAtOnceWithRetry(t *testing.T) {
+	const openDuration = 200 * time.Millisecond
+	h := handlertest.New(t, func(cfg *config.Config) {
+		cfg.APIBreakerFailures = 2
+		cfg.APIBreakerOpenDuration = openDuration
+	})
+	h.API.AddProfessional("anna", "secret", "Anna", "Smith")
+	h.API.AddClient(clientChatID, "John", "Doe")
+
+	client := h.Chat(clientChatID)
+	client.Send("/start").ExpectReply("Welcome back")
+
+	h.API.FailNext(http.StatusServiceUnavailable)
+	h.API.FailNext(http.StatusServiceUnavailable)
+	client.Press(common.BtnBookAppointment).
+		ExpectReply("temporarily unavailable").
+		ExpectCallbackData(common.BtnRetry, common.CallbackBookAppointment)
+	client.Press(common.BtnRetry).
+		ExpectReply("temporarily unavailable")
+
+	// The breaker is open: nothing reaches the API until it lets a probe through
+	sent := len(h.API.Requests())
+	client.Press(common.BtnRetry).
+		ExpectReply(common.ErrorMsgBookingServiceDown).
+		ExpectCallbackData(common.BtnRetry, common.CallbackBookAppointment)
+	if got := len(h.API.Requests()); got != sent {
+		t.Fatalf("%d requests reached the API while the breaker was open", got-sent)
+	}
+	if got := h.Handler.APIBreakerStats()["*"].State; got != "open" {
+		t.Fatalf("API breaker is %q, want open", got)
+	}
+
+	// Once the API is back, the retry button repeats the action
+	time.Sleep(openDuration)
+	client.Press(common.BtnRetry).
+		ExpectReply(common.UIMsgSelectProfessional).
+		ExpectState(models.StateWaitingForProfessionalSelection)
+}
+
+func TestCachedReadsAreInvalidatedByBookings(t *testing.T) {
+	h := handlertest.New(t, func(cfg *config.Config) {
+		cfg.APICacheProfessionalsTTL = time.Hour
+		cfg.APICacheAvailabilityTTL = time.Hour
+	})
+	h.API.AddProfessional("anna", "secret", "Anna", "Smith")
+	h.API.AddClient(clientChatID, "John", "Doe")
+	h.API.AddClient(clientChatID+1, "Jane", "Roe")
+
+	client := h.Chat(clientChatID)
+	client.Send("/start").
+		Press(common.BtnBookAppointment).
+		Press("👨‍💼 Anna Smith").
+		PressDate(bookingDay()).
+		ExpectReply("Select a time slot").
+		ExpectButtons("10:00")
+	client.Press(common.BtnCancelBooking)
+
+	// Booking through the bot drops the cached availability it changed
+	bookAs(t, h, clientChatID+1, "10:00")
+
+	client.Send("/start").
+		Press(common.BtnBookAppointment).
+		Press("👨‍💼 Anna Smith").
+		PressDate(bookingDay()).
+		ExpectReply("Select a time slot").
+		ExpectNoButtons("10:00")
+
+	// The calendar and the date selection share the availability of the day
+	var professionals, availability int
+	for _, req := range h.API.Requests() {
+		switch {
+		case req.Path == "/api/professionals":
+			professionals++
+		case strings.HasSuffix(req.Path, "/availability") && req.Query == "date="+bookingDay().Format("2006-01-02"):
+			availability++
+		}
+	}
+	if professionals != 1 || availability != 2 {
+		t.Fatalf("got %d professionals and %d availability requests for the day, want 1 and 2", professionals, availability)
+	}
+}
+
+func TestCalendarDisablesFullyBookedDays(t *testing.T) {
+	h := handlertest.New(t)
+	profID := h.API.AddProfessional("anna", "secret", "Anna", "Smith")
+	otherID := h.API.AddClient(clientChatID+1, "Jane", "Roe")
+	h.API.AddClient(clientChatID, "John", "Doe")
+
+	day := bookingDay()
+	for hour := mockapi.FirstSlotHour; hour < mockapi.LastSlotHour; hour++ {
+		start := time.Date(day.Year(), day.Month(), day.Day(), hour, 0, 0, 0, util.GetAppTimezone())
+		h.API.AddAppointment(mockapi.Appointment{
+			ClientID:       otherID,
+			ProfessionalID: profID,
+			StartTime:      start,
+			EndTime:        start.Add(time.Hour),
+			Status:         mockapi.StatusConfirmed,
+		})
+	}
+
+	client := h.Chat(clientChatID)
+	client.Send("/start").
+		Press(common.BtnBookAppointment).
+		Press("👨‍💼 Anna Smith")
+	if day.Month() != util.NowInAppTimezone().Month() {
+		client.Press(common.BtnNextMonth)
+	}
+
+	fullyBooked := fmt.Sprintf(common.BtnDateFullyBooked, day.Day())
+	client.ExpectReply(fmt.Sprintf("(%s %d)", day.Month(), day.Year())).
+		ExpectButtons(fullyBooked).
+		ExpectCallbackData(fullyBooked, common.CallbackDateFullyBooked)
+	nextDay := day.AddDate(0, 0, 1)
+	if nextDay.Month() == day.Month() {
+		client.ExpectButtons(fmt.Sprintf(common.BtnDateWithFreeSlots, nextDay.Day(), mockapi.LastSlotHour-mockapi.FirstSlotHour))
+	}
+
+	client.Press(fullyBooked).
+		ExpectAlert(common.AlertMsgNoFreeSlots).
+		ExpectState(models.StateWaitingForDateSelection)
+
+	client.PressDate(nextDay).
+		ExpectReply("Select a time slot").
+		ExpectButtons("10:00")
+}
+
+func TestCalendarShowsDaysThatFailedToLoadAsPlainDays(t *testing.T) {
+	h := handlertest.New(t)
+	profID := h.API.AddProfessional("anna", "secret", "Anna", "Smith")
+	h.API.AddClient(clientChatID, "John", "Doe")
+
+	day := bookingDay()
+	h.API.FailNextTo("/api/professionals/"+profID+"/availability", "date="+day.Format("2006-01-02"), http.StatusInternalServerError)
+
+	client := h.Chat(clientChatID)
+	client.Send("/start").
+		Press(common.BtnBookAppointment).
+		Press("👨‍💼 Anna Smith")
+	if day.Month() != util.NowInAppTimezone().Month() {
+		client.Press(common.BtnNextMonth)
+	}
+
+	// The other days still show their free slots, and the failed one can be picked
+	client.ExpectReply(fmt.Sprintf("(%s %d)", day.Month(), day.Year())).
+		ExpectButtons(fmt.Sprint(day.Day()))
+	nextDay := day.AddDate(0, 0, 1)
+	if nextDay.Month() == day.Month() {
+		client.ExpectButtons(fmt.Sprintf(common.BtnDateWithFreeSlots, nextDay.Day(), mockapi.LastSlotHour-mockapi.FirstSlotHour))
+	}
+
+	client.PressDate(day).
+		ExpectReply("Select a time slot").
+		ExpectButtons("10:00")
+}
+
+func TestForgedAndTamperedCallbacksAreRejected(t *testing.T) {
+	h := handlertest.New(t)
+	h.API.AddProfessional("anna", "secret", "Anna", "Smith")
+	h.API.AddClient(clientChatID, "John", "Doe")
+	bookAs(t, h, clientChatID, "11:00")
+
+	professional := h.Chat(professionalChatID)
+	professional.Send("/start").Press("👨‍💼 Professional").Send("anna").Send("secret")
+	professional.Press(common.BtnPendingAppointments).
+		ExpectReply("Pending Appointments")
+
+	replies := professional.Replies()
+	signed, ok := replies[len(replies)-1].CallbackData("✅ Confirm Appointment #1")
+	if !ok {
+		t.Fatal("confirm button not found")
+	}
+	if len(signed) > common.MaxCallbackDataLength {
+		t.Fatalf("signed callback data is %d bytes: %q", len(signed), signed)
+	}
+
+	apt := h.API.Appointments()[0]
+	professional.PressData(common.CallbackPrefixConfirmAppointment+apt.ID, 0).
+		ExpectReply(common.ErrorMsgInvalidCallback).
+		ExpectNoReply()
+
+	tampered := signed[:len(signed)-1] + "A"
+	if tampered == signed {
+		tampered = signed[:len(signed)-1] + "B"
+	}
+	professional.PressData(tampered, 0).
+		ExpectReply(common.ErrorMsgInvalidCallback).
+		ExpectNoReply()
+
+	// Signed buttons only work in the chat they were sent to
+	h.Chat(clientChatID).PressData(signed, 0).
+		ExpectReply(common.ErrorMsgInvalidCallback).
+		ExpectNoReply()
+
+	if got := h.API.Appointments()[0].Status; got != mockapi.StatusPending {
+		t.Fatalf("appointment status is %q, want pending", got)
+	}
+
+	professional.PressData(signed, 0).
+		ExpectReply("Appointment confirmed")
+}
+
+func TestCallbacksOfOtherRolesAreRejected(t *testing.T) {
+	h := handlertest.New(t)
+	h.API.AddProfessional("anna", "secret", "Anna", "Smith")
+	h.API.AddClient(clientChatID, "John", "Doe")
+
+	client := h.Chat(clientChatID)
+	client.Send("/start").ExpectReply("Welcome back, John")
+
+	client.PressData(common.CallbackProfessionalTimetable, 0).
+		ExpectAlert(common.AlertMsgProfessionalsOnly).
+		ExpectNoReply()
+
+	professional := h.Chat(professionalChatID)
+	professional.Send("/start").Press("👨‍💼 Professional").Send("anna").Send("secret")
+
+	professional.PressData(common.CallbackBookAppointment, 0).
+		ExpectAlert(common.AlertMsgClientsOnly).
+		ExpectNoReply()
+
+	stranger := h.Chat(300)
+	stranger.PressData(common.CallbackPendingAppointments, 0).
+		ExpectAlert(common.AlertMsgSignInRequired).
+		ExpectNoReply()
+
+	stats := h.Handler.CallbackStats()
+	if got := stats["rejected"]["role"]; got != 2 {
+		t.Fatalf("rejected by role = %d, want 2", got)
+	}
+}
+
+func TestUnknownInputWithoutSession(t *testing.T) {
+	h := handlertest.New(t)
+
+	h.Chat(clientChatID).
+		Send("hello").
+		ExpectReply("Unknown command").
+		ExpectNoSession()
+
+	h.Chat(clientChatID).
+		Send("/dashboard").
+		ExpectReply(common.AlertMsgSignInRequired)
+}
+
+func TestCommandsDuringRegistration(t *testing.T) {
+	h := handlertest.New(t)
+	h.API.AddProfessional("anna", "secret", "Anna", "Smith")
+
+	client := h.Chat(clientChatID)
+	client.Send("/start").
+		Press("👤 Client").
+		ExpectState(models.StateWaitingForFirstName)
+
+	client.Send("/book").
+		ExpectReply(common.AlertMsgSignInRequired).
+		ExpectState(models.StateWaitingForFirstName)
+
+	client.Send("/help").
+		ExpectReply(common.UIMsgHelp).
+		ExpectReply("/cancel").
+		ExpectState(models.StateWaitingForFirstName)
+
+	client.Send("/cancel").
+		ExpectReply(common.UIMsgActionCancelled).
+		ExpectNoSession()
+
+	client.Send("/cancel").
+		ExpectReply(common.UIMsgNothingToCancel)
+
+	client.Send("/start").Press("👤 Client").Send("John").Send("Doe").Send("skip").
+		ExpectReply("Registration successful")
+
+	if got, ok := h.Messenger.Commands(clientChatID); !ok || !containsString(got, "book") || containsString(got, "timetable") {
+		t.Fatalf("client menu = %v, want book without timetable", got)
+	}
+
+	client.Send("/book").
+		ExpectReply(common.UIMsgSelectProfessional).
+		ExpectState(models.StateWaitingForProfessionalSelection)
+
+	client.Send("/book").
+		ExpectReply(common.ErrorMsgFinishCurrentAction)
+
+	client.Send("/timetable").
+		ExpectReply(common.AlertMsgProfessionalsOnly)
+
+	client.Send("/cancel").
+		ExpectReply(common.ErrorMsgBookingCancelled).
+		ExpectState(models.StateNone)
+}
+
+func TestDeepLinkToProfessionalResumesAfterRegistration(t *testing.T) {
+	h := handlertest.New(t)
+	profID := h.API.AddProfessional("anna", "secret", "Anna", "Smith")
+
+	client := h.Chat(clientChatID)
+	client.Send("/start " + common.StartPayloadProfessionalPrefix + profID).
+		ExpectReply("Client Registration").
+		ExpectState(models.StateWaitingForFirstName)
+
+	client.Send("John").Send("Doe").Send("skip").
+		ExpectReply("Registration successful").
+		ExpectReply("Select a date").
+		ExpectState(models.StateWaitingForDateSelection).
+		ExpectSession(func(session *models.Session) error {
+			if session.Conversation.Booking.ProfessionalID != profID {
+				return fmt.Errorf("booking professional = %q, want %q", session.Conversation.Booking.ProfessionalID, profID)
+			}
+			if session.Referral != nil {
+				return fmt.Errorf("referral kept after registration: %+v", session.Referral)
+			}
+			return nil
+		})
+
+	// Registered clients go straight to booking; unknown professionals fall back to the dashboard
+	client.Send("/cancel").
+		Send("/start " + common.StartPayloadProfessionalPrefix + profID).
+		ExpectReply("Select a date").
+		ExpectState(models.StateWaitingForDateSelection)
+
+	client.Send("/cancel").
+		Send("/start " + common.StartPayloadProfessionalPrefix + "unknown").
+		ExpectReply(common.ErrorMsgReferralProfessionalNotFound).
+		ExpectReply("Welcome back, John").
+		ExpectState(models.StateNone)
+}
+
+func TestDeepLinkCampaignIsSentWithRegistration(t *testing.T) {
+	h := handlertest.New(t)
+
+	client := h.Chat(clientChatID)
+	client.Send("/start spring_promo").
+		ExpectReply("Welcome to the Booking Bot").
+		ExpectButtons("👤 Client", "👨‍💼 Professional")
+
+	client.Press("👤 Client").Send("John").Send("Doe").Send("skip").
+		ExpectReply("Registration successful").
+		ExpectState(models.StateNone)
+
+	var registered bool
+	for _, req := range h.API.Requests() {
+		if req.Path == "/api/clients/register" {
+			registered = strings.Contains(req.Body, `"campaign":"spring_promo"`)
+		}
+	}
+	if !registered {
+		t.Fatalf("campaign not sent with the registration: %+v", h.API.Requests())
+	}
+
+	// Payloads Telegram never sends are ignored
+	h.Chat(clientChatID + 1).Send("/start bad%20payload").
+		ExpectReply("Welcome to the Booking Bot").
+		ExpectNoSession()
+}
+
+// containsString reports whether values contains value
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func TestAPICallsActForTheChatUser(t *testing.T) {
+	h := handlertest.New(t)
+	h.API.AddProfessional("anna", "secret", "Anna", "Smith")
+	clientID := h.API.AddClient(clientChatID, "John", "Doe")
+
+	bookAs(t, h, clientChatID, "10:00")
+
+	var lookups, bookings int
+	for _, req := range h.API.Requests() {
+		switch {
+		case req.Method == http.MethodGet && strings.HasPrefix(req.Path, "/api/users/"):
+			// Looking up who the chat belongs to is done as the service
+			lookups++
+			if req.Subject != "" {
+				t.Errorf("user lookup made as %q, want the service", req.Subject)
+			}
+		case req.Method == http.MethodPost && req.Path == "/api/appointments":
+			bookings++
+			if req.Subject != clientID || req.Role != models.RoleClient {
+				t.Errorf("booking made as %q (%q), want client %q", req.Subject, req.Role, clientID)
+			}
+		}
+	}
+	if lookups == 0 || bookings != 1 {
+		t.Fatalf("got %d lookups and %d bookings", lookups, bookings)
+	}
+}
+
+// bookAs books the first professional at the given time for an already registered client
+func bookAs(t *testing.T, h *handlertest.Harness, chatID int64, slot string) {
+	t.Helper()
+
+	h.Chat(chatID).
+		Send("/start").
+		ExpectReply("Welcome back").
+		Press(common.BtnBookAppointment).
+		Press("👨‍💼 Anna Smith").
+		PressDate(bookingDay()).
+		Press(slot).
+		ExpectReply("Appointment booked successfully")
+}
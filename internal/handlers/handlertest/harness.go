@@ -0,0 +1,96 @@
+// Package handlertest drives handlers.Handler with synthetic Telegram updates
+// against a recording messenger and the in-memory booking API, so full
+// conversations can be scripted and asserted without network access.
+//
+//	h := handlertest.New(t)
+//	alice := h.Chat(100)
+//	alice.Send("/start").ExpectReply("Welcome to the Booking Bot")
+//	alice.Press("👤 Client").ExpectState(models.StateWaitingForFirstName)
+package handlertest
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"booking_client/internal/config"
+	"booking_client/internal/handlers"
+	"booking_client/internal/mockapi"
+	"booking_client/internal/models"
+	"booking_client/internal/token"
+	"booking_client/pkg/telegram/telegramtest"
+
+	"github.com/rs/zerolog"
+)
+
+// testJWTSecret satisfies the minimum secret length of the token maker
+const testJWTSecret = "handlertest-secret-0123456789abcdef"
+
+// Harness wires a Handler to a fake messenger and the mock booking API
+type Harness struct {
+	t         testing.TB
+	Messenger *telegramtest.FakeMessenger
+	API       *mockapi.Server
+	Handler   *handlers.Handler
+	Config    *config.Config
+
+	nextUpdateID   int
+	nextCallbackID int
+	chats          map[int64]*Chat
+}
+
+// New creates a harness; the mock API server is shut down when the test ends.
+// configure adjusts the handler config before the handler is created.
+func New(t testing.TB, configure ...func(cfg *config.Config)) *Harness {
+	t.Helper()
+
+	logger := zerolog.Nop()
+	tokenMaker, err := token.NewJWTMaker(testJWTSecret)
+	if err != nil {
+		t.Fatalf("failed to create token maker: %v", err)
+	}
+	api := mockapi.NewServer(tokenMaker, &logger)
+	server := httptest.NewServer(api)
+	t.Cleanup(server.Close)
+
+	cfg := &config.Config{
+		APIBaseURL:     server.URL,
+		JWTSecret:      testJWTSecret,
+		JWTTTL:         time.Hour,
+		CallbackSecret: testJWTSecret,
+		CallbackTTL:    time.Hour,
+	}
+	for _, apply := range configure {
+		apply(cfg)
+	}
+	messenger := telegramtest.NewFakeMessenger()
+
+	handler, err := handlers.NewHandler(messenger, cfg, &logger)
+	if err != nil {
+		t.Fatalf("failed to create handler: %v", err)
+	}
+
+	return &Harness{
+		t:         t,
+		Messenger: messenger,
+		API:       api,
+		Handler:   handler,
+		Config:    cfg,
+		chats:     make(map[int64]*Chat),
+	}
+}
+
+// Chat returns the scripted conversation of the given chat, creating it on first use
+func (h *Harness) Chat(chatID int64) *Chat {
+	if chat, exists := h.chats[chatID]; exists {
+		return chat
+	}
+	chat := &Chat{h: h, ID: chatID}
+	h.chats[chatID] = chat
+	return chat
+}
+
+// Session returns the handler's stored session of the chat
+func (h *Harness) Session(chatID int64) (*models.Session, bool) {
+	return h.Handler.UserRepository().GetSession(chatID)
+}
@@ -0,0 +1,18 @@
+package keyboards
+
+import (
+	"booking_client/internal/handlers/common"
+
+	"github.com/rs/zerolog"
+)
+
+// signedCallback signs the callback data of a parameterised button for the chat it is sent to.
+// A codec failure is a programming error; the unsigned fallback is rejected by the router.
+func signedCallback(codec *common.CallbackCodec, logger *zerolog.Logger, chatID int64, prefix, param string) string {
+	data, err := codec.Encode(chatID, prefix, param)
+	if err != nil {
+		logger.Error().Err(err).Str("prefix", prefix).Str("param", param).Msg("Failed to sign callback data")
+		return common.BuildCallback(prefix, param)
+	}
+	return data
+}
@@ -15,19 +15,25 @@ import (
 // ClientKeyboards handles keyboard creation for client-related operations
 type ClientKeyboards struct {
 	logger *zerolog.Logger
+	codec  *common.CallbackCodec
 }
 
 // NewClientKeyboards creates a new ClientKeyboards instance
-func NewClientKeyboards(logger *zerolog.Logger) *ClientKeyboards {
+func NewClientKeyboards(logger *zerolog.Logger, codec *common.CallbackCodec) *ClientKeyboards {
 	return &ClientKeyboards{
 		logger: logger,
+		codec:  codec,
 	}
 }
 
-// CreateDateKeyboard creates a keyboard for date selection
-func (kb *ClientKeyboards) CreateDateKeyboard(currentDate time.Time) tgbotapi.InlineKeyboardMarkup {
-	var rows [][]tgbotapi.InlineKeyboardButton
-	var currentRow []tgbotapi.InlineKeyboardButton
+// callback signs the callback data of a parameterised button
+func (kb *ClientKeyboards) callback(chatID int64, prefix, param string) string {
+	return signedCallback(kb.codec, kb.logger, chatID, prefix, param)
+}
+
+// BookableDates returns the days of the month of currentDate that are not in the past, as YYYY-MM-DD
+func BookableDates(currentDate time.Time) []string {
+	var dates []string
 
 	// Get first day of month and number of days
 	firstDay := time.Date(currentDate.Year(), currentDate.Month(), 1, 0, 0, 0, 0, time.UTC)
@@ -41,12 +47,35 @@ func (kb *ClientKeyboards) CreateDateKeyboard(currentDate time.Time) tgbotapi.In
 			(d.Year() == today.Year() && d.Month() == today.Month() && d.Day() < today.Day()) {
 			continue
 		}
+		dates = append(dates, d.Format("2006-01-02"))
+	}
+	return dates
+}
 
-		dateStr := d.Format("2006-01-02")
+// CreateDateKeyboard creates a keyboard for date selection.
+// Days in freeSlots (free slots by date) show how many slots are free, and those without any
+// are disabled; days missing from it, e.g. because they failed to load, can be picked as usual.
+func (kb *ClientKeyboards) CreateDateKeyboard(chatID int64, currentDate time.Time, freeSlots map[string]int) tgbotapi.InlineKeyboardMarkup {
+	var rows [][]tgbotapi.InlineKeyboardButton
+	var currentRow []tgbotapi.InlineKeyboardButton
+	today := time.Now()
+
+	for _, dateStr := range BookableDates(currentDate) {
+		d, _ := time.Parse("2006-01-02", dateStr)
 		button := tgbotapi.NewInlineKeyboardButtonData(
 			fmt.Sprintf("%d", d.Day()),
-			fmt.Sprintf("select_date_%s", dateStr),
+			kb.callback(chatID, common.CallbackPrefixSelectDate, dateStr),
 		)
+		if free, known := freeSlots[dateStr]; known {
+			if free > 0 {
+				button.Text = fmt.Sprintf(common.BtnDateWithFreeSlots, d.Day(), free)
+			} else {
+				button = tgbotapi.NewInlineKeyboardButtonData(
+					fmt.Sprintf(common.BtnDateFullyBooked, d.Day()),
+					common.CallbackDateFullyBooked,
+				)
+			}
+		}
 		currentRow = append(currentRow, button)
 
 		if len(currentRow) == common.DaysPerRow {
@@ -64,11 +93,11 @@ func (kb *ClientKeyboards) CreateDateKeyboard(currentDate time.Time) tgbotapi.In
 	var navButtons []tgbotapi.InlineKeyboardButton
 
 	if currentMonth != todayMonth {
-		prevButton := tgbotapi.NewInlineKeyboardButtonData(common.BtnPreviousMonth, "prev_month_"+currentMonth)
+		prevButton := tgbotapi.NewInlineKeyboardButtonData(common.BtnPreviousMonth, kb.callback(chatID, common.CallbackPrefixPrevMonth, currentMonth))
 		navButtons = append(navButtons, prevButton)
 	}
 
-	nextButton := tgbotapi.NewInlineKeyboardButtonData(common.BtnNextMonth, "next_month_"+currentMonth)
+	nextButton := tgbotapi.NewInlineKeyboardButtonData(common.BtnNextMonth, kb.callback(chatID, common.CallbackPrefixNextMonth, currentMonth))
 	navButtons = append(navButtons, nextButton)
 
 	rows = append(rows, tgbotapi.NewInlineKeyboardRow(navButtons...))
@@ -81,7 +110,7 @@ func (kb *ClientKeyboards) CreateDateKeyboard(currentDate time.Time) tgbotapi.In
 }
 
 // CreateTimeKeyboard creates a keyboard for time slot selection
-func (kb *ClientKeyboards) CreateTimeKeyboard(availability *schemas.ProfessionalAvailabilityResponse) tgbotapi.InlineKeyboardMarkup {
+func (kb *ClientKeyboards) CreateTimeKeyboard(chatID int64, availability *schemas.ProfessionalAvailabilityResponse) tgbotapi.InlineKeyboardMarkup {
 	var rows [][]tgbotapi.InlineKeyboardButton
 	var currentRow []tgbotapi.InlineKeyboardButton
 
@@ -102,7 +131,7 @@ func (kb *ClientKeyboards) CreateTimeKeyboard(availability *schemas.Professional
 		timeDisplay := localTime.Format("15:04")
 		button := tgbotapi.NewInlineKeyboardButtonData(
 			timeDisplay,
-			fmt.Sprintf("select_time_%s", timeDisplay),
+			kb.callback(chatID, common.CallbackPrefixSelectTime, timeDisplay),
 		)
 		currentRow = append(currentRow, button)
 
@@ -124,13 +153,13 @@ func (kb *ClientKeyboards) CreateTimeKeyboard(availability *schemas.Professional
 }
 
 // CreateProfessionalsKeyboard creates a keyboard for professional selection
-func (kb *ClientKeyboards) CreateProfessionalsKeyboard(professionals []models.User) tgbotapi.InlineKeyboardMarkup {
+func (kb *ClientKeyboards) CreateProfessionalsKeyboard(chatID int64, professionals []models.User) tgbotapi.InlineKeyboardMarkup {
 	var rows [][]tgbotapi.InlineKeyboardButton
 
 	for _, prof := range professionals {
 		button := tgbotapi.NewInlineKeyboardButtonData(
 			fmt.Sprintf("👨‍💼 %s %s", prof.FirstName, prof.LastName),
-			fmt.Sprintf("select_professional_%s", prof.ID),
+			kb.callback(chatID, common.CallbackPrefixSelectProfessional, prof.ID),
 		)
 		rows = append(rows, tgbotapi.NewInlineKeyboardRow(button))
 	}
@@ -143,13 +172,13 @@ func (kb *ClientKeyboards) CreateProfessionalsKeyboard(professionals []models.Us
 }
 
 // CreateAppointmentsKeyboard creates a keyboard for appointment management
-func (kb *ClientKeyboards) CreateAppointmentsKeyboard(appointments []schemas.ClientAppointment, buttonPrefix string) tgbotapi.InlineKeyboardMarkup {
+func (kb *ClientKeyboards) CreateAppointmentsKeyboard(chatID int64, appointments []schemas.ClientAppointment, buttonPrefix string) tgbotapi.InlineKeyboardMarkup {
 	var rows [][]tgbotapi.InlineKeyboardButton
 
 	for index, apt := range appointments {
 		button := tgbotapi.NewInlineKeyboardButtonData(
 			fmt.Sprintf(buttonPrefix, index+1),
-			fmt.Sprintf("cancel_appointment_%s", apt.ID),
+			kb.callback(chatID, common.CallbackPrefixCancelAppointment, apt.ID),
 		)
 		rows = append(rows, tgbotapi.NewInlineKeyboardRow(button))
 	}
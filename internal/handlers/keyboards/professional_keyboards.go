@@ -14,15 +14,31 @@ import (
 // ProfessionalKeyboards handles keyboard creation for professional-related operations
 type ProfessionalKeyboards struct {
 	logger *zerolog.Logger
+	codec  *common.CallbackCodec
 }
 
 // NewProfessionalKeyboards creates a new ProfessionalKeyboards instance
-func NewProfessionalKeyboards(logger *zerolog.Logger) *ProfessionalKeyboards {
+func NewProfessionalKeyboards(logger *zerolog.Logger, codec *common.CallbackCodec) *ProfessionalKeyboards {
 	return &ProfessionalKeyboards{
 		logger: logger,
+		codec:  codec,
 	}
 }
 
+// callback signs the callback data of a parameterised button
+func (kb *ProfessionalKeyboards) callback(chatID int64, prefix, param string) string {
+	return signedCallback(kb.codec, kb.logger, chatID, prefix, param)
+}
+
+// CreateSignInKeyboard creates the keyboard offering registration to professionals without an account
+func (kb *ProfessionalKeyboards) CreateSignInKeyboard() tgbotapi.InlineKeyboardMarkup {
+	return tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(common.BtnProfessionalSignUp, common.CallbackProfessionalSignUp),
+		),
+	)
+}
+
 // CreateProfessionalDashboardKeyboard creates the professional dashboard keyboard
 func (kb *ProfessionalKeyboards) CreateProfessionalDashboardKeyboard() tgbotapi.InlineKeyboardMarkup {
 	return tgbotapi.NewInlineKeyboardMarkup(
@@ -45,7 +61,7 @@ func (kb *ProfessionalKeyboards) CreateProfessionalDashboardKeyboard() tgbotapi.
 }
 
 // CreateProfessionalAppointmentsKeyboard creates a keyboard for professional appointment management
-func (kb *ProfessionalKeyboards) CreateProfessionalAppointmentsKeyboard(appointments []schemas.ProfessionalAppointment, showConfirm bool) tgbotapi.InlineKeyboardMarkup {
+func (kb *ProfessionalKeyboards) CreateProfessionalAppointmentsKeyboard(chatID int64, appointments []schemas.ProfessionalAppointment, showConfirm bool) tgbotapi.InlineKeyboardMarkup {
 	var rows [][]tgbotapi.InlineKeyboardButton
 
 	for index, apt := range appointments {
@@ -53,18 +69,18 @@ func (kb *ProfessionalKeyboards) CreateProfessionalAppointmentsKeyboard(appointm
 			// For pending appointments - show both confirm and cancel
 			confirmButton := tgbotapi.NewInlineKeyboardButtonData(
 				fmt.Sprintf(common.BtnConfirmAppointmentProf, index+1),
-				fmt.Sprintf("confirm_appointment_%s", apt.ID),
+				kb.callback(chatID, common.CallbackPrefixConfirmAppointment, apt.ID),
 			)
 			cancelButton := tgbotapi.NewInlineKeyboardButtonData(
 				fmt.Sprintf(common.BtnCancelAppointmentProf, index+1),
-				fmt.Sprintf("cancel_prof_appt_%s", apt.ID),
+				kb.callback(chatID, common.CallbackPrefixCancelProfAppt, apt.ID),
 			)
 			rows = append(rows, tgbotapi.NewInlineKeyboardRow(confirmButton, cancelButton))
 		} else {
 			// For upcoming appointments - show only cancel
 			cancelButton := tgbotapi.NewInlineKeyboardButtonData(
 				fmt.Sprintf(common.BtnCancelAppointmentProfAlt, index+1),
-				fmt.Sprintf("cancel_prof_appt_%s", apt.ID),
+				kb.callback(chatID, common.CallbackPrefixCancelProfAppt, apt.ID),
 			)
 			rows = append(rows, tgbotapi.NewInlineKeyboardRow(cancelButton))
 		}
@@ -78,7 +94,7 @@ func (kb *ProfessionalKeyboards) CreateProfessionalAppointmentsKeyboard(appointm
 }
 
 // CreateUnavailableDateKeyboard creates a keyboard for unavailable date selection
-func (kb *ProfessionalKeyboards) CreateUnavailableDateKeyboard(currentDate time.Time) tgbotapi.InlineKeyboardMarkup {
+func (kb *ProfessionalKeyboards) CreateUnavailableDateKeyboard(chatID int64, currentDate time.Time) tgbotapi.InlineKeyboardMarkup {
 	var rows [][]tgbotapi.InlineKeyboardButton
 	var currentRow []tgbotapi.InlineKeyboardButton
 
@@ -98,7 +114,7 @@ func (kb *ProfessionalKeyboards) CreateUnavailableDateKeyboard(currentDate time.
 		dateStr := d.Format("2006-01-02")
 		button := tgbotapi.NewInlineKeyboardButtonData(
 			fmt.Sprintf("%d", d.Day()),
-			fmt.Sprintf("select_unavailable_date_%s", dateStr),
+			kb.callback(chatID, common.CallbackPrefixSelectUnavailableDate, dateStr),
 		)
 		currentRow = append(currentRow, button)
 
@@ -119,11 +135,11 @@ func (kb *ProfessionalKeyboards) CreateUnavailableDateKeyboard(currentDate time.
 	var navButtons []tgbotapi.InlineKeyboardButton
 
 	if currentMonth != todayMonth {
-		prevButton := tgbotapi.NewInlineKeyboardButtonData(common.BtnPreviousUnavailableMonth, "prev_unavailable_month_"+currentMonth)
+		prevButton := tgbotapi.NewInlineKeyboardButtonData(common.BtnPreviousUnavailableMonth, kb.callback(chatID, common.CallbackPrefixPrevUnavailableMonth, currentMonth))
 		navButtons = append(navButtons, prevButton)
 	}
 
-	nextButton := tgbotapi.NewInlineKeyboardButtonData(common.BtnNextUnavailableMonth, "next_unavailable_month_"+currentMonth)
+	nextButton := tgbotapi.NewInlineKeyboardButtonData(common.BtnNextUnavailableMonth, kb.callback(chatID, common.CallbackPrefixNextUnavailableMonth, currentMonth))
 	navButtons = append(navButtons, nextButton)
 
 	rows = append(rows, tgbotapi.NewInlineKeyboardRow(navButtons...))
@@ -136,7 +152,7 @@ func (kb *ProfessionalKeyboards) CreateUnavailableDateKeyboard(currentDate time.
 }
 
 // CreateUnavailableStartTimeKeyboard creates a keyboard for unavailable start time selection
-func (kb *ProfessionalKeyboards) CreateUnavailableStartTimeKeyboard(availability *schemas.ProfessionalAvailabilityResponse) tgbotapi.InlineKeyboardMarkup {
+func (kb *ProfessionalKeyboards) CreateUnavailableStartTimeKeyboard(chatID int64, availability *schemas.ProfessionalAvailabilityResponse) tgbotapi.InlineKeyboardMarkup {
 	var rows [][]tgbotapi.InlineKeyboardButton
 	var currentRow []tgbotapi.InlineKeyboardButton
 
@@ -157,7 +173,7 @@ func (kb *ProfessionalKeyboards) CreateUnavailableStartTimeKeyboard(availability
 		timeDisplay := localTime.Format("15:04")
 		button := tgbotapi.NewInlineKeyboardButtonData(
 			timeDisplay,
-			fmt.Sprintf("select_unavailable_start_%s", timeDisplay),
+			kb.callback(chatID, common.CallbackPrefixSelectUnavailableStart, timeDisplay),
 		)
 		currentRow = append(currentRow, button)
 
@@ -179,7 +195,7 @@ func (kb *ProfessionalKeyboards) CreateUnavailableStartTimeKeyboard(availability
 }
 
 // CreateUnavailableEndTimeKeyboard creates a keyboard for unavailable end time selection
-func (kb *ProfessionalKeyboards) CreateUnavailableEndTimeKeyboard(startTime string, availability *schemas.ProfessionalAvailabilityResponse) tgbotapi.InlineKeyboardMarkup {
+func (kb *ProfessionalKeyboards) CreateUnavailableEndTimeKeyboard(chatID int64, startTime string, availability *schemas.ProfessionalAvailabilityResponse) tgbotapi.InlineKeyboardMarkup {
 	var rows [][]tgbotapi.InlineKeyboardButton
 	var currentRow []tgbotapi.InlineKeyboardButton
 
@@ -206,7 +222,7 @@ func (kb *ProfessionalKeyboards) CreateUnavailableEndTimeKeyboard(startTime stri
 			timeDisplay := slotEndLocal.Format("15:04")
 			button := tgbotapi.NewInlineKeyboardButtonData(
 				timeDisplay,
-				fmt.Sprintf("select_unavailable_end_%s", timeDisplay),
+				kb.callback(chatID, common.CallbackPrefixSelectUnavailableEnd, timeDisplay),
 			)
 			currentRow = append(currentRow, button)
 
@@ -232,7 +248,7 @@ func (kb *ProfessionalKeyboards) CreateUnavailableEndTimeKeyboard(startTime stri
 }
 
 // CreateUpcomingAppointmentsDateKeyboard creates a keyboard for upcoming appointments date selection
-func (kb *ProfessionalKeyboards) CreateUpcomingAppointmentsDateKeyboard(dates []string, currentMonth string) tgbotapi.InlineKeyboardMarkup {
+func (kb *ProfessionalKeyboards) CreateUpcomingAppointmentsDateKeyboard(chatID int64, dates []string, currentMonth string) tgbotapi.InlineKeyboardMarkup {
 	var rows [][]tgbotapi.InlineKeyboardButton
 	var currentRow []tgbotapi.InlineKeyboardButton
 
@@ -244,10 +260,10 @@ func (kb *ProfessionalKeyboards) CreateUpcomingAppointmentsDateKeyboard(dates []
 
 	var navButtons []tgbotapi.InlineKeyboardButton
 	if !isCurrentMonth {
-		prevButton := tgbotapi.NewInlineKeyboardButtonData(common.BtnPreviousMonth, "prev_upcoming_month_"+currentMonth)
+		prevButton := tgbotapi.NewInlineKeyboardButtonData(common.BtnPreviousMonth, kb.callback(chatID, common.CallbackPrefixPrevUpcomingMonth, currentMonth))
 		navButtons = append(navButtons, prevButton)
 	}
-	nextButton := tgbotapi.NewInlineKeyboardButtonData(common.BtnNextMonth, "next_upcoming_month_"+currentMonth)
+	nextButton := tgbotapi.NewInlineKeyboardButtonData(common.BtnNextMonth, kb.callback(chatID, common.CallbackPrefixNextUpcomingMonth, currentMonth))
 	navButtons = append(navButtons, nextButton)
 
 	if len(navButtons) > 0 {
@@ -271,7 +287,7 @@ func (kb *ProfessionalKeyboards) CreateUpcomingAppointmentsDateKeyboard(dates []
 
 		button := tgbotapi.NewInlineKeyboardButtonData(
 			displayText,
-			fmt.Sprintf("select_upcoming_date_%s", dateStr),
+			kb.callback(chatID, common.CallbackPrefixSelectUpcomingDate, dateStr),
 		)
 		currentRow = append(currentRow, button)
 
@@ -293,7 +309,7 @@ func (kb *ProfessionalKeyboards) CreateUpcomingAppointmentsDateKeyboard(dates []
 }
 
 // CreateTimetableKeyboard creates a keyboard for timetable with day navigation and appointment actions
-func (kb *ProfessionalKeyboards) CreateTimetableKeyboard(dateStr string, appointments []schemas.TimetableAppointment) tgbotapi.InlineKeyboardMarkup {
+func (kb *ProfessionalKeyboards) CreateTimetableKeyboard(chatID int64, dateStr string, appointments []schemas.TimetableAppointment) tgbotapi.InlineKeyboardMarkup {
 	var rows [][]tgbotapi.InlineKeyboardButton
 
 	// Add day navigation buttons
@@ -303,10 +319,10 @@ func (kb *ProfessionalKeyboards) CreateTimetableKeyboard(dateStr string, appoint
 
 	var navButtons []tgbotapi.InlineKeyboardButton
 	if !isToday {
-		prevButton := tgbotapi.NewInlineKeyboardButtonData(common.BtnPreviousTimetableDay, "prev_timetable_day_"+dateStr)
+		prevButton := tgbotapi.NewInlineKeyboardButtonData(common.BtnPreviousTimetableDay, kb.callback(chatID, common.CallbackPrefixPrevTimetableDay, dateStr))
 		navButtons = append(navButtons, prevButton)
 	}
-	nextButton := tgbotapi.NewInlineKeyboardButtonData(common.BtnNextTimetableDay, "next_timetable_day_"+dateStr)
+	nextButton := tgbotapi.NewInlineKeyboardButtonData(common.BtnNextTimetableDay, kb.callback(chatID, common.CallbackPrefixNextTimetableDay, dateStr))
 	navButtons = append(navButtons, nextButton)
 
 	if len(navButtons) > 0 {
@@ -317,7 +333,7 @@ func (kb *ProfessionalKeyboards) CreateTimetableKeyboard(dateStr string, appoint
 	for i, apt := range appointments {
 		cancelButton := tgbotapi.NewInlineKeyboardButtonData(
 			fmt.Sprintf(common.BtnCancelTimetableSlot, i+1),
-			fmt.Sprintf("cancel_appointment_%s", apt.ID),
+			kb.callback(chatID, common.CallbackPrefixCancelAppointment, apt.ID),
 		)
 		rows = append(rows, tgbotapi.NewInlineKeyboardRow(cancelButton))
 	}
@@ -330,14 +346,14 @@ func (kb *ProfessionalKeyboards) CreateTimetableKeyboard(dateStr string, appoint
 }
 
 // CreateClientsKeyboard creates a keyboard for client selection
-func CreateClientsKeyboard(clients []schemas.ProfessionalClient) tgbotapi.InlineKeyboardMarkup {
+func (kb *ProfessionalKeyboards) CreateClientsKeyboard(chatID int64, clients []schemas.ProfessionalClient) tgbotapi.InlineKeyboardMarkup {
 	var rows [][]tgbotapi.InlineKeyboardButton
 
 	// Add client buttons
 	for _, client := range clients {
 		btn := tgbotapi.NewInlineKeyboardButtonData(
 			fmt.Sprintf("%s %s", client.FirstName, client.LastName),
-			fmt.Sprintf("select_client_%s", client.ID),
+			kb.callback(chatID, common.CallbackPrefixSelectClient, client.ID),
 		)
 		rows = append(rows, tgbotapi.NewInlineKeyboardRow(btn))
 	}
@@ -350,7 +366,7 @@ func CreateClientsKeyboard(clients []schemas.ProfessionalClient) tgbotapi.Inline
 }
 
 // CreatePreviousAppointmentsNavigationKeyboard creates navigation keyboard for previous appointments
-func CreatePreviousAppointmentsNavigationKeyboard(currentMonth time.Time, hasAppointments bool) tgbotapi.InlineKeyboardMarkup {
+func (kb *ProfessionalKeyboards) CreatePreviousAppointmentsNavigationKeyboard(chatID int64, currentMonth time.Time, hasAppointments bool) tgbotapi.InlineKeyboardMarkup {
 	var rows [][]tgbotapi.InlineKeyboardButton
 
 	// Navigation buttons
@@ -360,7 +376,7 @@ func CreatePreviousAppointmentsNavigationKeyboard(currentMonth time.Time, hasApp
 	prevMonthStr := currentMonth.AddDate(0, -1, 0).Format("2006-01")
 	prevButton := tgbotapi.NewInlineKeyboardButtonData(
 		common.BtnPreviousMonth,
-		fmt.Sprintf("prev_previous_month_%s", prevMonthStr),
+		kb.callback(chatID, common.CallbackPrefixPrevPreviousMonth, prevMonthStr),
 	)
 	navButtons = append(navButtons, prevButton)
 
@@ -370,7 +386,7 @@ func CreatePreviousAppointmentsNavigationKeyboard(currentMonth time.Time, hasApp
 		nextMonthStr := nextMonth.Format("2006-01")
 		nextButton := tgbotapi.NewInlineKeyboardButtonData(
 			common.BtnNextMonth,
-			fmt.Sprintf("next_previous_month_%s", nextMonthStr),
+			kb.callback(chatID, common.CallbackPrefixNextPreviousMonth, nextMonthStr),
 		)
 		navButtons = append(navButtons, nextButton)
 	}
@@ -0,0 +1,146 @@
+package professional
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"errors"
+
+	"booking_client/internal/handlers/common"
+	"booking_client/internal/models"
+	apiService "booking_client/internal/services/api_service"
+)
+
+// HandleLogout signs the professional out of the chat, dropping the session and any flow in progress.
+// The chat is signed out even when the API can't be told.
+func (h *ProfessionalHandler) HandleLogout(ctx context.Context, chatID int64, messageID int) {
+	if err := h.apiService.SignOutProfessional(ctx, chatID); err != nil {
+		h.logger.Warn().Err(err).Int64("chat_id", chatID).Msg("Signed out locally, but the API did not unbind the chat")
+	}
+	h.sendMessage(chatID, common.UIMsgSignedOut)
+}
+
+// RevokeSession signs a chat out on behalf of an administrator and tells the chat about it.
+// The chat is signed out even when the API can't be told; the error says so.
+func (h *ProfessionalHandler) RevokeSession(ctx context.Context, chatID int64) error {
+	err := h.apiService.SignOutProfessional(ctx, chatID)
+	h.sendMessage(chatID, common.UIMsgSessionRevoked)
+	return err
+}
+
+// StartChangePassword starts the password change, asking for the current password first
+func (h *ProfessionalHandler) StartChangePassword(ctx context.Context, chatID int64, messageID int) {
+	session, ok := common.GetSessionOrSendError(h.apiService.GetUserRepository(), h.bot, h.logger, chatID)
+	if !ok {
+		return
+	}
+	if !h.startFlow(chatID, session, models.FlowChangePassword) {
+		return
+	}
+	session.TrackMessage(messageID)
+	h.apiService.GetUserRepository().SetSession(chatID, session)
+
+	h.sendMessage(chatID, common.UIMsgChangePassword)
+}
+
+// HandleCurrentPasswordInput verifies the current password; a wrong password ends the flow
+func (h *ProfessionalHandler) HandleCurrentPasswordInput(ctx context.Context, chatID int64, password string, messageID int) {
+	session, ok := common.GetSessionOrSendError(h.apiService.GetUserRepository(), h.bot, h.logger, chatID)
+	if !ok {
+		return
+	}
+
+	username := session.Profile.Username
+	if until, locked := h.lockout.LockedUntil(chatID, username); locked {
+		session.Conversation.Clear()
+		h.apiService.GetUserRepository().SetSession(chatID, session)
+		h.sendLocked(chatID, common.ErrorMsgSignInLocked, until)
+		return
+	}
+
+	req := &apiService.VerifyPasswordRequest{ChatID: chatID, Password: password}
+	err := h.apiService.VerifyProfessionalPassword(ctx, req)
+	if err != nil && !errors.Is(err, apiService.ErrUnauthorized) {
+		h.sendError(ctx, chatID, common.ErrorMsgFailedToChangePassword, err)
+		return
+	}
+	if err != nil {
+		h.logger.Warn().Err(err).Int64("chat_id", chatID).Msg("Password change with a wrong current password")
+		session.Conversation.Clear()
+		h.apiService.GetUserRepository().SetSession(chatID, session)
+		h.sendMessage(chatID, common.ErrorMsgWrongPassword)
+		h.failSignIn(chatID, username)
+		return
+	}
+	h.lockout.Succeed(chatID, username)
+
+	if !h.transition(chatID, session, models.StateWaitingForChangedPassword) {
+		return
+	}
+	session.TrackMessage(messageID)
+	h.apiService.GetUserRepository().SetSession(chatID, session)
+
+	h.sendMessage(chatID, common.SuccessMsgPasswordVerified)
+}
+
+// HandleChangedPasswordInput handles the new password, keeping only its hash until it is confirmed
+func (h *ProfessionalHandler) HandleChangedPasswordInput(ctx context.Context, chatID int64, password string, messageID int) {
+	session, ok := common.GetSessionOrSendError(h.apiService.GetUserRepository(), h.bot, h.logger, chatID)
+	if !ok {
+		return
+	}
+	if len([]rune(password)) < minPasswordLength {
+		h.sendMessage(chatID, common.ErrorMsgPasswordTooShort)
+		return
+	}
+
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		h.sendError(ctx, chatID, common.ErrorMsgFailedToChangePassword, err)
+		return
+	}
+	change := session.Conversation.ChangePassword
+	change.PasswordSalt = salt
+	change.PasswordHash = hashPassword(salt, password)
+	if !h.transition(chatID, session, models.StateWaitingForChangedPasswordConfirmation) {
+		return
+	}
+	session.TrackMessage(messageID)
+	h.apiService.GetUserRepository().SetSession(chatID, session)
+
+	h.sendMessage(chatID, common.UIMsgConfirmPassword)
+}
+
+// HandleChangedPasswordConfirmation changes the password once confirmed; a mismatch asks for it again
+func (h *ProfessionalHandler) HandleChangedPasswordConfirmation(ctx context.Context, chatID int64, password string, messageID int) {
+	session, ok := common.GetSessionOrSendError(h.apiService.GetUserRepository(), h.bot, h.logger, chatID)
+	if !ok {
+		return
+	}
+	change := session.Conversation.ChangePassword
+
+	if !hmac.Equal(hashPassword(change.PasswordSalt, password), change.PasswordHash) {
+		change.PasswordSalt = nil
+		change.PasswordHash = nil
+		if !h.transition(chatID, session, models.StateWaitingForChangedPassword) {
+			return
+		}
+		session.TrackMessage(messageID)
+		h.apiService.GetUserRepository().SetSession(chatID, session)
+		h.sendMessage(chatID, common.ErrorMsgPasswordMismatch)
+		return
+	}
+
+	session.Conversation.Clear()
+	session.TrackMessage(messageID)
+	h.apiService.GetUserRepository().SetSession(chatID, session)
+
+	req := &apiService.ChangePasswordRequest{ChatID: chatID, NewPassword: password}
+	if err := h.apiService.ChangeProfessionalPassword(ctx, req); err != nil {
+		h.sendError(ctx, chatID, common.ErrorMsgFailedToChangePassword, err)
+		return
+	}
+
+	h.sendMessage(chatID, common.SuccessMsgPasswordChanged)
+	h.ShowDashboard(ctx, chatID, 0)
+}
@@ -4,22 +4,27 @@ import (
 	"booking_client/internal/handlers/common"
 	apiService "booking_client/internal/services/api_service"
 	"context"
+	"errors"
 )
 
 // HandleConfirmAppointment handles professional appointment confirmation
 func (h *ProfessionalHandler) HandleConfirmAppointment(ctx context.Context, chatID int64, appointmentID string, messageID int) {
-	user, ok := common.GetUserOrSendError(h.apiService.GetUserRepository(), h.bot, h.logger, chatID)
+	session, ok := common.GetSessionOrSendError(h.apiService.GetUserRepository(), h.bot, h.logger, chatID)
 	if !ok {
 		return
 	}
-	user.LastMessageID = &messageID
-	user.MessagesToDelete = append(user.MessagesToDelete, &messageID)
-	h.apiService.GetUserRepository().SetUser(chatID, user)
+	session.TrackMessage(messageID)
+	h.apiService.GetUserRepository().SetSession(chatID, session)
 
 	// Confirm the appointment
 	req := &apiService.ConfirmAppointmentRequest{}
 
-	response, err := h.apiService.ConfirmProfessionalAppointment(ctx, user.ID, appointmentID, req)
+	response, err := h.apiService.ConfirmProfessionalAppointment(ctx, session.Profile.ID, appointmentID, req)
+	if errors.Is(err, apiService.ErrNotFound) || errors.Is(err, apiService.ErrConflict) {
+		h.sendMessage(chatID, common.ErrorMsgAppointmentNoLongerActive)
+		h.ShowDashboard(ctx, chatID, 0)
+		return
+	}
 	if err != nil {
 		h.sendError(ctx, chatID, common.ErrorMsgFailedToConfirmAppointment, err)
 		return
@@ -37,9 +42,9 @@ func (h *ProfessionalHandler) HandleConfirmAppointment(ctx context.Context, chat
 
 	err = h.bot.SendMessage(chatID, text)
 	if err == nil {
-		h.apiService.GetUserRepository().SetUser(chatID, user)
+		h.apiService.GetUserRepository().SetSession(chatID, session)
 	}
-	h.ShowDashboard(ctx, chatID, user, 0)
+	h.ShowDashboard(ctx, chatID, 0)
 
 	// Notify client about confirmation
 	h.notificationService.NotifyClientAppointmentConfirmation(response)
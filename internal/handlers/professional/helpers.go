@@ -2,10 +2,13 @@ package professional
 
 import (
 	"booking_client/internal/common"
+	handlersCommon "booking_client/internal/handlers/common"
 	"booking_client/internal/models"
 	"booking_client/internal/schemas"
+	apiService "booking_client/internal/services/api_service"
 	"context"
 	"fmt"
+	"strings"
 	"time"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
@@ -15,9 +18,26 @@ import (
 
 // sendError sends an error message to the user (ProfessionalHandler version)
 func (h *ProfessionalHandler) sendError(ctx context.Context, chatID int64, message string, err error) {
-	text := fmt.Sprintf(message, err.Error())
+	logger := common.GetLogger(ctx)
+	if err != nil {
+		logger.Error().Err(err).Int64("chat_id", chatID).Msg("Request failed")
+	}
+
+	// The raw error goes to the logs only; users see a description of its kind
+	text := message
+	if strings.Contains(message, "%s") {
+		text = fmt.Sprintf(message, apiService.FormatErrorForUser(err))
+	}
+
+	// Outages get a button to try again: the pressed button once more, or /start after typed input
+	retryData, _ := common.GetRetryCallback(ctx)
+	if keyboard, ok := handlersCommon.RetryKeyboard(err, retryData); ok {
+		if err := h.bot.SendMessageWithKeyboard(chatID, text, keyboard); err != nil {
+			logger.Error().Err(err).Msg("Failed to send error message")
+		}
+		return
+	}
 	if err := h.bot.SendMessage(chatID, text); err != nil {
-		logger := common.GetLogger(ctx)
 		logger.Error().Err(err).Msg("Failed to send error message")
 	}
 }
@@ -60,33 +80,32 @@ func (h *ProfessionalHandler) editMessageWithKeyboard(chatID int64, messageID in
 	}
 }
 
-// validateUserState checks if user is in a valid state for the given action (ProfessionalHandler version)
-func (h *ProfessionalHandler) validateUserState(ctx context.Context, chatID int64, allowedStates []string) (*models.User, bool) {
-	user, ok := common.GetUserOrSendError(h.apiService.GetUserRepository(), h.bot, common.GetLogger(ctx), chatID)
-	if !ok {
-		return nil, false
+// sessionOrNew returns the chat's session, or a new one for chats without a session
+func (h *ProfessionalHandler) sessionOrNew(chatID int64) *models.Session {
+	if session, exists := h.apiService.GetUserRepository().GetSession(chatID); exists && session != nil {
+		return session
 	}
+	return models.NewSession(chatID)
+}
 
-	// Check if user is in an allowed state
-	for _, allowedState := range allowedStates {
-		if user.State == allowedState {
-			return user, true
-		}
+// startFlow starts a conversation flow, telling the user if that fails
+func (h *ProfessionalHandler) startFlow(chatID int64, session *models.Session, flow string) bool {
+	if err := h.machine.Start(session, flow); err != nil {
+		h.logger.Error().Err(err).Int64("chat_id", chatID).Msg("Failed to start conversation flow")
+		h.sendMessage(chatID, common.ErrorMsgInvalidState)
+		return false
 	}
-
-	// User is not in an allowed state
-	h.sendMessage(chatID, common.ErrorMsgInvalidState)
-	return nil, false
+	return true
 }
 
-// clearUnavailableState clears all unavailable-related state from user
-func (h *ProfessionalHandler) clearUnavailableState(user *models.User) {
-	user.State = models.StateNone
-	user.SelectedDate = ""
-	user.SelectedUnavailableStartTime = ""
-	user.SelectedUnavailableEndTime = ""
-	user.SelectedUnavailableDescription = ""
-	user.SelectedAppointmentID = ""
+// transition moves the session to the next state of its flow, telling the user if that is not allowed
+func (h *ProfessionalHandler) transition(chatID int64, session *models.Session, state string) bool {
+	if err := h.machine.Transition(session, state); err != nil {
+		h.logger.Error().Err(err).Int64("chat_id", chatID).Msg("Invalid conversation transition")
+		h.sendMessage(chatID, common.ErrorMsgInvalidState)
+		return false
+	}
+	return true
 }
 
 // createProfessionalDashboardKeyboard creates the professional dashboard keyboard
@@ -95,26 +114,26 @@ func (h *ProfessionalHandler) createProfessionalDashboardKeyboard() tgbotapi.Inl
 	return h.keyboards.CreateProfessionalDashboardKeyboard()
 }
 
-func (h *ProfessionalHandler) createProfessionalAppointmentsKeyboard(appointments []schemas.ProfessionalAppointment, showConfirm bool) tgbotapi.InlineKeyboardMarkup {
-	return h.keyboards.CreateProfessionalAppointmentsKeyboard(appointments, showConfirm)
+func (h *ProfessionalHandler) createProfessionalAppointmentsKeyboard(chatID int64, appointments []schemas.ProfessionalAppointment, showConfirm bool) tgbotapi.InlineKeyboardMarkup {
+	return h.keyboards.CreateProfessionalAppointmentsKeyboard(chatID, appointments, showConfirm)
 }
 
-func (h *ProfessionalHandler) createUnavailableDateKeyboard(currentDate time.Time) tgbotapi.InlineKeyboardMarkup {
-	return h.keyboards.CreateUnavailableDateKeyboard(currentDate)
+func (h *ProfessionalHandler) createUnavailableDateKeyboard(chatID int64, currentDate time.Time) tgbotapi.InlineKeyboardMarkup {
+	return h.keyboards.CreateUnavailableDateKeyboard(chatID, currentDate)
 }
 
-func (h *ProfessionalHandler) createUnavailableStartTimeKeyboard(availability *schemas.ProfessionalAvailabilityResponse) tgbotapi.InlineKeyboardMarkup {
-	return h.keyboards.CreateUnavailableStartTimeKeyboard(availability)
+func (h *ProfessionalHandler) createUnavailableStartTimeKeyboard(chatID int64, availability *schemas.ProfessionalAvailabilityResponse) tgbotapi.InlineKeyboardMarkup {
+	return h.keyboards.CreateUnavailableStartTimeKeyboard(chatID, availability)
 }
 
-func (h *ProfessionalHandler) createUnavailableEndTimeKeyboard(startTime string, availability *schemas.ProfessionalAvailabilityResponse) tgbotapi.InlineKeyboardMarkup {
-	return h.keyboards.CreateUnavailableEndTimeKeyboard(startTime, availability)
+func (h *ProfessionalHandler) createUnavailableEndTimeKeyboard(chatID int64, startTime string, availability *schemas.ProfessionalAvailabilityResponse) tgbotapi.InlineKeyboardMarkup {
+	return h.keyboards.CreateUnavailableEndTimeKeyboard(chatID, startTime, availability)
 }
 
-func (h *ProfessionalHandler) createUpcomingAppointmentsDateKeyboard(dates []string, currentMonth string) tgbotapi.InlineKeyboardMarkup {
-	return h.keyboards.CreateUpcomingAppointmentsDateKeyboard(dates, currentMonth)
+func (h *ProfessionalHandler) createUpcomingAppointmentsDateKeyboard(chatID int64, dates []string, currentMonth string) tgbotapi.InlineKeyboardMarkup {
+	return h.keyboards.CreateUpcomingAppointmentsDateKeyboard(chatID, dates, currentMonth)
 }
 
-func (h *ProfessionalHandler) createTimetableKeyboard(dateStr string, appointments []schemas.TimetableAppointment) tgbotapi.InlineKeyboardMarkup {
-	return h.keyboards.CreateTimetableKeyboard(dateStr, appointments)
+func (h *ProfessionalHandler) createTimetableKeyboard(chatID int64, dateStr string, appointments []schemas.TimetableAppointment) tgbotapi.InlineKeyboardMarkup {
+	return h.keyboards.CreateTimetableKeyboard(chatID, dateStr, appointments)
 }
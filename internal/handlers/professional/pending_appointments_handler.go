@@ -9,7 +9,7 @@ import (
 
 // HandlePendingAppointments shows pending appointments for professionals
 func (h *ProfessionalHandler) HandlePendingAppointments(ctx context.Context, chatID int64, messageID int) {
-	user, ok := common.GetUserOrSendError(h.apiService.GetUserRepository(), h.bot, h.logger, chatID)
+	session, ok := common.GetSessionOrSendError(h.apiService.GetUserRepository(), h.bot, h.logger, chatID)
 	if !ok {
 		return
 	}
@@ -20,7 +20,7 @@ func (h *ProfessionalHandler) HandlePendingAppointments(ctx context.Context, cha
 		h.bot.DeleteMessage(chatID, messageID)
 	}()
 
-	appointments, err := h.apiService.GetProfessionalAppointments(ctx, user.ID, "pending")
+	appointments, err := h.apiService.GetProfessionalAppointments(ctx, session.Profile.ID, "pending")
 	if err != nil {
 		h.sendError(ctx, chatID, common.ErrorMsgFailedToLoadPendingAppointments, err)
 		return
@@ -32,10 +32,9 @@ func (h *ProfessionalHandler) HandlePendingAppointments(ctx context.Context, cha
 			h.sendError(ctx, chatID, common.ErrorMsgFailedToSendMessage, err)
 			return
 		}
-		user.LastMessageID = &id
-		user.MessagesToDelete = append(user.MessagesToDelete, &id)
-		h.apiService.GetUserRepository().SetUser(chatID, user)
-		h.ShowDashboard(ctx, chatID, user, 0)
+		session.TrackMessage(id)
+		h.apiService.GetUserRepository().SetSession(chatID, session)
+		h.ShowDashboard(ctx, chatID, 0)
 		return
 	}
 
@@ -44,6 +43,6 @@ func (h *ProfessionalHandler) HandlePendingAppointments(ctx context.Context, cha
 		text += common.FormatProfessionalAppointmentDetails(&apt, index)
 	}
 
-	keyboard := h.createProfessionalAppointmentsKeyboard(appointments.Appointments, true)
+	keyboard := h.createProfessionalAppointmentsKeyboard(chatID, appointments.Appointments, true)
 	h.sendMessageWithKeyboard(chatID, text, keyboard)
 }
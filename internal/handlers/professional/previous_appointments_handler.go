@@ -2,7 +2,7 @@ package professional
 
 import (
 	"booking_client/internal/handlers/common"
-	"booking_client/internal/handlers/keyboards"
+	"booking_client/internal/models"
 	"context"
 	"fmt"
 	"time"
@@ -10,7 +10,7 @@ import (
 
 // HandlePreviousAppointments shows the list of clients for the professional
 func (h *ProfessionalHandler) HandlePreviousAppointments(ctx context.Context, chatID int64, messageID int) {
-	user, ok := common.GetUserOrSendError(h.apiService.GetUserRepository(), h.bot, h.logger, chatID)
+	session, ok := common.GetSessionOrSendError(h.apiService.GetUserRepository(), h.bot, h.logger, chatID)
 	if !ok {
 		return
 	}
@@ -22,7 +22,7 @@ func (h *ProfessionalHandler) HandlePreviousAppointments(ctx context.Context, ch
 	}()
 
 	// Get clients for this professional
-	clients, err := h.apiService.GetProfessionalClients(ctx, user.ID)
+	clients, err := h.apiService.GetProfessionalClients(ctx, session.Profile.ID)
 	if err != nil {
 		h.sendError(ctx, chatID, common.ErrorMsgFailedToRetrieveClients, err)
 		return
@@ -34,7 +34,7 @@ func (h *ProfessionalHandler) HandlePreviousAppointments(ctx context.Context, ch
 	}
 
 	// Create clients keyboard
-	keyboard := keyboards.CreateClientsKeyboard(clients)
+	keyboard := h.keyboards.CreateClientsKeyboard(chatID, clients)
 
 	text := "👥 Select a client to view their previous appointments:"
 
@@ -43,20 +43,22 @@ func (h *ProfessionalHandler) HandlePreviousAppointments(ctx context.Context, ch
 
 // HandleClientSelection handles when a client is selected
 func (h *ProfessionalHandler) HandleClientSelection(ctx context.Context, chatID int64, clientID string, messageID int) {
-	user, ok := common.GetUserOrSendError(h.apiService.GetUserRepository(), h.bot, h.logger, chatID)
+	session, ok := common.GetSessionOrSendError(h.apiService.GetUserRepository(), h.bot, h.logger, chatID)
 	if !ok {
 		return
 	}
-	user.LastMessageID = &messageID
-	user.MessagesToDelete = append(user.MessagesToDelete, &messageID)
+	session.TrackMessage(messageID)
 
-	// Store selected client ID in user state
-	user.SelectedClientID = &clientID
-	h.apiService.GetUserRepository().SetUser(chatID, user)
+	// Store selected client ID in the conversation
+	if !h.startFlow(chatID, session, models.FlowPreviousAppointments) {
+		return
+	}
+	session.Conversation.PreviousAppointments.ClientID = clientID
+	h.apiService.GetUserRepository().SetSession(chatID, session)
 
 	// Show appointments for current month
 	currentMonth := time.Now()
-	h.showAppointmentsForMonth(ctx, chatID, user.ID, clientID, currentMonth, messageID)
+	h.showAppointmentsForMonth(ctx, chatID, session.Profile.ID, clientID, currentMonth, messageID)
 }
 
 // HandlePreviousMonthNavigation handles month navigation for previous appointments
@@ -70,16 +72,16 @@ func (h *ProfessionalHandler) HandlePreviousAppointmentsMonthNavigation(ctx cont
 	h.bot.DeleteMessage(chatID, messageID)
 
 	// Get professional ID and client ID from user
-	user, ok := common.GetUserOrSendError(h.apiService.GetUserRepository(), h.bot, h.logger, chatID)
-	if !ok || user.SelectedClientID == nil {
+	session, ok := common.GetSessionOrSendError(h.apiService.GetUserRepository(), h.bot, h.logger, chatID)
+	if !ok || session.Conversation.PreviousAppointments == nil {
 		h.sendError(ctx, chatID, "User session or selected client not found.", nil)
 		return
 	}
-	user.MessagesToDelete = append(user.MessagesToDelete, &messageID)
-	h.apiService.GetUserRepository().SetUser(chatID, user)
+	session.TrackMessage(messageID)
+	h.apiService.GetUserRepository().SetSession(chatID, session)
 
-	professionalID := user.ID
-	clientID := *user.SelectedClientID
+	professionalID := session.Profile.ID
+	clientID := session.Conversation.PreviousAppointments.ClientID
 
 	// Use the month directly (it's already the correct target month from callback)
 	h.showAppointmentsForMonth(ctx, chatID, professionalID, clientID, month, messageID)
@@ -95,7 +97,7 @@ func (h *ProfessionalHandler) showAppointmentsForMonth(ctx context.Context, chat
 	}
 
 	// Create navigation keyboard
-	keyboard := keyboards.CreatePreviousAppointmentsNavigationKeyboard(month, len(appointments) > 0)
+	keyboard := h.keyboards.CreatePreviousAppointmentsNavigationKeyboard(chatID, month, len(appointments) > 0)
 
 	// Format appointments text
 	text := fmt.Sprintf("📅 Previous appointments for %s %s:\n\n", month.Format("January"), month.Format("2006"))
@@ -5,8 +5,8 @@ import (
 	"time"
 
 	"booking_client/internal/handlers/common"
+	"booking_client/internal/handlers/fsm"
 	"booking_client/internal/handlers/keyboards"
-	"booking_client/internal/models"
 	apiService "booking_client/internal/services/api_service"
 	"booking_client/pkg/telegram"
 	"fmt"
@@ -16,35 +16,41 @@ import (
 
 // ProfessionalHandler handles all professional-related operations
 type ProfessionalHandler struct {
-	bot                 *telegram.Bot
+	bot                 telegram.Messenger
 	logger              *zerolog.Logger
 	apiService          *apiService.APIService
 	notificationService *common.NotificationService
 	keyboards           *keyboards.ProfessionalKeyboards
+	machine             *fsm.Machine
+	inviteCode          string // Required to register as a professional, empty for open registration
+	lockout             *SignInLockout
 }
 
 // NewProfessionalHandler creates a new professional handler
-func NewProfessionalHandler(bot *telegram.Bot, logger *zerolog.Logger, apiService *apiService.APIService) *ProfessionalHandler {
+func NewProfessionalHandler(bot telegram.Messenger, logger *zerolog.Logger, apiService *apiService.APIService, machine *fsm.Machine, codec *common.CallbackCodec, inviteCode string, lockout *SignInLockout) *ProfessionalHandler {
 	return &ProfessionalHandler{
 		bot:                 bot,
 		logger:              logger,
 		apiService:          apiService,
-		notificationService: common.NewNotificationService(bot, logger, apiService),
-		keyboards:           keyboards.NewProfessionalKeyboards(logger),
+		notificationService: common.NewNotificationService(bot, logger, apiService, codec),
+		keyboards:           keyboards.NewProfessionalKeyboards(logger, codec),
+		machine:             machine,
+		inviteCode:          inviteCode,
+		lockout:             lockout,
 	}
 }
 
 // ShowDashboard shows the professional dashboard with appointment options
-func (h *ProfessionalHandler) ShowDashboard(ctx context.Context, chatID int64, user *models.User, messageID int) {
-	currentUser, ok := common.GetUserOrSendError(h.apiService.GetUserRepository(), h.bot, h.logger, chatID)
+func (h *ProfessionalHandler) ShowDashboard(ctx context.Context, chatID int64, messageID int) {
+	session, ok := common.GetSessionOrSendError(h.apiService.GetUserRepository(), h.bot, h.logger, chatID)
 	if !ok {
 		return
 	}
-	currentUser.LastMessageID = &messageID
-	currentUser.MessagesToDelete = append(currentUser.MessagesToDelete, &messageID)
-	currentUser.State = models.StateNone
-	messageIDs := append([]*int{}, user.MessagesToDelete...)
-	h.apiService.GetUserRepository().SetUser(chatID, currentUser)
+	session.TrackMessage(messageID)
+	session.Conversation.Clear()
+	messageIDs := append([]*int{}, session.MessagesToDelete...)
+	session.MessagesToDelete = nil
+	h.apiService.GetUserRepository().SetSession(chatID, session)
 
 	go func() {
 		time.Sleep(3 * time.Second)
@@ -55,7 +61,7 @@ func (h *ProfessionalHandler) ShowDashboard(ctx context.Context, chatID int64, u
 		}
 	}()
 
-	text := fmt.Sprintf(common.UIMsgWelcomeBackProfessional, currentUser.LastName, currentUser.Role)
+	text := fmt.Sprintf(common.UIMsgWelcomeBackProfessional, session.Profile.LastName, session.Profile.Role)
 	keyboard := h.createProfessionalDashboardKeyboard()
 
 	h.sendMessageWithKeyboard(chatID, text, keyboard)
@@ -2,6 +2,10 @@ package professional
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"math"
+	"time"
 
 	"booking_client/internal/handlers/common"
 	"booking_client/internal/models"
@@ -10,60 +14,74 @@ import (
 
 // StartSignIn starts the professional sign-in process
 func (h *ProfessionalHandler) StartSignIn(ctx context.Context, chatID int64, messageID int) {
-	// Create a temporary user with state
-	tempUser := &models.User{
-		ChatID: &chatID,
-		Role:   "professional",
-		State:  models.StateWaitingForUsername,
+	// Start the sign-in flow, keeping the profile and referral of a chat that has a session
+	session := h.sessionOrNew(chatID)
+	if !h.startFlow(chatID, session, models.FlowSignIn) {
+		return
 	}
 
 	// Store in memory for state tracking
-	h.apiService.GetUserRepository().SetUser(chatID, tempUser)
+	h.apiService.GetUserRepository().SetSession(chatID, session)
 
-	h.sendMessage(chatID, common.UIMsgProfessionalSignIn)
+	h.sendMessageWithKeyboard(chatID, common.UIMsgProfessionalSignIn, h.keyboards.CreateSignInKeyboard())
 }
 
 // HandleUsernameInput handles username input for professional sign-in
 func (h *ProfessionalHandler) HandleUsernameInput(ctx context.Context, chatID int64, username string, messageID int) {
-	user, ok := common.GetUserOrSendError(h.apiService.GetUserRepository(), h.bot, h.logger, chatID)
+	session, ok := common.GetSessionOrSendError(h.apiService.GetUserRepository(), h.bot, h.logger, chatID)
 	if !ok {
 		return
 	}
-	user.Username = username
-	user.State = models.StateWaitingForPassword
-	user.LastMessageID = &messageID
-	user.MessagesToDelete = append(user.MessagesToDelete, &messageID)
-	h.apiService.GetUserRepository().SetUser(chatID, user)
+	session.Conversation.SignIn.Username = username
+	if !h.transition(chatID, session, models.StateWaitingForPassword) {
+		return
+	}
+	session.TrackMessage(messageID)
+	h.apiService.GetUserRepository().SetSession(chatID, session)
 
 	h.sendMessage(chatID, common.SuccessMsgUsernameSaved)
 }
 
 // HandlePasswordInput handles password input for professional sign-in
 func (h *ProfessionalHandler) HandlePasswordInput(ctx context.Context, chatID int64, password string, messageID int) {
-	user, ok := common.GetUserOrSendError(h.apiService.GetUserRepository(), h.bot, h.logger, chatID)
+	session, ok := common.GetSessionOrSendError(h.apiService.GetUserRepository(), h.bot, h.logger, chatID)
 	if !ok {
 		return
 	}
 
+	username := session.Conversation.SignIn.Username
+	if until, locked := h.lockout.LockedUntil(chatID, username); locked {
+		h.machine.Drop(chatID, session)
+		h.sendLocked(chatID, common.ErrorMsgSignInLocked, until)
+		return
+	}
+
 	// Sign in the professional
 	req := &apiService.ProfessionalSignInRequest{
-		Username: user.Username,
+		Username: username,
 		Password: password,
 		ChatID:   chatID,
 	}
 
 	signedInUser, err := h.apiService.SignInProfessional(ctx, req)
+	if errors.Is(err, apiService.ErrUnauthorized) || errors.Is(err, apiService.ErrNotFound) {
+		h.machine.Drop(chatID, session)
+		h.sendMessage(chatID, common.ErrorMsgWrongCredentials)
+		h.failSignIn(chatID, username)
+		return
+	}
 	if err != nil {
-		h.apiService.GetUserRepository().DeleteUser(chatID)
+		h.machine.Drop(chatID, session)
 		h.sendError(ctx, chatID, common.ErrorMsgSignInFailed, err)
 		return
 	}
+	h.lockout.Succeed(chatID, username)
 
-	// Clear state
-	user.State = models.StateNone
-	user.LastMessageID = &messageID
-	user.MessagesToDelete = append(user.MessagesToDelete, &messageID)
-	h.apiService.GetUserRepository().SetUser(chatID, signedInUser)
+	// Clear state and keep the signed-in profile
+	session.SetProfile(*signedInUser, time.Now())
+	session.Conversation.Clear()
+	session.TrackMessage(messageID)
+	h.apiService.GetUserRepository().SetSession(chatID, session)
 
 	// Build success message
 	text := common.NewSuccessMessage("sign_in_success").
@@ -75,5 +93,43 @@ func (h *ProfessionalHandler) HandlePasswordInput(ctx context.Context, chatID in
 		Build()
 
 	h.sendMessage(chatID, text)
-	h.ShowDashboard(ctx, chatID, signedInUser, 0)
+	h.ShowDashboard(ctx, chatID, 0)
+}
+
+// failSignIn records a failed password check, telling the user when it locks them out
+func (h *ProfessionalHandler) failSignIn(chatID int64, username string) {
+	if until, locked := h.lockout.Fail(chatID, username); locked {
+		h.logger.Warn().Int64("chat_id", chatID).Time("locked_until", until).Msg("Sign-in locked after repeated failures")
+		h.sendLocked(chatID, common.ErrorMsgSignInLocked, until)
+	}
+}
+
+// sendLocked tells the user how long the lockout lasts; format takes the time left as text
+func (h *ProfessionalHandler) sendLocked(chatID int64, format string, until time.Time) {
+	h.sendMessage(chatID, fmt.Sprintf(format, formatWait(time.Until(until))))
+}
+
+// formatWait spells out a wait rounded up to whole minutes, e.g. "1 minute" or "1 hour 30 minutes"
+func formatWait(wait time.Duration) string {
+	minutes := int(math.Ceil(wait.Minutes()))
+	if minutes < 1 {
+		minutes = 1
+	}
+	hours, minutes := minutes/60, minutes%60
+	switch {
+	case hours == 0:
+		return countOf(minutes, "minute")
+	case minutes == 0:
+		return countOf(hours, "hour")
+	default:
+		return countOf(hours, "hour") + " " + countOf(minutes, "minute")
+	}
+}
+
+// countOf returns the count followed by the unit, plural unless the count is one
+func countOf(count int, unit string) string {
+	if count == 1 {
+		return "1 " + unit
+	}
+	return fmt.Sprintf("%d %ss", count, unit)
 }
@@ -0,0 +1,128 @@
+package professional
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// signInFailures counts the failed sign-in attempts of one chat or username
+type signInFailures struct {
+	count       int
+	lastFailure time.Time
+	lockedUntil time.Time
+}
+
+// SignInLockout blocks password checks after repeated failures. Each chat has its own
+// limit, so one chat guessing a username doesn't lock the account out of other chats;
+// a higher limit per username slows down guessing spread over many chats.
+type SignInLockout struct {
+	mu                  sync.Mutex
+	maxAttempts         int
+	maxUsernameAttempts int
+	duration            time.Duration
+	failures            map[string]*signInFailures
+	now                 func() time.Time
+}
+
+// NewSignInLockout creates a lockout allowing maxAttempts failures per chat and
+// maxUsernameAttempts per username across chats, forgotten after duration without
+// failures; a lock lasts duration too. Zero maxAttempts or duration disables it,
+// zero maxUsernameAttempts only the limit per username.
+func NewSignInLockout(maxAttempts, maxUsernameAttempts int, duration time.Duration) *SignInLockout {
+	return &SignInLockout{
+		maxAttempts:         maxAttempts,
+		maxUsernameAttempts: maxUsernameAttempts,
+		duration:            duration,
+		failures:            make(map[string]*signInFailures),
+		now:                 time.Now,
+	}
+}
+
+// LockedUntil reports whether the chat or the username is locked, and until when
+func (l *SignInLockout) LockedUntil(chatID int64, username string) (time.Time, bool) {
+	if l.disabled() {
+		return time.Time{}, false
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	var until time.Time
+	for _, key := range l.keys(chatID, username) {
+		if f, exists := l.failures[key.name]; exists && f.lockedUntil.After(now) && f.lockedUntil.After(until) {
+			until = f.lockedUntil
+		}
+	}
+	return until, !until.IsZero()
+}
+
+// Fail records a failed attempt; returns until when the chat or username is now locked, if it is
+func (l *SignInLockout) Fail(chatID int64, username string) (time.Time, bool) {
+	if l.disabled() {
+		return time.Time{}, false
+	}
+	l.mu.Lock()
+	now := l.now()
+	l.sweep(now)
+	for _, key := range l.keys(chatID, username) {
+		f, exists := l.failures[key.name]
+		if !exists {
+			f = &signInFailures{}
+			l.failures[key.name] = f
+		}
+		f.count++
+		f.lastFailure = now
+		if f.count >= key.maxAttempts {
+			f.count = 0
+			f.lockedUntil = now.Add(l.duration)
+		}
+	}
+	l.mu.Unlock()
+
+	return l.LockedUntil(chatID, username)
+}
+
+// Succeed forgets the failures of the chat and the username
+func (l *SignInLockout) Succeed(chatID int64, username string) {
+	if l.disabled() {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, key := range l.keys(chatID, username) {
+		delete(l.failures, key.name)
+	}
+}
+
+// disabled reports whether the lockout never locks
+func (l *SignInLockout) disabled() bool {
+	return l == nil || l.maxAttempts <= 0 || l.duration <= 0
+}
+
+// sweep drops entries that are neither locked nor recently failed
+func (l *SignInLockout) sweep(now time.Time) {
+	for key, f := range l.failures {
+		if now.After(f.lockedUntil) && now.Sub(f.lastFailure) > l.duration {
+			delete(l.failures, key)
+		}
+	}
+}
+
+// lockoutKey is a counter a sign-in attempt is recorded under, with its limit
+type lockoutKey struct {
+	name        string
+	maxAttempts int
+}
+
+// keys returns the counters a sign-in attempt is recorded under.
+// Attempts without a username, such as invite codes, count against the chat only.
+func (l *SignInLockout) keys(chatID int64, username string) []lockoutKey {
+	keys := []lockoutKey{{name: fmt.Sprintf("chat:%d", chatID), maxAttempts: l.maxAttempts}}
+	if username != "" && l.maxUsernameAttempts > 0 {
+		keys = append(keys, lockoutKey{name: "username:" + strings.ToLower(username), maxAttempts: l.maxUsernameAttempts})
+	}
+	return keys
+}
@@ -0,0 +1,247 @@
+package professional
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"errors"
+	"regexp"
+	"time"
+
+	"booking_client/internal/handlers/common"
+	"booking_client/internal/models"
+	apiService "booking_client/internal/services/api_service"
+)
+
+// minPasswordLength is the shortest password accepted at registration
+const minPasswordLength = 8
+
+// usernamePattern is what professionals may choose as a username
+var usernamePattern = regexp.MustCompile(`^[A-Za-z0-9_.]{3,32}$`)
+
+// StartSignUp starts the professional registration process, asking for the invite code first when one is configured
+func (h *ProfessionalHandler) StartSignUp(ctx context.Context, chatID int64, messageID int) {
+	// Start the registration flow, keeping the profile and referral of a chat that has a session
+	session := h.sessionOrNew(chatID)
+	if !h.startFlow(chatID, session, models.FlowProfessionalSignUp) {
+		return
+	}
+
+	text := common.UIMsgProfessionalSignUpInvite
+	if h.inviteCode == "" {
+		if !h.transition(chatID, session, models.StateWaitingForProfessionalFirstName) {
+			return
+		}
+		text = common.UIMsgProfessionalSignUp
+	}
+	session.TrackMessage(messageID)
+	h.apiService.GetUserRepository().SetSession(chatID, session)
+
+	h.sendMessage(chatID, text)
+}
+
+// HandleInviteCodeInput checks the invite code; a wrong code ends the registration
+func (h *ProfessionalHandler) HandleInviteCodeInput(ctx context.Context, chatID int64, code string, messageID int) {
+	session, ok := common.GetSessionOrSendError(h.apiService.GetUserRepository(), h.bot, h.logger, chatID)
+	if !ok {
+		return
+	}
+
+	// Wrong codes count against the chat like wrong passwords, so codes can't be guessed
+	if until, locked := h.lockout.LockedUntil(chatID, ""); locked {
+		h.machine.Drop(chatID, session)
+		h.sendLocked(chatID, common.ErrorMsgInviteCodeLocked, until)
+		return
+	}
+	if subtle.ConstantTimeCompare([]byte(code), []byte(h.inviteCode)) != 1 {
+		h.logger.Warn().Int64("chat_id", chatID).Msg("Professional registration with an invalid invite code")
+		h.machine.Drop(chatID, session)
+		h.sendMessage(chatID, common.ErrorMsgInvalidInviteCode)
+		if until, locked := h.lockout.Fail(chatID, ""); locked {
+			h.logger.Warn().Int64("chat_id", chatID).Time("locked_until", until).Msg("Invite code locked after repeated failures")
+			h.sendLocked(chatID, common.ErrorMsgInviteCodeLocked, until)
+		}
+		return
+	}
+
+	if !h.transition(chatID, session, models.StateWaitingForProfessionalFirstName) {
+		return
+	}
+	session.TrackMessage(messageID)
+	h.apiService.GetUserRepository().SetSession(chatID, session)
+
+	h.sendMessage(chatID, common.SuccessMsgInviteCodeAccepted)
+}
+
+// HandleSignUpFirstNameInput handles first name input for professional registration
+func (h *ProfessionalHandler) HandleSignUpFirstNameInput(ctx context.Context, chatID int64, firstName string, messageID int) {
+	session, ok := common.GetSessionOrSendError(h.apiService.GetUserRepository(), h.bot, h.logger, chatID)
+	if !ok {
+		return
+	}
+	session.Conversation.ProfessionalSignUp.FirstName = firstName
+	if !h.transition(chatID, session, models.StateWaitingForProfessionalLastName) {
+		return
+	}
+	session.TrackMessage(messageID)
+	h.apiService.GetUserRepository().SetSession(chatID, session)
+
+	h.sendMessage(chatID, common.SuccessMsgFirstNameSaved)
+}
+
+// HandleSignUpLastNameInput handles last name input for professional registration
+func (h *ProfessionalHandler) HandleSignUpLastNameInput(ctx context.Context, chatID int64, lastName string, messageID int) {
+	session, ok := common.GetSessionOrSendError(h.apiService.GetUserRepository(), h.bot, h.logger, chatID)
+	if !ok {
+		return
+	}
+	session.Conversation.ProfessionalSignUp.LastName = lastName
+	if !h.transition(chatID, session, models.StateWaitingForProfessionalPhone) {
+		return
+	}
+	session.TrackMessage(messageID)
+	h.apiService.GetUserRepository().SetSession(chatID, session)
+
+	h.sendMessage(chatID, common.SuccessMsgLastNameSaved)
+}
+
+// HandleSignUpPhoneInput handles phone number input for professional registration
+func (h *ProfessionalHandler) HandleSignUpPhoneInput(ctx context.Context, chatID int64, phone string, messageID int) {
+	session, ok := common.GetSessionOrSendError(h.apiService.GetUserRepository(), h.bot, h.logger, chatID)
+	if !ok {
+		return
+	}
+	if phone != "skip" && phone != "" {
+		session.Conversation.ProfessionalSignUp.PhoneNumber = &phone
+	}
+	if !h.transition(chatID, session, models.StateWaitingForNewUsername) {
+		return
+	}
+	session.TrackMessage(messageID)
+	h.apiService.GetUserRepository().SetSession(chatID, session)
+
+	h.sendMessage(chatID, common.SuccessMsgPhoneSaved)
+}
+
+// HandleNewUsernameInput handles the username chosen at registration
+func (h *ProfessionalHandler) HandleNewUsernameInput(ctx context.Context, chatID int64, username string, messageID int) {
+	session, ok := common.GetSessionOrSendError(h.apiService.GetUserRepository(), h.bot, h.logger, chatID)
+	if !ok {
+		return
+	}
+	if !usernamePattern.MatchString(username) {
+		h.sendMessage(chatID, common.ErrorMsgInvalidUsername)
+		return
+	}
+
+	session.Conversation.ProfessionalSignUp.Username = username
+	if !h.transition(chatID, session, models.StateWaitingForNewPassword) {
+		return
+	}
+	session.TrackMessage(messageID)
+	h.apiService.GetUserRepository().SetSession(chatID, session)
+
+	h.sendMessage(chatID, common.SuccessMsgNewUsernameSaved)
+}
+
+// HandleNewPasswordInput handles the password chosen at registration, keeping only its hash
+func (h *ProfessionalHandler) HandleNewPasswordInput(ctx context.Context, chatID int64, password string, messageID int) {
+	session, ok := common.GetSessionOrSendError(h.apiService.GetUserRepository(), h.bot, h.logger, chatID)
+	if !ok {
+		return
+	}
+	if len([]rune(password)) < minPasswordLength {
+		h.sendMessage(chatID, common.ErrorMsgPasswordTooShort)
+		return
+	}
+
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		h.sendError(ctx, chatID, common.ErrorMsgRegistrationFailed, err)
+		return
+	}
+	signUp := session.Conversation.ProfessionalSignUp
+	signUp.PasswordSalt = salt
+	signUp.PasswordHash = hashPassword(salt, password)
+	if !h.transition(chatID, session, models.StateWaitingForPasswordConfirmation) {
+		return
+	}
+	session.TrackMessage(messageID)
+	h.apiService.GetUserRepository().SetSession(chatID, session)
+
+	h.sendMessage(chatID, common.UIMsgConfirmPassword)
+}
+
+// HandlePasswordConfirmationInput registers the professional once the password is confirmed
+// and opens their dashboard; a mismatch asks for the password again
+func (h *ProfessionalHandler) HandlePasswordConfirmationInput(ctx context.Context, chatID int64, password string, messageID int) {
+	session, ok := common.GetSessionOrSendError(h.apiService.GetUserRepository(), h.bot, h.logger, chatID)
+	if !ok {
+		return
+	}
+	signUp := session.Conversation.ProfessionalSignUp
+
+	if !hmac.Equal(hashPassword(signUp.PasswordSalt, password), signUp.PasswordHash) {
+		signUp.PasswordSalt = nil
+		signUp.PasswordHash = nil
+		if !h.transition(chatID, session, models.StateWaitingForNewPassword) {
+			return
+		}
+		session.TrackMessage(messageID)
+		h.apiService.GetUserRepository().SetSession(chatID, session)
+		h.sendMessage(chatID, common.ErrorMsgPasswordMismatch)
+		return
+	}
+
+	req := &apiService.RegisterRequest{
+		FirstName:   signUp.FirstName,
+		LastName:    signUp.LastName,
+		ChatID:      chatID,
+		PhoneNumber: signUp.PhoneNumber,
+		Role:        models.RoleProfessional,
+		Username:    signUp.Username,
+		Password:    password,
+	}
+
+	registeredUser, err := h.apiService.RegisterProfessional(ctx, req)
+	if errors.Is(err, apiService.ErrConflict) {
+		signUp.Username = ""
+		signUp.PasswordSalt = nil
+		signUp.PasswordHash = nil
+		if !h.transition(chatID, session, models.StateWaitingForNewUsername) {
+			return
+		}
+		h.apiService.GetUserRepository().SetSession(chatID, session)
+		h.sendMessage(chatID, common.ErrorMsgUsernameTaken)
+		return
+	}
+	if err != nil {
+		h.machine.Drop(chatID, session)
+		h.sendError(ctx, chatID, common.ErrorMsgRegistrationFailed, err)
+		return
+	}
+
+	// Clear state and keep the registered profile
+	session.SetProfile(*registeredUser, time.Now())
+	session.Conversation.Clear()
+	session.TrackMessage(messageID)
+	h.apiService.GetUserRepository().SetSession(chatID, session)
+
+	text := common.NewSuccessMessage("registration_success").
+		WithData("first_name", registeredUser.FirstName).
+		WithData("last_name", registeredUser.LastName).
+		WithData("role", registeredUser.Role).
+		Build()
+
+	h.sendMessage(chatID, text)
+	h.ShowDashboard(ctx, chatID, 0)
+}
+
+// hashPassword hashes a password with a salt, to compare the confirmation without storing the password
+func hashPassword(salt []byte, password string) []byte {
+	mac := hmac.New(sha256.New, salt)
+	mac.Write([]byte(password))
+	return mac.Sum(nil)
+}
@@ -11,7 +11,7 @@ import (
 
 // HandleTimetable shows the professional's timetable for the current date
 func (h *ProfessionalHandler) HandleTimetable(ctx context.Context, chatID int64, messageID int) {
-	user, ok := common.GetUserOrSendError(h.apiService.GetUserRepository(), h.bot, h.logger, chatID)
+	session, ok := common.GetSessionOrSendError(h.apiService.GetUserRepository(), h.bot, h.logger, chatID)
 	if !ok {
 		return
 	}
@@ -23,7 +23,21 @@ func (h *ProfessionalHandler) HandleTimetable(ctx context.Context, chatID int64,
 	}()
 
 	currentDate := time.Now().Format("2006-01-02")
-	h.showTimetable(ctx, chatID, user, currentDate)
+	h.showTimetable(ctx, chatID, &session.Profile, currentDate)
+}
+
+// HandleTimetableForDate shows the professional's timetable for a date typed as YYYY-MM-DD
+func (h *ProfessionalHandler) HandleTimetableForDate(ctx context.Context, chatID int64, dateStr string, messageID int) {
+	session, ok := common.GetSessionOrSendError(h.apiService.GetUserRepository(), h.bot, h.logger, chatID)
+	if !ok {
+		return
+	}
+
+	if _, err := time.Parse("2006-01-02", dateStr); err != nil {
+		h.sendMessage(chatID, common.ErrorMsgInvalidDateFormat)
+		return
+	}
+	h.showTimetable(ctx, chatID, &session.Profile, dateStr)
 }
 
 // showTimetable shows the professional's timetable for a specific date
@@ -47,13 +61,13 @@ func (h *ProfessionalHandler) showTimetable(ctx context.Context, chatID int64, u
 		}
 	}
 
-	keyboard := h.createTimetableKeyboard(dateStr, timetable.Appointments)
+	keyboard := h.createTimetableKeyboard(chatID, dateStr, timetable.Appointments)
 	h.sendMessageWithKeyboard(chatID, text, keyboard)
 }
 
 // HandleTimetableDateNavigation handles timetable date navigation
 func (h *ProfessionalHandler) HandleTimetableDateNavigation(ctx context.Context, chatID int64, dateStr string, direction string, messageID int) {
-	user, ok := common.GetUserOrSendError(h.apiService.GetUserRepository(), h.bot, h.logger, chatID)
+	session, ok := common.GetSessionOrSendError(h.apiService.GetUserRepository(), h.bot, h.logger, chatID)
 	if !ok {
 		return
 	}
@@ -73,5 +87,5 @@ func (h *ProfessionalHandler) HandleTimetableDateNavigation(ctx context.Context,
 	}
 
 	newDateStr := newDate.Format("2006-01-02")
-	h.showTimetable(ctx, chatID, user, newDateStr)
+	h.showTimetable(ctx, chatID, &session.Profile, newDateStr)
 }
@@ -14,7 +14,7 @@ import (
 
 // HandleSetUnavailable starts the unavailable appointment setting process
 func (h *ProfessionalHandler) HandleSetUnavailable(ctx context.Context, chatID int64, messageID int) {
-	user, ok := common.GetUserOrSendError(h.apiService.GetUserRepository(), h.bot, h.logger, chatID)
+	session, ok := common.GetSessionOrSendError(h.apiService.GetUserRepository(), h.bot, h.logger, chatID)
 	if !ok {
 		return
 	}
@@ -25,8 +25,10 @@ func (h *ProfessionalHandler) HandleSetUnavailable(ctx context.Context, chatID i
 	}()
 
 	// Set state for unavailable appointment
-	user.State = models.StateWaitingForUnavailableDateSelection
-	h.apiService.GetUserRepository().SetUser(chatID, user)
+	if !h.startFlow(chatID, session, models.FlowUnavailable) {
+		return
+	}
+	h.apiService.GetUserRepository().SetSession(chatID, session)
 
 	// Show current month dates
 	h.showUnavailableDateSelection(chatID, time.Now())
@@ -35,27 +37,26 @@ func (h *ProfessionalHandler) HandleSetUnavailable(ctx context.Context, chatID i
 // showUnavailableDateSelection shows available dates for the current month
 func (h *ProfessionalHandler) showUnavailableDateSelection(chatID int64, currentDate time.Time) {
 	text := fmt.Sprintf(common.UIMsgSelectUnavailableDate, currentDate.Month(), currentDate.Year())
-	keyboard := h.createUnavailableDateKeyboard(currentDate)
+	keyboard := h.createUnavailableDateKeyboard(chatID, currentDate)
 	h.sendMessageWithKeyboard(chatID, text, keyboard)
 }
 
 // HandleUnavailableDateSelection handles when user selects a date for unavailable time
 func (h *ProfessionalHandler) HandleUnavailableDateSelection(ctx context.Context, chatID int64, date string, messageID int) {
-	user, valid := h.validateUserState(ctx, chatID, []string{
-		models.StateWaitingForUnavailableDateSelection,
-	})
-	if !valid {
+	session, ok := common.GetSessionOrSendError(h.apiService.GetUserRepository(), h.bot, h.logger, chatID)
+	if !ok {
 		return
 	}
 
-	user.State = models.StateWaitingForUnavailableStartTime
-	user.SelectedDate = date
-	user.LastMessageID = &messageID
-	user.MessagesToDelete = append(user.MessagesToDelete, &messageID)
-	h.apiService.GetUserRepository().SetUser(chatID, user)
+	if !h.transition(chatID, session, models.StateWaitingForUnavailableStartTime) {
+		return
+	}
+	session.Conversation.Unavailable.Date = date
+	session.TrackMessage(messageID)
+	h.apiService.GetUserRepository().SetSession(chatID, session)
 
 	// Get availability for selected date to show time slots
-	availability, err := h.apiService.GetProfessionalAvailability(ctx, user.ID, date)
+	availability, err := h.apiService.GetProfessionalAvailability(ctx, session.Profile.ID, date)
 	if err != nil {
 		h.sendError(ctx, chatID, common.ErrorMsgFailedToLoadAvailability, err)
 		return
@@ -67,27 +68,27 @@ func (h *ProfessionalHandler) HandleUnavailableDateSelection(ctx context.Context
 // showUnavailableStartTimeSelection shows available time slots for start time
 func (h *ProfessionalHandler) showUnavailableStartTimeSelection(chatID int64, availability *schemas.ProfessionalAvailabilityResponse) {
 	text := fmt.Sprintf(common.UIMsgSelectUnavailableStartTime, availability.Date)
-	keyboard := h.createUnavailableStartTimeKeyboard(availability)
+	keyboard := h.createUnavailableStartTimeKeyboard(chatID, availability)
 	h.sendMessageWithKeyboard(chatID, text, keyboard)
 }
 
 // HandleUnavailableStartTimeSelection handles when user selects start time for unavailable period
 func (h *ProfessionalHandler) HandleUnavailableStartTimeSelection(ctx context.Context, chatID int64, startTime string, messageID int) {
-	user, valid := h.validateUserState(ctx, chatID, []string{
-		models.StateWaitingForUnavailableStartTime,
-	})
-	if !valid {
+	session, ok := common.GetSessionOrSendError(h.apiService.GetUserRepository(), h.bot, h.logger, chatID)
+	if !ok {
 		return
 	}
 
-	user.State = models.StateWaitingForUnavailableEndTime
-	user.SelectedUnavailableStartTime = startTime // Store start time temporarily
-	user.LastMessageID = &messageID
-	user.MessagesToDelete = append(user.MessagesToDelete, &messageID)
-	h.apiService.GetUserRepository().SetUser(chatID, user)
+	unavailable := session.Conversation.Unavailable
+	if !h.transition(chatID, session, models.StateWaitingForUnavailableEndTime) {
+		return
+	}
+	unavailable.StartTime = startTime
+	session.TrackMessage(messageID)
+	h.apiService.GetUserRepository().SetSession(chatID, session)
 
 	// Get availability for the selected date to determine available end times
-	availability, err := h.apiService.GetProfessionalAvailability(ctx, user.ID, user.SelectedDate)
+	availability, err := h.apiService.GetProfessionalAvailability(ctx, session.Profile.ID, unavailable.Date)
 	if err != nil {
 		h.sendError(ctx, chatID, common.ErrorMsgFailedToLoadAvailability, err)
 		return
@@ -134,7 +135,7 @@ func (h *ProfessionalHandler) showUnavailableEndTimeSelection(chatID int64, star
 		text += fmt.Sprintf("\n\n"+common.UIMsgUnavailableSlotWarning, unavailableStartLocal.Format("15:04"), slotDetails)
 	}
 
-	keyboard := h.createUnavailableEndTimeKeyboard(startTime, availability)
+	keyboard := h.createUnavailableEndTimeKeyboard(chatID, startTime, availability)
 
 	// If no slots available, show a message
 	if len(keyboard.InlineKeyboard) == 1 && len(keyboard.InlineKeyboard[0]) == 1 && keyboard.InlineKeyboard[0][0].Text == "❌ Cancel" {
@@ -146,44 +147,42 @@ func (h *ProfessionalHandler) showUnavailableEndTimeSelection(chatID int64, star
 
 // HandleUnavailableEndTimeSelection handles when user selects end time for unavailable period
 func (h *ProfessionalHandler) HandleUnavailableEndTimeSelection(ctx context.Context, chatID int64, endTime string, messageID int) {
-	user, valid := h.validateUserState(ctx, chatID, []string{
-		models.StateWaitingForUnavailableEndTime,
-	})
-	if !valid {
+	session, ok := common.GetSessionOrSendError(h.apiService.GetUserRepository(), h.bot, h.logger, chatID)
+	if !ok {
 		return
 	}
 
 	// Store end time and ask for description
-	user.State = models.StateWaitingForUnavailableDescription
-	user.SelectedUnavailableEndTime = endTime
-	user.LastMessageID = &messageID
-	user.MessagesToDelete = append(user.MessagesToDelete, &messageID)
-	h.apiService.GetUserRepository().SetUser(chatID, user)
+	unavailable := session.Conversation.Unavailable
+	if !h.transition(chatID, session, models.StateWaitingForUnavailableDescription) {
+		return
+	}
+	unavailable.EndTime = endTime
+	session.TrackMessage(messageID)
+	h.apiService.GetUserRepository().SetSession(chatID, session)
 
-	text := fmt.Sprintf(common.UIMsgUnavailableDescription, user.SelectedDate, user.SelectedUnavailableStartTime, endTime)
+	text := fmt.Sprintf(common.UIMsgUnavailableDescription, unavailable.Date, unavailable.StartTime, endTime)
 	id, err := h.sendMessageWithID(chatID, text)
 	if err != nil {
 		h.sendError(ctx, chatID, common.ErrorMsgFailedToSendMessage, err)
 		return
 	}
-	user.LastMessageID = &id
-	user.MessagesToDelete = append(user.MessagesToDelete, &id)
-	h.apiService.GetUserRepository().SetUser(chatID, user)
+	session.TrackMessage(id)
+	h.apiService.GetUserRepository().SetSession(chatID, session)
 }
 
 // HandleUnavailableDescription handles when user provides description for unavailable period
 func (h *ProfessionalHandler) HandleUnavailableDescription(ctx context.Context, chatID int64, description string, messageID int) {
-	user, valid := h.validateUserState(ctx, chatID, []string{
-		models.StateWaitingForUnavailableDescription,
-	})
-	if !valid {
+	session, ok := common.GetSessionOrSendError(h.apiService.GetUserRepository(), h.bot, h.logger, chatID)
+	if !ok {
 		return
 	}
 
 	// Create unavailable appointment
-	start, _ := time.Parse("15:04", user.SelectedUnavailableStartTime)
-	end, _ := time.Parse("15:04", user.SelectedUnavailableEndTime)
-	date := user.SelectedDate
+	unavailable := session.Conversation.Unavailable
+	start, _ := time.Parse("15:04", unavailable.StartTime)
+	end, _ := time.Parse("15:04", unavailable.EndTime)
+	date := unavailable.Date
 
 	// Parse the date and combine with times
 	selectedDate, err := time.Parse("2006-01-02", date)
@@ -200,7 +199,7 @@ func (h *ProfessionalHandler) HandleUnavailableDescription(ctx context.Context,
 
 	// Create unavailable appointment request
 	req := &apiService.CreateUnavailableAppointmentRequest{
-		ProfessionalID: user.ID,
+		ProfessionalID: session.Profile.ID,
 		StartAt:        startDateTime.Format(time.RFC3339),
 		EndAt:          endDateTime.Format(time.RFC3339),
 		Description:    description,
@@ -212,51 +211,40 @@ func (h *ProfessionalHandler) HandleUnavailableDescription(ctx context.Context,
 		return
 	}
 	// Clear state
-	h.clearUnavailableState(user)
-	user.LastMessageID = &messageID
-	user.MessagesToDelete = append(user.MessagesToDelete, &messageID)
-	h.apiService.GetUserRepository().SetUser(chatID, user)
+	session.Conversation.Clear()
+	session.TrackMessage(messageID)
+	h.apiService.GetUserRepository().SetSession(chatID, session)
 
 	text := fmt.Sprintf(common.SuccessMsgUnavailablePeriodSet,
 		date, start.Format("15:04"), end.Format("15:04"), appointment.Appointment.Description)
 
 	h.sendMessage(chatID, text)
-	h.ShowDashboard(ctx, chatID, user, 0)
+	h.ShowDashboard(ctx, chatID, 0)
 }
 
 // HandleCancelUnavailable cancels the unavailable appointment setting process
 func (h *ProfessionalHandler) HandleCancelUnavailable(ctx context.Context, chatID int64, messageID int) {
-	user, ok := common.GetUserOrSendError(h.apiService.GetUserRepository(), h.bot, h.logger, chatID)
+	session, ok := common.GetSessionOrSendError(h.apiService.GetUserRepository(), h.bot, h.logger, chatID)
 	if !ok {
 		return
 	}
 
-	// Clear all unavailable-related state
-	h.clearUnavailableState(user)
-	user.LastMessageID = &messageID
-	user.MessagesToDelete = append(user.MessagesToDelete, &messageID)
-	h.apiService.GetUserRepository().SetUser(chatID, user)
+	// Drop the unavailable flow and its data
+	session.Conversation.Clear()
+	session.TrackMessage(messageID)
+	h.apiService.GetUserRepository().SetSession(chatID, session)
 
 	id, err := h.bot.SendMessageWithID(chatID, common.ErrorMsgUnavailableCancelled)
 	if err != nil {
 		h.sendError(ctx, chatID, common.ErrorMsgFailedToSendMessage, err)
 		return
 	}
-	user.LastMessageID = &id
-	user.MessagesToDelete = append(user.MessagesToDelete, &id)
-	h.ShowDashboard(ctx, chatID, user, 0)
+	session.TrackMessage(id)
+	h.ShowDashboard(ctx, chatID, 0)
 }
 
 // HandleUnavailableMonthNavigation handles month navigation for unavailable appointments
 func (h *ProfessionalHandler) HandleUnavailableMonthNavigation(ctx context.Context, chatID int64, month string, direction string, messageID int) {
-	// Validate user state - only allow if waiting for unavailable date selection
-	h.bot.DeleteMessage(chatID, messageID)
-	_, valid := h.validateUserState(ctx, chatID, []string{
-		models.StateWaitingForUnavailableDateSelection,
-	})
-	if !valid {
-		return
-	}
 	h.bot.DeleteMessage(chatID, messageID)
 
 	// Parse current month
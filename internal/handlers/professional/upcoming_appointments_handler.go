@@ -11,7 +11,7 @@ import (
 
 // HandleUpcomingAppointments shows upcoming appointments for professionals
 func (h *ProfessionalHandler) HandleUpcomingAppointments(ctx context.Context, chatID int64, messageID int) {
-	user, ok := common.GetUserOrSendError(h.apiService.GetUserRepository(), h.bot, h.logger, chatID)
+	session, ok := common.GetSessionOrSendError(h.apiService.GetUserRepository(), h.bot, h.logger, chatID)
 	if !ok {
 		return
 	}
@@ -22,7 +22,7 @@ func (h *ProfessionalHandler) HandleUpcomingAppointments(ctx context.Context, ch
 		h.bot.DeleteMessage(chatID, messageID)
 	}()
 
-	h.showUpcomingAppointmentsDatePicker(ctx, chatID, user)
+	h.showUpcomingAppointmentsDatePicker(ctx, chatID, &session.Profile)
 }
 
 // showUpcomingAppointmentsDatePicker shows date picker for upcoming appointments
@@ -41,13 +41,13 @@ func (h *ProfessionalHandler) showUpcomingAppointmentsDatePicker(ctx context.Con
 	}
 
 	text := fmt.Sprintf(common.UIMsgSelectUpcomingAppointmentsDate, targetMonth)
-	keyboard := h.createUpcomingAppointmentsDateKeyboard(appointmentDates.Dates, targetMonth)
+	keyboard := h.createUpcomingAppointmentsDateKeyboard(chatID, appointmentDates.Dates, targetMonth)
 	h.sendMessageWithKeyboard(chatID, text, keyboard)
 }
 
 // HandleUpcomingAppointmentsMonthNavigation handles month navigation for upcoming appointments
 func (h *ProfessionalHandler) HandleUpcomingAppointmentsMonthNavigation(ctx context.Context, chatID int64, monthStr string, direction string, messageID int) {
-	user, ok := common.GetUserOrSendError(h.apiService.GetUserRepository(), h.bot, h.logger, chatID)
+	session, ok := common.GetSessionOrSendError(h.apiService.GetUserRepository(), h.bot, h.logger, chatID)
 	if !ok {
 		return
 	}
@@ -66,18 +66,18 @@ func (h *ProfessionalHandler) HandleUpcomingAppointmentsMonthNavigation(ctx cont
 		newMonth = currentMonth.AddDate(0, 1, 0)
 	}
 
-	h.showUpcomingAppointmentsDatePicker(ctx, chatID, user, newMonth.Format("2006-01"))
+	h.showUpcomingAppointmentsDatePicker(ctx, chatID, &session.Profile, newMonth.Format("2006-01"))
 }
 
 // HandleUpcomingAppointmentsDateSelection handles date selection from upcoming appointments picker
 func (h *ProfessionalHandler) HandleUpcomingAppointmentsDateSelection(ctx context.Context, chatID int64, dateStr string, messageID int) {
-	user, ok := common.GetUserOrSendError(h.apiService.GetUserRepository(), h.bot, h.logger, chatID)
+	session, ok := common.GetSessionOrSendError(h.apiService.GetUserRepository(), h.bot, h.logger, chatID)
 	if !ok {
 		return
 	}
 	h.bot.DeleteMessage(chatID, messageID)
 
-	appointments, err := h.apiService.GetProfessionalAppointmentsByDate(ctx, user.ID, "confirmed", dateStr)
+	appointments, err := h.apiService.GetProfessionalAppointmentsByDate(ctx, session.Profile.ID, "confirmed", dateStr)
 	if err != nil {
 		h.sendError(ctx, chatID, common.ErrorMsgFailedToLoadAppointments, err)
 		return
@@ -85,7 +85,7 @@ func (h *ProfessionalHandler) HandleUpcomingAppointmentsDateSelection(ctx contex
 
 	if len(appointments.Appointments) == 0 {
 		h.sendMessage(chatID, common.UIMsgNoUpcomingAppointments)
-		h.ShowDashboard(ctx, chatID, user, 0)
+		h.ShowDashboard(ctx, chatID, 0)
 		return
 	}
 
@@ -94,6 +94,6 @@ func (h *ProfessionalHandler) HandleUpcomingAppointmentsDateSelection(ctx contex
 		text += common.FormatProfessionalAppointmentDetails(&apt, index)
 	}
 
-	keyboard := h.createProfessionalAppointmentsKeyboard(appointments.Appointments, false)
+	keyboard := h.createProfessionalAppointmentsKeyboard(chatID, appointments.Appointments, false)
 	h.sendMessageWithKeyboard(chatID, text, keyboard)
 }
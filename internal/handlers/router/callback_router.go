@@ -2,92 +2,329 @@ package router
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"sort"
 	"strings"
 
+	handlersCommon "booking_client/internal/handlers/common"
+	"booking_client/internal/models"
 	"booking_client/pkg/telegram"
 
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 	"github.com/rs/zerolog"
 )
 
 // CallbackHandler is a function that handles a callback with a parameter and messageID
 type CallbackHandler func(ctx context.Context, chatID int64, param string, messageID int)
 
-// PrefixHandler holds a prefix and its associated handler
-type PrefixHandler struct {
-	Prefix  string
-	Handler CallbackHandler
+// Route is a registered callback handler together with who may use it
+type Route struct {
+	Pattern string          `json:"pattern"`
+	Prefix  bool            `json:"prefix"`           // Pattern matches callback data by prefix
+	Role    string          `json:"role,omitempty"`   // Required profile role, AnyRole for any registered user, empty for everyone
+	Answer  string          `json:"answer,omitempty"` // Text the query is answered with, shown as an alert
+	Nested  bool            `json:"nested,omitempty"` // Prefix may be nested with other prefixes that allow it
+	Handler CallbackHandler `json:"-"`
 }
 
-// CallbackRouter routes callback queries to registered handlers
+// RouteOption configures a route when it is registered
+type RouteOption func(route *Route)
+
+// RequireRole restricts a route to users with the given profile role, or AnyRole
+func RequireRole(role string) RouteOption {
+	return func(route *Route) {
+		route.Role = role
+	}
+}
+
+// AnswerWith answers the callback queries of a route with an alert, e.g. for buttons that only explain
+func AnswerWith(alert string) RouteOption {
+	return func(route *Route) {
+		route.Answer = alert
+	}
+}
+
+// AllowNested lets a prefix route be nested with other prefixes, e.g. "cancel_" and
+// "cancel_appointment_", when both allow it; the longest match wins
+func AllowNested() RouteOption {
+	return func(route *Route) {
+		route.Nested = true
+	}
+}
+
+// Call is a verified callback query on its way through the middleware chain
+type Call struct {
+	QueryID   string
+	ChatID    int64
+	MessageID int
+	Data      string          // Verified callback data
+	Param     string          // Part of Data after the route prefix
+	Route     *Route          // Matched route
+	Session   *models.Session // Set by LoadSession, nil for chats without a session
+	Rejection string          // Why the call was not dispatched, empty if it was
+
+	bot      telegram.Messenger
+	answered bool
+}
+
+// Answer answers the callback query; Telegram accepts one answer per query, so later ones are ignored
+func (c *Call) Answer(text string, showAlert bool) error {
+	if c.answered {
+		return nil
+	}
+	c.answered = true
+	return c.bot.AnswerCallbackQuery(c.QueryID, text, showAlert)
+}
+
+// Reject stops the call and shows the reason to the user as an alert
+func (c *Call) Reject(reason, alert string) error {
+	c.Rejection = reason
+	return c.Answer(alert, true)
+}
+
+// Next continues the middleware chain
+type Next func(ctx context.Context, call *Call)
+
+// Middleware wraps the dispatch of a call; it may stop the call by not calling next
+type Middleware func(next Next) Next
+
+// CallbackRouter routes callback queries to registered handlers.
+// Exact matches win; otherwise the route with the longest matching prefix is used.
 type CallbackRouter struct {
-	exactHandlers  map[string]CallbackHandler
-	prefixHandlers []PrefixHandler
+	exactHandlers  map[string]*Route
+	prefixHandlers prefixTree
+	errs           []error
+	middleware     []Middleware
 	logger         *zerolog.Logger
-	bot            *telegram.Bot
+	bot            telegram.Messenger
+	codec          *handlersCommon.CallbackCodec
 }
 
-// NewCallbackRouter creates a new callback router
-func NewCallbackRouter(logger *zerolog.Logger, bot *telegram.Bot) *CallbackRouter {
+// NewCallbackRouter creates a new callback router.
+// Prefix callbacks carry parameters, so they are only dispatched when signed by codec.
+func NewCallbackRouter(logger *zerolog.Logger, bot telegram.Messenger, codec *handlersCommon.CallbackCodec) *CallbackRouter {
 	return &CallbackRouter{
-		exactHandlers:  make(map[string]CallbackHandler),
-		prefixHandlers: []PrefixHandler{},
-		logger:         logger,
-		bot:            bot,
+		exactHandlers: make(map[string]*Route),
+		logger:        logger,
+		bot:           bot,
+		codec:         codec,
 	}
 }
 
-// RegisterExact registers a handler for an exact callback match
-func (r *CallbackRouter) RegisterExact(callback string, handler CallbackHandler) {
-	r.exactHandlers[callback] = handler
+// Use appends middleware to the chain; the first one added runs outermost
+func (r *CallbackRouter) Use(middleware ...Middleware) {
+	r.middleware = append(r.middleware, middleware...)
+}
+
+// RegisterExact registers a handler for an exact callback match.
+// The handler may be nil if the route answers with an alert (AnswerWith).
+// Conflicting registrations are reported by Validate.
+func (r *CallbackRouter) RegisterExact(callback string, handler CallbackHandler, opts ...RouteOption) {
+	route := newRoute(callback, false, handler, opts)
+	switch {
+	case callback == "":
+		r.fail(route, "empty callback")
+		return
+	case handler == nil && route.Answer == "":
+		r.fail(route, "no handler and no alert")
+		return
+	case r.exactHandlers[callback] != nil:
+		r.fail(route, "registered twice")
+		return
+	case r.prefixHandlers.get(callback) != nil:
+		r.fail(route, "also registered as a prefix")
+		return
+	}
+
+	r.exactHandlers[callback] = route
 	r.logger.Debug().Str("callback", callback).Msg("Registered exact callback handler")
 }
 
+// RegisterAlert registers an exact callback that is only answered with an alert,
+// e.g. for buttons that explain why nothing can be done
+func (r *CallbackRouter) RegisterAlert(callback, alert string, opts ...RouteOption) {
+	r.RegisterExact(callback, nil, append(opts, AnswerWith(alert))...)
+}
+
 // RegisterPrefix registers a handler for a callback prefix
-// The handler will receive the part after the prefix as a parameter
-func (r *CallbackRouter) RegisterPrefix(prefix string, handler CallbackHandler) {
-	r.prefixHandlers = append(r.prefixHandlers, PrefixHandler{
-		Prefix:  prefix,
-		Handler: handler,
-	})
+// The handler will receive the part after the prefix as a parameter.
+// Prefixes must end with "_" so parameters are delimited; conflicting
+// registrations are reported by Validate.
+func (r *CallbackRouter) RegisterPrefix(prefix string, handler CallbackHandler, opts ...RouteOption) {
+	route := newRoute(prefix, true, handler, opts)
+	switch {
+	case !strings.HasSuffix(prefix, "_") || prefix == "_":
+		r.fail(route, `prefix must end with "_"`)
+		return
+	case handler == nil:
+		r.fail(route, "no handler")
+		return
+	case r.exactHandlers[prefix] != nil:
+		r.fail(route, "also registered as an exact callback")
+		return
+	}
+	if existing := r.prefixHandlers.insert(route); existing != nil {
+		r.fail(route, "registered twice")
+		return
+	}
+
+	// Nested prefixes are ambiguous unless both routes ask for them; the longest match wins
+	for _, other := range r.prefixHandlers.nested(prefix) {
+		if !route.Nested || !other.Nested {
+			r.fail(route, fmt.Sprintf("nested with prefix %q without AllowNested on both", other.Pattern))
+			continue
+		}
+		r.logger.Debug().
+			Str("prefix", prefix).
+			Str("overlaps", other.Pattern).
+			Msg("Nested callback prefixes, the longest match wins")
+	}
 	r.logger.Debug().Str("prefix", prefix).Msg("Registered prefix callback handler")
 }
 
-// Route routes the callback to the appropriate handler
-// Returns true if a handler was found and executed, false otherwise
-func (r *CallbackRouter) Route(ctx context.Context, chatID int64, callbackData string, messageID int) bool {
-	// Try exact match first (most common case)
-	if handler, exists := r.exactHandlers[callbackData]; exists {
-		r.logger.Debug().
-			Int64("chat_id", chatID).
-			Str("callback", callbackData).
-			Msg("Routing to exact handler")
-		handler(ctx, chatID, "", messageID)
-		return true
+// Validate reports every conflicting registration; call it once all routes are registered
+func (r *CallbackRouter) Validate() error {
+	return errors.Join(r.errs...)
+}
+
+// Routes returns the route table sorted by pattern, exact routes before prefixes of the same name
+func (r *CallbackRouter) Routes() []Route {
+	routes := make([]Route, 0, len(r.exactHandlers)+r.prefixHandlers.size)
+	for _, route := range r.exactHandlers {
+		routes = append(routes, *route)
+	}
+	for _, route := range r.prefixHandlers.all() {
+		routes = append(routes, *route)
 	}
+	sort.Slice(routes, func(i, j int) bool {
+		if routes[i].Pattern != routes[j].Pattern {
+			return routes[i].Pattern < routes[j].Pattern
+		}
+		return !routes[i].Prefix
+	})
+	return routes
+}
+
+// Route verifies the callback query and passes it through the middleware to its handler.
+// The query is always answered. Returns true if the callback was handled or rejected,
+// false if no handler was found.
+func (r *CallbackRouter) Route(ctx context.Context, query *tgbotapi.CallbackQuery) bool {
+	call := &Call{
+		QueryID:   query.ID,
+		ChatID:    query.Message.Chat.ID,
+		MessageID: query.Message.MessageID,
+		Data:      query.Data,
+		bot:       r.bot,
+	}
+	defer r.answer(call)
 
-	// Try prefix matches
-	for _, ph := range r.prefixHandlers {
-		if strings.HasPrefix(callbackData, ph.Prefix) {
-			param := callbackData[len(ph.Prefix):]
-			r.logger.Debug().
-				Int64("chat_id", chatID).
-				Str("prefix", ph.Prefix).
-				Str("param", param).
-				Msg("Routing to prefix handler")
-			ph.Handler(ctx, chatID, param, messageID)
+	signed := r.codec.IsSigned(call.Data)
+	if signed {
+		decoded, err := r.codec.Decode(call.ChatID, call.Data)
+		if err != nil {
+			r.reject(call, err)
 			return true
 		}
+		call.Data = decoded
 	}
 
-	// No handler found
-	r.logger.Warn().
-		Int64("chat_id", chatID).
-		Str("callback", callbackData).
-		Msg("No handler found for callback")
-	return false
+	route := r.match(call.Data)
+	if route == nil {
+		// No handler found
+		r.logger.Warn().
+			Int64("chat_id", call.ChatID).
+			Str("callback", call.Data).
+			Msg("No handler found for callback")
+		return false
+	}
+
+	// Parameters typed into forged callback data are never trusted
+	if route.Prefix && !signed {
+		r.reject(call, handlersCommon.ErrCallbackUnsigned)
+		return true
+	}
+
+	call.Route = route
+	call.Param = call.Data[len(route.Pattern):]
+	r.chain(r.dispatch)(ctx, call)
+	return true
 }
 
 // GetStats returns statistics about registered handlers
 func (r *CallbackRouter) GetStats() (exactCount int, prefixCount int) {
-	return len(r.exactHandlers), len(r.prefixHandlers)
+	return len(r.exactHandlers), r.prefixHandlers.size
+}
+
+// match returns the route of the callback data: the exact match, else the longest prefix
+func (r *CallbackRouter) match(data string) *Route {
+	if route, exists := r.exactHandlers[data]; exists {
+		return route
+	}
+	return r.prefixHandlers.longest(data)
+}
+
+// fail records a conflicting registration
+func (r *CallbackRouter) fail(route *Route, reason string) {
+	kind := "exact callback"
+	if route.Prefix {
+		kind = "prefix"
+	}
+	r.errs = append(r.errs, fmt.Errorf("callback %s %q: %s", kind, route.Pattern, reason))
+}
+
+// chain wraps final in the registered middleware
+func (r *CallbackRouter) chain(final Next) Next {
+	next := final
+	for i := len(r.middleware) - 1; i >= 0; i-- {
+		next = r.middleware[i](next)
+	}
+	return next
+}
+
+// answer clears the loading state of the button if nothing answered the query yet
+func (r *CallbackRouter) answer(call *Call) {
+	if err := call.Answer("", false); err != nil {
+		r.logger.Error().Err(err).Msg("Failed to answer callback query")
+	}
+}
+
+// reject tells the user that a button can no longer be used
+func (r *CallbackRouter) reject(call *Call, err error) {
+	r.logger.Warn().
+		Err(err).
+		Int64("chat_id", call.ChatID).
+		Str("callback", call.Data).
+		Msg("Rejected callback data")
+
+	text := handlersCommon.ErrorMsgInvalidCallback
+	if errors.Is(err, handlersCommon.ErrCallbackExpired) {
+		text = handlersCommon.ErrorMsgExpiredCallback
+	}
+	if sendErr := r.bot.SendMessage(call.ChatID, text); sendErr != nil {
+		r.logger.Error().Err(sendErr).Msg("Failed to send rejected callback message")
+	}
+}
+
+// dispatch answers the query, so the button stops loading while the handler calls the API,
+// and runs the handler of the matched route
+func (r *CallbackRouter) dispatch(ctx context.Context, call *Call) {
+	if call.Route.Answer != "" {
+		if err := call.Answer(call.Route.Answer, true); err != nil {
+			r.logger.Error().Err(err).Msg("Failed to answer callback query")
+		}
+	}
+	r.answer(call)
+	if call.Route.Handler != nil {
+		call.Route.Handler(ctx, call.ChatID, call.Param, call.MessageID)
+	}
+}
+
+// newRoute applies the options to a new route
+func newRoute(pattern string, prefix bool, handler CallbackHandler, opts []RouteOption) *Route {
+	route := &Route{Pattern: pattern, Prefix: prefix, Handler: handler}
+	for _, opt := range opts {
+		opt(route)
+	}
+	return route
 }
@@ -0,0 +1,114 @@
+package router_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	handlersCommon "booking_client/internal/handlers/common"
+	"booking_client/internal/handlers/router"
+	"booking_client/pkg/telegram/telegramtest"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/rs/zerolog"
+)
+
+// newRouter creates a router without middleware and the codec signing its callbacks
+func newRouter(t *testing.T) (*router.CallbackRouter, *handlersCommon.CallbackCodec) {
+	t.Helper()
+
+	codec, err := handlersCommon.NewCallbackCodec("router-test-secret", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger := zerolog.Nop()
+	return router.NewCallbackRouter(&logger, telegramtest.NewFakeMessenger(), codec), codec
+}
+
+// route sends callback data through the router and returns the pattern of the route that ran
+func route(t *testing.T, r *router.CallbackRouter, data string) (pattern string, param string) {
+	t.Helper()
+
+	query := &tgbotapi.CallbackQuery{
+		ID:      "1",
+		Message: &tgbotapi.Message{MessageID: 1, Chat: &tgbotapi.Chat{ID: 1}},
+		Data:    data,
+	}
+	r.Use(func(next router.Next) router.Next {
+		return func(ctx context.Context, call *router.Call) {
+			pattern, param = call.Route.Pattern, call.Param
+		}
+	})
+	r.Route(context.Background(), query)
+	return pattern, param
+}
+
+func TestLongestPrefixWinsRegardlessOfOrder(t *testing.T) {
+	noop := func(context.Context, int64, string, int) {}
+
+	for _, order := range [][]string{
+		{"cancel_", "cancel_appointment_", "cancel_appointment_x_"},
+		{"cancel_appointment_x_", "cancel_appointment_", "cancel_"},
+	} {
+		r, codec := newRouter(t)
+		for _, prefix := range order {
+			r.RegisterPrefix(prefix, noop, router.AllowNested())
+		}
+		if err := r.Validate(); err != nil {
+			t.Fatalf("unexpected conflict: %v", err)
+		}
+
+		data, err := codec.Encode(1, handlersCommon.CallbackPrefixCancelAppointment, "x_42")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if pattern, param := route(t, r, data); pattern != "cancel_appointment_x_" || param != "42" {
+			t.Fatalf("order %v: routed to %q with %q, want cancel_appointment_x_ with 42", order, pattern, param)
+		}
+	}
+}
+
+func TestConflictingRoutesAreReported(t *testing.T) {
+	noop := func(context.Context, int64, string, int) {}
+
+	r, _ := newRouter(t)
+	r.RegisterExact("dashboard", noop)
+	r.RegisterExact("dashboard", noop)
+	r.RegisterPrefix("select_", noop)
+	r.RegisterPrefix("select_", noop)
+	r.RegisterPrefix("select", noop)
+	r.RegisterExact("select_", noop)
+	r.RegisterExact("idle", nil)
+	r.RegisterPrefix("idle_", nil)
+	r.RegisterAlert("full", "Pick another day")
+	r.RegisterPrefix("select_date_", noop)
+	r.RegisterPrefix("book_", noop, router.AllowNested())
+	r.RegisterPrefix("book_time_", noop, router.AllowNested())
+
+	err := r.Validate()
+	if err == nil {
+		t.Fatal("expected conflicting routes to be reported")
+	}
+	for _, want := range []string{
+		`exact callback "dashboard": registered twice`,
+		`prefix "select_": registered twice`,
+		`prefix "select": prefix must end with "_"`,
+		`exact callback "select_": also registered as a prefix`,
+		`exact callback "idle": no handler and no alert`,
+		`prefix "idle_": no handler`,
+		`prefix "select_date_": nested with prefix "select_" without AllowNested on both`,
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not mention %q", err, want)
+		}
+	}
+	if strings.Contains(err.Error(), "book_") {
+		t.Errorf("error %q reports prefixes nested with AllowNested", err)
+	}
+
+	routes := r.Routes()
+	if len(routes) != 6 || routes[0].Pattern != "book_" || routes[2].Pattern != "dashboard" ||
+		routes[3].Pattern != "full" || routes[3].Answer == "" || routes[4].Pattern != "select_" || !routes[4].Prefix {
+		t.Fatalf("unexpected route table: %+v", routes)
+	}
+}
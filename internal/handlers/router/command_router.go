@@ -0,0 +1,256 @@
+package router
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"booking_client/internal/common"
+	handlersCommon "booking_client/internal/handlers/common"
+	"booking_client/internal/models"
+	"booking_client/internal/repository"
+	"booking_client/pkg/telegram"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/rs/zerolog"
+)
+
+// CommandHandler is a function that handles a command with its arguments and messageID
+type CommandHandler func(ctx context.Context, chatID int64, args string, messageID int)
+
+// Command is a slash command together with who may use it
+type Command struct {
+	Name        string // Without the leading slash
+	Description string // Shown in the Telegram menu and /help
+	Role        string // Required profile role, AnyRole for any registered user, empty for everyone
+	Global      bool   // Works in any conversation state, to escape from a flow
+	Admin       bool   // Only for admin chats; hidden from menus and unknown to everyone else
+	Handler     CommandHandler
+}
+
+// commandNamePattern is what Telegram accepts as a command name
+var commandNamePattern = regexp.MustCompile(`^[a-z0-9_]{1,32}$`)
+
+// CommandRouter routes slash commands to registered handlers and publishes
+// the Telegram command menu matching each chat's role
+type CommandRouter struct {
+	commands map[string]*Command
+	order    []*Command
+	errs     []error
+	userRepo repository.UserRepository
+	logger   *zerolog.Logger
+	bot      telegram.Messenger
+	busy     func(session *models.Session) bool
+	admins   map[int64]bool
+}
+
+// NewCommandRouter creates a new command router
+func NewCommandRouter(logger *zerolog.Logger, bot telegram.Messenger, userRepo repository.UserRepository) *CommandRouter {
+	return &CommandRouter{
+		commands: make(map[string]*Command),
+		userRepo: userRepo,
+		logger:   logger,
+		bot:      bot,
+		busy:     (*models.Session).InConversation,
+		admins:   make(map[int64]bool),
+	}
+}
+
+// SetAdmins sets the chats allowed to use admin commands
+func (r *CommandRouter) SetAdmins(chatIDs []int64) {
+	r.admins = make(map[int64]bool, len(chatIDs))
+	for _, chatID := range chatIDs {
+		r.admins[chatID] = true
+	}
+}
+
+// SetBusy sets the check deciding whether a session is in a flow that only global commands may interrupt
+func (r *CommandRouter) SetBusy(busy func(session *models.Session) bool) {
+	r.busy = busy
+}
+
+// IsCommand reports whether the text is a slash command
+func IsCommand(text string) bool {
+	return strings.HasPrefix(text, "/")
+}
+
+// IsGlobalCommand reports whether the text is a command that works in any conversation state
+func (r *CommandRouter) IsGlobalCommand(text string) bool {
+	if !IsCommand(text) {
+		return false
+	}
+	name, _ := parseCommand(text)
+	command, exists := r.commands[name]
+	return exists && command.Global && !command.Admin
+}
+
+// Register registers a command; menus list commands in registration order.
+// Conflicting registrations are reported by Validate.
+func (r *CommandRouter) Register(command Command) {
+	switch {
+	case !commandNamePattern.MatchString(command.Name):
+		r.errs = append(r.errs, fmt.Errorf("command %q: name must be 1-32 lowercase letters, digits or underscores", command.Name))
+		return
+	case r.commands[command.Name] != nil:
+		r.errs = append(r.errs, fmt.Errorf("command %q: registered twice", command.Name))
+		return
+	}
+
+	registered := command
+	r.commands[command.Name] = &registered
+	r.order = append(r.order, &registered)
+	r.logger.Debug().Str("command", command.Name).Str("role", command.Role).Msg("Registered command handler")
+}
+
+// Validate reports every conflicting registration; call it once all commands are registered
+func (r *CommandRouter) Validate() error {
+	return errors.Join(r.errs...)
+}
+
+// Commands returns the commands available to a role, empty for chats without a profile.
+// Admin commands are never listed.
+func (r *CommandRouter) Commands(role string) []Command {
+	var commands []Command
+	for _, command := range r.order {
+		if !command.Admin && allowsRole(command.Role, role) {
+			commands = append(commands, *command)
+		}
+	}
+	return commands
+}
+
+// Route runs the handler of a slash command.
+// Returns true if the command exists, whether or not the chat may use it.
+func (r *CommandRouter) Route(ctx context.Context, chatID int64, text string, messageID int) bool {
+	name, args := parseCommand(text)
+	command, exists := r.commands[name]
+	if !exists || (command.Admin && !r.admins[chatID]) {
+		r.logger.Debug().Int64("chat_id", chatID).Str("command", name).Msg("No handler found for command")
+		return false
+	}
+
+	logger := common.GetLogger(ctx)
+	session, _ := r.userRepo.GetSession(chatID)
+	if reason, text := r.authorize(command, session); reason != "" {
+		logger.Info().
+			Int64("chat_id", chatID).
+			Str("command", name).
+			Str("rejected", reason).
+			Msg("Rejected command")
+		if err := r.bot.SendMessage(chatID, text); err != nil {
+			logger.Error().Err(err).Msg("Failed to send rejected command message")
+		}
+		return true
+	}
+
+	logger.Debug().
+		Int64("chat_id", chatID).
+		Str("command", name).
+		Str("args", args).
+		Msg("Routing command")
+	command.Handler(ctx, chatID, args, messageID)
+	return true
+}
+
+// PublishDefaultMenu publishes the menu shown to chats without a profile
+func (r *CommandRouter) PublishDefaultMenu() error {
+	return r.bot.SetCommands(0, botCommands(r.Commands("")))
+}
+
+// SyncMenu publishes the menu of the chat's current role if it changed since the last sync.
+// The published role is kept in the session, so it survives restarts with a persistent store.
+func (r *CommandRouter) SyncMenu(ctx context.Context, chatID int64) {
+	session, exists := r.userRepo.GetSession(chatID)
+	if !exists || session == nil {
+		// The session may have been evicted, expired or dropped after a menu was published
+		// to the chat; without it there is no telling, so the menu for chats without a
+		// profile is published again
+		r.publishMenu(ctx, chatID, "")
+		return
+	}
+	role := menuRole(session)
+	if session.MenuRole == role {
+		return
+	}
+
+	if !r.publishMenu(ctx, chatID, role) {
+		return
+	}
+
+	// The chat may have signed in or out while the menu was published; the next sync catches up
+	session, exists = r.userRepo.GetSession(chatID)
+	if !exists || session == nil || menuRole(session) != role {
+		return
+	}
+	session.MenuRole = role
+	r.userRepo.SetSession(chatID, session)
+}
+
+// publishMenu publishes the menu of a role to the chat; returns false if it failed
+func (r *CommandRouter) publishMenu(ctx context.Context, chatID int64, role string) bool {
+	if err := r.bot.SetCommands(chatID, botCommands(r.Commands(role))); err != nil {
+		logger := common.GetLogger(ctx)
+		logger.Error().Err(err).Int64("chat_id", chatID).Msg("Failed to publish command menu")
+		return false
+	}
+	return true
+}
+
+// menuRole returns the role whose menu the chat should see, empty for chats without a profile
+func menuRole(session *models.Session) string {
+	if !session.IsRegistered() {
+		return ""
+	}
+	return session.Profile.Role
+}
+
+// authorize checks the command's role and state requirements.
+// Returns the rejection reason and the message for the user, or empty strings.
+func (r *CommandRouter) authorize(command *Command, session *models.Session) (string, string) {
+	registered := session != nil && session.IsRegistered()
+	switch {
+	case command.Role == "":
+	case !registered:
+		return RejectedSignedOut, handlersCommon.AlertMsgSignInRequired
+	case command.Role != AnyRole && session.Profile.Role != command.Role:
+		if text, exists := roleAlerts[command.Role]; exists {
+			return RejectedRole, text
+		}
+		return RejectedRole, handlersCommon.AlertMsgActionUnavailable
+	}
+
+	if !command.Global && session != nil && r.busy(session) {
+		return RejectedState, handlersCommon.ErrorMsgFinishCurrentAction
+	}
+	return "", ""
+}
+
+// allowsRole reports whether a command requiring required is available to role
+func allowsRole(required, role string) bool {
+	switch required {
+	case "":
+		return true
+	case AnyRole:
+		return role != ""
+	default:
+		return required == role
+	}
+}
+
+// parseCommand splits "/name@bot args" into the command name and its arguments
+func parseCommand(text string) (string, string) {
+	name, args, _ := strings.Cut(strings.TrimPrefix(text, "/"), " ")
+	name, _, _ = strings.Cut(name, "@")
+	return strings.ToLower(name), strings.TrimSpace(args)
+}
+
+// botCommands converts commands to the Telegram menu format
+func botCommands(commands []Command) []tgbotapi.BotCommand {
+	menu := make([]tgbotapi.BotCommand, 0, len(commands))
+	for _, command := range commands {
+		menu = append(menu, tgbotapi.BotCommand{Command: command.Name, Description: command.Description})
+	}
+	return menu
+}
@@ -0,0 +1,60 @@
+package router_test
+
+import (
+	"context"
+	"testing"
+
+	"booking_client/internal/handlers/router"
+	"booking_client/internal/models"
+	"booking_client/internal/repository"
+	"booking_client/pkg/telegram/telegramtest"
+
+	"github.com/rs/zerolog"
+)
+
+func TestMenuSyncIsRememberedInTheSession(t *testing.T) {
+	logger := zerolog.Nop()
+	bot := telegramtest.NewFakeMessenger()
+	repo := repository.NewMemoryUserRepository()
+	newCommandRouter := func() *router.CommandRouter {
+		r := router.NewCommandRouter(&logger, bot, repo)
+		r.Register(router.Command{Name: "timetable", Description: "Timetable", Role: models.RoleProfessional})
+		return r
+	}
+
+	const chatID int64 = 7
+	session := models.NewSession(chatID)
+	session.Profile.ID = "pro-1"
+	session.Profile.Role = models.RoleProfessional
+	repo.SetSession(chatID, session)
+
+	r := newCommandRouter()
+	r.SyncMenu(context.Background(), chatID)
+	r.SyncMenu(context.Background(), chatID)
+	if menus := bot.MessagesForChat(chatID, telegramtest.KindCommands); len(menus) != 1 {
+		t.Fatalf("published %d menus, want 1", len(menus))
+	}
+
+	// A restarted router knows the menu from the session
+	newCommandRouter().SyncMenu(context.Background(), chatID)
+	if menus := bot.MessagesForChat(chatID, telegramtest.KindCommands); len(menus) != 1 {
+		t.Fatalf("published %d menus after a restart, want 1", len(menus))
+	}
+
+	// Signing out switches back to the default menu
+	stored, _ := repo.GetSession(chatID)
+	repo.SetSession(chatID, stored.SignedOut(chatID))
+	r.SyncMenu(context.Background(), chatID)
+	if got, _ := bot.Commands(chatID); len(got) != 0 {
+		t.Fatalf("signed out menu = %v, want the default menu", got)
+	}
+
+	// A chat whose session is gone gets the default menu back, whatever it was shown before
+	repo.SetSession(chatID, session)
+	r.SyncMenu(context.Background(), chatID)
+	repo.DeleteSession(chatID)
+	r.SyncMenu(context.Background(), chatID)
+	if got, _ := bot.Commands(chatID); len(got) != 0 {
+		t.Fatalf("menu without a session = %v, want the default menu", got)
+	}
+}
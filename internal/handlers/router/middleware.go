@@ -0,0 +1,182 @@
+package router
+
+import (
+	"context"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"booking_client/internal/common"
+	handlersCommon "booking_client/internal/handlers/common"
+	"booking_client/internal/models"
+	"booking_client/internal/repository"
+)
+
+// AnyRole lets any registered user use a route
+const AnyRole = "*"
+
+// Rejection reasons
+const (
+	RejectedSignedOut   = "signed_out"
+	RejectedRole        = "role"
+	RejectedState       = "state"
+	RejectedPanic       = "panic"
+	RejectedUnavailable = "unavailable"
+)
+
+// roleAlerts tell users which role a route is restricted to
+var roleAlerts = map[string]string{
+	models.RoleClient:       handlersCommon.AlertMsgClientsOnly,
+	models.RoleProfessional: handlersCommon.AlertMsgProfessionalsOnly,
+}
+
+// Logging logs every routed callback with its outcome and latency
+func Logging() Middleware {
+	return func(next Next) Next {
+		return func(ctx context.Context, call *Call) {
+			start := time.Now()
+			next(ctx, call)
+
+			logger := common.GetLogger(ctx)
+			event := logger.Debug()
+			if call.Rejection != "" {
+				event = logger.Info().Str("rejected", call.Rejection)
+			}
+			event.
+				Int64("chat_id", call.ChatID).
+				Str("route", call.Route.Pattern).
+				Str("param", call.Param).
+				Dur("latency", time.Since(start)).
+				Msg("Routed callback")
+		}
+	}
+}
+
+// Recover turns a panicking handler into an error for the user instead of a dropped update
+func Recover() Middleware {
+	return func(next Next) Next {
+		return func(ctx context.Context, call *Call) {
+			defer func() {
+				r := recover()
+				if r == nil {
+					return
+				}
+				logger := common.GetLogger(ctx)
+				logger.Error().
+					Interface("panic", r).
+					Str("route", call.Route.Pattern).
+					Str("stack", string(debug.Stack())).
+					Msg("Panic recovered in callback handler")
+
+				// The query is answered before the handler runs, so fall back to a message
+				if call.answered {
+					call.Rejection = RejectedPanic
+					if err := call.bot.SendMessage(call.ChatID, handlersCommon.AlertMsgSomethingWentWrong); err != nil {
+						logger.Error().Err(err).Msg("Failed to send handler failure message")
+					}
+					return
+				}
+				if err := call.Reject(RejectedPanic, handlersCommon.AlertMsgSomethingWentWrong); err != nil {
+					logger.Error().Err(err).Msg("Failed to answer callback query")
+				}
+			}()
+			next(ctx, call)
+		}
+	}
+}
+
+// LoadSession loads the session of the chat into the call
+func LoadSession(userRepo repository.UserRepository) Middleware {
+	return func(next Next) Next {
+		return func(ctx context.Context, call *Call) {
+			if session, exists := userRepo.GetSession(call.ChatID); exists {
+				call.Session = session
+			}
+			next(ctx, call)
+		}
+	}
+}
+
+// Authorize enforces the role declared by the route; runs after LoadSession.
+// The conversation states a callback is valid in are declared by the flows of the fsm package.
+func Authorize() Middleware {
+	return func(next Next) Next {
+		return func(ctx context.Context, call *Call) {
+			route := call.Route
+			registered := call.Session != nil && call.Session.IsRegistered()
+
+			switch {
+			case route.Role == "":
+			case !registered:
+				reject(ctx, call, RejectedSignedOut, handlersCommon.AlertMsgSignInRequired)
+				return
+			case route.Role != AnyRole && call.Session.Profile.Role != route.Role:
+				alert, exists := roleAlerts[route.Role]
+				if !exists {
+					alert = handlersCommon.AlertMsgActionUnavailable
+				}
+				reject(ctx, call, RejectedRole, alert)
+				return
+			}
+
+			next(ctx, call)
+		}
+	}
+}
+
+// Metrics counts routed callbacks per route and rejections per reason
+type Metrics struct {
+	mu       sync.Mutex
+	routed   map[string]int64
+	rejected map[string]int64
+}
+
+// NewMetrics creates empty callback metrics
+func NewMetrics() *Metrics {
+	return &Metrics{
+		routed:   make(map[string]int64),
+		rejected: make(map[string]int64),
+	}
+}
+
+// Middleware returns the middleware recording into the metrics
+func (m *Metrics) Middleware() Middleware {
+	return func(next Next) Next {
+		return func(ctx context.Context, call *Call) {
+			next(ctx, call)
+
+			m.mu.Lock()
+			defer m.mu.Unlock()
+			m.routed[call.Route.Pattern]++
+			if call.Rejection != "" {
+				m.rejected[call.Rejection]++
+			}
+		}
+	}
+}
+
+// Snapshot returns a copy of the counters, suitable for expvar
+func (m *Metrics) Snapshot() map[string]map[string]int64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	snapshot := map[string]map[string]int64{
+		"routed":   make(map[string]int64, len(m.routed)),
+		"rejected": make(map[string]int64, len(m.rejected)),
+	}
+	for route, count := range m.routed {
+		snapshot["routed"][route] = count
+	}
+	for reason, count := range m.rejected {
+		snapshot["rejected"][reason] = count
+	}
+	return snapshot
+}
+
+// reject logs and rejects a call
+func reject(ctx context.Context, call *Call, reason, alert string) {
+	logger := common.GetLogger(ctx)
+	if err := call.Reject(reason, alert); err != nil {
+		logger.Error().Err(err).Msg("Failed to answer callback query")
+	}
+}
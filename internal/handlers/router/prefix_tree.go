@@ -0,0 +1,100 @@
+package router
+
+// prefixTree finds the route with the longest prefix of callback data,
+// independent of the order the prefixes were registered in
+type prefixTree struct {
+	root prefixNode
+	size int
+}
+
+// prefixNode is a trie node; route is set when a prefix ends here
+type prefixNode struct {
+	children map[byte]*prefixNode
+	route    *Route
+}
+
+// insert adds the route under its pattern; returns the route already there, if any
+func (t *prefixTree) insert(route *Route) *Route {
+	node := &t.root
+	for i := 0; i < len(route.Pattern); i++ {
+		if node.children == nil {
+			node.children = make(map[byte]*prefixNode)
+		}
+		child, exists := node.children[route.Pattern[i]]
+		if !exists {
+			child = &prefixNode{}
+			node.children[route.Pattern[i]] = child
+		}
+		node = child
+	}
+	if node.route != nil {
+		return node.route
+	}
+	node.route = route
+	t.size++
+	return nil
+}
+
+// get returns the route registered under exactly pattern, nil if there is none
+func (t *prefixTree) get(pattern string) *Route {
+	node := &t.root
+	for i := 0; i < len(pattern); i++ {
+		child, exists := node.children[pattern[i]]
+		if !exists {
+			return nil
+		}
+		node = child
+	}
+	return node.route
+}
+
+// longest returns the route with the longest pattern that prefixes data, nil if none does
+func (t *prefixTree) longest(data string) *Route {
+	var match *Route
+	node := &t.root
+	for i := 0; i < len(data); i++ {
+		child, exists := node.children[data[i]]
+		if !exists {
+			break
+		}
+		node = child
+		if node.route != nil {
+			match = node.route
+		}
+	}
+	return match
+}
+
+// nested returns the routes whose patterns are prefixes of pattern, or that pattern is a prefix of
+func (t *prefixTree) nested(pattern string) []*Route {
+	var routes []*Route
+	node := &t.root
+	for i := 0; i < len(pattern); i++ {
+		child, exists := node.children[pattern[i]]
+		if !exists {
+			return routes
+		}
+		node = child
+		if node.route != nil && i < len(pattern)-1 {
+			routes = append(routes, node.route)
+		}
+	}
+	return append(routes, node.descendants(false)...)
+}
+
+// descendants returns the routes below the node, and the node's own when self is set
+func (n *prefixNode) descendants(self bool) []*Route {
+	var routes []*Route
+	if self && n.route != nil {
+		routes = append(routes, n.route)
+	}
+	for _, child := range n.children {
+		routes = append(routes, child.descendants(true)...)
+	}
+	return routes
+}
+
+// all returns every route in the tree
+func (t *prefixTree) all() []*Route {
+	return t.root.descendants(true)
+}
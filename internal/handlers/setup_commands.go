@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	handlersCommon "booking_client/internal/handlers/common"
+	"booking_client/internal/handlers/router"
+	"booking_client/internal/models"
+)
+
+// setupCommands registers all slash commands with the command router.
+// Global commands work in any state, so users can always get out of a flow.
+func (h *Handler) setupCommands() {
+	h.commandRouter.Register(router.Command{
+		Name:        "start",
+		Description: "Start or return to your dashboard",
+		Global:      true,
+		Handler: func(ctx context.Context, chatID int64, payload string, messageID int) {
+			h.handleStart(ctx, chatID, payload, messageID)
+		},
+	})
+	h.commandRouter.Register(router.Command{
+		Name:        "dashboard",
+		Description: "Open your dashboard",
+		Role:        router.AnyRole,
+		Global:      true,
+		Handler: func(ctx context.Context, chatID int64, _ string, _ int) {
+			h.handleDashboard(ctx, chatID)
+		},
+	})
+	h.commandRouter.Register(router.Command{
+		Name:        "book",
+		Description: "Book an appointment",
+		Role:        models.RoleClient,
+		Handler: func(ctx context.Context, chatID int64, _ string, messageID int) {
+			h.clientHandler.HandleBookAppointment(ctx, chatID, messageID)
+		},
+	})
+	h.commandRouter.Register(router.Command{
+		Name:        "timetable",
+		Description: "Show your timetable, optionally for a date (YYYY-MM-DD)",
+		Role:        models.RoleProfessional,
+		Handler: func(ctx context.Context, chatID int64, date string, messageID int) {
+			if date == "" {
+				h.professionalHandler.HandleTimetable(ctx, chatID, messageID)
+				return
+			}
+			h.professionalHandler.HandleTimetableForDate(ctx, chatID, date, messageID)
+		},
+	})
+	h.commandRouter.Register(router.Command{
+		Name:        "password",
+		Description: "Change your password",
+		Role:        models.RoleProfessional,
+		Handler: func(ctx context.Context, chatID int64, _ string, messageID int) {
+			h.professionalHandler.StartChangePassword(ctx, chatID, messageID)
+		},
+	})
+	h.commandRouter.Register(router.Command{
+		Name:        "logout",
+		Description: "Sign out of this chat",
+		Role:        models.RoleProfessional,
+		Global:      true,
+		Handler: func(ctx context.Context, chatID int64, _ string, messageID int) {
+			h.professionalHandler.HandleLogout(ctx, chatID, messageID)
+		},
+	})
+	h.commandRouter.Register(router.Command{
+		Name:        "cancel",
+		Description: "Cancel the current action",
+		Global:      true,
+		Handler: func(ctx context.Context, chatID int64, _ string, messageID int) {
+			if !h.machine.Cancel(ctx, chatID, messageID) {
+				h.sendMessage(ctx, chatID, handlersCommon.UIMsgNothingToCancel)
+			}
+		},
+	})
+	h.commandRouter.Register(router.Command{
+		Name:        "help",
+		Description: "Show available commands",
+		Global:      true,
+		Handler: func(ctx context.Context, chatID int64, _ string, _ int) {
+			h.handleHelp(ctx, chatID)
+		},
+	})
+	h.commandRouter.Register(router.Command{
+		Name:        "revoke",
+		Description: "Sign a professional out of a chat: /revoke <chat id>",
+		Global:      true,
+		Admin:       true,
+		Handler: func(ctx context.Context, chatID int64, args string, _ int) {
+			h.handleRevoke(ctx, chatID, args)
+		},
+	})
+}
+
+// handleHelp lists the commands available to the chat's role
+func (h *Handler) handleHelp(ctx context.Context, chatID int64) {
+	role := ""
+	if session, exists := h.apiService.GetUserRepository().GetSession(chatID); exists && session != nil && session.IsRegistered() {
+		role = session.Profile.Role
+	}
+
+	var text strings.Builder
+	text.WriteString(handlersCommon.UIMsgHelp)
+	for _, command := range h.commandRouter.Commands(role) {
+		text.WriteString(fmt.Sprintf("/%s - %s\n", command.Name, command.Description))
+	}
+	h.sendMessage(ctx, chatID, text.String())
+}
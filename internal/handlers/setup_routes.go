@@ -4,9 +4,12 @@ import (
 	"context"
 
 	handlersCommon "booking_client/internal/handlers/common"
+	"booking_client/internal/handlers/router"
+	"booking_client/internal/models"
 )
 
-// setupRoutes registers all callback handlers with the router
+// setupRoutes registers all callback handlers with the router.
+// Routes declare the role they need; role selection is open to everyone.
 func (h *Handler) setupRoutes() {
 	// Initial selection
 	h.callbackRouter.RegisterExact(handlersCommon.CallbackClient, func(ctx context.Context, chatID int64, _ string, messageID int) {
@@ -15,124 +18,135 @@ func (h *Handler) setupRoutes() {
 	h.callbackRouter.RegisterExact(handlersCommon.CallbackProfessional, func(ctx context.Context, chatID int64, _ string, messageID int) {
 		h.professionalHandler.StartSignIn(ctx, chatID, messageID)
 	})
+	h.callbackRouter.RegisterExact(handlersCommon.CallbackProfessionalSignUp, func(ctx context.Context, chatID int64, _ string, messageID int) {
+		h.professionalHandler.StartSignUp(ctx, chatID, messageID)
+	})
+
+	// Trying again after an outage, when there is no button to press again
+	h.callbackRouter.RegisterExact(handlersCommon.CallbackRetryStart, func(ctx context.Context, chatID int64, _ string, messageID int) {
+		h.handleStart(ctx, chatID, "", messageID)
+	})
 
 	// Client callbacks
 	h.callbackRouter.RegisterExact(handlersCommon.CallbackBookAppointment, func(ctx context.Context, chatID int64, _ string, messageID int) {
 		h.clientHandler.HandleBookAppointment(ctx, chatID, messageID)
-	})
+	}, router.RequireRole(models.RoleClient))
 	h.callbackRouter.RegisterExact(handlersCommon.CallbackPendingAppointments, func(ctx context.Context, chatID int64, _ string, messageID int) {
 		h.clientHandler.HandlePendingAppointments(ctx, chatID, messageID)
-	})
+	}, router.RequireRole(models.RoleClient))
 	h.callbackRouter.RegisterExact(handlersCommon.CallbackUpcomingAppointments, func(ctx context.Context, chatID int64, _ string, messageID int) {
 		h.clientHandler.HandleUpcomingAppointments(ctx, chatID, messageID)
-	})
+	}, router.RequireRole(models.RoleClient))
+	// Fully booked days only tell the client to pick another one
+	h.callbackRouter.RegisterAlert(handlersCommon.CallbackDateFullyBooked, handlersCommon.AlertMsgNoFreeSlots,
+		router.RequireRole(models.RoleClient))
 	h.callbackRouter.RegisterExact(handlersCommon.CallbackCancelBooking, func(ctx context.Context, chatID int64, _ string, messageID int) {
 		h.clientHandler.HandleCancelBooking(ctx, chatID, messageID)
-	})
+	}, router.RequireRole(models.RoleClient))
 
 	// Professional callbacks
 	h.callbackRouter.RegisterExact(handlersCommon.CallbackProfessionalPendingAppointments, func(ctx context.Context, chatID int64, _ string, messageID int) {
 		h.professionalHandler.HandlePendingAppointments(ctx, chatID, messageID)
-	})
+	}, router.RequireRole(models.RoleProfessional))
 	h.callbackRouter.RegisterExact(handlersCommon.CallbackProfessionalUpcomingAppointments, func(ctx context.Context, chatID int64, _ string, messageID int) {
 		h.professionalHandler.HandleUpcomingAppointments(ctx, chatID, messageID)
-	})
+	}, router.RequireRole(models.RoleProfessional))
 	h.callbackRouter.RegisterExact(handlersCommon.CallbackProfessionalTimetable, func(ctx context.Context, chatID int64, _ string, messageID int) {
 		h.professionalHandler.HandleTimetable(ctx, chatID, messageID)
-	})
+	}, router.RequireRole(models.RoleProfessional))
 	h.callbackRouter.RegisterExact(handlersCommon.CallbackSetUnavailable, func(ctx context.Context, chatID int64, _ string, messageID int) {
 		h.professionalHandler.HandleSetUnavailable(ctx, chatID, messageID)
-	})
+	}, router.RequireRole(models.RoleProfessional))
 	h.callbackRouter.RegisterExact(handlersCommon.CallbackProfessionalPreviousAppointments, func(ctx context.Context, chatID int64, _ string, messageID int) {
 		h.professionalHandler.HandlePreviousAppointments(ctx, chatID, messageID)
-	})
+	}, router.RequireRole(models.RoleProfessional))
 
 	// Unavailable navigation
 	h.callbackRouter.RegisterPrefix(handlersCommon.CallbackPrefixPrevUnavailableMonth, func(ctx context.Context, chatID int64, month string, messageID int) {
 		h.professionalHandler.HandleUnavailableMonthNavigation(ctx, chatID, month, handlersCommon.DirectionPrev, messageID)
-	})
+	}, router.RequireRole(models.RoleProfessional))
 	h.callbackRouter.RegisterPrefix(handlersCommon.CallbackPrefixNextUnavailableMonth, func(ctx context.Context, chatID int64, month string, messageID int) {
 		h.professionalHandler.HandleUnavailableMonthNavigation(ctx, chatID, month, handlersCommon.DirectionNext, messageID)
-	})
+	}, router.RequireRole(models.RoleProfessional))
 	h.callbackRouter.RegisterExact(handlersCommon.CallbackCancelUnavailable, func(ctx context.Context, chatID int64, _ string, messageID int) {
 		h.professionalHandler.HandleCancelUnavailable(ctx, chatID, messageID)
-	})
+	}, router.RequireRole(models.RoleProfessional))
 
 	// Professional timetable navigation
 	h.callbackRouter.RegisterPrefix(handlersCommon.CallbackPrefixPrevTimetableDay, func(ctx context.Context, chatID int64, date string, messageID int) {
 		h.professionalHandler.HandleTimetableDateNavigation(ctx, chatID, date, handlersCommon.DirectionPrev, messageID)
-	})
+	}, router.RequireRole(models.RoleProfessional))
 	h.callbackRouter.RegisterPrefix(handlersCommon.CallbackPrefixNextTimetableDay, func(ctx context.Context, chatID int64, date string, messageID int) {
 		h.professionalHandler.HandleTimetableDateNavigation(ctx, chatID, date, handlersCommon.DirectionNext, messageID)
-	})
+	}, router.RequireRole(models.RoleProfessional))
 
 	// Professional upcoming appointments navigation
 	h.callbackRouter.RegisterPrefix(handlersCommon.CallbackPrefixPrevUpcomingMonth, func(ctx context.Context, chatID int64, month string, messageID int) {
 		h.professionalHandler.HandleUpcomingAppointmentsMonthNavigation(ctx, chatID, month, handlersCommon.DirectionPrev, messageID)
-	})
+	}, router.RequireRole(models.RoleProfessional))
 	h.callbackRouter.RegisterPrefix(handlersCommon.CallbackPrefixNextUpcomingMonth, func(ctx context.Context, chatID int64, month string, messageID int) {
 		h.professionalHandler.HandleUpcomingAppointmentsMonthNavigation(ctx, chatID, month, handlersCommon.DirectionNext, messageID)
-	})
+	}, router.RequireRole(models.RoleProfessional))
 	h.callbackRouter.RegisterPrefix(handlersCommon.CallbackPrefixSelectUpcomingDate, func(ctx context.Context, chatID int64, date string, messageID int) {
 		h.professionalHandler.HandleUpcomingAppointmentsDateSelection(ctx, chatID, date, messageID)
-	})
+	}, router.RequireRole(models.RoleProfessional))
 
 	// Client booking flow - month navigation
 	h.callbackRouter.RegisterPrefix(handlersCommon.CallbackPrefixPrevMonth, func(ctx context.Context, chatID int64, month string, messageID int) {
 		h.clientHandler.HandleBookAppointmentsMonthNavigation(ctx, chatID, month, handlersCommon.DirectionPrev, messageID)
-	})
+	}, router.RequireRole(models.RoleClient))
 	h.callbackRouter.RegisterPrefix(handlersCommon.CallbackPrefixNextMonth, func(ctx context.Context, chatID int64, month string, messageID int) {
 		h.clientHandler.HandleBookAppointmentsMonthNavigation(ctx, chatID, month, handlersCommon.DirectionNext, messageID)
-	})
+	}, router.RequireRole(models.RoleClient))
 
 	// Selection callbacks
 	h.callbackRouter.RegisterPrefix(handlersCommon.CallbackPrefixSelectProfessional, func(ctx context.Context, chatID int64, professionalID string, messageID int) {
 		h.clientHandler.HandleProfessionalSelection(ctx, chatID, professionalID, messageID)
-	})
+	}, router.RequireRole(models.RoleClient))
 	h.callbackRouter.RegisterPrefix(handlersCommon.CallbackPrefixSelectDate, func(ctx context.Context, chatID int64, date string, messageID int) {
 		h.clientHandler.HandleDateSelection(ctx, chatID, date, messageID)
-	})
+	}, router.RequireRole(models.RoleClient))
 	h.callbackRouter.RegisterPrefix(handlersCommon.CallbackPrefixSelectTime, func(ctx context.Context, chatID int64, startTime string, messageID int) {
 		h.clientHandler.HandleTimeSelection(ctx, chatID, startTime, messageID)
-	})
+	}, router.RequireRole(models.RoleClient))
 
-	// Appointment actions
+	// Appointment actions (cancel buttons are shown to both roles)
 	h.callbackRouter.RegisterPrefix(handlersCommon.CallbackPrefixCancelAppointment, func(ctx context.Context, chatID int64, appointmentID string, messageID int) {
 		h.clientHandler.HandleCancelAppointment(ctx, chatID, appointmentID, messageID)
-	})
+	}, router.RequireRole(router.AnyRole))
 	h.callbackRouter.RegisterPrefix(handlersCommon.CallbackPrefixConfirmAppointment, func(ctx context.Context, chatID int64, appointmentID string, messageID int) {
 		h.professionalHandler.HandleConfirmAppointment(ctx, chatID, appointmentID, messageID)
-	})
+	}, router.RequireRole(models.RoleProfessional))
 	h.callbackRouter.RegisterPrefix(handlersCommon.CallbackPrefixCancelProfAppt, func(ctx context.Context, chatID int64, appointmentID string, messageID int) {
 		h.professionalHandler.HandleCancelAppointment(ctx, chatID, appointmentID, messageID)
-	})
+	}, router.RequireRole(models.RoleProfessional))
 
 	// Unavailable flow
 	h.callbackRouter.RegisterPrefix(handlersCommon.CallbackPrefixSelectUnavailableDate, func(ctx context.Context, chatID int64, date string, messageID int) {
 		h.professionalHandler.HandleUnavailableDateSelection(ctx, chatID, date, messageID)
-	})
+	}, router.RequireRole(models.RoleProfessional))
 	h.callbackRouter.RegisterPrefix(handlersCommon.CallbackPrefixSelectUnavailableStart, func(ctx context.Context, chatID int64, startTime string, messageID int) {
 		h.professionalHandler.HandleUnavailableStartTimeSelection(ctx, chatID, startTime, messageID)
-	})
+	}, router.RequireRole(models.RoleProfessional))
 	h.callbackRouter.RegisterPrefix(handlersCommon.CallbackPrefixSelectUnavailableEnd, func(ctx context.Context, chatID int64, endTime string, messageID int) {
 		h.professionalHandler.HandleUnavailableEndTimeSelection(ctx, chatID, endTime, messageID)
-	})
+	}, router.RequireRole(models.RoleProfessional))
 
 	// Previous appointments flow
 	h.callbackRouter.RegisterPrefix(handlersCommon.CallbackPrefixSelectClient, func(ctx context.Context, chatID int64, clientID string, messageID int) {
 		h.professionalHandler.HandleClientSelection(ctx, chatID, clientID, messageID)
-	})
+	}, router.RequireRole(models.RoleProfessional))
 	h.callbackRouter.RegisterPrefix(handlersCommon.CallbackPrefixPrevPreviousMonth, func(ctx context.Context, chatID int64, month string, messageID int) {
 		h.professionalHandler.HandlePreviousAppointmentsMonthNavigation(ctx, chatID, month, handlersCommon.DirectionPrev, messageID)
-	})
+	}, router.RequireRole(models.RoleProfessional))
 	h.callbackRouter.RegisterPrefix(handlersCommon.CallbackPrefixNextPreviousMonth, func(ctx context.Context, chatID int64, month string, messageID int) {
 		h.professionalHandler.HandlePreviousAppointmentsMonthNavigation(ctx, chatID, month, handlersCommon.DirectionNext, messageID)
-	})
+	}, router.RequireRole(models.RoleProfessional))
 
 	// Back to dashboard (special case - needs user lookup)
 	h.callbackRouter.RegisterExact(handlersCommon.CallbackBackToDashboard, func(ctx context.Context, chatID int64, _ string, messageID int) {
-		user, exists := h.apiService.GetUserRepository().GetUser(chatID)
-		if !exists || user == nil {
+		session, exists := h.apiService.GetUserRepository().GetSession(chatID)
+		if !exists || session == nil {
 			text := "❌ User session not found. Please use /start to begin."
 			if err := h.bot.SendMessage(chatID, text); err != nil {
 				// Use base logger for system errors in callback registration
@@ -141,10 +155,10 @@ func (h *Handler) setupRoutes() {
 			return
 		}
 		// Show appropriate dashboard based on user role
-		if user.Role == "professional" {
-			h.professionalHandler.ShowDashboard(ctx, chatID, user, messageID)
+		if session.Profile.Role == models.RoleProfessional {
+			h.professionalHandler.ShowDashboard(ctx, chatID, messageID)
 		} else {
 			h.clientHandler.ShowDashboard(ctx, chatID, messageID)
 		}
-	})
+	}, router.RequireRole(router.AnyRole))
 }
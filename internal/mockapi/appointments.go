@@ -0,0 +1,368 @@
+package mockapi
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"booking_client/internal/models"
+	"booking_client/internal/schemas"
+	"booking_client/internal/util"
+)
+
+// createAppointment serves POST /api/appointments
+func (s *Server) createAppointment(w http.ResponseWriter, r *http.Request, body []byte) {
+	var req struct {
+		ClientID       string `json:"client_id"`
+		ProfessionalID string `json:"professional_id"`
+		StartTime      string `json:"start_time"`
+		EndTime        string `json:"end_time"`
+	}
+	if !decodeBody(w, r, body, &req) {
+		return
+	}
+	start, end, ok := parsePeriod(w, r, req.StartTime, req.EndTime)
+	if !ok {
+		return
+	}
+	if !s.isClient(req.ClientID) || !s.isProfessional(req.ProfessionalID) {
+		writeError(w, r, http.StatusNotFound, "not_found", "client or professional not found")
+		return
+	}
+	if !start.After(time.Now()) {
+		writeError(w, r, http.StatusBadRequest, "bad_request", "appointment must start in the future")
+		return
+	}
+	if s.overlapping(req.ProfessionalID, start, end) != nil {
+		writeError(w, r, http.StatusConflict, "conflict", "time slot is not available")
+		return
+	}
+
+	apt := s.storeAppointment(Appointment{
+		Type:           TypeAppointment,
+		ClientID:       req.ClientID,
+		ProfessionalID: req.ProfessionalID,
+		StartTime:      start,
+		EndTime:        end,
+		Status:         StatusPending,
+	})
+
+	client := s.users[apt.ClientID]
+	writeJSON(w, http.StatusCreated, schemas.CreateAppointmentResponse{
+		Appointment: models.Appointment{
+			ID:          apt.ID,
+			Type:        apt.Type,
+			StartTime:   formatTime(apt.StartTime),
+			EndTime:     formatTime(apt.EndTime),
+			Status:      apt.Status,
+			Description: apt.Description,
+			CreatedAt:   formatTime(apt.CreatedAt),
+			UpdatedAt:   formatTime(apt.UpdatedAt),
+		},
+		Client: models.Client{
+			ID:        client.ID,
+			FirstName: client.FirstName,
+			LastName:  client.LastName,
+			ChatID:    derefChatID(client.ChatID),
+		},
+		Professional: s.toProfessional(apt.ProfessionalID),
+	})
+}
+
+// createUnavailable serves POST /api/professionals/{id}/unavailable_appointments
+func (s *Server) createUnavailable(w http.ResponseWriter, r *http.Request, professionalID string, body []byte) {
+	var req struct {
+		StartAt     string `json:"start_at"`
+		EndAt       string `json:"end_at"`
+		Description string `json:"description"`
+	}
+	if !decodeBody(w, r, body, &req) {
+		return
+	}
+	start, end, ok := parsePeriod(w, r, req.StartAt, req.EndAt)
+	if !ok {
+		return
+	}
+	if !s.isProfessional(professionalID) {
+		writeError(w, r, http.StatusNotFound, "not_found", "professional not found")
+		return
+	}
+	if s.overlapping(professionalID, start, end) != nil {
+		writeError(w, r, http.StatusConflict, "conflict", "period overlaps an existing appointment")
+		return
+	}
+
+	apt := s.storeAppointment(Appointment{
+		Type:           TypeUnavailable,
+		ProfessionalID: professionalID,
+		StartTime:      start,
+		EndTime:        end,
+		Status:         StatusConfirmed,
+		Description:    req.Description,
+	})
+
+	writeJSON(w, http.StatusCreated, schemas.CreateUnavailableAppointmentResponse{
+		Appointment: schemas.UnavailableAppointment{
+			ID:          apt.ID,
+			Type:        apt.Type,
+			StartTime:   formatTime(apt.StartTime),
+			EndTime:     formatTime(apt.EndTime),
+			Status:      apt.Status,
+			Description: apt.Description,
+			CreatedAt:   formatTime(apt.CreatedAt),
+			UpdatedAt:   formatTime(apt.UpdatedAt),
+		},
+	})
+}
+
+// listClientAppointments serves GET /api/clients/{id}/appointments.
+// Only upcoming appointments are listed, like the booking API does.
+func (s *Server) listClientAppointments(w http.ResponseWriter, r *http.Request, clientID, status string) {
+	if !s.isClient(clientID) {
+		writeError(w, r, http.StatusNotFound, "not_found", "client not found")
+		return
+	}
+
+	now := time.Now()
+	response := schemas.GetClientAppointmentsResponse{Appointments: []schemas.ClientAppointment{}}
+	for _, apt := range s.sortedAppointments() {
+		if apt.ClientID != clientID || (status != "" && apt.Status != status) || apt.EndTime.Before(now) {
+			continue
+		}
+		professional := s.toClientAppointmentProfessional(apt.ProfessionalID)
+		response.Appointments = append(response.Appointments, schemas.ClientAppointment{
+			ID:           apt.ID,
+			Type:         apt.Type,
+			StartTime:    formatTime(apt.StartTime),
+			EndTime:      formatTime(apt.EndTime),
+			Status:       apt.Status,
+			Description:  apt.Description,
+			CreatedAt:    formatTime(apt.CreatedAt),
+			UpdatedAt:    formatTime(apt.UpdatedAt),
+			Professional: &professional,
+		})
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+// listProfessionalAppointments serves GET /api/professionals/{id}/appointments
+// with optional status and date (YYYY-MM-DD) filters
+func (s *Server) listProfessionalAppointments(w http.ResponseWriter, r *http.Request, professionalID, status, date string) {
+	if !s.isProfessional(professionalID) {
+		writeError(w, r, http.StatusNotFound, "not_found", "professional not found")
+		return
+	}
+
+	now := time.Now()
+	response := schemas.GetProfessionalAppointmentsResponse{Appointments: []schemas.ProfessionalAppointment{}}
+	for _, apt := range s.sortedAppointments() {
+		if apt.ProfessionalID != professionalID || apt.Type != TypeAppointment || apt.EndTime.Before(now) {
+			continue
+		}
+		if status != "" && apt.Status != status {
+			continue
+		}
+		if date != "" && localDate(apt.StartTime) != date {
+			continue
+		}
+		response.Appointments = append(response.Appointments, s.toProfessionalAppointment(apt))
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+// confirmAppointment serves PATCH /api/professionals/{id}/appointments/{id}/confirm
+func (s *Server) confirmAppointment(w http.ResponseWriter, r *http.Request, professionalID, appointmentID string) {
+	apt, exists := s.appointments[appointmentID]
+	if !exists || apt.ProfessionalID != professionalID || apt.Type != TypeAppointment {
+		writeError(w, r, http.StatusNotFound, "not_found", "appointment not found")
+		return
+	}
+	if apt.Status != StatusPending {
+		writeError(w, r, http.StatusConflict, "conflict", fmt.Sprintf("appointment is %s", apt.Status))
+		return
+	}
+	apt.Status = StatusConfirmed
+	apt.UpdatedAt = time.Now()
+
+	writeJSON(w, http.StatusOK, schemas.ConfirmProfessionalAppointmentResponse{
+		Appointment:  s.toProfessionalAppointment(apt),
+		Client:       s.toProfessionalAppointmentClient(apt.ClientID),
+		Professional: s.toProfessional(apt.ProfessionalID),
+	})
+}
+
+// cancelAppointment serves the client and professional cancel endpoints
+func (s *Server) cancelAppointment(w http.ResponseWriter, r *http.Request, body []byte, ownerID, appointmentID, cancelledBy string) {
+	var req struct {
+		CancellationReason string `json:"cancellation_reason"`
+	}
+	if !decodeBody(w, r, body, &req) {
+		return
+	}
+	if req.CancellationReason == "" {
+		writeError(w, r, http.StatusBadRequest, "bad_request", "cancellation_reason is required")
+		return
+	}
+
+	apt, exists := s.appointments[appointmentID]
+	owned := exists && ((cancelledBy == "client" && apt.ClientID == ownerID) ||
+		(cancelledBy == "professional" && apt.ProfessionalID == ownerID))
+	if !owned || apt.Type != TypeAppointment {
+		writeError(w, r, http.StatusNotFound, "not_found", "appointment not found")
+		return
+	}
+	if apt.Status == StatusCancelled {
+		writeError(w, r, http.StatusConflict, "conflict", "appointment is already cancelled")
+		return
+	}
+	apt.Status = StatusCancelled
+	apt.CancellationReason = req.CancellationReason
+	apt.CancelledBy = cancelledBy
+	apt.UpdatedAt = time.Now()
+
+	cancelled := models.CancelledAppointment{
+		ID:                 apt.ID,
+		Type:               apt.Type,
+		StartTime:          formatTime(apt.StartTime),
+		EndTime:            formatTime(apt.EndTime),
+		Status:             apt.Status,
+		Description:        apt.Description,
+		CancellationReason: apt.CancellationReason,
+		CancelledBy:        apt.CancelledBy,
+		CreatedAt:          formatTime(apt.CreatedAt),
+		UpdatedAt:          formatTime(apt.UpdatedAt),
+	}
+
+	if cancelledBy == "client" {
+		client := s.users[apt.ClientID]
+		writeJSON(w, http.StatusOK, schemas.CancelClientAppointmentResponse{
+			Appointment: cancelled,
+			Client: schemas.ClientAppointmentClient{
+				ID:          client.ID,
+				FirstName:   client.FirstName,
+				LastName:    client.LastName,
+				PhoneNumber: client.PhoneNumber,
+				ChatID:      client.ChatID,
+			},
+			Professional: s.toClientAppointmentProfessional(apt.ProfessionalID),
+		})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, schemas.CancelProfessionalAppointmentResponse{
+		Appointment:  schemas.CancelledAppointment(cancelled),
+		Client:       s.toProfessionalAppointmentClient(apt.ClientID),
+		Professional: s.toProfessional(apt.ProfessionalID),
+	})
+}
+
+// storeAppointment assigns an ID and timestamps and stores the appointment
+func (s *Server) storeAppointment(apt Appointment) *Appointment {
+	apt.ID = s.newID("apt")
+	apt.CreatedAt = time.Now()
+	apt.UpdatedAt = apt.CreatedAt
+	s.appointments[apt.ID] = &apt
+	return &apt
+}
+
+// overlapping returns an active appointment of the professional overlapping the period
+func (s *Server) overlapping(professionalID string, start, end time.Time) *Appointment {
+	for _, apt := range s.sortedAppointments() {
+		if apt.ProfessionalID != professionalID || apt.Status == StatusCancelled {
+			continue
+		}
+		if apt.StartTime.Before(end) && start.Before(apt.EndTime) {
+			return apt
+		}
+	}
+	return nil
+}
+
+// toProfessionalAppointment converts an appointment to the professional view
+func (s *Server) toProfessionalAppointment(apt *Appointment) schemas.ProfessionalAppointment {
+	result := schemas.ProfessionalAppointment{
+		ID:          apt.ID,
+		Type:        apt.Type,
+		StartTime:   formatTime(apt.StartTime),
+		EndTime:     formatTime(apt.EndTime),
+		Status:      apt.Status,
+		Description: apt.Description,
+		CreatedAt:   formatTime(apt.CreatedAt),
+		UpdatedAt:   formatTime(apt.UpdatedAt),
+	}
+	if apt.ClientID != "" {
+		client := s.toProfessionalAppointmentClient(apt.ClientID)
+		result.Client = &client
+	}
+	return result
+}
+
+// toProfessional converts a stored professional to the response shape
+func (s *Server) toProfessional(id string) schemas.Professional {
+	u := s.users[id]
+	professional := schemas.Professional{
+		ID:        u.ID,
+		Username:  u.Username,
+		FirstName: u.FirstName,
+		LastName:  u.LastName,
+		ChatID:    derefChatID(u.ChatID),
+	}
+	if u.PhoneNumber != nil {
+		professional.PhoneNumber = *u.PhoneNumber
+	}
+	return professional
+}
+
+// toClientAppointmentProfessional converts a stored professional to the shape used in client responses
+func (s *Server) toClientAppointmentProfessional(id string) schemas.ClientAppointmentProfessional {
+	u := s.users[id]
+	return schemas.ClientAppointmentProfessional{
+		ID:          u.ID,
+		Username:    u.Username,
+		FirstName:   u.FirstName,
+		LastName:    u.LastName,
+		PhoneNumber: u.PhoneNumber,
+		ChatID:      u.ChatID,
+	}
+}
+
+// toProfessionalAppointmentClient converts a stored client to the shape used in professional responses
+func (s *Server) toProfessionalAppointmentClient(id string) schemas.ProfessionalAppointmentClient {
+	u := s.users[id]
+	return schemas.ProfessionalAppointmentClient{
+		ID:          u.ID,
+		FirstName:   u.FirstName,
+		LastName:    u.LastName,
+		PhoneNumber: u.PhoneNumber,
+		ChatID:      u.ChatID,
+	}
+}
+
+// parsePeriod parses an RFC3339 start and end, answering 400 if invalid
+func parsePeriod(w http.ResponseWriter, r *http.Request, startStr, endStr string) (time.Time, time.Time, bool) {
+	start, startErr := time.Parse(time.RFC3339, startStr)
+	end, endErr := time.Parse(time.RFC3339, endStr)
+	if startErr != nil || endErr != nil || !end.After(start) {
+		writeError(w, r, http.StatusBadRequest, "bad_request", "invalid time period")
+		return time.Time{}, time.Time{}, false
+	}
+	return start, end, true
+}
+
+// formatTime formats a time as RFC3339 in the app timezone
+func formatTime(t time.Time) string {
+	return t.In(util.GetAppTimezone()).Format(time.RFC3339)
+}
+
+// localDate returns the YYYY-MM-DD date of t in the app timezone
+func localDate(t time.Time) string {
+	return t.In(util.GetAppTimezone()).Format("2006-01-02")
+}
+
+// derefChatID returns the chat ID or 0 if unset
+func derefChatID(chatID *int64) int64 {
+	if chatID == nil {
+		return 0
+	}
+	return *chatID
+}
@@ -0,0 +1,151 @@
+package mockapi
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"booking_client/internal/schemas"
+	"booking_client/internal/util"
+)
+
+// availability serves GET /api/professionals/{id}/availability with hourly slots
+func (s *Server) availability(w http.ResponseWriter, r *http.Request, professionalID, date string) {
+	day, err := time.ParseInLocation("2006-01-02", date, util.GetAppTimezone())
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, "bad_request", "invalid date, expected YYYY-MM-DD")
+		return
+	}
+	if !s.isProfessional(professionalID) {
+		writeError(w, r, http.StatusNotFound, "not_found", "professional not found")
+		return
+	}
+
+	response := schemas.ProfessionalAvailabilityResponse{Date: date, Slots: []schemas.TimeSlot{}}
+	for hour := FirstSlotHour; hour < LastSlotHour; hour++ {
+		start := time.Date(day.Year(), day.Month(), day.Day(), hour, 0, 0, 0, day.Location())
+		end := start.Add(time.Hour)
+		slot := schemas.TimeSlot{
+			StartTime: formatTime(start),
+			EndTime:   formatTime(end),
+			Available: true,
+		}
+		if apt := s.overlapping(professionalID, start, end); apt != nil {
+			slot.Available = false
+			slot.Type = apt.Type
+			slot.Description = s.describe(apt)
+		}
+		response.Slots = append(response.Slots, slot)
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+// appointmentDates serves GET /api/professionals/{id}/appointment_dates,
+// the days of a month (YYYY-MM, default current) with upcoming confirmed appointments
+func (s *Server) appointmentDates(w http.ResponseWriter, r *http.Request, professionalID, month string) {
+	if month == "" {
+		month = util.NowInAppTimezone().Format("2006-01")
+	}
+	if _, err := time.Parse("2006-01", month); err != nil {
+		writeError(w, r, http.StatusBadRequest, "bad_request", "invalid month, expected YYYY-MM")
+		return
+	}
+	if !s.isProfessional(professionalID) {
+		writeError(w, r, http.StatusNotFound, "not_found", "professional not found")
+		return
+	}
+
+	now := time.Now()
+	seen := make(map[string]bool)
+	dates := []string{}
+	for _, apt := range s.sortedAppointments() {
+		if apt.ProfessionalID != professionalID || apt.Type != TypeAppointment || apt.Status != StatusConfirmed {
+			continue
+		}
+		date := localDate(apt.StartTime)
+		if apt.EndTime.Before(now) || date[:7] != month || seen[date] {
+			continue
+		}
+		seen[date] = true
+		dates = append(dates, date)
+	}
+	writeJSON(w, http.StatusOK, schemas.GetProfessionalAppointmentDatesResponse{Month: month, Dates: dates})
+}
+
+// timetable serves GET /api/professionals/{id}/timetable,
+// the confirmed appointments and unavailable periods of a day
+func (s *Server) timetable(w http.ResponseWriter, r *http.Request, professionalID, date string) {
+	if _, err := time.Parse("2006-01-02", date); err != nil {
+		writeError(w, r, http.StatusBadRequest, "bad_request", "invalid date, expected YYYY-MM-DD")
+		return
+	}
+	if !s.isProfessional(professionalID) {
+		writeError(w, r, http.StatusNotFound, "not_found", "professional not found")
+		return
+	}
+
+	response := schemas.GetProfessionalTimetableResponse{Date: date, Appointments: []schemas.TimetableAppointment{}}
+	for _, apt := range s.sortedAppointments() {
+		if apt.ProfessionalID != professionalID || apt.Status != StatusConfirmed || localDate(apt.StartTime) != date {
+			continue
+		}
+		response.Appointments = append(response.Appointments, schemas.TimetableAppointment{
+			ID:          apt.ID,
+			StartTime:   formatTime(apt.StartTime),
+			EndTime:     formatTime(apt.EndTime),
+			Description: s.describe(apt),
+		})
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+// previousAppointments serves GET /api/professionals/{id}/previous_appointments,
+// the past confirmed appointments with a client, optionally limited to a month (YYYY-MM)
+func (s *Server) previousAppointments(w http.ResponseWriter, r *http.Request, professionalID, clientID, month string) {
+	if month != "" {
+		if _, err := time.Parse("2006-01", month); err != nil {
+			writeError(w, r, http.StatusBadRequest, "bad_request", "invalid month, expected YYYY-MM")
+			return
+		}
+	}
+	if !s.isProfessional(professionalID) || !s.isClient(clientID) {
+		writeError(w, r, http.StatusNotFound, "not_found", "professional or client not found")
+		return
+	}
+
+	now := time.Now()
+	sorted := s.sortedAppointments()
+	appointments := []schemas.PreviousAppointment{}
+	// Most recent first
+	for i := len(sorted) - 1; i >= 0; i-- {
+		apt := sorted[i]
+		if apt.ProfessionalID != professionalID || apt.ClientID != clientID || apt.Status != StatusConfirmed {
+			continue
+		}
+		if !apt.EndTime.Before(now) || (month != "" && localDate(apt.StartTime)[:7] != month) {
+			continue
+		}
+		appointments = append(appointments, schemas.PreviousAppointment{
+			ID:          apt.ID,
+			StartTime:   formatTime(apt.StartTime),
+			EndTime:     formatTime(apt.EndTime),
+			Description: s.describe(apt),
+		})
+	}
+	writeJSON(w, http.StatusOK, schemas.GetPreviousAppointmentsByClientResponse{Appointments: appointments})
+}
+
+// describe returns the text shown for an appointment in schedules
+func (s *Server) describe(apt *Appointment) string {
+	if apt.Type == TypeUnavailable {
+		return apt.Description
+	}
+	client, exists := s.users[apt.ClientID]
+	if !exists {
+		return apt.Description
+	}
+	if apt.Description != "" {
+		return fmt.Sprintf("%s %s: %s", client.FirstName, client.LastName, apt.Description)
+	}
+	return fmt.Sprintf("%s %s", client.FirstName, client.LastName)
+}
@@ -0,0 +1,444 @@
+// Package mockapi is an in-memory implementation of the booking API.
+// It serves every endpoint used by APIService with the shapes of the schemas
+// package, checks JWTs like the real backend and keeps all state in memory.
+package mockapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"booking_client/internal/models"
+	"booking_client/internal/token"
+
+	"github.com/rs/zerolog"
+)
+
+// Slot range served by the availability endpoint (app timezone)
+const (
+	FirstSlotHour = 9
+	LastSlotHour  = 17
+)
+
+// Appointment statuses
+const (
+	StatusPending   = "pending"
+	StatusConfirmed = "confirmed"
+	StatusCancelled = "cancelled"
+)
+
+// Appointment types
+const (
+	TypeAppointment = "appointment"
+	TypeUnavailable = "unavailable"
+)
+
+// Appointment is an appointment or unavailable period stored by the server
+type Appointment struct {
+	ID                 string
+	Type               string
+	ClientID           string
+	ProfessionalID     string
+	StartTime          time.Time
+	EndTime            time.Time
+	Status             string
+	Description        string
+	CancellationReason string
+	CancelledBy        string
+	CreatedAt          time.Time
+	UpdatedAt          time.Time
+}
+
+// RecordedRequest is a single request received by the server
+type RecordedRequest struct {
+	Method string
+	Path   string
+	Query  string
+	Body   string
+	// IdempotencyKey is the Idempotency-Key header, empty when not sent
+	IdempotencyKey string
+	// Subject and Role identify the user a delegated token acts for; both are empty for service tokens
+	Subject string
+	Role    string
+}
+
+// user is a stored client or professional
+type user struct {
+	models.User
+	password string
+}
+
+// Server is the in-memory booking API
+type Server struct {
+	tokenMaker token.Maker
+	logger     *zerolog.Logger
+
+	mu           sync.Mutex
+	nextID       int
+	users        map[string]*user
+	appointments map[string]*Appointment
+	requests     []RecordedRequest
+	failures     []int
+	lostReplies  []int
+	// targetedFailures are injected failures of requests to one path and query, by "path?query"
+	targetedFailures map[string][]int
+	// replies keeps the response to each idempotency key, replayed when the key is sent again
+	replies map[string]*httptest.ResponseRecorder
+}
+
+// Ensure Server implements http.Handler
+var _ http.Handler = (*Server)(nil)
+
+// NewServer creates an empty mock API that accepts tokens verified by tokenMaker
+func NewServer(tokenMaker token.Maker, logger *zerolog.Logger) *Server {
+	return &Server{
+		tokenMaker:   tokenMaker,
+		logger:       logger,
+		users:        make(map[string]*user),
+		appointments: make(map[string]*Appointment),
+		replies:      make(map[string]*httptest.ResponseRecorder),
+	}
+}
+
+// AddProfessional stores a professional that can sign in with the given credentials
+func (s *Server) AddProfessional(username, password, firstName, lastName string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.createUser("professional", registerRequest{
+		FirstName: firstName,
+		LastName:  lastName,
+		Username:  username,
+		Password:  password,
+	}).ID
+}
+
+// AddClient stores an already registered client bound to the chat
+func (s *Server) AddClient(chatID int64, firstName, lastName string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.createUser("client", registerRequest{
+		FirstName: firstName,
+		LastName:  lastName,
+		ChatID:    chatID,
+	}).ID
+}
+
+// AddAppointment stores an appointment as is (e.g. past appointments for history views)
+func (s *Server) AddAppointment(apt Appointment) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if apt.ID == "" {
+		apt.ID = s.newID("apt")
+	}
+	if apt.Type == "" {
+		apt.Type = TypeAppointment
+	}
+	if apt.CreatedAt.IsZero() {
+		apt.CreatedAt = time.Now()
+		apt.UpdatedAt = apt.CreatedAt
+	}
+	s.appointments[apt.ID] = &apt
+	return apt.ID
+}
+
+// Appointments returns a copy of all stored appointments ordered by start time
+func (s *Server) Appointments() []Appointment {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	result := make([]Appointment, 0, len(s.appointments))
+	for _, apt := range s.sortedAppointments() {
+		result = append(result, *apt)
+	}
+	return result
+}
+
+// Requests returns a copy of all received requests in order
+func (s *Server) Requests() []RecordedRequest {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]RecordedRequest(nil), s.requests...)
+}
+
+// FailNext makes the next authorized request fail with the given HTTP status
+func (s *Server) FailNext(status int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures = append(s.failures, status)
+}
+
+// FailNextTo makes the next authorized request to path with the raw query fail with the
+// given HTTP status, leaving requests elsewhere alone
+func (s *Server) FailNextTo(path, query string, status int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.targetedFailures == nil {
+		s.targetedFailures = make(map[string][]int)
+	}
+	target := path + "?" + query
+	s.targetedFailures[target] = append(s.targetedFailures[target], status)
+}
+
+// LoseNextReply makes the next authorized request take effect but answer with the given
+// HTTP status, as when a gateway times out after the API did the work
+func (s *Server) LoseNextReply(status int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lostReplies = append(s.lostReplies, status)
+}
+
+// ServeHTTP authenticates the request and dispatches it to the matching endpoint
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, "bad_request", "failed to read body")
+		return
+	}
+
+	s.logger.Debug().
+		Str("method", r.Method).
+		Str("path", r.URL.Path).
+		Str("query", r.URL.RawQuery).
+		Msg("Mock API request")
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	payload, authErr := s.authenticate(r)
+	recorded := RecordedRequest{
+		Method:         r.Method,
+		Path:           r.URL.Path,
+		Query:          r.URL.RawQuery,
+		Body:           string(body),
+		IdempotencyKey: r.Header.Get("Idempotency-Key"),
+	}
+	if payload != nil && payload.Delegated() {
+		recorded.Subject = payload.Subject
+		recorded.Role = payload.Role
+	}
+	s.requests = append(s.requests, recorded)
+
+	if authErr != nil {
+		// Rejected tokens are challenged, unlike wrong passwords, so clients know to mint a new one
+		w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+		writeError(w, r, http.StatusUnauthorized, "unauthorized", authErr.Error())
+		return
+	}
+	if err := authorize(payload, r.URL.Path); err != nil {
+		writeError(w, r, http.StatusForbidden, "forbidden", err.Error())
+		return
+	}
+
+	target := r.URL.Path + "?" + r.URL.RawQuery
+	if statuses := s.targetedFailures[target]; len(statuses) > 0 {
+		s.targetedFailures[target] = statuses[1:]
+		writeError(w, r, statuses[0], "injected_failure", "injected failure")
+		return
+	}
+	if len(s.failures) > 0 {
+		status := s.failures[0]
+		s.failures = s.failures[1:]
+		writeError(w, r, status, "injected_failure", "injected failure")
+		return
+	}
+
+	// A repeated idempotency key gets the first response again without redoing the work
+	key := recorded.IdempotencyKey
+	if key != "" {
+		key = r.Method + " " + r.URL.Path + " " + key
+		if reply, exists := s.replies[key]; exists {
+			replay(w, reply)
+			return
+		}
+	}
+
+	reply := httptest.NewRecorder()
+	s.route(reply, r, body)
+	if key != "" {
+		s.replies[key] = reply
+	}
+
+	if len(s.lostReplies) > 0 {
+		status := s.lostReplies[0]
+		s.lostReplies = s.lostReplies[1:]
+		writeError(w, r, status, "injected_failure", "reply lost after the request took effect")
+		return
+	}
+	replay(w, reply)
+}
+
+// replay writes a recorded response
+func replay(w http.ResponseWriter, reply *httptest.ResponseRecorder) {
+	for name, values := range reply.Header() {
+		w.Header()[name] = values
+	}
+	w.WriteHeader(reply.Code)
+	w.Write(reply.Body.Bytes())
+}
+
+// authenticate verifies the bearer token like the booking API does and returns its payload
+func (s *Server) authenticate(r *http.Request) (*token.Payload, error) {
+	header := r.Header.Get("Authorization")
+	if !strings.HasPrefix(header, "Bearer ") {
+		return nil, fmt.Errorf("missing bearer token")
+	}
+	return s.tokenMaker.VerifyToken(strings.TrimPrefix(header, "Bearer "))
+}
+
+// authorize limits delegated tokens to the resources of their own user: a client token may
+// only use /api/clients/<its id>/..., a professional token /api/professionals/<its id>/....
+// Service tokens, and resources of the other role (e.g. availability for clients), are not limited.
+func authorize(payload *token.Payload, path string) error {
+	if !payload.Delegated() {
+		return nil
+	}
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	if len(segments) < 4 || segments[0] != "api" || segments[1] != payload.Role+"s" {
+		return nil
+	}
+	if segments[2] != payload.Subject {
+		return fmt.Errorf("%s %s may not act for %s", payload.Role, payload.Subject, segments[2])
+	}
+	return nil
+}
+
+// route dispatches a request by method and path
+func (s *Server) route(w http.ResponseWriter, r *http.Request, body []byte) {
+	segments := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	query := r.URL.Query()
+
+	switch r.Method {
+	case http.MethodGet:
+		if p, ok := match(segments, "api", "users", "*"); ok {
+			s.getUser(w, r, p[0])
+		} else if _, ok := match(segments, "api", "professionals"); ok {
+			s.listProfessionals(w, r)
+		} else if p, ok := match(segments, "api", "professionals", "*", "availability"); ok {
+			s.availability(w, r, p[0], query.Get("date"))
+		} else if p, ok := match(segments, "api", "professionals", "*", "appointments"); ok {
+			s.listProfessionalAppointments(w, r, p[0], query.Get("status"), query.Get("date"))
+		} else if p, ok := match(segments, "api", "professionals", "*", "appointment_dates"); ok {
+			s.appointmentDates(w, r, p[0], query.Get("month"))
+		} else if p, ok := match(segments, "api", "professionals", "*", "timetable"); ok {
+			s.timetable(w, r, p[0], query.Get("date"))
+		} else if p, ok := match(segments, "api", "professionals", "*", "clients"); ok {
+			s.listProfessionalClients(w, r, p[0])
+		} else if p, ok := match(segments, "api", "professionals", "*", "previous_appointments"); ok {
+			s.previousAppointments(w, r, p[0], query.Get("client_id"), query.Get("month"))
+		} else if p, ok := match(segments, "api", "clients", "*", "appointments"); ok {
+			s.listClientAppointments(w, r, p[0], query.Get("status"))
+		} else {
+			s.notFound(w, r)
+		}
+	case http.MethodPost:
+		if _, ok := match(segments, "api", "clients", "register"); ok {
+			s.registerClient(w, r, body)
+		} else if _, ok := match(segments, "api", "professionals", "register"); ok {
+			s.registerProfessional(w, r, body)
+		} else if _, ok := match(segments, "api", "professionals", "sign_in"); ok {
+			s.signIn(w, r, body)
+		} else if _, ok := match(segments, "api", "professionals", "sign_out"); ok {
+			s.signOut(w, r, body)
+		} else if _, ok := match(segments, "api", "professionals", "verify_password"); ok {
+			s.verifyPassword(w, r, body)
+		} else if _, ok := match(segments, "api", "professionals", "change_password"); ok {
+			s.changePassword(w, r, body)
+		} else if p, ok := match(segments, "api", "professionals", "*", "unavailable_appointments"); ok {
+			s.createUnavailable(w, r, p[0], body)
+		} else if _, ok := match(segments, "api", "appointments"); ok {
+			s.createAppointment(w, r, body)
+		} else {
+			s.notFound(w, r)
+		}
+	case http.MethodPatch:
+		if p, ok := match(segments, "api", "clients", "*", "appointments", "*", "cancel"); ok {
+			s.cancelAppointment(w, r, body, p[0], p[1], "client")
+		} else if p, ok := match(segments, "api", "professionals", "*", "appointments", "*", "confirm"); ok {
+			s.confirmAppointment(w, r, p[0], p[1])
+		} else if p, ok := match(segments, "api", "professionals", "*", "appointments", "*", "cancel"); ok {
+			s.cancelAppointment(w, r, body, p[0], p[1], "professional")
+		} else {
+			s.notFound(w, r)
+		}
+	default:
+		writeError(w, r, http.StatusMethodNotAllowed, "method_not_allowed", "method not allowed")
+	}
+}
+
+// notFound answers requests without a matching endpoint
+func (s *Server) notFound(w http.ResponseWriter, r *http.Request) {
+	writeError(w, r, http.StatusNotFound, "not_found", fmt.Sprintf("no route for %s %s", r.Method, r.URL.Path))
+}
+
+// newID returns a unique, readable ID with the given prefix
+func (s *Server) newID(prefix string) string {
+	s.nextID++
+	return fmt.Sprintf("%s-%d", prefix, s.nextID)
+}
+
+// sortedAppointments returns the stored appointments ordered by start time
+func (s *Server) sortedAppointments() []*Appointment {
+	result := make([]*Appointment, 0, len(s.appointments))
+	for _, apt := range s.appointments {
+		result = append(result, apt)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].StartTime.Equal(result[j].StartTime) {
+			return result[i].ID < result[j].ID
+		}
+		return result[i].StartTime.Before(result[j].StartTime)
+	})
+	return result
+}
+
+// match compares path segments against a pattern where "*" captures one segment
+func match(segments []string, pattern ...string) ([]string, bool) {
+	if len(segments) != len(pattern) {
+		return nil, false
+	}
+	var params []string
+	for i, part := range pattern {
+		if part == "*" {
+			params = append(params, segments[i])
+			continue
+		}
+		if segments[i] != part {
+			return nil, false
+		}
+	}
+	return params, true
+}
+
+// decodeBody unmarshals a JSON request body, answering 400 on failure
+func decodeBody(w http.ResponseWriter, r *http.Request, body []byte, v interface{}) bool {
+	if err := json.Unmarshal(body, v); err != nil {
+		writeError(w, r, http.StatusBadRequest, "bad_request", "invalid request body")
+		return false
+	}
+	return true
+}
+
+// writeJSON writes a JSON response with the given status
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+// writeError writes an error response in the booking API's format
+func writeError(w http.ResponseWriter, r *http.Request, status int, errorType, message string) {
+	writeJSON(w, status, map[string]string{
+		"error":      errorType,
+		"message":    message,
+		"request_id": r.Header.Get("X-Request-ID"),
+	})
+}
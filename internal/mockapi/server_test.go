@@ -0,0 +1,171 @@
+package mockapi_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"booking_client/internal/mockapi"
+	"booking_client/internal/schemas"
+	"booking_client/internal/token"
+	"booking_client/internal/util"
+
+	"github.com/rs/zerolog"
+)
+
+const testSecret = "mockapi-test-secret-0123456789abcdef"
+
+// newServer starts a mock API and returns it with a valid bearer token
+func newServer(t *testing.T) (*mockapi.Server, *httptest.Server, string) {
+	t.Helper()
+
+	maker, err := token.NewJWTMaker(testSecret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	authToken, err := maker.CreateToken("booking_client", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	logger := zerolog.Nop()
+	api := mockapi.NewServer(maker, &logger)
+	server := httptest.NewServer(api)
+	t.Cleanup(server.Close)
+	return api, server, authToken
+}
+
+// do performs a request and decodes the JSON response into result
+func do(t *testing.T, method, url, authToken string, body, result interface{}) int {
+	t.Helper()
+
+	var reader *bytes.Reader
+	if body != nil {
+		data, _ := json.Marshal(body)
+		reader = bytes.NewReader(data)
+	} else {
+		reader = bytes.NewReader(nil)
+	}
+	req, _ := http.NewRequest(method, url, reader)
+	if authToken != "" {
+		req.Header.Set("Authorization", "Bearer "+authToken)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if result != nil {
+		json.NewDecoder(resp.Body).Decode(result)
+	}
+	return resp.StatusCode
+}
+
+func TestServerRejectsInvalidTokens(t *testing.T) {
+	_, server, _ := newServer(t)
+
+	otherMaker, _ := token.NewJWTMaker("another-secret-0123456789abcdefghij")
+	forged, _ := otherMaker.CreateToken("booking_client", time.Minute)
+	maker, _ := token.NewJWTMaker(testSecret)
+	expired, _ := maker.CreateToken("booking_client", -time.Minute)
+
+	for name, authToken := range map[string]string{
+		"missing": "",
+		"forged":  forged,
+		"expired": expired,
+		"garbage": "not-a-jwt",
+	} {
+		if status := do(t, http.MethodGet, server.URL+"/api/professionals", authToken, nil, nil); status != http.StatusUnauthorized {
+			t.Errorf("%s token: got status %d, want 401", name, status)
+		}
+	}
+}
+
+func TestUnavailablePeriodBlocksAvailability(t *testing.T) {
+	api, server, authToken := newServer(t)
+	profID := api.AddProfessional("anna", "secret", "Anna", "Smith")
+
+	day := util.NowInAppTimezone().AddDate(0, 0, 2)
+	at := func(hour int) string {
+		return time.Date(day.Year(), day.Month(), day.Day(), hour, 0, 0, 0, util.GetAppTimezone()).Format(time.RFC3339)
+	}
+
+	var created schemas.CreateUnavailableAppointmentResponse
+	status := do(t, http.MethodPost, server.URL+"/api/professionals/"+profID+"/unavailable_appointments", authToken,
+		map[string]string{"professional_id": profID, "start_at": at(12), "end_at": at(14), "description": "Lunch"}, &created)
+	if status != http.StatusCreated || created.Appointment.Description != "Lunch" {
+		t.Fatalf("create unavailable: status %d, response %+v", status, created)
+	}
+
+	var availability schemas.ProfessionalAvailabilityResponse
+	do(t, http.MethodGet, server.URL+"/api/professionals/"+profID+"/availability?date="+day.Format("2006-01-02"), authToken, nil, &availability)
+	blocked := 0
+	for _, slot := range availability.Slots {
+		if !slot.Available {
+			blocked++
+			if slot.Type != mockapi.TypeUnavailable || slot.Description != "Lunch" {
+				t.Errorf("unexpected blocked slot %+v", slot)
+			}
+		}
+	}
+	if blocked != 2 {
+		t.Fatalf("got %d blocked slots, want 2", blocked)
+	}
+
+	var timetable schemas.GetProfessionalTimetableResponse
+	do(t, http.MethodGet, server.URL+"/api/professionals/"+profID+"/timetable?date="+day.Format("2006-01-02"), authToken, nil, &timetable)
+	if len(timetable.Appointments) != 1 || timetable.Appointments[0].Description != "Lunch" {
+		t.Fatalf("unexpected timetable %+v", timetable)
+	}
+
+	status = do(t, http.MethodPost, server.URL+"/api/professionals/"+profID+"/unavailable_appointments", authToken,
+		map[string]string{"professional_id": profID, "start_at": at(13), "end_at": at(15), "description": "Overlap"}, nil)
+	if status != http.StatusConflict {
+		t.Fatalf("overlapping period: got status %d, want 409", status)
+	}
+}
+
+func TestDelegatedTokensActOnlyForTheirUser(t *testing.T) {
+	api, server, _ := newServer(t)
+	annaID := api.AddProfessional("anna", "secret", "Anna", "Smith")
+	markID := api.AddProfessional("mark", "secret", "Mark", "Brown")
+	johnID := api.AddClient(100, "John", "Doe")
+	janeID := api.AddClient(101, "Jane", "Roe")
+
+	maker, _ := token.NewJWTMaker(testSecret)
+	asUser := func(userID, role string, chatID int64) string {
+		authToken, err := maker.CreateUserToken("booking_client", token.Actor{UserID: userID, Role: role, ChatID: chatID}, time.Minute)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return authToken
+	}
+	john := asUser(johnID, "client", 100)
+	anna := asUser(annaID, "professional", 200)
+	day := util.NowInAppTimezone().AddDate(0, 0, 2).Format("2006-01-02")
+
+	for _, tc := range []struct {
+		name      string
+		authToken string
+		path      string
+		want      int
+	}{
+		{"own appointments", john, "/api/clients/" + johnID + "/appointments", http.StatusOK},
+		{"another client's appointments", john, "/api/clients/" + janeID + "/appointments", http.StatusForbidden},
+		{"availability of a professional", john, "/api/professionals/" + annaID + "/availability?date=" + day, http.StatusOK},
+		{"own timetable", anna, "/api/professionals/" + annaID + "/timetable?date=" + day, http.StatusOK},
+		{"another professional's timetable", anna, "/api/professionals/" + markID + "/timetable?date=" + day, http.StatusForbidden},
+	} {
+		if status := do(t, http.MethodGet, server.URL+tc.path, tc.authToken, nil, nil); status != tc.want {
+			t.Errorf("%s: got status %d, want %d", tc.name, status, tc.want)
+		}
+	}
+
+	requests := api.Requests()
+	if last := requests[len(requests)-1]; last.Subject != annaID || last.Role != "professional" {
+		t.Errorf("delegated identity not recorded: %+v", last)
+	}
+}
@@ -0,0 +1,258 @@
+package mockapi
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"booking_client/internal/models"
+	"booking_client/internal/schemas"
+)
+
+// registerRequest is the body of the client and professional register endpoints
+type registerRequest struct {
+	FirstName   string  `json:"first_name"`
+	LastName    string  `json:"last_name"`
+	ChatID      int64   `json:"chat_id"`
+	PhoneNumber *string `json:"phone_number"`
+	Username    string  `json:"username"`
+	Password    string  `json:"password"`
+}
+
+// getUser serves GET /api/users/{chat_id}
+func (s *Server) getUser(w http.ResponseWriter, r *http.Request, chatIDParam string) {
+	chatID, err := strconv.ParseInt(chatIDParam, 10, 64)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, "bad_request", "invalid chat id")
+		return
+	}
+	u := s.userByChatID(chatID)
+	if u == nil {
+		writeError(w, r, http.StatusNotFound, "not_found", "user not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]models.User{"user": u.User})
+}
+
+// registerClient serves POST /api/clients/register
+func (s *Server) registerClient(w http.ResponseWriter, r *http.Request, body []byte) {
+	var req registerRequest
+	if !decodeBody(w, r, body, &req) {
+		return
+	}
+	if req.FirstName == "" || req.LastName == "" || req.ChatID == 0 {
+		writeError(w, r, http.StatusBadRequest, "bad_request", "first_name, last_name and chat_id are required")
+		return
+	}
+	if s.userByChatID(req.ChatID) != nil {
+		writeError(w, r, http.StatusConflict, "conflict", "user already registered")
+		return
+	}
+
+	u := s.createUser("client", req)
+	writeJSON(w, http.StatusCreated, schemas.ClientRegisterResponse{
+		ID:          u.ID,
+		ChatID:      u.ChatID,
+		FirstName:   u.FirstName,
+		LastName:    u.LastName,
+		PhoneNumber: u.PhoneNumber,
+		CreatedAt:   u.CreatedAt,
+		UpdatedAt:   u.UpdatedAt,
+		Role:        u.Role,
+	})
+}
+
+// registerProfessional serves POST /api/professionals/register
+func (s *Server) registerProfessional(w http.ResponseWriter, r *http.Request, body []byte) {
+	var req registerRequest
+	if !decodeBody(w, r, body, &req) {
+		return
+	}
+	if req.FirstName == "" || req.LastName == "" || req.Username == "" || req.Password == "" {
+		writeError(w, r, http.StatusBadRequest, "bad_request", "first_name, last_name, username and password are required")
+		return
+	}
+	for _, existing := range s.users {
+		if existing.Role == "professional" && existing.Username == req.Username {
+			writeError(w, r, http.StatusConflict, "conflict", "username already taken")
+			return
+		}
+	}
+
+	u := s.createUser("professional", req)
+	writeJSON(w, http.StatusCreated, map[string]models.User{"user": u.User})
+}
+
+// signIn serves POST /api/professionals/sign_in and binds the chat to the professional
+func (s *Server) signIn(w http.ResponseWriter, r *http.Request, body []byte) {
+	var req struct {
+		Username string `json:"username"`
+		Password string `json:"password"`
+		ChatID   int64  `json:"chat_id"`
+	}
+	if !decodeBody(w, r, body, &req) {
+		return
+	}
+
+	for _, u := range s.users {
+		if u.Role == "professional" && u.Username == req.Username && u.password == req.Password {
+			chatID := req.ChatID
+			u.ChatID = &chatID
+			u.UpdatedAt = time.Now().Format(time.RFC3339)
+			writeJSON(w, http.StatusOK, map[string]models.User{"user": u.User})
+			return
+		}
+	}
+	writeError(w, r, http.StatusUnauthorized, "unauthorized", "invalid username or password")
+}
+
+// signOut serves POST /api/professionals/sign_out and unbinds the chat from its professional
+func (s *Server) signOut(w http.ResponseWriter, r *http.Request, body []byte) {
+	var req struct {
+		ChatID int64 `json:"chat_id"`
+	}
+	if !decodeBody(w, r, body, &req) {
+		return
+	}
+
+	u := s.userByChatID(req.ChatID)
+	if u == nil || u.Role != "professional" {
+		writeError(w, r, http.StatusNotFound, "not_found", "no professional signed in to this chat")
+		return
+	}
+	u.ChatID = nil
+	u.UpdatedAt = time.Now().Format(time.RFC3339)
+	writeJSON(w, http.StatusOK, map[string]models.User{"user": u.User})
+}
+
+// verifyPassword serves POST /api/professionals/verify_password for the professional bound to the chat
+func (s *Server) verifyPassword(w http.ResponseWriter, r *http.Request, body []byte) {
+	var req struct {
+		ChatID   int64  `json:"chat_id"`
+		Password string `json:"password"`
+	}
+	if !decodeBody(w, r, body, &req) {
+		return
+	}
+
+	u := s.userByChatID(req.ChatID)
+	if u == nil || u.Role != "professional" {
+		writeError(w, r, http.StatusNotFound, "not_found", "no professional signed in to this chat")
+		return
+	}
+	if u.password != req.Password {
+		writeError(w, r, http.StatusUnauthorized, "unauthorized", "wrong password")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{})
+}
+
+// changePassword serves POST /api/professionals/change_password for the professional bound to the chat
+func (s *Server) changePassword(w http.ResponseWriter, r *http.Request, body []byte) {
+	var req struct {
+		ChatID      int64  `json:"chat_id"`
+		NewPassword string `json:"new_password"`
+	}
+	if !decodeBody(w, r, body, &req) {
+		return
+	}
+	if req.NewPassword == "" {
+		writeError(w, r, http.StatusBadRequest, "bad_request", "new_password is required")
+		return
+	}
+
+	u := s.userByChatID(req.ChatID)
+	if u == nil || u.Role != "professional" {
+		writeError(w, r, http.StatusNotFound, "not_found", "no professional signed in to this chat")
+		return
+	}
+	u.password = req.NewPassword
+	u.UpdatedAt = time.Now().Format(time.RFC3339)
+	writeJSON(w, http.StatusOK, map[string]models.User{"user": u.User})
+}
+
+// listProfessionals serves GET /api/professionals
+func (s *Server) listProfessionals(w http.ResponseWriter, r *http.Request) {
+	professionals := []models.User{}
+	for _, u := range s.users {
+		if u.Role == "professional" {
+			professionals = append(professionals, u.User)
+		}
+	}
+	sort.Slice(professionals, func(i, j int) bool { return professionals[i].ID < professionals[j].ID })
+	writeJSON(w, http.StatusOK, schemas.GetProfessionalsResponse{Professionals: professionals})
+}
+
+// listProfessionalClients serves GET /api/professionals/{id}/clients
+func (s *Server) listProfessionalClients(w http.ResponseWriter, r *http.Request, professionalID string) {
+	if !s.isProfessional(professionalID) {
+		writeError(w, r, http.StatusNotFound, "not_found", "professional not found")
+		return
+	}
+
+	seen := make(map[string]bool)
+	clients := []schemas.ProfessionalClient{}
+	for _, apt := range s.sortedAppointments() {
+		if apt.ProfessionalID != professionalID || apt.Type != TypeAppointment || seen[apt.ClientID] {
+			continue
+		}
+		seen[apt.ClientID] = true
+		client := s.users[apt.ClientID]
+		clients = append(clients, schemas.ProfessionalClient{
+			ID:        client.ID,
+			FirstName: client.FirstName,
+			LastName:  client.LastName,
+		})
+	}
+	sort.Slice(clients, func(i, j int) bool {
+		return clients[i].LastName+clients[i].FirstName < clients[j].LastName+clients[j].FirstName
+	})
+	writeJSON(w, http.StatusOK, map[string][]schemas.ProfessionalClient{"clients": clients})
+}
+
+// createUser stores a newly registered user
+func (s *Server) createUser(role string, req registerRequest) *user {
+	now := time.Now().Format(time.RFC3339)
+	u := &user{
+		User: models.User{
+			ID:          s.newID(role),
+			Username:    req.Username,
+			FirstName:   req.FirstName,
+			LastName:    req.LastName,
+			PhoneNumber: req.PhoneNumber,
+			Role:        role,
+			CreatedAt:   now,
+			UpdatedAt:   now,
+		},
+		password: req.Password,
+	}
+	if req.ChatID != 0 {
+		chatID := req.ChatID
+		u.ChatID = &chatID
+	}
+	s.users[u.ID] = u
+	return u
+}
+
+// userByChatID finds the user bound to the chat
+func (s *Server) userByChatID(chatID int64) *user {
+	for _, u := range s.users {
+		if u.ChatID != nil && *u.ChatID == chatID {
+			return u
+		}
+	}
+	return nil
+}
+
+// isProfessional reports whether the ID belongs to a professional
+func (s *Server) isProfessional(id string) bool {
+	u, exists := s.users[id]
+	return exists && u.Role == "professional"
+}
+
+// isClient reports whether the ID belongs to a client
+func (s *Server) isClient(id string) bool {
+	u, exists := s.users[id]
+	return exists && u.Role == "client"
+}
@@ -3,13 +3,26 @@ package models
 // User states for bot interaction
 const (
 	StateNone                = ""
-	StateWaitingForRole      = "waiting_for_role"
 	StateWaitingForFirstName = "waiting_for_first_name"
 	StateWaitingForLastName  = "waiting_for_last_name"
 	StateWaitingForPhone     = "waiting_for_phone"
 	StateWaitingForUsername  = "waiting_for_username"
 	StateWaitingForPassword  = "waiting_for_password"
 
+	// Professional registration states
+	StateWaitingForInviteCode            = "waiting_for_invite_code"
+	StateWaitingForProfessionalFirstName = "waiting_for_professional_first_name"
+	StateWaitingForProfessionalLastName  = "waiting_for_professional_last_name"
+	StateWaitingForProfessionalPhone     = "waiting_for_professional_phone"
+	StateWaitingForNewUsername           = "waiting_for_new_username"
+	StateWaitingForNewPassword           = "waiting_for_new_password"
+	StateWaitingForPasswordConfirmation  = "waiting_for_password_confirmation"
+
+	// Password change states
+	StateWaitingForCurrentPassword             = "waiting_for_current_password"
+	StateWaitingForChangedPassword             = "waiting_for_changed_password"
+	StateWaitingForChangedPasswordConfirmation = "waiting_for_changed_password_confirmation"
+
 	// Appointment booking states
 	StateWaitingForProfessionalSelection = "waiting_for_professional_selection"
 	StateWaitingForDateSelection         = "waiting_for_date_selection"
@@ -22,4 +35,7 @@ const (
 	StateWaitingForUnavailableStartTime     = "waiting_for_unavailable_start_time"
 	StateWaitingForUnavailableEndTime       = "waiting_for_unavailable_end_time"
 	StateWaitingForUnavailableDescription   = "waiting_for_unavailable_description"
+
+	// Previous appointments states
+	StateBrowsingPreviousAppointments = "browsing_previous_appointments"
 )
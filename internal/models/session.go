@@ -0,0 +1,229 @@
+package models
+
+import (
+	"bytes"
+	"time"
+)
+
+// Session is the bot-side state of one chat: the cached API profile and the
+// conversation the chat is currently in
+type Session struct {
+	Profile          User         `json:"profile"`                      // Cached profile, zero until registered or signed in
+	ProfileSyncedAt  time.Time    `json:"profile_synced_at,omitempty"`  // When the profile was last loaded from the API
+	Conversation     Conversation `json:"conversation"`                 // Current multi-step flow
+	Referral         *Referral    `json:"referral,omitempty"`           // Deep link the chat started from, kept until registration completes
+	LastMessageID    *int         `json:"last_message_id,omitempty"`    // ID of the last message sent to user
+	MessagesToDelete []*int       `json:"messages_to_delete,omitempty"` // IDs of messages to delete
+	MenuRole         string       `json:"menu_role,omitempty"`          // Role whose command menu was last published to the chat, empty for the default menu
+}
+
+// Referral is the payload of the t.me/<bot>?start=<payload> link a chat started from
+type Referral struct {
+	ProfessionalID string `json:"professional_id,omitempty"` // Professional to book with once registered
+	Campaign       string `json:"campaign,omitempty"`        // Campaign the link belongs to, for attribution
+}
+
+// NewSession creates an empty session for the chat
+func NewSession(chatID int64) *Session {
+	return &Session{Profile: User{ChatID: &chatID}}
+}
+
+// SignedOut returns an empty session for the chat that remembers the menu published to it,
+// so the menu can be switched back to the default one
+func (s *Session) SignedOut(chatID int64) *Session {
+	signedOut := NewSession(chatID)
+	signedOut.MenuRole = s.MenuRole
+	return signedOut
+}
+
+// Clone returns a deep copy of the session, so stores never share a session with the
+// goroutines reading and changing it
+func (s *Session) Clone() *Session {
+	if s == nil {
+		return nil
+	}
+	clone := *s
+	clone.Profile = s.Profile.Clone()
+	clone.Conversation = s.Conversation.Clone()
+	clone.Referral = clonePtr(s.Referral)
+	clone.LastMessageID = clonePtr(s.LastMessageID)
+	if s.MessagesToDelete != nil {
+		clone.MessagesToDelete = make([]*int, len(s.MessagesToDelete))
+		for i, id := range s.MessagesToDelete {
+			clone.MessagesToDelete[i] = clonePtr(id)
+		}
+	}
+	return &clone
+}
+
+// IsRegistered reports whether the session holds a profile known to the API
+func (s *Session) IsRegistered() bool {
+	return s.Profile.ID != ""
+}
+
+// SetProfile replaces the cached profile, leaving the conversation untouched
+func (s *Session) SetProfile(user User, syncedAt time.Time) {
+	s.Profile = user
+	s.ProfileSyncedAt = syncedAt
+}
+
+// InConversation reports whether the chat is in the middle of a multi-step flow
+func (s *Session) InConversation() bool {
+	return s.Conversation.State != StateNone
+}
+
+// TrackMessage remembers messages for deletion, the last one as the last message.
+// Zero IDs (no message) are ignored.
+func (s *Session) TrackMessage(messageIDs ...int) {
+	for _, messageID := range messageIDs {
+		if messageID == 0 {
+			continue
+		}
+		id := messageID
+		s.LastMessageID = &id
+		s.MessagesToDelete = append(s.MessagesToDelete, &id)
+	}
+}
+
+// Conversation flows
+const (
+	FlowNone                 = ""
+	FlowRegistration         = "registration"
+	FlowSignIn               = "sign_in"
+	FlowProfessionalSignUp   = "professional_sign_up"
+	FlowChangePassword       = "change_password"
+	FlowBooking              = "booking"
+	FlowCancellation         = "cancellation"
+	FlowUnavailable          = "unavailable"
+	FlowPreviousAppointments = "previous_appointments"
+)
+
+// Conversation is the state of one flow together with its typed scratch data.
+// Only the payload of the current flow is set; Start and Clear reset the others.
+type Conversation struct {
+	Flow      string    `json:"flow,omitempty"`
+	State     string    `json:"state,omitempty"`
+	EnteredAt time.Time `json:"entered_at,omitempty"` // When the current state was entered
+
+	Registration         *RegistrationData         `json:"registration,omitempty"`
+	SignIn               *SignInData               `json:"sign_in,omitempty"`
+	ProfessionalSignUp   *ProfessionalSignUpData   `json:"professional_sign_up,omitempty"`
+	ChangePassword       *ChangePasswordData       `json:"change_password,omitempty"`
+	Booking              *BookingData              `json:"booking,omitempty"`
+	Cancellation         *CancellationData         `json:"cancellation,omitempty"`
+	Unavailable          *UnavailableData          `json:"unavailable,omitempty"`
+	PreviousAppointments *PreviousAppointmentsData `json:"previous_appointments,omitempty"`
+}
+
+// RegistrationData is collected during client registration
+type RegistrationData struct {
+	FirstName string `json:"first_name,omitempty"`
+	LastName  string `json:"last_name,omitempty"`
+}
+
+// SignInData is collected during professional sign-in
+type SignInData struct {
+	Username string `json:"username,omitempty"`
+}
+
+// ProfessionalSignUpData is collected during professional self-registration.
+// Only a salted hash of the password is kept until it is confirmed.
+type ProfessionalSignUpData struct {
+	FirstName    string  `json:"first_name,omitempty"`
+	LastName     string  `json:"last_name,omitempty"`
+	PhoneNumber  *string `json:"phone_number,omitempty"`
+	Username     string  `json:"username,omitempty"`
+	PasswordSalt []byte  `json:"password_salt,omitempty"`
+	PasswordHash []byte  `json:"password_hash,omitempty"`
+}
+
+// ChangePasswordData holds the salted hash of the new password until it is confirmed
+type ChangePasswordData struct {
+	PasswordSalt []byte `json:"password_salt,omitempty"`
+	PasswordHash []byte `json:"password_hash,omitempty"`
+}
+
+// BookingData is collected while a client books an appointment
+type BookingData struct {
+	ProfessionalID string `json:"professional_id,omitempty"`
+	Date           string `json:"date,omitempty"`
+}
+
+// CancellationData identifies the appointment waiting for a cancellation reason
+type CancellationData struct {
+	AppointmentID string `json:"appointment_id"`
+}
+
+// UnavailableData is collected while a professional blocks a period
+type UnavailableData struct {
+	Date      string `json:"date,omitempty"`
+	StartTime string `json:"start_time,omitempty"`
+	EndTime   string `json:"end_time,omitempty"`
+}
+
+// PreviousAppointmentsData is the client whose past appointments are browsed
+type PreviousAppointmentsData struct {
+	ClientID string `json:"client_id"`
+}
+
+// Start begins a flow in the given state with an empty payload for it
+func (c *Conversation) Start(flow, state string) {
+	*c = Conversation{Flow: flow, State: state}
+	switch flow {
+	case FlowRegistration:
+		c.Registration = &RegistrationData{}
+	case FlowSignIn:
+		c.SignIn = &SignInData{}
+	case FlowProfessionalSignUp:
+		c.ProfessionalSignUp = &ProfessionalSignUpData{}
+	case FlowChangePassword:
+		c.ChangePassword = &ChangePasswordData{}
+	case FlowBooking:
+		c.Booking = &BookingData{}
+	case FlowCancellation:
+		c.Cancellation = &CancellationData{}
+	case FlowUnavailable:
+		c.Unavailable = &UnavailableData{}
+	case FlowPreviousAppointments:
+		c.PreviousAppointments = &PreviousAppointmentsData{}
+	}
+}
+
+// Clone returns a deep copy of the conversation and the payload of its flow
+func (c Conversation) Clone() Conversation {
+	clone := c
+	clone.Registration = clonePtr(c.Registration)
+	clone.SignIn = clonePtr(c.SignIn)
+	if c.ProfessionalSignUp != nil {
+		signUp := *c.ProfessionalSignUp
+		signUp.PhoneNumber = clonePtr(signUp.PhoneNumber)
+		signUp.PasswordSalt = bytes.Clone(signUp.PasswordSalt)
+		signUp.PasswordHash = bytes.Clone(signUp.PasswordHash)
+		clone.ProfessionalSignUp = &signUp
+	}
+	if c.ChangePassword != nil {
+		clone.ChangePassword = &ChangePasswordData{
+			PasswordSalt: bytes.Clone(c.ChangePassword.PasswordSalt),
+			PasswordHash: bytes.Clone(c.ChangePassword.PasswordHash),
+		}
+	}
+	clone.Booking = clonePtr(c.Booking)
+	clone.Cancellation = clonePtr(c.Cancellation)
+	clone.Unavailable = clonePtr(c.Unavailable)
+	clone.PreviousAppointments = clonePtr(c.PreviousAppointments)
+	return clone
+}
+
+// clonePtr returns a pointer to a copy of the value p points to, or nil
+func clonePtr[T any](p *T) *T {
+	if p == nil {
+		return nil
+	}
+	clone := *p
+	return &clone
+}
+
+// Clear ends the current flow and drops its data
+func (c *Conversation) Clear() {
+	*c = Conversation{}
+}
@@ -1,27 +1,30 @@
 package models
 
-// User represents a user in the system
+// User roles
+const (
+	RoleClient       = "client"
+	RoleProfessional = "professional"
+)
+
+// User represents a user profile as returned by the booking API
 type User struct {
-	ID                             string  `json:"id"`
-	ChatID                         *int64  `json:"chat_id,omitempty"`
-	Username                       string  `json:"username"`
-	FirstName                      string  `json:"first_name"`
-	LastName                       string  `json:"last_name"`
-	Role                           string  `json:"role"` // "client" or "professional"
-	PhoneNumber                    *string `json:"phone_number,omitempty"`
-	State                          string  `json:"state,omitempty"`                            // Bot interaction state
-	SelectedProfessionalID         string  `json:"selected_professional_id,omitempty"`         // Temporary storage for appointment booking
-	SelectedDate                   string  `json:"selected_date,omitempty"`                    // Temporary storage for selected date
-	SelectedTime                   string  `json:"selected_time,omitempty"`                    // Temporary storage for selected time
-	SelectedUnavailableStartTime   string  `json:"selected_unavailable_start_time,omitempty"`  // Temporary storage for selected unavailable start time
-	SelectedUnavailableEndTime     string  `json:"selected_unavailable_end_time,omitempty"`    // Temporary storage for selected unavailable end time
-	SelectedUnavailableDescription string  `json:"selected_unavailable_description,omitempty"` // Temporary storage for selected unavailable description
-	SelectedAppointmentID          string  `json:"selected_appointment_id,omitempty"`          // Temporary storage for appointment cancellation
-	SelectedClientID               *string `json:"selected_client_id,omitempty"`               // Temporary storage for selected client
-	LastMessageID                  *int    `json:"last_message_id,omitempty"`                  // ID of the last message sent to user
-	MessagesToDelete               []*int  `json:"messages_to_delete,omitempty"`               // IDs of messages to delete
-	CreatedAt                      string  `json:"created_at"`
-	UpdatedAt                      string  `json:"updated_at"`
+	ID          string  `json:"id"`
+	ChatID      *int64  `json:"chat_id,omitempty"`
+	Username    string  `json:"username"`
+	FirstName   string  `json:"first_name"`
+	LastName    string  `json:"last_name"`
+	Role        string  `json:"role"` // "client" or "professional"
+	PhoneNumber *string `json:"phone_number,omitempty"`
+	CreatedAt   string  `json:"created_at"`
+	UpdatedAt   string  `json:"updated_at"`
+}
+
+// Clone returns a copy of the user that shares no pointers with it
+func (u User) Clone() User {
+	clone := u
+	clone.ChatID = clonePtr(u.ChatID)
+	clone.PhoneNumber = clonePtr(u.PhoneNumber)
+	return clone
 }
 
 // Appointment represents an appointment
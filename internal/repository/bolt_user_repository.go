@@ -0,0 +1,169 @@
+package repository
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"booking_client/internal/models"
+
+	"github.com/rs/zerolog"
+	bolt "go.etcd.io/bbolt"
+)
+
+// sessionsBucket holds one record per chat, keyed by the big-endian chat ID
+var sessionsBucket = []byte("sessions")
+
+// BoltUserRepository persists sessions in an embedded BoltDB file.
+// All sessions are loaded into memory on open and every change is written
+// through, so reads stay as cheap as with MemoryUserRepository.
+type BoltUserRepository struct {
+	db     *bolt.DB
+	cache  *MemoryUserRepository
+	logger *zerolog.Logger
+}
+
+// Ensure BoltUserRepository implements UserRepository
+var _ UserRepository = (*BoltUserRepository)(nil)
+
+// NewBoltUserRepository opens (or creates) the session database at path
+// and loads all stored sessions, migrating old schema versions
+func NewBoltUserRepository(path string, logger *zerolog.Logger) (*BoltUserRepository, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create session store directory: %w", err)
+	}
+
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open session store %s: %w", path, err)
+	}
+
+	r := &BoltUserRepository{
+		db:     db,
+		cache:  NewMemoryUserRepository(),
+		logger: logger,
+	}
+	if err := r.load(); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	logger.Info().
+		Str("path", path).
+		Int("sessions", r.cache.Count()).
+		Int("schema_version", CurrentSchemaVersion()).
+		Msg("Session store opened")
+
+	return r, nil
+}
+
+// load reads all sessions into the cache and rewrites migrated records
+func (r *BoltUserRepository) load() error {
+	return r.db.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists(sessionsBucket)
+		if err != nil {
+			return fmt.Errorf("failed to create sessions bucket: %w", err)
+		}
+
+		migrated := make(map[int64]*models.Session)
+		err = bucket.ForEach(func(key, value []byte) error {
+			if len(key) != 8 {
+				r.logger.Warn().Hex("key", key).Msg("Skipping session with invalid key")
+				return nil
+			}
+			chatID := int64(binary.BigEndian.Uint64(key))
+
+			session, wasMigrated, err := decodeSession(value)
+			if err != nil {
+				return fmt.Errorf("failed to load session of chat %d: %w", chatID, err)
+			}
+			r.cache.SetSession(chatID, session)
+			if wasMigrated {
+				migrated[chatID] = session
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		for chatID, session := range migrated {
+			value, err := encodeSession(session)
+			if err != nil {
+				return err
+			}
+			if err := bucket.Put(chatKey(chatID), value); err != nil {
+				return fmt.Errorf("failed to store migrated session: %w", err)
+			}
+		}
+		if len(migrated) > 0 {
+			r.logger.Info().Int("sessions", len(migrated)).Msg("Migrated sessions to current schema version")
+		}
+		return nil
+	})
+}
+
+// GetSession retrieves the session of a chat
+func (r *BoltUserRepository) GetSession(chatID int64) (*models.Session, bool) {
+	return r.cache.GetSession(chatID)
+}
+
+// SetSession stores the session of a chat and persists it
+func (r *BoltUserRepository) SetSession(chatID int64, session *models.Session) {
+	r.cache.SetSession(chatID, session)
+
+	value, err := encodeSession(session)
+	if err == nil {
+		err = r.db.Update(func(tx *bolt.Tx) error {
+			return tx.Bucket(sessionsBucket).Put(chatKey(chatID), value)
+		})
+	}
+	if err != nil {
+		r.logger.Error().Err(err).Int64("chat_id", chatID).Msg("Failed to persist session")
+	}
+}
+
+// DeleteSession removes the session of a chat
+func (r *BoltUserRepository) DeleteSession(chatID int64) {
+	r.cache.DeleteSession(chatID)
+
+	err := r.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(sessionsBucket).Delete(chatKey(chatID))
+	})
+	if err != nil {
+		r.logger.Error().Err(err).Int64("chat_id", chatID).Msg("Failed to delete persisted session")
+	}
+}
+
+// GetAllSessions returns all stored sessions (for debugging/admin purposes)
+func (r *BoltUserRepository) GetAllSessions() map[int64]*models.Session {
+	return r.cache.GetAllSessions()
+}
+
+// SessionExists checks if a session exists for the given chat ID
+func (r *BoltUserRepository) SessionExists(chatID int64) bool {
+	return r.cache.SessionExists(chatID)
+}
+
+// Count returns the number of stored sessions
+func (r *BoltUserRepository) Count() int {
+	return r.cache.Count()
+}
+
+// Close closes the database file
+func (r *BoltUserRepository) Close() error {
+	if err := r.db.Close(); err != nil && !errors.Is(err, bolt.ErrDatabaseNotOpen) {
+		return fmt.Errorf("failed to close session store: %w", err)
+	}
+	return nil
+}
+
+// chatKey encodes a chat ID as a bucket key
+func chatKey(chatID int64) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, uint64(chatID))
+	return key
+}
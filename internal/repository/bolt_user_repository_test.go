@@ -0,0 +1,100 @@
+package repository
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"testing"
+
+	"booking_client/internal/models"
+
+	"github.com/rs/zerolog"
+	bolt "go.etcd.io/bbolt"
+)
+
+func TestBoltUserRepositorySurvivesReopen(t *testing.T) {
+	logger := zerolog.Nop()
+	path := filepath.Join(t.TempDir(), "sessions.db")
+
+	repo, err := NewBoltUserRepository(path, &logger)
+	if err != nil {
+		t.Fatalf("failed to open store: %v", err)
+	}
+	session := models.NewSession(1)
+	session.Profile.ID = "u1"
+	session.Conversation.Start(models.FlowRegistration, models.StateWaitingForLastName)
+	session.Conversation.Registration.FirstName = "John"
+	repo.SetSession(1, session)
+	repo.SetSession(2, models.NewSession(2))
+	repo.DeleteSession(2)
+	if err := repo.Close(); err != nil {
+		t.Fatalf("failed to close store: %v", err)
+	}
+
+	repo, err = NewBoltUserRepository(path, &logger)
+	if err != nil {
+		t.Fatalf("failed to reopen store: %v", err)
+	}
+	defer repo.Close()
+
+	restored, exists := repo.GetSession(1)
+	if !exists || restored.Profile.ID != "u1" || restored.Conversation.State != models.StateWaitingForLastName ||
+		restored.Conversation.Registration == nil || restored.Conversation.Registration.FirstName != "John" {
+		t.Fatalf("session not restored: %+v", restored)
+	}
+	if repo.SessionExists(2) || repo.Count() != 1 {
+		t.Fatalf("deleted session restored, count %d", repo.Count())
+	}
+}
+
+func TestBoltUserRepositoryMigratesUserRecords(t *testing.T) {
+	logger := zerolog.Nop()
+	path := filepath.Join(t.TempDir(), "sessions.db")
+
+	// Version 1 stored the profile and the booking scratch data in one user object
+	db, err := bolt.Open(path, 0o600, nil)
+	if err != nil {
+		t.Fatalf("failed to open db: %v", err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists(sessionsBucket)
+		if err != nil {
+			return err
+		}
+		return bucket.Put(chatKey(1), []byte(`{"version":1,"data":{"id":"u1","first_name":"John","role":"client",`+
+			`"state":"waiting_for_time_selection","selected_professional_id":"p1","selected_date":"2025-01-02"}}`))
+	})
+	if err != nil {
+		t.Fatalf("failed to seed db: %v", err)
+	}
+	db.Close()
+
+	repo, err := NewBoltUserRepository(path, &logger)
+	if err != nil {
+		t.Fatalf("failed to open store: %v", err)
+	}
+	defer repo.Close()
+
+	session, exists := repo.GetSession(1)
+	if !exists || session.Profile.ID != "u1" || session.Profile.FirstName != "John" {
+		t.Fatalf("profile not migrated: %+v", session)
+	}
+	conversation := session.Conversation
+	if conversation.Flow != models.FlowBooking || conversation.State != models.StateWaitingForTimeSelection ||
+		conversation.Booking == nil || conversation.Booking.ProfessionalID != "p1" || conversation.Booking.Date != "2025-01-02" {
+		t.Fatalf("conversation not migrated: %+v", conversation)
+	}
+
+	err = repo.db.View(func(tx *bolt.Tx) error {
+		var record sessionRecord
+		if err := json.Unmarshal(tx.Bucket(sessionsBucket).Get(chatKey(1)), &record); err != nil {
+			return err
+		}
+		if record.Version != CurrentSchemaVersion() {
+			t.Errorf("stored version is %d, want %d", record.Version, CurrentSchemaVersion())
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("failed to read record: %v", err)
+	}
+}
@@ -0,0 +1,292 @@
+package repository
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	"booking_client/internal/models"
+
+	"github.com/rs/zerolog"
+)
+
+// EvictionReason tells why a session was evicted
+type EvictionReason string
+
+const (
+	// EvictionIdle means the conversation state was dropped after the idle TTL
+	EvictionIdle EvictionReason = "idle"
+	// EvictionCapacity means the least recently used session was removed to stay within the cap
+	EvictionCapacity EvictionReason = "capacity"
+)
+
+// EvictionFunc is called after a session was evicted with the session as it was before
+type EvictionFunc func(chatID int64, session *models.Session, reason EvictionReason)
+
+// ExpiryOptions configures ExpiringUserRepository; zero values disable the feature
+type ExpiryOptions struct {
+	// IdleTTL abandons an unfinished conversation after this long without activity
+	IdleTTL time.Duration
+	// MaxSessions caps the number of stored sessions, evicting the least recently used
+	MaxSessions int
+	// SweepInterval is how often idle conversations are looked for
+	SweepInterval time.Duration
+}
+
+// ExpiringUserRepository bounds another UserRepository: it abandons idle
+// conversations and evicts the least recently used sessions beyond a cap.
+// Idle expiry only clears the conversation state; the profile and sign-in stay.
+type ExpiringUserRepository struct {
+	next    UserRepository
+	options ExpiryOptions
+	logger  *zerolog.Logger
+	now     func() time.Time
+
+	mu      sync.Mutex
+	lru     *list.List // of *sessionEntry, most recently used first
+	entries map[int64]*list.Element
+	onEvict EvictionFunc
+
+	stop     chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+// sessionEntry tracks the last activity of a chat
+type sessionEntry struct {
+	chatID   int64
+	lastSeen time.Time
+}
+
+// Ensure ExpiringUserRepository implements UserRepository
+var _ UserRepository = (*ExpiringUserRepository)(nil)
+
+// NewExpiringUserRepository wraps next and starts the background sweeper if an idle TTL is set.
+// Sessions already stored in next count as active from now on.
+func NewExpiringUserRepository(next UserRepository, options ExpiryOptions, logger *zerolog.Logger) *ExpiringUserRepository {
+	r := &ExpiringUserRepository{
+		next:    next,
+		options: options,
+		logger:  logger,
+		now:     time.Now,
+		lru:     list.New(),
+		entries: make(map[int64]*list.Element),
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+
+	now := r.now()
+	for chatID := range next.GetAllSessions() {
+		r.entries[chatID] = r.lru.PushFront(&sessionEntry{chatID: chatID, lastSeen: now})
+	}
+
+	if options.IdleTTL > 0 && options.SweepInterval > 0 {
+		go r.sweepLoop()
+	} else {
+		close(r.done)
+	}
+	return r
+}
+
+// OnEvict registers the function notified about evicted sessions
+func (r *ExpiringUserRepository) OnEvict(fn EvictionFunc) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.onEvict = fn
+}
+
+// GetSession retrieves the session of a chat and marks the session as active
+func (r *ExpiringUserRepository) GetSession(chatID int64) (*models.Session, bool) {
+	// Reading and touching under one lock keeps the sweeper from abandoning a session being read
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	session, exists := r.next.GetSession(chatID)
+	if exists {
+		r.touch(chatID)
+	}
+	return session, exists
+}
+
+// SetSession stores a session, evicting the least recently used sessions beyond the cap
+func (r *ExpiringUserRepository) SetSession(chatID int64, session *models.Session) {
+	r.mu.Lock()
+	r.next.SetSession(chatID, session)
+	r.touch(chatID)
+	r.mu.Unlock()
+
+	for _, evicted := range r.evictOverCapacity() {
+		r.logger.Info().Int64("chat_id", evicted.chatID).Msg("Session evicted, store is at capacity")
+		if evicted.session != nil {
+			go r.notify(evicted.chatID, evicted.session, EvictionCapacity)
+		}
+	}
+}
+
+// DeleteSession removes the session of a chat
+func (r *ExpiringUserRepository) DeleteSession(chatID int64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.delete(chatID)
+}
+
+// delete removes the session of a chat and its activity; the lock must be held
+func (r *ExpiringUserRepository) delete(chatID int64) {
+	r.next.DeleteSession(chatID)
+	if elem, exists := r.entries[chatID]; exists {
+		r.lru.Remove(elem)
+		delete(r.entries, chatID)
+	}
+}
+
+// GetAllSessions returns all stored sessions (for debugging/admin purposes)
+func (r *ExpiringUserRepository) GetAllSessions() map[int64]*models.Session {
+	return r.next.GetAllSessions()
+}
+
+// SessionExists checks if a session exists for the given chat ID
+func (r *ExpiringUserRepository) SessionExists(chatID int64) bool {
+	return r.next.SessionExists(chatID)
+}
+
+// Count returns the number of stored sessions
+func (r *ExpiringUserRepository) Count() int {
+	return r.next.Count()
+}
+
+// Close stops the sweeper and closes the wrapped repository
+func (r *ExpiringUserRepository) Close() error {
+	r.stopOnce.Do(func() { close(r.stop) })
+	<-r.done
+	return r.next.Close()
+}
+
+// Sweep abandons the conversations idle for longer than the TTL
+func (r *ExpiringUserRepository) Sweep() {
+	if r.options.IdleTTL <= 0 {
+		return
+	}
+	cutoff := r.now().Add(-r.options.IdleTTL)
+
+	for _, chatID := range r.idleSince(cutoff) {
+		abandoned, ok := r.abandon(chatID, cutoff)
+		if !ok {
+			continue
+		}
+
+		r.logger.Info().
+			Int64("chat_id", chatID).
+			Str("state", abandoned.Conversation.State).
+			Msg("Idle conversation abandoned")
+		r.notify(chatID, abandoned, EvictionIdle)
+	}
+}
+
+// abandon clears the conversation of a chat still idle since cutoff and returns the session
+// as it was. The check and the clear hold the lock GetSession and SetSession take, so a chat
+// active in the meantime is left alone.
+func (r *ExpiringUserRepository) abandon(chatID int64, cutoff time.Time) (*models.Session, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	elem, exists := r.entries[chatID]
+	if !exists || !elem.Value.(*sessionEntry).lastSeen.Before(cutoff) {
+		return nil, false
+	}
+	session, exists := r.next.GetSession(chatID)
+	if !exists || session == nil || !session.InConversation() {
+		return nil, false
+	}
+	abandoned := session.Clone()
+
+	// Sessions without an ID never finished registration, nothing to keep
+	if !session.IsRegistered() {
+		r.delete(chatID)
+	} else {
+		session.Conversation.Clear()
+		r.next.SetSession(chatID, session)
+	}
+	return abandoned, true
+}
+
+// sweepLoop runs Sweep periodically until Close
+func (r *ExpiringUserRepository) sweepLoop() {
+	defer close(r.done)
+
+	ticker := time.NewTicker(r.options.SweepInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			r.Sweep()
+		case <-r.stop:
+			return
+		}
+	}
+}
+
+// touch marks the session as most recently used; the lock must be held
+func (r *ExpiringUserRepository) touch(chatID int64) {
+	now := r.now()
+	if elem, exists := r.entries[chatID]; exists {
+		elem.Value.(*sessionEntry).lastSeen = now
+		r.lru.MoveToFront(elem)
+		return
+	}
+	r.entries[chatID] = r.lru.PushFront(&sessionEntry{chatID: chatID, lastSeen: now})
+}
+
+// idleSince returns the chats without activity since cutoff, least recent first
+func (r *ExpiringUserRepository) idleSince(cutoff time.Time) []int64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var chatIDs []int64
+	for elem := r.lru.Back(); elem != nil; elem = elem.Prev() {
+		entry := elem.Value.(*sessionEntry)
+		if !entry.lastSeen.Before(cutoff) {
+			break
+		}
+		chatIDs = append(chatIDs, entry.chatID)
+	}
+	return chatIDs
+}
+
+// evictedSession is a session removed from the store
+type evictedSession struct {
+	chatID  int64
+	session *models.Session
+}
+
+// evictOverCapacity removes the least recently used sessions beyond MaxSessions
+func (r *ExpiringUserRepository) evictOverCapacity() []evictedSession {
+	if r.options.MaxSessions <= 0 {
+		return nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var evicted []evictedSession
+	for r.lru.Len() > r.options.MaxSessions {
+		entry := r.lru.Remove(r.lru.Back()).(*sessionEntry)
+		delete(r.entries, entry.chatID)
+
+		session, _ := r.next.GetSession(entry.chatID)
+		r.next.DeleteSession(entry.chatID)
+		evicted = append(evicted, evictedSession{chatID: entry.chatID, session: session})
+	}
+	return evicted
+}
+
+// notify calls the eviction function, if any
+func (r *ExpiringUserRepository) notify(chatID int64, session *models.Session, reason EvictionReason) {
+	r.mu.Lock()
+	onEvict := r.onEvict
+	r.mu.Unlock()
+
+	if onEvict != nil {
+		onEvict(chatID, session, reason)
+	}
+}
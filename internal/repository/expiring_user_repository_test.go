@@ -0,0 +1,138 @@
+package repository
+
+import (
+	"testing"
+	"time"
+
+	"booking_client/internal/models"
+
+	"github.com/rs/zerolog"
+)
+
+// newTestExpiringRepository returns a repository with a manually advanced clock
+func newTestExpiringRepository(options ExpiryOptions) (*ExpiringUserRepository, *time.Time) {
+	logger := zerolog.Nop()
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	repo := NewExpiringUserRepository(NewMemoryUserRepository(), options, &logger)
+	repo.now = func() time.Time { return now }
+	return repo, &now
+}
+
+// testSession returns a session with the given profile ID in the given flow
+func testSession(id, flow, state string) *models.Session {
+	session := &models.Session{Profile: models.User{ID: id}}
+	session.Conversation.Start(flow, state)
+	return session
+}
+
+func TestExpiringUserRepositoryAbandonsIdleConversations(t *testing.T) {
+	repo, now := newTestExpiringRepository(ExpiryOptions{IdleTTL: 30 * time.Minute})
+	defer repo.Close()
+
+	var evicted []int64
+	repo.OnEvict(func(chatID int64, session *models.Session, reason EvictionReason) {
+		if reason != EvictionIdle || !session.InConversation() {
+			t.Errorf("unexpected eviction of chat %d: reason=%s state=%q", chatID, reason, session.Conversation.State)
+		}
+		evicted = append(evicted, chatID)
+	})
+
+	repo.SetSession(1, testSession("c1", models.FlowBooking, models.StateWaitingForTimeSelection))
+	repo.SetSession(2, testSession("", models.FlowRegistration, models.StateWaitingForLastName))
+	repo.SetSession(3, testSession("c3", models.FlowNone, models.StateNone))
+	repo.SetSession(4, testSession("c4", models.FlowBooking, models.StateWaitingForDateSelection))
+
+	*now = now.Add(20 * time.Minute)
+	repo.GetSession(4)
+	*now = now.Add(20 * time.Minute)
+	repo.Sweep()
+
+	if len(evicted) != 2 {
+		t.Fatalf("evicted chats %v, want [1 2]", evicted)
+	}
+	session, exists := repo.GetSession(1)
+	if !exists || session.InConversation() || session.Conversation.Booking != nil || session.Profile.ID != "c1" {
+		t.Fatalf("idle conversation not cleared but profile kept: %+v", session)
+	}
+	if repo.SessionExists(2) {
+		t.Fatal("unfinished registration was not removed")
+	}
+	if session, _ := repo.GetSession(4); session.Conversation.State != models.StateWaitingForDateSelection {
+		t.Fatalf("active conversation abandoned, state %q", session.Conversation.State)
+	}
+}
+
+func TestExpiringUserRepositoryEvictsLeastRecentlyUsed(t *testing.T) {
+	repo, now := newTestExpiringRepository(ExpiryOptions{MaxSessions: 2})
+	defer repo.Close()
+
+	for chatID := int64(1); chatID <= 2; chatID++ {
+		repo.SetSession(chatID, testSession("u", models.FlowNone, models.StateNone))
+		*now = now.Add(time.Minute)
+	}
+	repo.GetSession(1)
+	repo.SetSession(3, testSession("u", models.FlowNone, models.StateNone))
+
+	if repo.SessionExists(2) || !repo.SessionExists(1) || !repo.SessionExists(3) {
+		t.Fatalf("least recently used session not evicted: %v", repo.GetAllSessions())
+	}
+}
+
+func TestExpiringUserRepositoryKeepsConversationsTouchedDuringSweep(t *testing.T) {
+	repo, now := newTestExpiringRepository(ExpiryOptions{IdleTTL: 30 * time.Minute})
+	defer repo.Close()
+
+	var evicted []int64
+	repo.OnEvict(func(chatID int64, _ *models.Session, _ EvictionReason) {
+		evicted = append(evicted, chatID)
+	})
+
+	repo.SetSession(1, testSession("c1", models.FlowBooking, models.StateWaitingForTimeSelection))
+	*now = now.Add(time.Hour)
+	cutoff := now.Add(-30 * time.Minute)
+
+	// The chat becomes active after the sweeper found it idle, before it is cleared
+	idle := repo.idleSince(cutoff)
+	if len(idle) != 1 || idle[0] != 1 {
+		t.Fatalf("idle chats %v, want [1]", idle)
+	}
+	repo.GetSession(1)
+	if _, ok := repo.abandon(1, cutoff); ok {
+		t.Fatal("conversation touched after the idle check was abandoned")
+	}
+
+	repo.Sweep()
+	if session, _ := repo.GetSession(1); session.Conversation.State != models.StateWaitingForTimeSelection || len(evicted) != 0 {
+		t.Fatalf("active conversation abandoned: state %q, evicted %v", session.Conversation.State, evicted)
+	}
+}
+
+func TestExpiringUserRepositorySweepsSessionsWorkersAreChanging(t *testing.T) {
+	logger := zerolog.Nop()
+	repo := NewExpiringUserRepository(NewMemoryUserRepository(), ExpiryOptions{IdleTTL: time.Nanosecond}, &logger)
+	defer repo.Close()
+	repo.SetSession(1, testSession("c1", models.FlowBooking, models.StateWaitingForTimeSelection))
+
+	// A worker changes the session it read while the sweeper abandons the chat's conversation
+	read := make(chan *models.Session)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		session, _ := repo.GetSession(1)
+		read <- session
+		session.Conversation.Start(models.FlowCancellation, models.StateWaitingForCancellationReason)
+		session.TrackMessage(1)
+	}()
+	worker := <-read
+	time.Sleep(time.Millisecond)
+	repo.Sweep()
+	<-done
+
+	session, _ := repo.GetSession(1)
+	if session.Profile.ID != "c1" || session.InConversation() {
+		t.Fatalf("stored session after sweep = %+v, want the profile without a conversation", session)
+	}
+	if worker.Conversation.Flow != models.FlowCancellation {
+		t.Fatalf("worker's session changed by the sweeper: %+v", worker.Conversation)
+	}
+}
@@ -0,0 +1,90 @@
+package repository
+
+import (
+	"booking_client/internal/models"
+	"sync"
+)
+
+// MemoryUserRepository handles in-memory session storage using sync.Map.
+// Sessions are copied in and out, so callers never share one with another goroutine.
+type MemoryUserRepository struct {
+	storage *sync.Map
+}
+
+// Ensure MemoryUserRepository implements UserRepository
+var _ UserRepository = (*MemoryUserRepository)(nil)
+
+// NewMemoryUserRepository creates a new in-memory session repository
+func NewMemoryUserRepository() *MemoryUserRepository {
+	return &MemoryUserRepository{
+		storage: &sync.Map{},
+	}
+}
+
+// GetSession retrieves the session of a chat
+func (r *MemoryUserRepository) GetSession(chatID int64) (*models.Session, bool) {
+	value, exists := r.storage.Load(chatID)
+	if !exists {
+		return nil, false
+	}
+
+	session, ok := value.(*models.Session)
+	if !ok {
+		return nil, false
+	}
+
+	return session.Clone(), true
+}
+
+// SetSession stores the session of a chat
+func (r *MemoryUserRepository) SetSession(chatID int64, session *models.Session) {
+	r.storage.Store(chatID, session.Clone())
+}
+
+// DeleteSession removes the session of a chat
+func (r *MemoryUserRepository) DeleteSession(chatID int64) {
+	r.storage.Delete(chatID)
+}
+
+// GetAllSessions returns all stored sessions (for debugging/admin purposes)
+func (r *MemoryUserRepository) GetAllSessions() map[int64]*models.Session {
+	sessions := make(map[int64]*models.Session)
+
+	r.storage.Range(func(key, value interface{}) bool {
+		chatID, ok := key.(int64)
+		if !ok {
+			return true // Continue iteration
+		}
+
+		session, ok := value.(*models.Session)
+		if !ok {
+			return true // Continue iteration
+		}
+
+		sessions[chatID] = session.Clone()
+		return true // Continue iteration
+	})
+
+	return sessions
+}
+
+// SessionExists checks if a session exists for the given chat ID
+func (r *MemoryUserRepository) SessionExists(chatID int64) bool {
+	_, exists := r.storage.Load(chatID)
+	return exists
+}
+
+// Count returns the number of stored sessions
+func (r *MemoryUserRepository) Count() int {
+	count := 0
+	r.storage.Range(func(key, value interface{}) bool {
+		count++
+		return true // Continue iteration
+	})
+	return count
+}
+
+// Close is a no-op for the in-memory repository
+func (r *MemoryUserRepository) Close() error {
+	return nil
+}
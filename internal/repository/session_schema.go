@@ -0,0 +1,149 @@
+package repository
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"booking_client/internal/models"
+)
+
+// sessionRecord is the envelope every persisted session is stored in,
+// so the models.Session JSON can evolve without breaking existing data
+type sessionRecord struct {
+	Version int             `json:"version"`
+	Data    json.RawMessage `json:"data"`
+}
+
+// sessionMigration upgrades session JSON by one schema version
+type sessionMigration func(data json.RawMessage) (json.RawMessage, error)
+
+// sessionMigrations[i] upgrades data from version i+1 to version i+2.
+// Append a migration whenever the stored shape of models.Session changes.
+var sessionMigrations = []sessionMigration{
+	migrateUserToSession,
+}
+
+// CurrentSchemaVersion returns the schema version written by this build
+func CurrentSchemaVersion() int {
+	return len(sessionMigrations) + 1
+}
+
+// encodeSession wraps a session in a record of the current schema version
+func encodeSession(user *models.Session) ([]byte, error) {
+	data, err := json.Marshal(user)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal session: %w", err)
+	}
+	return json.Marshal(sessionRecord{Version: CurrentSchemaVersion(), Data: data})
+}
+
+// decodeSession unwraps a record, migrating it to the current schema version.
+// Reports whether a migration was applied so the caller can write it back.
+func decodeSession(raw []byte) (*models.Session, bool, error) {
+	var record sessionRecord
+	if err := json.Unmarshal(raw, &record); err != nil {
+		return nil, false, fmt.Errorf("failed to unmarshal session record: %w", err)
+	}
+
+	current := CurrentSchemaVersion()
+	if record.Version < 1 || record.Version > current {
+		return nil, false, fmt.Errorf("unsupported session schema version %d (current %d)", record.Version, current)
+	}
+
+	data := record.Data
+	for version := record.Version; version < current; version++ {
+		migrated, err := sessionMigrations[version-1](data)
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to migrate session from version %d: %w", version, err)
+		}
+		data = migrated
+	}
+
+	var session models.Session
+	if err := json.Unmarshal(data, &session); err != nil {
+		return nil, false, fmt.Errorf("failed to unmarshal session: %w", err)
+	}
+	return &session, record.Version != current, nil
+}
+
+// userV1 is the version 1 record: profile and conversation scratch data in one struct
+type userV1 struct {
+	ID                           string  `json:"id"`
+	ChatID                       *int64  `json:"chat_id,omitempty"`
+	Username                     string  `json:"username"`
+	FirstName                    string  `json:"first_name"`
+	LastName                     string  `json:"last_name"`
+	Role                         string  `json:"role"`
+	PhoneNumber                  *string `json:"phone_number,omitempty"`
+	State                        string  `json:"state,omitempty"`
+	SelectedProfessionalID       string  `json:"selected_professional_id,omitempty"`
+	SelectedDate                 string  `json:"selected_date,omitempty"`
+	SelectedUnavailableStartTime string  `json:"selected_unavailable_start_time,omitempty"`
+	SelectedUnavailableEndTime   string  `json:"selected_unavailable_end_time,omitempty"`
+	SelectedAppointmentID        string  `json:"selected_appointment_id,omitempty"`
+	SelectedClientID             *string `json:"selected_client_id,omitempty"`
+	LastMessageID                *int    `json:"last_message_id,omitempty"`
+	MessagesToDelete             []*int  `json:"messages_to_delete,omitempty"`
+	CreatedAt                    string  `json:"created_at"`
+	UpdatedAt                    string  `json:"updated_at"`
+}
+
+// migrateUserToSession splits a version 1 user into profile and typed conversation (version 2)
+func migrateUserToSession(data json.RawMessage) (json.RawMessage, error) {
+	var old userV1
+	if err := json.Unmarshal(data, &old); err != nil {
+		return nil, err
+	}
+
+	session := models.Session{
+		LastMessageID:    old.LastMessageID,
+		MessagesToDelete: old.MessagesToDelete,
+	}
+	if old.ID != "" {
+		session.Profile = models.User{
+			ID:          old.ID,
+			ChatID:      old.ChatID,
+			Username:    old.Username,
+			FirstName:   old.FirstName,
+			LastName:    old.LastName,
+			Role:        old.Role,
+			PhoneNumber: old.PhoneNumber,
+			CreatedAt:   old.CreatedAt,
+			UpdatedAt:   old.UpdatedAt,
+		}
+	} else {
+		session.Profile = models.User{ChatID: old.ChatID, Role: old.Role}
+	}
+
+	conversation := &session.Conversation
+	switch old.State {
+	case models.StateNone:
+		if old.SelectedClientID != nil {
+			conversation.Start(models.FlowPreviousAppointments, models.StateBrowsingPreviousAppointments)
+			conversation.PreviousAppointments.ClientID = *old.SelectedClientID
+		}
+	case models.StateWaitingForFirstName, models.StateWaitingForLastName, models.StateWaitingForPhone:
+		conversation.Start(models.FlowRegistration, old.State)
+		conversation.Registration.FirstName = old.FirstName
+		conversation.Registration.LastName = old.LastName
+	case models.StateWaitingForUsername, models.StateWaitingForPassword:
+		conversation.Start(models.FlowSignIn, old.State)
+		conversation.SignIn.Username = old.Username
+	case models.StateWaitingForProfessionalSelection, models.StateWaitingForDateSelection,
+		models.StateWaitingForTimeSelection, models.StateBookingAppointment:
+		conversation.Start(models.FlowBooking, old.State)
+		conversation.Booking.ProfessionalID = old.SelectedProfessionalID
+		conversation.Booking.Date = old.SelectedDate
+	case models.StateWaitingForCancellationReason:
+		conversation.Start(models.FlowCancellation, old.State)
+		conversation.Cancellation.AppointmentID = old.SelectedAppointmentID
+	case models.StateWaitingForUnavailableDateSelection, models.StateWaitingForUnavailableStartTime,
+		models.StateWaitingForUnavailableEndTime, models.StateWaitingForUnavailableDescription:
+		conversation.Start(models.FlowUnavailable, old.State)
+		conversation.Unavailable.Date = old.SelectedDate
+		conversation.Unavailable.StartTime = old.SelectedUnavailableStartTime
+		conversation.Unavailable.EndTime = old.SelectedUnavailableEndTime
+	}
+
+	return json.Marshal(session)
+}
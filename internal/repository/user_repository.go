@@ -1,81 +1,45 @@
 package repository
 
 import (
-	"booking_client/internal/models"
-	"sync"
-)
+	"fmt"
 
-// UserRepository handles in-memory user storage using sync.Map
-type UserRepository struct {
-	storage *sync.Map
-}
+	"booking_client/internal/models"
 
-// NewUserRepository creates a new user repository
-func NewUserRepository() *UserRepository {
-	return &UserRepository{
-		storage: &sync.Map{},
-	}
-}
+	"github.com/rs/zerolog"
+)
 
-// GetUser retrieves a user by chat ID
-func (r *UserRepository) GetUser(chatID int64) (*models.User, bool) {
-	value, exists := r.storage.Load(chatID)
-	if !exists {
-		return nil, false
-	}
+// Session store backends
+const (
+	StoreMemory = "memory"
+	StoreBolt   = "bolt"
+)
 
-	user, ok := value.(*models.User)
-	if !ok {
-		return nil, false
+// UserRepository stores the bot session (profile and conversation state) of each chat
+type UserRepository interface {
+	// GetSession retrieves the session of a chat
+	GetSession(chatID int64) (*models.Session, bool)
+	// SetSession stores the session of a chat
+	SetSession(chatID int64, session *models.Session)
+	// DeleteSession removes the session of a chat
+	DeleteSession(chatID int64)
+	// GetAllSessions returns all stored sessions (for debugging/admin purposes)
+	GetAllSessions() map[int64]*models.Session
+	// SessionExists checks if a session exists for the given chat ID
+	SessionExists(chatID int64) bool
+	// Count returns the number of stored sessions
+	Count() int
+	// Close releases the underlying storage
+	Close() error
+}
+
+// NewUserRepository creates the session repository for the given store backend
+func NewUserRepository(store, path string, logger *zerolog.Logger) (UserRepository, error) {
+	switch store {
+	case StoreMemory, "":
+		return NewMemoryUserRepository(), nil
+	case StoreBolt:
+		return NewBoltUserRepository(path, logger)
+	default:
+		return nil, fmt.Errorf("unknown session store %q", store)
 	}
-
-	return user, true
-}
-
-// SetUser stores a user with the given chat ID
-func (r *UserRepository) SetUser(chatID int64, user *models.User) {
-	r.storage.Store(chatID, user)
-}
-
-// DeleteUser removes a user by chat ID
-func (r *UserRepository) DeleteUser(chatID int64) {
-	r.storage.Delete(chatID)
-}
-
-// GetAllUsers returns all stored users (for debugging/admin purposes)
-func (r *UserRepository) GetAllUsers() map[int64]*models.User {
-	users := make(map[int64]*models.User)
-
-	r.storage.Range(func(key, value interface{}) bool {
-		chatID, ok := key.(int64)
-		if !ok {
-			return true // Continue iteration
-		}
-
-		user, ok := value.(*models.User)
-		if !ok {
-			return true // Continue iteration
-		}
-
-		users[chatID] = user
-		return true // Continue iteration
-	})
-
-	return users
-}
-
-// UserExists checks if a user exists for the given chat ID
-func (r *UserRepository) UserExists(chatID int64) bool {
-	_, exists := r.storage.Load(chatID)
-	return exists
-}
-
-// Count returns the number of stored users
-func (r *UserRepository) Count() int {
-	count := 0
-	r.storage.Range(func(key, value interface{}) bool {
-		count++
-		return true // Continue iteration
-	})
-	return count
 }
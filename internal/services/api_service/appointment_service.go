@@ -12,7 +12,10 @@ func (s *APIService) CreateAppointment(ctx context.Context, req *CreateAppointme
 	url := s.buildURL("api", "appointments")
 
 	var response schemas.CreateAppointmentResponse
-	if err := s.makePostRequest(ctx, url, req, &response, http.StatusCreated); err != nil {
+	err := s.makeIdempotentRequest(ctx, http.MethodPost, url, req, &response, http.StatusCreated)
+	// Even a failed write may have changed the slots (a lost reply, a slot taken meanwhile)
+	s.cache.invalidateProfessional(req.ProfessionalID)
+	if err != nil {
 		return nil, err
 	}
 
@@ -0,0 +1,214 @@
+package api_service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned without calling the API while the breaker of the endpoint,
+// or of the whole API, is open; it is also ErrUnavailable
+var ErrCircuitOpen = fmt.Errorf("%w: circuit open", ErrUnavailable)
+
+// serviceEndpoint names the breaker shared by all endpoints, opened by consecutive failures anywhere
+const serviceEndpoint = "*"
+
+// Breaker states
+const (
+	BreakerClosed   = "closed"
+	BreakerOpen     = "open"
+	BreakerHalfOpen = "half_open"
+)
+
+// BreakerOptions configures the circuit breakers guarding the booking API
+type BreakerOptions struct {
+	FailureThreshold int           // Consecutive failures opening a breaker, 0 disables
+	OpenDuration     time.Duration // How long an open breaker fails calls before letting one probe through
+}
+
+// BreakerStatus is the state of one breaker, for monitoring
+type BreakerStatus struct {
+	State               string    `json:"state"`
+	ConsecutiveFailures int       `json:"consecutive_failures"`
+	OpenedAt            time.Time `json:"opened_at,omitempty"`
+	Opened              int64     `json:"opened"`
+	Rejected            int64     `json:"rejected"`
+}
+
+// circuitBreaker tracks the health of one endpoint
+type circuitBreaker struct {
+	state    string
+	failures int
+	openedAt time.Time
+	// probing is set while the one call let through a half-open breaker is in flight
+	probing  bool
+	opened   int64
+	rejected int64
+}
+
+// breakers holds a breaker per endpoint and one for the whole API.
+// A call is let through only when both its endpoint's breaker and the API's allow it.
+type breakers struct {
+	mu        sync.Mutex
+	options   BreakerOptions
+	endpoints map[string]*circuitBreaker
+	now       func() time.Time
+}
+
+// newBreakers creates the breakers; they are created per endpoint on first use
+func newBreakers(options BreakerOptions) *breakers {
+	return &breakers{
+		options:   options,
+		endpoints: make(map[string]*circuitBreaker),
+		now:       time.Now,
+	}
+}
+
+// allow reports ErrCircuitOpen when the endpoint or the API may not be called now
+func (b *breakers) allow(endpoint string) error {
+	if b.disabled() {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := b.now()
+	// Rejections are counted by the endpoint's breaker when it is the one open
+	checked := []*circuitBreaker{b.get(endpoint), b.get(serviceEndpoint)}
+	for _, cb := range checked {
+		if !b.ready(cb, now) {
+			cb.rejected++
+			return fmt.Errorf("%s: %w", endpoint, ErrCircuitOpen)
+		}
+	}
+	for _, cb := range checked {
+		if cb.state == BreakerHalfOpen {
+			cb.probing = true
+		}
+	}
+	return nil
+}
+
+// record feeds the outcome of a call let through by allow to the breakers
+func (b *breakers) record(endpoint string, err error) {
+	if b.disabled() {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := b.now()
+	for _, cb := range []*circuitBreaker{b.get(serviceEndpoint), b.get(endpoint)} {
+		cb.probing = false
+		switch {
+		case errors.Is(err, context.Canceled):
+			// The user's update was abandoned; it says nothing about the API
+		case !tripping(err):
+			cb.state = BreakerClosed
+			cb.failures = 0
+		default:
+			cb.failures++
+			if cb.state == BreakerHalfOpen || cb.failures >= b.options.FailureThreshold {
+				if cb.state != BreakerOpen {
+					cb.opened++
+				}
+				cb.state = BreakerOpen
+				cb.openedAt = now
+			}
+		}
+	}
+}
+
+// available reports whether the API as a whole may be called, without taking a half-open probe
+func (b *breakers) available() bool {
+	if b.disabled() {
+		return true
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	cb, exists := b.endpoints[serviceEndpoint]
+	return !exists || cb.state != BreakerOpen || b.now().Sub(cb.openedAt) >= b.options.OpenDuration
+}
+
+// snapshot returns the status of every breaker by endpoint
+func (b *breakers) snapshot() map[string]BreakerStatus {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	stats := make(map[string]BreakerStatus, len(b.endpoints))
+	for endpoint, cb := range b.endpoints {
+		stats[endpoint] = BreakerStatus{
+			State:               cb.state,
+			ConsecutiveFailures: cb.failures,
+			OpenedAt:            cb.openedAt,
+			Opened:              cb.opened,
+			Rejected:            cb.rejected,
+		}
+	}
+	return stats
+}
+
+// ready reports whether a breaker lets a call through, half-opening an open one whose time is up
+func (b *breakers) ready(cb *circuitBreaker, now time.Time) bool {
+	switch cb.state {
+	case BreakerOpen:
+		if now.Sub(cb.openedAt) < b.options.OpenDuration {
+			return false
+		}
+		cb.state = BreakerHalfOpen
+		return true
+	case BreakerHalfOpen:
+		return !cb.probing
+	}
+	return true
+}
+
+// get returns the breaker of an endpoint, creating a closed one
+func (b *breakers) get(endpoint string) *circuitBreaker {
+	cb, exists := b.endpoints[endpoint]
+	if !exists {
+		cb = &circuitBreaker{state: BreakerClosed}
+		b.endpoints[endpoint] = cb
+	}
+	return cb
+}
+
+// disabled reports whether the breakers never open
+func (b *breakers) disabled() bool {
+	return b == nil || b.options.FailureThreshold <= 0 || b.options.OpenDuration <= 0
+}
+
+// tripping reports whether a failure counts against the health of the API:
+// no response at all, or a server error. Client errors mean the API is up.
+func tripping(err error) bool {
+	if err == nil {
+		return false
+	}
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.StatusCode >= http.StatusInternalServerError
+	}
+	return errors.Is(err, ErrUnavailable) || errors.Is(err, ErrTimeout)
+}
+
+// endpointOf names the endpoint of a request by its method and path, with path segments
+// holding IDs (any segment with a digit) replaced by "*": GET /api/professionals/*/availability
+func endpointOf(method, rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return method
+	}
+	segments := strings.Split(strings.Trim(u.Path, "/"), "/")
+	for i, segment := range segments {
+		if strings.ContainsAny(segment, "0123456789") {
+			segments[i] = "*"
+		}
+	}
+	return method + " /" + strings.Join(segments, "/")
+}
@@ -0,0 +1,120 @@
+package api_service
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestBreakerOpensHalfOpensAndCloses(t *testing.T) {
+	now := time.Now()
+	b := newBreakers(BreakerOptions{FailureThreshold: 2, OpenDuration: time.Minute})
+	b.now = func() time.Time { return now }
+	const endpoint = "GET /api/professionals"
+	serverError := &APIError{StatusCode: http.StatusInternalServerError}
+
+	for i := 0; i < 2; i++ {
+		if err := b.allow(endpoint); err != nil {
+			t.Fatalf("closed breaker rejected call %d: %v", i, err)
+		}
+		b.record(endpoint, serverError)
+	}
+
+	err := b.allow(endpoint)
+	if !errors.Is(err, ErrCircuitOpen) || !errors.Is(err, ErrUnavailable) {
+		t.Fatalf("open breaker returned %v, want ErrCircuitOpen and ErrUnavailable", err)
+	}
+	if b.available() {
+		t.Fatal("API reported available while its breaker is open")
+	}
+
+	// Once the open duration is over, one probe goes through at a time
+	now = now.Add(time.Minute)
+	if err := b.allow(endpoint); err != nil {
+		t.Fatalf("half-open breaker rejected the probe: %v", err)
+	}
+	if err := b.allow(endpoint); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("second call during the probe returned %v, want ErrCircuitOpen", err)
+	}
+
+	// A failed probe opens the breaker again
+	b.record(endpoint, serverError)
+	if err := b.allow(endpoint); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("breaker after a failed probe returned %v, want ErrCircuitOpen", err)
+	}
+
+	now = now.Add(time.Minute)
+	if err := b.allow(endpoint); err != nil {
+		t.Fatalf("half-open breaker rejected the probe: %v", err)
+	}
+	b.record(endpoint, nil)
+
+	stats := b.snapshot()
+	if got := stats[endpoint]; got.State != BreakerClosed || got.Opened != 2 || got.Rejected != 3 {
+		t.Fatalf("endpoint breaker: got %+v, want closed after opening twice and rejecting 3 calls", got)
+	}
+	if got := stats[serviceEndpoint]; got.State != BreakerClosed || got.Opened != 2 {
+		t.Fatalf("API breaker: got %+v, want closed after opening twice", got)
+	}
+}
+
+func TestBreakerCountsOnlyOutages(t *testing.T) {
+	b := newBreakers(BreakerOptions{FailureThreshold: 1, OpenDuration: time.Minute})
+	const endpoint = "POST /api/appointments"
+
+	for _, err := range []error{
+		&APIError{StatusCode: http.StatusConflict},
+		&APIError{StatusCode: http.StatusNotFound},
+		context.Canceled,
+	} {
+		b.record(endpoint, err)
+		if err := b.allow(endpoint); err != nil {
+			t.Fatalf("breaker opened after %v", err)
+		}
+	}
+
+	b.record(endpoint, transportError(errors.New("connection refused")))
+	if err := b.allow(endpoint); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("breaker after a refused connection returned %v, want ErrCircuitOpen", err)
+	}
+}
+
+func TestBreakerIsPerEndpoint(t *testing.T) {
+	b := newBreakers(BreakerOptions{FailureThreshold: 2, OpenDuration: time.Minute})
+	const broken, healthy = "GET /api/professionals/*/availability", "GET /api/users/*"
+	serverError := &APIError{StatusCode: http.StatusBadGateway}
+
+	// Successes elsewhere keep the API as a whole closed
+	for i := 0; i < 2; i++ {
+		b.record(broken, serverError)
+		b.record(healthy, nil)
+	}
+
+	if err := b.allow(broken); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("failing endpoint returned %v, want ErrCircuitOpen", err)
+	}
+	if err := b.allow(healthy); err != nil {
+		t.Fatalf("healthy endpoint rejected: %v", err)
+	}
+	if !b.available() {
+		t.Fatal("API reported unavailable while only one endpoint fails")
+	}
+}
+
+func TestEndpointOfReplacesIDs(t *testing.T) {
+	tests := []struct {
+		method, url, want string
+	}{
+		{http.MethodGet, "http://api/api/users/123456", "GET /api/users/*"},
+		{http.MethodGet, "http://api/api/professionals/pro-1/availability?date=2026-01-01", "GET /api/professionals/*/availability"},
+		{http.MethodPatch, "http://api/api/professionals/pro-1/appointments/apt-2/confirm", "PATCH /api/professionals/*/appointments/*/confirm"},
+		{http.MethodPost, "http://api/api/professionals/sign_in", "POST /api/professionals/sign_in"},
+	}
+	for _, tt := range tests {
+		if got := endpointOf(tt.method, tt.url); got != tt.want {
+			t.Errorf("endpointOf(%s, %s) = %q, want %q", tt.method, tt.url, got, tt.want)
+		}
+	}
+}
@@ -5,7 +5,6 @@ import (
 	"net/http"
 	"net/url"
 
-	"booking_client/internal/common"
 	"booking_client/internal/schemas"
 )
 
@@ -33,8 +32,7 @@ func (s *APIService) GetClientAppointments(ctx context.Context, clientID, status
 	url := s.buildURLWithQuery([]string{"api", "clients", clientID, "appointments"}, query)
 
 	var response schemas.GetClientAppointmentsResponse
-	requestID := common.GetRequestID(ctx)
-	if err := s.makeGetRequestWithContext(ctx, url, &response, requestID); err != nil {
+	if err := s.makeGetRequest(ctx, url, &response); err != nil {
 		return nil, err
 	}
 
@@ -46,9 +44,12 @@ func (s *APIService) CancelClientAppointment(ctx context.Context, clientID, appo
 	url := s.buildURL("api", "clients", clientID, "appointments", appointmentID, "cancel")
 
 	var response schemas.CancelClientAppointmentResponse
-	if err := s.makePatchRequest(ctx, url, req, &response); err != nil {
+	if err := s.makeIdempotentRequest(ctx, http.MethodPatch, url, req, &response, http.StatusOK); err != nil {
+		// The professional is only known from the response
+		s.cache.invalidateAppointments()
 		return nil, err
 	}
+	s.cache.invalidateProfessional(response.Professional.ID)
 
 	return &response, nil
 }
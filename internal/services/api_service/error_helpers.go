@@ -1,17 +1,44 @@
 package api_service
 
-// FormatErrorForUser formats an API error for display to end users
-// Returns a user-friendly error message with contact information if request_id is available
+import "errors"
+
+// Messages shown to users for each kind of failure
+const (
+	userMsgNotFound     = "it no longer exists"
+	userMsgConflict     = "it conflicts with a change made in the meantime"
+	userMsgUnauthorized = "you are not allowed to do this"
+	userMsgUnavailable  = "the booking service is temporarily unavailable, please try again in a moment"
+	userMsgTimeout      = "the booking service took too long to answer, please try again in a moment"
+	userMsgUnexpected   = "unexpected error"
+)
+
+// FormatErrorForUser formats an API error for display to end users.
+// Known kinds get a friendly text; validation errors keep the API's message, which is
+// written for users; other API errors add contact information when a request_id is available.
+// Errors that did not come from the API are never shown as is.
 func FormatErrorForUser(err error) string {
-	if apiErr, ok := err.(*APIError); ok {
+	var apiErr *APIError
+	switch {
+	case errors.Is(err, ErrTimeout):
+		return userMsgTimeout
+	case errors.Is(err, ErrUnavailable):
+		return userMsgUnavailable
+	case errors.Is(err, ErrNotFound):
+		return userMsgNotFound
+	case errors.Is(err, ErrConflict):
+		return userMsgConflict
+	case errors.Is(err, ErrUnauthorized):
+		return userMsgUnauthorized
+	case errors.As(err, &apiErr):
 		return apiErr.FormatUserMessage()
 	}
-	return err.Error()
+	return userMsgUnexpected
 }
 
 // GetRequestID extracts the request ID from an API error if available
 func GetRequestID(err error) string {
-	if apiErr, ok := err.(*APIError); ok {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
 		return apiErr.RequestID
 	}
 	return ""
@@ -19,6 +46,6 @@ func GetRequestID(err error) string {
 
 // IsAPIError checks if the error is an APIError
 func IsAPIError(err error) bool {
-	_, ok := err.(*APIError)
-	return ok
+	var apiErr *APIError
+	return errors.As(err, &apiErr)
 }
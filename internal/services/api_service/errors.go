@@ -1,6 +1,26 @@
 package api_service
 
-import "fmt"
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// Kinds of API failures; match them with errors.Is, on *APIError and on transport errors alike
+var (
+	ErrNotFound     = errors.New("not found")
+	ErrConflict     = errors.New("conflict")
+	ErrValidation   = errors.New("validation failed")
+	ErrUnauthorized = errors.New("unauthorized")
+	ErrUnavailable  = errors.New("booking service unavailable")
+	ErrTimeout      = errors.New("booking service timed out")
+)
+
+// maxErrorBodyLength bounds how much of a non-JSON error body is kept as the message
+const maxErrorBodyLength = 200
 
 // ErrorResponse represents the error response from the API
 type ErrorResponse struct {
@@ -19,17 +39,76 @@ type APIError struct {
 
 // Error implements the error interface
 func (e *APIError) Error() string {
+	msg := e.Message
+	if msg == "" {
+		msg = fmt.Sprintf("API returned status %d", e.StatusCode)
+	}
 	if e.RequestID != "" {
-		return fmt.Sprintf("%s (request_id: %s)", e.Message, e.RequestID)
+		return fmt.Sprintf("%s (request_id: %s)", msg, e.RequestID)
+	}
+	return msg
+}
+
+// Unwrap returns the kind of the error by status code, so errors.Is(err, ErrNotFound) works
+func (e *APIError) Unwrap() error {
+	switch e.StatusCode {
+	case http.StatusNotFound:
+		return ErrNotFound
+	case http.StatusConflict:
+		return ErrConflict
+	case http.StatusBadRequest, http.StatusUnprocessableEntity:
+		return ErrValidation
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return ErrUnauthorized
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusTooManyRequests:
+		return ErrUnavailable
+	case http.StatusRequestTimeout, http.StatusGatewayTimeout:
+		return ErrTimeout
 	}
-	return e.Message
+	return nil
 }
 
 // FormatUserMessage formats the error message for end users
 func (e *APIError) FormatUserMessage() string {
 	msg := e.Message
+	if msg == "" {
+		msg = "unexpected error"
+	}
 	if e.RequestID != "" {
 		msg += fmt.Sprintf("\n\nPlease contact support (maksimfilipenka122@gmail.com) with request_id: %s", e.RequestID)
 	}
 	return msg
 }
+
+// newAPIError builds an APIError from an error response, keeping the start of bodies that are not JSON
+func newAPIError(statusCode int, errorResp *ErrorResponse, body []byte) *APIError {
+	if errorResp != nil {
+		return &APIError{
+			StatusCode: statusCode,
+			ErrorType:  errorResp.Error,
+			Message:    errorResp.Message,
+			RequestID:  errorResp.RequestID,
+		}
+	}
+
+	message := strings.TrimSpace(string(body))
+	if len(message) > maxErrorBodyLength {
+		message = message[:maxErrorBodyLength] + "…"
+	}
+	if message == "" {
+		message = http.StatusText(statusCode)
+	}
+	return &APIError{StatusCode: statusCode, Message: message}
+}
+
+// transportError classifies a request that got no response as ErrTimeout or ErrUnavailable
+func transportError(err error) error {
+	if errors.Is(err, context.Canceled) {
+		return fmt.Errorf("request cancelled: %w", err)
+	}
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		return fmt.Errorf("failed to make request: %w: %w", ErrTimeout, err)
+	}
+	return fmt.Errorf("failed to make request: %w: %w", ErrUnavailable, err)
+}
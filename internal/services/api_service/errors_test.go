@@ -0,0 +1,64 @@
+package api_service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestParseAPIErrorKinds(t *testing.T) {
+	s := &APIService{}
+
+	for _, tc := range []struct {
+		name   string
+		status int
+		body   string
+		kind   error
+	}{
+		{"json not found", http.StatusNotFound, `{"error":"not_found","message":"user not found","request_id":"r1"}`, ErrNotFound},
+		{"json conflict", http.StatusConflict, `{"error":"conflict","message":"time slot is not available"}`, ErrConflict},
+		{"json validation", http.StatusBadRequest, `{"error":"bad_request","message":"invalid date"}`, ErrValidation},
+		{"json forbidden", http.StatusForbidden, `{"error":"forbidden","message":"not yours"}`, ErrUnauthorized},
+		{"html bad gateway", http.StatusBadGateway, "<html><body>502 Bad Gateway</body></html>", ErrUnavailable},
+		{"plain timeout", http.StatusGatewayTimeout, "upstream timed out", ErrTimeout},
+		{"empty not found", http.StatusNotFound, "", ErrNotFound},
+	} {
+		err := fmt.Errorf("wrapped: %w", s.parseAPIError(tc.status, []byte(tc.body)))
+		if !errors.Is(err, tc.kind) {
+			t.Errorf("%s: %v is not %v", tc.name, err, tc.kind)
+		}
+		var apiErr *APIError
+		if !errors.As(err, &apiErr) || apiErr.StatusCode != tc.status || apiErr.Message == "" {
+			t.Errorf("%s: got %#v", tc.name, apiErr)
+		}
+	}
+
+	if err := s.parseAPIError(http.StatusInternalServerError, []byte(`{"error":"internal","message":"boom"}`)); errors.Is(err, ErrUnavailable) {
+		t.Error("internal server error classified as unavailable")
+	}
+}
+
+func TestFormatErrorForUserHidesInternals(t *testing.T) {
+	s := &APIService{}
+
+	validation := s.parseAPIError(http.StatusBadRequest, []byte(`{"error":"bad_request","message":"appointment must start in the future"}`))
+	if got := FormatErrorForUser(validation); got != "appointment must start in the future" {
+		t.Errorf("validation message not kept: %q", got)
+	}
+
+	for _, err := range []error{
+		transportError(fmt.Errorf("dial tcp 10.0.0.1:8080: %w", context.DeadlineExceeded)),
+		s.parseAPIError(http.StatusServiceUnavailable, []byte("<html>maintenance</html>")),
+		errors.New("unexpected end of JSON input"),
+	} {
+		if got := FormatErrorForUser(err); strings.Contains(got, "tcp") || strings.Contains(got, "html") || strings.Contains(got, "JSON") {
+			t.Errorf("internal details shown to the user: %q", got)
+		}
+	}
+	if err := transportError(context.DeadlineExceeded); !errors.Is(err, ErrTimeout) {
+		t.Errorf("deadline not classified as timeout: %v", err)
+	}
+}
@@ -5,31 +5,104 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"math/rand"
 	"net/http"
 	"strings"
+	"time"
+
+	"github.com/google/uuid"
 )
 
+// RetryOptions configures how failed idempotent requests are retried
+type RetryOptions struct {
+	MaxRetries int           // Retries after the first attempt, 0 disables
+	BaseDelay  time.Duration // Upper bound of the first backoff, doubled per retry
+	MaxDelay   time.Duration // Upper bound of any backoff
+}
+
+// apiRequest is a single API call, sent once or, when idempotent, retried as one
+type apiRequest struct {
+	method         string
+	url            string
+	body           interface{}
+	expectedStatus int
+	// idempotencyKey is sent with every attempt so the API applies a POST or PATCH only once
+	idempotencyKey string
+}
+
+// idempotent reports whether the request may be sent again after a failure
+func (r *apiRequest) idempotent() bool {
+	return r.method == http.MethodGet || r.idempotencyKey != ""
+}
+
 // makeRequest performs an HTTP request with auth header and returns the response body
 func (s *APIService) makeRequest(ctx context.Context, method, url string, body interface{}, expectedStatus int) ([]byte, error) {
-	var reqBody io.Reader
-	if body != nil {
-		jsonData, err := json.Marshal(body)
-		if err != nil {
+	return s.send(ctx, &apiRequest{method: method, url: url, body: body, expectedStatus: expectedStatus})
+}
+
+// send performs the request, retrying idempotent ones on outages, timeouts and 502/503/504
+// with jittered exponential backoff. Attempts fail at once while the endpoint's breaker is open.
+func (s *APIService) send(ctx context.Context, apiReq *apiRequest) ([]byte, error) {
+	var jsonData []byte
+	if apiReq.body != nil {
+		var err error
+		if jsonData, err = json.Marshal(apiReq.body); err != nil {
 			return nil, fmt.Errorf("failed to marshal request: %w", err)
 		}
-		reqBody = bytes.NewBuffer(jsonData)
 	}
 
-	req, err := http.NewRequestWithContext(ctx, method, url, reqBody)
+	endpoint := endpointOf(apiReq.method, apiReq.url)
+	for attempt := 0; ; attempt++ {
+		if err := s.breakers.allow(endpoint); err != nil {
+			return nil, err
+		}
+		respBody, err := s.attempt(ctx, apiReq, jsonData)
+		s.breakers.record(endpoint, err)
+		if err == nil || !apiReq.idempotent() || attempt >= s.retry.MaxRetries || !retryable(err) {
+			return respBody, err
+		}
+
+		delay := s.backoff(attempt)
+		logger := common.GetLogger(ctx)
+		logger.Warn().
+			Err(err).
+			Str("method", apiReq.method).
+			Str("url", apiReq.url).
+			Int("attempt", attempt+1).
+			Dur("delay", delay).
+			Msg("Retrying API request")
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, err
+		case <-timer.C:
+		}
+	}
+}
+
+// attempt sends the request once
+func (s *APIService) attempt(ctx context.Context, apiReq *apiRequest, jsonData []byte) ([]byte, error) {
+	var reqBody io.Reader
+	if jsonData != nil {
+		reqBody = bytes.NewReader(jsonData)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, apiReq.method, apiReq.url, reqBody)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
-	if body != nil {
+	if jsonData != nil {
 		req.Header.Set("Content-Type", "application/json")
 	}
+	if apiReq.idempotencyKey != "" {
+		req.Header.Set("Idempotency-Key", apiReq.idempotencyKey)
+	}
 
 	if err := s.addAuthHeader(req); err != nil {
 		return nil, err
@@ -42,96 +115,118 @@ func (s *APIService) makeRequest(ctx context.Context, method, url string, body i
 
 	resp, err := s.client.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("failed to make request: %w", err)
+		return nil, transportError(err)
 	}
 	defer resp.Body.Close()
 
 	respBody, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read response body: %w", err)
+		return nil, transportError(fmt.Errorf("failed to read response body: %w", err))
 	}
 
-	if resp.StatusCode != expectedStatus {
+	if tokenRejected(resp) {
+		// The token may be signed with a key the API no longer accepts; mint a new one next time
+		s.tokens.Invalidate()
+	}
+	if resp.StatusCode != apiReq.expectedStatus {
 		return nil, s.parseAPIError(resp.StatusCode, respBody)
 	}
 
 	return respBody, nil
 }
 
-// parseAPIError attempts to parse an API error response
-func (s *APIService) parseAPIError(statusCode int, body []byte) error {
-	var errorResp ErrorResponse
-	if err := json.Unmarshal(body, &errorResp); err != nil {
-		// If we can't parse the error response, return the raw body
-		return fmt.Errorf("API returned status %d: %s", statusCode, strings.TrimSpace(string(body)))
-	}
-
-	// Return structured error
-	return &APIError{
-		StatusCode: statusCode,
-		ErrorType:  errorResp.Error,
-		Message:    errorResp.Message,
-		RequestID:  errorResp.RequestID,
+// tokenRejected reports whether the API refused the bearer token itself, as opposed to
+// credentials in the request (a wrong password is 401 too). Token rejections carry a
+// WWW-Authenticate challenge for the Bearer scheme (RFC 6750).
+func tokenRejected(resp *http.Response) bool {
+	if resp.StatusCode != http.StatusUnauthorized {
+		return false
 	}
+	challenge := strings.TrimSpace(resp.Header.Get("WWW-Authenticate"))
+	return len(challenge) >= len("Bearer") && strings.EqualFold(challenge[:len("Bearer")], "Bearer")
 }
 
-// makePostRequest performs a POST request and unmarshals the response
-func (s *APIService) makePostRequest(ctx context.Context, url string, reqBody interface{}, result interface{}, expectedStatus int) error {
-	body, err := s.makeRequest(ctx, http.MethodPost, url, reqBody, expectedStatus)
-	if err != nil {
-		return err
+// retryable reports whether a failed attempt may succeed when sent again
+func retryable(err error) bool {
+	if errors.Is(err, ErrCircuitOpen) {
+		return false
 	}
-
-	if err := json.Unmarshal(body, result); err != nil {
-		return fmt.Errorf("failed to unmarshal response: %w", err)
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		switch apiErr.StatusCode {
+		case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			return true
+		}
+		return false
 	}
-
-	return nil
+	return errors.Is(err, ErrUnavailable) || errors.Is(err, ErrTimeout)
 }
 
-// makePatchRequest performs a PATCH request and unmarshals the response
-func (s *APIService) makePatchRequest(ctx context.Context, url string, reqBody interface{}, result interface{}) error {
-	body, err := s.makeRequest(ctx, http.MethodPatch, url, reqBody, http.StatusOK)
-	if err != nil {
-		return err
+// backoff returns a random delay up to BaseDelay doubled per retry, capped at MaxDelay
+func (s *APIService) backoff(attempt int) time.Duration {
+	limit := s.retry.BaseDelay << attempt
+	if limit <= 0 || limit > s.retry.MaxDelay {
+		limit = s.retry.MaxDelay
 	}
-
-	if err := json.Unmarshal(body, result); err != nil {
-		return fmt.Errorf("failed to unmarshal response: %w", err)
+	if limit <= 0 {
+		return 0
 	}
+	return time.Duration(rand.Int63n(int64(limit) + 1))
+}
 
-	return nil
+// newIdempotencyKey returns a key identifying one logical write across its retries
+func newIdempotencyKey() string {
+	return uuid.NewString()
 }
 
-// makeGetRequestWithContext performs a GET request
-func (s *APIService) makeGetRequestWithContext(ctx context.Context, url string, result interface{}, requestID string) error {
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
-	if err != nil {
-		return fmt.Errorf("failed to create request: %w", err)
+// parseAPIError builds an *APIError from an error response, JSON or not
+func (s *APIService) parseAPIError(statusCode int, body []byte) error {
+	var errorResp ErrorResponse
+	if err := json.Unmarshal(body, &errorResp); err != nil || (errorResp.Error == "" && errorResp.Message == "") {
+		// Proxies answer with HTML or plain text; keep what we can of it
+		return newAPIError(statusCode, nil, body)
 	}
+	return newAPIError(statusCode, &errorResp, body)
+}
 
-	if err := s.addRequestHeaders(req, requestID); err != nil {
+// makePostRequest performs a POST request and unmarshals the response
+func (s *APIService) makePostRequest(ctx context.Context, url string, reqBody interface{}, result interface{}, expectedStatus int) error {
+	body, err := s.makeRequest(ctx, http.MethodPost, url, reqBody, expectedStatus)
+	if err != nil {
 		return err
 	}
+	return unmarshalResponse(body, result)
+}
 
-	resp, err := s.client.Do(req)
+// makeIdempotentRequest performs a POST or PATCH under a new idempotency key, so it is
+// retried like a GET, and unmarshals the response
+func (s *APIService) makeIdempotentRequest(ctx context.Context, method, url string, reqBody interface{}, result interface{}, expectedStatus int) error {
+	body, err := s.send(ctx, &apiRequest{
+		method:         method,
+		url:            url,
+		body:           reqBody,
+		expectedStatus: expectedStatus,
+		idempotencyKey: newIdempotencyKey(),
+	})
 	if err != nil {
-		return fmt.Errorf("failed to make request: %w", err)
+		return err
 	}
-	defer resp.Body.Close()
+	return unmarshalResponse(body, result)
+}
 
-	body, err := io.ReadAll(resp.Body)
+// makeGetRequest performs a GET request and unmarshals the response
+func (s *APIService) makeGetRequest(ctx context.Context, url string, result interface{}) error {
+	body, err := s.makeRequest(ctx, http.MethodGet, url, nil, http.StatusOK)
 	if err != nil {
-		return fmt.Errorf("failed to read response body: %w", err)
-	}
-
-	if resp.StatusCode != http.StatusOK {
-		return s.parseAPIError(resp.StatusCode, body)
+		return err
 	}
+	return unmarshalResponse(body, result)
+}
 
+// unmarshalResponse decodes a JSON response body
+func unmarshalResponse(body []byte, result interface{}) error {
 	if err := json.Unmarshal(body, result); err != nil {
 		return fmt.Errorf("failed to unmarshal response: %w", err)
 	}
-
 	return nil
 }
@@ -0,0 +1,63 @@
+package api_service
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestBackoffIsJitteredAndCapped(t *testing.T) {
+	s := &APIService{retry: RetryOptions{MaxRetries: 10, BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}}
+
+	for attempt := 0; attempt < 10; attempt++ {
+		limit := 100 * time.Millisecond << attempt
+		if limit > time.Second {
+			limit = time.Second
+		}
+		for i := 0; i < 20; i++ {
+			if delay := s.backoff(attempt); delay < 0 || delay > limit {
+				t.Fatalf("attempt %d: delay %v outside [0, %v]", attempt, delay, limit)
+			}
+		}
+	}
+
+	// Shifting far enough overflows; the cap must still hold
+	if delay := s.backoff(80); delay < 0 || delay > time.Second {
+		t.Fatalf("overflowed backoff %v", delay)
+	}
+}
+
+func TestOnlyRejectedTokensAreDropped(t *testing.T) {
+	var mu sync.Mutex
+	var tokens []string
+	var challenge bool
+	s := newCachedService(t, func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		tokens = append(tokens, r.Header.Get("Authorization"))
+		if challenge {
+			w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+		}
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(`{"error":"unauthorized","message":"rejected"}`))
+	})
+	verify := func() {
+		s.VerifyProfessionalPassword(context.Background(), &VerifyPasswordRequest{ChatID: 1, Password: "wrong"})
+	}
+
+	// A wrong password keeps the token
+	verify()
+	verify()
+	// A rejected token is replaced on the next request
+	mu.Lock()
+	challenge = true
+	mu.Unlock()
+	verify()
+	verify()
+
+	if len(tokens) != 4 || tokens[0] != tokens[1] || tokens[1] != tokens[2] || tokens[2] == tokens[3] {
+		t.Fatalf("tokens sent = %q, want the first kept after a wrong password and replaced after a rejection", tokens)
+	}
+}
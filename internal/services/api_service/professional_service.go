@@ -2,13 +2,17 @@ package api_service
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"net/http"
 	"net/url"
+	"sync"
 	"time"
 
-	"booking_client/internal/common"
 	"booking_client/internal/models"
 	"booking_client/internal/schemas"
+
+	"golang.org/x/sync/errgroup"
 )
 
 // RegisterProfessional registers a new professional
@@ -23,8 +27,11 @@ func (s *APIService) RegisterProfessional(ctx context.Context, req *RegisterRequ
 		return nil, err
 	}
 
+	// The new professional can be booked at once
+	s.cache.invalidateProfessionals()
+
 	// Store the newly registered user in local storage
-	s.userRepository.SetUser(req.ChatID, &response.User)
+	s.storeProfile(req.ChatID, &response.User)
 	s.logger.Debug().Int64("chat_id", req.ChatID).Msg("Newly registered professional stored in local storage")
 
 	return &response.User, nil
@@ -43,19 +50,57 @@ func (s *APIService) SignInProfessional(ctx context.Context, req *ProfessionalSi
 	}
 
 	// Store the signed-in user in local storage
-	s.userRepository.SetUser(req.ChatID, &response.User)
+	s.storeProfile(req.ChatID, &response.User)
 	s.logger.Debug().Int64("chat_id", req.ChatID).Msg("Professional signed in and stored in local storage")
 
 	return &response.User, nil
 }
 
+// SignOutProfessional drops the profile and any conversation of the chat, then asks the API
+// to unbind the chat from its professional. The chat is signed out locally even when the API
+// call fails; the error only tells that the API may still find the chat bound.
+func (s *APIService) SignOutProfessional(ctx context.Context, chatID int64) error {
+	if session, exists := s.userRepository.GetSession(chatID); exists && session != nil {
+		s.userRepository.SetSession(chatID, session.SignedOut(chatID))
+	}
+	s.logger.Debug().Int64("chat_id", chatID).Msg("Professional signed out and removed from local storage")
+
+	url := s.buildURL("api", "professionals", "sign_out")
+
+	var response struct {
+		User models.User `json:"user"`
+	}
+
+	req := &ProfessionalSignOutRequest{ChatID: chatID}
+	return s.makePostRequest(ctx, url, req, &response, http.StatusOK)
+}
+
+// VerifyProfessionalPassword checks the password of the professional signed in to the chat,
+// without signing in again; a wrong password is ErrUnauthorized
+func (s *APIService) VerifyProfessionalPassword(ctx context.Context, req *VerifyPasswordRequest) error {
+	url := s.buildURL("api", "professionals", "verify_password")
+
+	var response struct{}
+	return s.makePostRequest(ctx, url, req, &response, http.StatusOK)
+}
+
+// ChangeProfessionalPassword sets a new password for the professional signed in to the chat
+func (s *APIService) ChangeProfessionalPassword(ctx context.Context, req *ChangePasswordRequest) error {
+	url := s.buildURL("api", "professionals", "change_password")
+
+	var response struct {
+		User models.User `json:"user"`
+	}
+
+	return s.makePostRequest(ctx, url, req, &response, http.StatusOK)
+}
+
 // GetProfessionals retrieves all professionals
 func (s *APIService) GetProfessionals(ctx context.Context) (*schemas.GetProfessionalsResponse, error) {
 	url := s.buildURL("api", "professionals")
 
 	var response schemas.GetProfessionalsResponse
-	requestID := common.GetRequestID(ctx)
-	if err := s.makeGetRequestWithContext(ctx, url, &response, requestID); err != nil {
+	if err := s.cachedGet(ctx, cacheProfessionals, "", url, &response); err != nil {
 		return nil, err
 	}
 
@@ -70,14 +115,43 @@ func (s *APIService) GetProfessionalAvailability(ctx context.Context, profession
 	url := s.buildURLWithQuery([]string{"api", "professionals", professionalID, "availability"}, query)
 
 	var response schemas.ProfessionalAvailabilityResponse
-	requestID := common.GetRequestID(ctx)
-	if err := s.makeGetRequestWithContext(ctx, url, &response, requestID); err != nil {
+	if err := s.cachedGet(ctx, cacheAvailability, professionalID, url, &response); err != nil {
 		return nil, err
 	}
 
 	return &response, nil
 }
 
+// GetProfessionalAvailabilityForDates retrieves the availability of a professional on each of the
+// given dates, by date. Dates are requested in parallel, at most API_AVAILABILITY_CONCURRENCY at once.
+// A date that fails is left out of the map and its error joined into the one returned, so callers
+// can still use the dates that loaded.
+func (s *APIService) GetProfessionalAvailabilityForDates(ctx context.Context, professionalID string, dates []string) (map[string]*schemas.ProfessionalAvailabilityResponse, error) {
+	var g errgroup.Group
+	g.SetLimit(s.availabilityConcurrency)
+
+	var mu sync.Mutex
+	var errs []error
+	availability := make(map[string]*schemas.ProfessionalAvailabilityResponse, len(dates))
+	for _, date := range dates {
+		date := date
+		g.Go(func() error {
+			response, err := s.GetProfessionalAvailability(ctx, professionalID, date)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				errs = append(errs, fmt.Errorf("availability on %s: %w", date, err))
+				return nil
+			}
+			availability[date] = response
+			return nil
+		})
+	}
+	g.Wait()
+
+	return availability, errors.Join(errs...)
+}
+
 // GetProfessionalAppointments retrieves professional appointments with optional status filter
 func (s *APIService) GetProfessionalAppointments(ctx context.Context, professionalID, status string) (*schemas.GetProfessionalAppointmentsResponse, error) {
 	query := url.Values{}
@@ -88,8 +162,7 @@ func (s *APIService) GetProfessionalAppointments(ctx context.Context, profession
 	url := s.buildURLWithQuery([]string{"api", "professionals", professionalID, "appointments"}, query)
 
 	var response schemas.GetProfessionalAppointmentsResponse
-	requestID := common.GetRequestID(ctx)
-	if err := s.makeGetRequestWithContext(ctx, url, &response, requestID); err != nil {
+	if err := s.makeGetRequest(ctx, url, &response); err != nil {
 		return nil, err
 	}
 
@@ -106,8 +179,7 @@ func (s *APIService) GetProfessionalAppointmentDates(ctx context.Context, profes
 	url := s.buildURLWithQuery([]string{"api", "professionals", professionalID, "appointment_dates"}, query)
 
 	var response schemas.GetProfessionalAppointmentDatesResponse
-	requestID := common.GetRequestID(ctx)
-	if err := s.makeGetRequestWithContext(ctx, url, &response, requestID); err != nil {
+	if err := s.cachedGet(ctx, cacheAppointmentDates, professionalID, url, &response); err != nil {
 		return nil, err
 	}
 
@@ -119,7 +191,9 @@ func (s *APIService) ConfirmProfessionalAppointment(ctx context.Context, profess
 	url := s.buildURL("api", "professionals", professionalID, "appointments", appointmentID, "confirm")
 
 	var response schemas.ConfirmProfessionalAppointmentResponse
-	if err := s.makePatchRequest(ctx, url, req, &response); err != nil {
+	err := s.makeIdempotentRequest(ctx, http.MethodPatch, url, req, &response, http.StatusOK)
+	s.cache.invalidateProfessional(professionalID)
+	if err != nil {
 		return nil, err
 	}
 
@@ -131,7 +205,9 @@ func (s *APIService) CancelProfessionalAppointment(ctx context.Context, professi
 	url := s.buildURL("api", "professionals", professionalID, "appointments", appointmentID, "cancel")
 
 	var response schemas.CancelProfessionalAppointmentResponse
-	if err := s.makePatchRequest(ctx, url, req, &response); err != nil {
+	err := s.makeIdempotentRequest(ctx, http.MethodPatch, url, req, &response, http.StatusOK)
+	s.cache.invalidateProfessional(professionalID)
+	if err != nil {
 		return nil, err
 	}
 
@@ -143,7 +219,9 @@ func (s *APIService) CreateUnavailableAppointment(ctx context.Context, req *Crea
 	url := s.buildURL("api", "professionals", req.ProfessionalID, "unavailable_appointments")
 
 	var response schemas.CreateUnavailableAppointmentResponse
-	if err := s.makePostRequest(ctx, url, req, &response, http.StatusCreated); err != nil {
+	err := s.makeIdempotentRequest(ctx, http.MethodPost, url, req, &response, http.StatusCreated)
+	s.cache.invalidateProfessional(req.ProfessionalID)
+	if err != nil {
 		return nil, err
 	}
 
@@ -159,8 +237,7 @@ func (s *APIService) GetProfessionalAppointmentsByDate(ctx context.Context, prof
 	url := s.buildURLWithQuery([]string{"api", "professionals", professionalID, "appointments"}, query)
 
 	var response schemas.GetProfessionalAppointmentsResponse
-	requestID := common.GetRequestID(ctx)
-	if err := s.makeGetRequestWithContext(ctx, url, &response, requestID); err != nil {
+	if err := s.makeGetRequest(ctx, url, &response); err != nil {
 		return nil, err
 	}
 
@@ -175,8 +252,7 @@ func (s *APIService) GetProfessionalTimetable(ctx context.Context, professionalI
 	url := s.buildURLWithQuery([]string{"api", "professionals", professionalID, "timetable"}, query)
 
 	var response schemas.GetProfessionalTimetableResponse
-	requestID := common.GetRequestID(ctx)
-	if err := s.makeGetRequestWithContext(ctx, url, &response, requestID); err != nil {
+	if err := s.makeGetRequest(ctx, url, &response); err != nil {
 		return nil, err
 	}
 
@@ -191,8 +267,7 @@ func (s *APIService) GetProfessionalClients(ctx context.Context, professionalID
 		Clients []schemas.ProfessionalClient `json:"clients"`
 	}
 
-	requestID := common.GetRequestID(ctx)
-	if err := s.makeGetRequestWithContext(ctx, url, &response, requestID); err != nil {
+	if err := s.makeGetRequest(ctx, url, &response); err != nil {
 		return nil, err
 	}
 
@@ -210,8 +285,7 @@ func (s *APIService) GetPreviousAppointmentsByClient(ctx context.Context, profes
 	url := s.buildURLWithQuery([]string{"api", "professionals", professionalID, "previous_appointments"}, query)
 
 	var response schemas.GetPreviousAppointmentsByClientResponse
-	requestID := common.GetRequestID(ctx)
-	if err := s.makeGetRequestWithContext(ctx, url, &response, requestID); err != nil {
+	if err := s.makeGetRequest(ctx, url, &response); err != nil {
 		return nil, err
 	}
 
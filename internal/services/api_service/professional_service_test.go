@@ -0,0 +1,62 @@
+package api_service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestAvailabilityForDatesIsBoundedAndComplete(t *testing.T) {
+	var inFlight, maxInFlight atomic.Int32
+	s := newCachedService(t, func(w http.ResponseWriter, r *http.Request) {
+		current := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for {
+			seen := maxInFlight.Load()
+			if current <= seen || maxInFlight.CompareAndSwap(seen, current) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		fmt.Fprintf(w, `{"date":%q,"slots":[]}`, r.URL.Query().Get("date"))
+	})
+
+	dates := []string{"2026-01-01", "2026-01-02", "2026-01-03", "2026-01-04", "2026-01-05"}
+	availability, err := s.GetProfessionalAvailabilityForDates(context.Background(), "pro-1", dates)
+	if err != nil {
+		t.Fatalf("GetProfessionalAvailabilityForDates: %v", err)
+	}
+
+	if got := maxInFlight.Load(); got > 2 {
+		t.Fatalf("%d requests in flight at once, want at most 2", got)
+	}
+	for _, date := range dates {
+		if day, exists := availability[date]; !exists || day.Date != date {
+			t.Fatalf("availability on %s: got %+v", date, day)
+		}
+	}
+}
+
+func TestAvailabilityForDatesKeepsTheDaysThatLoaded(t *testing.T) {
+	s := newCachedService(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("date") == "2026-01-02" {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"error":"not_found","message":"professional not found"}`))
+			return
+		}
+		fmt.Fprintf(w, `{"date":%q,"slots":[]}`, r.URL.Query().Get("date"))
+	})
+
+	availability, err := s.GetProfessionalAvailabilityForDates(context.Background(), "pro-1", []string{"2026-01-01", "2026-01-02", "2026-01-03"})
+	if !errors.Is(err, ErrNotFound) || !strings.Contains(err.Error(), "2026-01-02") {
+		t.Fatalf("got %v, want ErrNotFound for 2026-01-02", err)
+	}
+	if _, exists := availability["2026-01-02"]; exists || len(availability) != 2 {
+		t.Fatalf("got availability on %d days, want the 2 that loaded: %+v", len(availability), availability)
+	}
+}
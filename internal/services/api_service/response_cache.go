@@ -0,0 +1,274 @@
+package api_service
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"booking_client/internal/common"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// Cached endpoints
+const (
+	cacheProfessionals    = "professionals"
+	cacheAvailability     = "availability"
+	cacheAppointmentDates = "appointment_dates"
+)
+
+// CacheOptions configures the read-through cache of API responses, per endpoint.
+// A zero TTL turns caching of the endpoint off.
+type CacheOptions struct {
+	ProfessionalsTTL    time.Duration
+	AvailabilityTTL     time.Duration
+	AppointmentDatesTTL time.Duration
+	MaxEntries          int // Entries kept at most, soonest to expire evicted in batches first; 0 is unbounded
+}
+
+// CacheStats counts how the requests of one cached endpoint were served, for monitoring
+type CacheStats struct {
+	Hits      int64 `json:"hits"`
+	Misses    int64 `json:"misses"`
+	Coalesced int64 `json:"coalesced"`
+}
+
+// cachePolicy is how the responses of one endpoint are cached
+type cachePolicy struct {
+	ttl time.Duration
+	// perUser keys responses by the chat they were fetched for, as they are only for its user
+	perUser bool
+}
+
+// cacheEntry is a response body, decoded again on every hit so callers never share a value
+type cacheEntry struct {
+	body           []byte
+	expires        time.Time
+	professionalID string
+}
+
+// evictFraction is the share of MaxEntries freed at once when the cache is full,
+// so the cost of finding the entries soonest to expire is spread over many puts
+const evictFraction = 10
+
+// responseCache is a TTL cache of GET responses in front of the API.
+// Concurrent misses for the same key share one request.
+type responseCache struct {
+	mu         sync.Mutex
+	policies   map[string]cachePolicy
+	entries    map[string]*cacheEntry
+	stats      map[string]*CacheStats
+	maxEntries int
+	// generation changes on every invalidation, so responses fetched before it are not stored
+	generation uint64
+	group      singleflight.Group
+	now        func() time.Time
+}
+
+// newResponseCache creates the cache with the policies of the cached endpoints
+func newResponseCache(options CacheOptions) *responseCache {
+	return &responseCache{
+		policies: map[string]cachePolicy{
+			cacheProfessionals:    {ttl: options.ProfessionalsTTL},
+			cacheAvailability:     {ttl: options.AvailabilityTTL},
+			cacheAppointmentDates: {ttl: options.AppointmentDatesTTL, perUser: true},
+		},
+		entries:    make(map[string]*cacheEntry),
+		stats:      make(map[string]*CacheStats),
+		maxEntries: options.MaxEntries,
+		now:        time.Now,
+	}
+}
+
+// cachedGet performs a GET through the cache of the endpoint and unmarshals the response.
+// professionalID tags the entry, so writes concerning the professional invalidate it.
+func (s *APIService) cachedGet(ctx context.Context, endpoint, professionalID, url string, result interface{}) error {
+	c := s.cache
+	policy := c.policies[endpoint]
+	if policy.ttl <= 0 {
+		return s.makeGetRequest(ctx, url, result)
+	}
+
+	key := url
+	if policy.perUser {
+		chatID, _ := common.GetChatID(ctx)
+		key = fmt.Sprintf("%s#chat=%d", url, chatID)
+	}
+
+	body, generation, hit := c.get(endpoint, key)
+	if !hit {
+		// Requests started before an invalidation are not joined by those started after it.
+		// The request outlives the caller that started it, so one caller giving up doesn't
+		// fail the others; each caller still stops waiting when its own context is done.
+		results := c.group.DoChan(fmt.Sprintf("%s#gen=%d", key, generation), func() (interface{}, error) {
+			fetchCtx, cancel := s.sharedContext(ctx)
+			defer cancel()
+			// Responses shared between users are fetched as the bot, not as whichever user missed first
+			if !policy.perUser {
+				fetchCtx = common.WithServiceIdentity(fetchCtx)
+			}
+
+			body, err := s.makeRequest(fetchCtx, http.MethodGet, url, nil, http.StatusOK)
+			if err != nil {
+				return nil, err
+			}
+			c.put(key, generation, policy, professionalID, body)
+			return body, nil
+		})
+
+		var result singleflight.Result
+		select {
+		case <-ctx.Done():
+			return transportError(ctx.Err())
+		case result = <-results:
+		}
+		if result.Err != nil {
+			return result.Err
+		}
+		if result.Shared {
+			c.count(endpoint, func(stats *CacheStats) { stats.Coalesced++ })
+		}
+		body = result.Val.([]byte)
+	}
+	return unmarshalResponse(body, result)
+}
+
+// sharedContext detaches a request shared by several callers from the cancellation of the
+// caller that started it, keeping its values (chat, logger). It is bounded instead by the
+// longest the request may take with every attempt and backoff.
+func (s *APIService) sharedContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	ctx = context.WithoutCancel(ctx)
+	if s.client.Timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	attempts := time.Duration(s.retry.MaxRetries + 1)
+	return context.WithTimeout(ctx, attempts*s.client.Timeout+(attempts-1)*s.retry.MaxDelay)
+}
+
+// get returns the fresh body stored under key, or the current generation on a miss
+func (c *responseCache) get(endpoint, key string) ([]byte, uint64, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stats := c.statsOf(endpoint)
+	if entry, exists := c.entries[key]; exists && c.now().Before(entry.expires) {
+		stats.Hits++
+		return entry.body, c.generation, true
+	}
+	stats.Misses++
+	return nil, c.generation, false
+}
+
+// put stores a body unless the cache was invalidated since the request started
+func (c *responseCache) put(key string, generation uint64, policy cachePolicy, professionalID string, body []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if generation != c.generation {
+		return
+	}
+	now := c.now()
+	if c.maxEntries > 0 && len(c.entries) >= c.maxEntries {
+		c.evict(now)
+	}
+	c.entries[key] = &cacheEntry{
+		body:           body,
+		expires:        now.Add(policy.ttl),
+		professionalID: professionalID,
+	}
+}
+
+// evict drops expired entries and then those closest to expiry, until a tenth of
+// maxEntries (at least one) is free, so a full cache is scanned once per many puts
+func (c *responseCache) evict(now time.Time) {
+	live := make([]string, 0, len(c.entries))
+	for key, entry := range c.entries {
+		if !now.Before(entry.expires) {
+			delete(c.entries, key)
+			continue
+		}
+		live = append(live, key)
+	}
+
+	target := c.maxEntries - max(c.maxEntries/evictFraction, 1)
+	if len(live) <= target {
+		return
+	}
+	sort.Slice(live, func(i, j int) bool {
+		return c.entries[live[i]].expires.Before(c.entries[live[j]].expires)
+	})
+	for _, key := range live[:len(live)-target] {
+		delete(c.entries, key)
+	}
+}
+
+// invalidateProfessional drops the entries about a professional, after a write changed them
+func (c *responseCache) invalidateProfessional(professionalID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.generation++
+	for key, entry := range c.entries {
+		if entry.professionalID == professionalID {
+			delete(c.entries, key)
+		}
+	}
+}
+
+// invalidateAppointments drops the entries of every professional, for writes whose professional is unknown
+func (c *responseCache) invalidateAppointments() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.generation++
+	for key, entry := range c.entries {
+		if entry.professionalID != "" {
+			delete(c.entries, key)
+		}
+	}
+}
+
+// invalidateProfessionals drops the list of professionals, after one registered
+func (c *responseCache) invalidateProfessionals() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.generation++
+	for key, entry := range c.entries {
+		if entry.professionalID == "" {
+			delete(c.entries, key)
+		}
+	}
+}
+
+// snapshot returns the counters of every cached endpoint
+func (c *responseCache) snapshot() map[string]CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stats := make(map[string]CacheStats, len(c.stats))
+	for endpoint, s := range c.stats {
+		stats[endpoint] = *s
+	}
+	return stats
+}
+
+// count updates the counters of an endpoint
+func (c *responseCache) count(endpoint string, update func(stats *CacheStats)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	update(c.statsOf(endpoint))
+}
+
+// statsOf returns the counters of an endpoint, creating them; the lock must be held
+func (c *responseCache) statsOf(endpoint string) *CacheStats {
+	stats, exists := c.stats[endpoint]
+	if !exists {
+		stats = &CacheStats{}
+		c.stats[endpoint] = stats
+	}
+	return stats
+}
@@ -0,0 +1,254 @@
+package api_service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"booking_client/internal/common"
+	"booking_client/internal/config"
+	"booking_client/internal/models"
+
+	"github.com/rs/zerolog"
+)
+
+// newCachedService creates a service caching every endpoint for an hour against handler,
+// fetching at most two dates of availability at once
+func newCachedService(t *testing.T, handler http.HandlerFunc) *APIService {
+	t.Helper()
+
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	logger := zerolog.Nop()
+	s, err := NewAPIService(&config.Config{
+		APIBaseURL:                  server.URL,
+		APITimeout:                  time.Second,
+		JWTSecret:                   "cache-test-secret-0123456789abcdef",
+		JWTTTL:                      time.Hour,
+		JWTUserTTL:                  time.Minute,
+		APICacheProfessionalsTTL:    time.Hour,
+		APICacheAvailabilityTTL:     time.Hour,
+		APICacheAppointmentDatesTTL: time.Hour,
+		APIAvailabilityConcurrency:  2,
+	}, &logger)
+	if err != nil {
+		t.Fatalf("failed to create service: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func TestConcurrentMissesShareOneRequest(t *testing.T) {
+	var requests atomic.Int32
+	release := make(chan struct{})
+	s := newCachedService(t, func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		<-release
+		w.Write([]byte(`{"professionals":[]}`))
+	})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := s.GetProfessionals(context.Background()); err != nil {
+				t.Errorf("GetProfessionals: %v", err)
+			}
+		}()
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if _, err := s.GetProfessionals(context.Background()); err != nil {
+		t.Fatalf("GetProfessionals: %v", err)
+	}
+	if got := requests.Load(); got != 1 {
+		t.Fatalf("API got %d requests, want 1", got)
+	}
+	if stats := s.CacheStats()[cacheProfessionals]; stats.Hits == 0 {
+		t.Fatalf("no cache hits recorded: %+v", stats)
+	}
+}
+
+func TestCancelledCallerDoesNotFailSharedRequest(t *testing.T) {
+	var requests atomic.Int32
+	started := make(chan struct{}, 1)
+	release := make(chan struct{})
+	s := newCachedService(t, func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		started <- struct{}{}
+		<-release
+		w.Write([]byte(`{"professionals":[]}`))
+	})
+
+	// The caller starting the request gives up while it is in flight
+	ctx, cancel := context.WithCancel(context.Background())
+	first := make(chan error, 1)
+	go func() {
+		_, err := s.GetProfessionals(ctx)
+		first <- err
+	}()
+	<-started
+
+	joined := make(chan error, 1)
+	go func() {
+		_, err := s.GetProfessionals(context.Background())
+		joined <- err
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	cancel()
+	if err := <-first; !errors.Is(err, context.Canceled) {
+		t.Fatalf("cancelled caller got %v, want context.Canceled", err)
+	}
+	close(release)
+	if err := <-joined; err != nil {
+		t.Fatalf("joined caller failed with the cancelled one: %v", err)
+	}
+	if got := requests.Load(); got != 1 {
+		t.Fatalf("API got %d requests, want 1", got)
+	}
+}
+
+func TestSharedResponsesAreFetchedAsTheService(t *testing.T) {
+	var mu sync.Mutex
+	tokens := make(map[string]string)
+	s := newCachedService(t, func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		tokens[r.URL.Path] = r.Header.Get("Authorization")
+		mu.Unlock()
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/availability"):
+			w.Write([]byte(`{"date":"2026-01-01","slots":[]}`))
+		case strings.HasSuffix(r.URL.Path, "/appointment_dates"):
+			w.Write([]byte(`{"month":"2026-01","dates":[]}`))
+		default:
+			w.Write([]byte(`{"professionals":[]}`))
+		}
+	})
+
+	const chatID int64 = 7
+	session := models.NewSession(chatID)
+	session.Profile.ID = "pro-1"
+	session.Profile.Role = models.RoleProfessional
+	s.userRepository.SetSession(chatID, session)
+	userCtx := common.WithChatID(context.Background(), chatID)
+
+	if _, err := s.GetProfessionals(context.Background()); err != nil {
+		t.Fatalf("GetProfessionals: %v", err)
+	}
+	if _, err := s.GetProfessionalAvailability(userCtx, "pro-1", "2026-01-01"); err != nil {
+		t.Fatalf("GetProfessionalAvailability: %v", err)
+	}
+	if _, err := s.GetProfessionalAppointmentDates(userCtx, "pro-1", "2026-01"); err != nil {
+		t.Fatalf("GetProfessionalAppointmentDates: %v", err)
+	}
+
+	// Availability is served to every user, so the user who missed first lends it no identity;
+	// appointment dates are cached per chat and fetched as its user
+	service := tokens["/api/professionals"]
+	if got := tokens["/api/professionals/pro-1/availability"]; got != service {
+		t.Fatalf("availability fetched with %q, want the service token %q", got, service)
+	}
+	if got := tokens["/api/professionals/pro-1/appointment_dates"]; got == service {
+		t.Fatal("appointment dates fetched with the service token, want the user's")
+	}
+}
+
+func TestInvalidationDropsResponsesInFlight(t *testing.T) {
+	var requests atomic.Int32
+	started := make(chan struct{}, 1)
+	release := make(chan struct{})
+	s := newCachedService(t, func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) == 1 {
+			started <- struct{}{}
+			<-release
+		}
+		w.Write([]byte(`{"date":"2026-01-01","slots":[]}`))
+	})
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if _, err := s.GetProfessionalAvailability(context.Background(), "pro-1", "2026-01-01"); err != nil {
+			t.Errorf("GetProfessionalAvailability: %v", err)
+		}
+	}()
+
+	// A booking lands while the availability is being fetched
+	<-started
+	s.cache.invalidateProfessional("pro-1")
+	close(release)
+	<-done
+
+	if _, err := s.GetProfessionalAvailability(context.Background(), "pro-1", "2026-01-01"); err != nil {
+		t.Fatalf("GetProfessionalAvailability: %v", err)
+	}
+	if got := requests.Load(); got != 2 {
+		t.Fatalf("API got %d requests, want the stale response refetched", got)
+	}
+}
+
+func TestCacheEvictsSoonestToExpire(t *testing.T) {
+	c := newResponseCache(CacheOptions{AvailabilityTTL: time.Minute, MaxEntries: 2})
+	now := time.Now()
+	c.now = func() time.Time { return now }
+	policy := c.policies[cacheAvailability]
+
+	c.put("a", 0, policy, "pro-1", []byte("a"))
+	now = now.Add(time.Second)
+	c.put("b", 0, policy, "pro-1", []byte("b"))
+	c.put("c", 0, policy, "pro-2", []byte("c"))
+
+	if _, _, hit := c.get(cacheAvailability, "a"); hit {
+		t.Fatal("oldest entry was kept beyond MaxEntries")
+	}
+	for _, key := range []string{"b", "c"} {
+		if _, _, hit := c.get(cacheAvailability, key); !hit {
+			t.Fatalf("entry %q was evicted", key)
+		}
+	}
+
+	c.invalidateProfessional("pro-2")
+	if _, _, hit := c.get(cacheAvailability, "c"); hit {
+		t.Fatal("entry of an invalidated professional was kept")
+	}
+}
+
+func TestCacheEvictsInBatches(t *testing.T) {
+	c := newResponseCache(CacheOptions{AvailabilityTTL: time.Minute, MaxEntries: 20})
+	now := time.Now()
+	c.now = func() time.Time { return now }
+	policy := c.policies[cacheAvailability]
+
+	for i := 0; i < 20; i++ {
+		c.put(fmt.Sprintf("k%02d", i), 0, policy, "pro-1", nil)
+		now = now.Add(time.Second)
+	}
+	c.put("new", 0, policy, "pro-1", nil)
+
+	// A full cache frees a tenth of its entries, soonest to expire first
+	if got := len(c.entries); got != 19 {
+		t.Fatalf("cache kept %d entries, want 19", got)
+	}
+	for _, key := range []string{"k00", "k01"} {
+		if _, _, hit := c.get(cacheAvailability, key); hit {
+			t.Fatalf("entry %q was kept", key)
+		}
+	}
+	for _, key := range []string{"k02", "k19", "new"} {
+		if _, _, hit := c.get(cacheAvailability, key); !hit {
+			t.Fatalf("entry %q was evicted", key)
+		}
+	}
+}
@@ -7,6 +7,9 @@ type RegisterRequest struct {
 	ChatID      int64   `json:"chat_id" binding:"required"`
 	PhoneNumber *string `json:"phone_number,omitempty"`
 	Role        string  `json:"role" binding:"required"` // "client" or "professional"
+	Username    string  `json:"username,omitempty"`      // Professionals only
+	Password    string  `json:"password,omitempty"`      // Professionals only
+	Campaign    *string `json:"campaign,omitempty"`      // Deep link campaign the user arrived from
 }
 
 // ProfessionalSignInRequest represents a professional sign-in request
@@ -16,6 +19,23 @@ type ProfessionalSignInRequest struct {
 	ChatID   int64  `json:"chat_id" binding:"required"`
 }
 
+// ProfessionalSignOutRequest represents a request to unbind a chat from its professional
+type ProfessionalSignOutRequest struct {
+	ChatID int64 `json:"chat_id" binding:"required"`
+}
+
+// VerifyPasswordRequest represents a request to check the password of the professional signed in to a chat
+type VerifyPasswordRequest struct {
+	ChatID   int64  `json:"chat_id" binding:"required"`
+	Password string `json:"password" binding:"required"`
+}
+
+// ChangePasswordRequest represents a request to change the password of the professional signed in to a chat
+type ChangePasswordRequest struct {
+	ChatID      int64  `json:"chat_id" binding:"required"`
+	NewPassword string `json:"new_password" binding:"required"`
+}
+
 // CreateAppointmentRequest represents a request to create an appointment
 type CreateAppointmentRequest struct {
 	ClientID       string `json:"client_id" binding:"required"`
@@ -1,14 +1,15 @@
 package api_service
 
 import (
+	"booking_client/internal/common"
 	"booking_client/internal/config"
 	"booking_client/internal/repository"
 	"booking_client/internal/token"
+	"context"
 	"fmt"
 	"net/http"
 	"net/url"
 	"path"
-	"time"
 
 	"github.com/rs/zerolog"
 )
@@ -18,62 +19,150 @@ type APIService struct {
 	baseURL        string
 	client         *http.Client
 	logger         *zerolog.Logger
-	userRepository *repository.UserRepository
-	tokenMaker     token.Maker
+	userRepository *repository.ExpiringUserRepository
+	tokens         *token.Manager
+	retry          RetryOptions
+	breakers       *breakers
+	cache          *responseCache
+	// availabilityConcurrency bounds the days of a calendar requested at once
+	availabilityConcurrency int
 }
 
+// serviceName identifies the bot to the booking API
+const serviceName = "booking_client"
+
 // NewAPIService creates a new API service
 func NewAPIService(config *config.Config, logger *zerolog.Logger) (*APIService, error) {
-	// Create token maker
-	tokenMaker, err := token.NewJWTMaker(config.JWTSecret)
+	tokenMaker, err := newTokenMaker(config)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create token maker: %w", err)
 	}
 
+	store, err := repository.NewUserRepository(config.SessionStore, config.SessionStorePath, logger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create user repository: %w", err)
+	}
+	userRepository := repository.NewExpiringUserRepository(store, repository.ExpiryOptions{
+		IdleTTL:       config.SessionIdleTTL,
+		MaxSessions:   config.SessionMaxEntries,
+		SweepInterval: config.SessionSweepInterval,
+	}, logger)
+
 	return &APIService{
 		baseURL: config.APIBaseURL,
 		client: &http.Client{
-			Timeout: 30 * time.Second,
+			Timeout: config.APITimeout,
+		},
+		retry: RetryOptions{
+			MaxRetries: config.APIMaxRetries,
+			BaseDelay:  config.APIRetryBaseDelay,
+			MaxDelay:   config.APIRetryMaxDelay,
 		},
-		logger:         logger,
-		userRepository: repository.NewUserRepository(),
-		tokenMaker:     tokenMaker,
+		breakers: newBreakers(BreakerOptions{
+			FailureThreshold: config.APIBreakerFailures,
+			OpenDuration:     config.APIBreakerOpenDuration,
+		}),
+		cache: newResponseCache(CacheOptions{
+			ProfessionalsTTL:    config.APICacheProfessionalsTTL,
+			AvailabilityTTL:     config.APICacheAvailabilityTTL,
+			AppointmentDatesTTL: config.APICacheAppointmentDatesTTL,
+			MaxEntries:          config.APICacheMaxEntries,
+		}),
+		availabilityConcurrency: max(config.APIAvailabilityConcurrency, 1),
+		logger:                  logger,
+		userRepository:          userRepository,
+		tokens: token.NewManager(tokenMaker, token.ManagerOptions{
+			Service: serviceName,
+			TTL:     config.JWTTTL,
+			UserTTL: config.JWTUserTTL,
+		}),
 	}, nil
 }
 
+// newTokenMaker creates the maker signing with the configured key file, or else the shared secret
+func newTokenMaker(config *config.Config) (token.Maker, error) {
+	var key *token.Key
+	var err error
+	if config.JWTPrivateKeyFile != "" {
+		key, err = token.LoadKeyFile(config.JWTKeyID, config.JWTPrivateKeyFile)
+	} else {
+		key, err = token.NewHMACKey(config.JWTKeyID, config.JWTSecret)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return token.NewJWTMakerWithOptions(token.JWTOptions{
+		Issuer:     config.JWTIssuer,
+		Audience:   config.JWTAudience,
+		SigningKey: key,
+	})
+}
+
 // addAuthHeader adds the JWT authorization header to the request
 func (s *APIService) addAuthHeader(req *http.Request) error {
-	authToken, err := s.tokenMaker.CreateToken("booking_client", 24*time.Hour)
+	authToken, err := s.authToken(req.Context())
 	if err != nil {
 		return fmt.Errorf("failed to create auth token: %w", err)
 	}
-	fmt.Println("authToken", authToken)
 
 	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", authToken))
 
 	return nil
 }
 
-// addRequestHeaders adds common headers including request_id to the request
-func (s *APIService) addRequestHeaders(req *http.Request, requestID string) error {
-	// Add auth header
-	if err := s.addAuthHeader(req); err != nil {
-		return err
+// authToken returns a token acting for the registered user of the chat whose update is
+// being handled, or the service token outside updates and for unregistered chats
+func (s *APIService) authToken(ctx context.Context) (string, error) {
+	if common.IsServiceIdentity(ctx) {
+		return s.tokens.Token()
 	}
-
-	// Add request_id header
-	if requestID != "" {
-		req.Header.Set("X-Request-ID", requestID)
+	chatID, ok := common.GetChatID(ctx)
+	if !ok {
+		return s.tokens.Token()
+	}
+	session, exists := s.userRepository.GetSession(chatID)
+	if !exists || session == nil || !session.IsRegistered() {
+		return s.tokens.Token()
 	}
 
-	return nil
+	return s.tokens.UserToken(token.Actor{
+		UserID: session.Profile.ID,
+		Role:   session.Profile.Role,
+		ChatID: chatID,
+	})
+}
+
+// Available reports whether the booking API may be called, false while its breaker is open
+func (s *APIService) Available() bool {
+	return s.breakers.available()
+}
+
+// BreakerStats returns the state of the circuit breakers by endpoint; "*" is the whole API
+func (s *APIService) BreakerStats() map[string]BreakerStatus {
+	return s.breakers.snapshot()
 }
 
-// GetUserRepository returns the user repository for direct access if needed
-func (s *APIService) GetUserRepository() *repository.UserRepository {
+// CacheStats returns the hits, misses and coalesced requests of the cached endpoints
+func (s *APIService) CacheStats() map[string]CacheStats {
+	return s.cache.snapshot()
+}
+
+// GetUserRepository returns the session repository for direct access if needed
+func (s *APIService) GetUserRepository() repository.UserRepository {
 	return s.userRepository
 }
 
+// OnSessionEvicted registers the function notified when a session expires or is evicted
+func (s *APIService) OnSessionEvicted(fn repository.EvictionFunc) {
+	s.userRepository.OnEvict(fn)
+}
+
+// Close releases the resources held by the service, closing the session store
+func (s *APIService) Close() error {
+	return s.userRepository.Close()
+}
+
 // buildURL constructs a URL from the base URL and path segments
 func (s *APIService) buildURL(pathSegments ...string) string {
 	u, _ := url.Parse(s.baseURL)
@@ -6,47 +6,63 @@ import (
 	"context"
 	"fmt"
 	"strconv"
+	"time"
 )
 
-// GetUserByChatID retrieves a user by their chat ID (checks local storage first, then API)
+// GetUserByChatID retrieves a user by their chat ID (checks the cached session profile first, then API)
 func (s *APIService) GetUserByChatID(ctx context.Context, chatID int64) (*models.User, error) {
 	// First, check local storage
-	if user, exists := s.userRepository.GetUser(chatID); exists {
+	if session, exists := s.userRepository.GetSession(chatID); exists && session.IsRegistered() {
 		logger := common.GetLogger(ctx)
 		logger.Debug().Int64("chat_id", chatID).Msg("User found in local storage")
-		return user, nil
+		profile := session.Profile
+		return &profile, nil
 	}
 
 	// If not found locally, fetch from API
 	logger := common.GetLogger(ctx)
 	logger.Debug().Int64("chat_id", chatID).Msg("User not found in local storage, fetching from API")
+	return s.RefreshUserProfile(ctx, chatID)
+}
+
+// RefreshUserProfile reloads the profile of a chat from the API and caches it in the
+// session, leaving any conversation in progress untouched
+func (s *APIService) RefreshUserProfile(ctx context.Context, chatID int64) (*models.User, error) {
 	user, err := s.fetchUserFromAPI(ctx, chatID)
 	if err != nil {
 		return nil, err
 	}
 
-	// Store in local storage for future use
-	s.userRepository.SetUser(chatID, user)
+	s.storeProfile(chatID, user)
+	logger := common.GetLogger(ctx)
 	logger.Debug().Int64("chat_id", chatID).Msg("User stored in local storage")
 
 	return user, nil
 }
 
-// fetchUserFromAPI fetches a user from the API
+// storeProfile caches the profile in the chat session, creating the session if needed
+func (s *APIService) storeProfile(chatID int64, user *models.User) {
+	session, exists := s.userRepository.GetSession(chatID)
+	if !exists || session == nil {
+		session = models.NewSession(chatID)
+	}
+	session.SetProfile(*user, time.Now())
+	s.userRepository.SetSession(chatID, session)
+}
+
+// fetchUserFromAPI fetches a user from the API; the lookup is made as the service,
+// since it is how the bot learns who the chat's user is
 func (s *APIService) fetchUserFromAPI(ctx context.Context, chatID int64) (*models.User, error) {
+	ctx = common.WithServiceIdentity(ctx)
 	url := s.buildURL("api", "users", strconv.FormatInt(chatID, 10))
 
 	var response struct {
 		User models.User `json:"user"`
 	}
 
-	requestID := common.GetRequestID(ctx)
-	if err := s.makeGetRequestWithContext(ctx, url, &response, requestID); err != nil {
-		// Handle 404 specifically for better error message
-		if fmt.Sprint(err) == "API returned status 404" {
-			return nil, fmt.Errorf("user not found")
-		}
-		return nil, err
+	if err := s.makeGetRequest(ctx, url, &response); err != nil {
+		// Keep the kind, so callers can tell unregistered chats from failures with errors.Is(err, ErrNotFound)
+		return nil, fmt.Errorf("failed to fetch user: %w", err)
 	}
 
 	return &response.User, nil
@@ -1,6 +1,7 @@
 package token
 
 import (
+	"errors"
 	"fmt"
 	"time"
 
@@ -9,53 +10,126 @@ import (
 
 const minSecretKeySize = 32
 
+// ErrNoSigningKey is returned when a maker that only verifies is asked to create a token
+var ErrNoSigningKey = errors.New("no signing key configured")
+
+// JWTOptions configures a JWTMaker
+type JWTOptions struct {
+	Issuer     string // iss of created tokens, required of verified tokens when set
+	Audience   string // aud of created tokens, required of verified tokens when set
+	SigningKey *Key   // Signs created tokens; nil makes a maker that only verifies
+	VerifyKeys []*Key // Further keys accepted by kid, such as keys being rotated out
+}
+
 // JWTMaker is a JSON Web Token maker
 type JWTMaker struct {
-	secretKey string
+	issuer   string
+	audience string
+	signing  *Key
+	keys     map[string]*Key
 }
 
-// NewJWTMaker creates a new JWTMaker
+// NewJWTMaker creates a new JWTMaker signing with a shared HS256 secret
 func NewJWTMaker(secretKey string) (Maker, error) {
-	if len(secretKey) < minSecretKeySize {
-		return nil, fmt.Errorf("invalid key size: must be at least %d characters", minSecretKeySize)
+	key, err := NewHMACKey("", secretKey)
+	if err != nil {
+		return nil, err
 	}
-	return &JWTMaker{secretKey}, nil
+	return NewJWTMakerWithOptions(JWTOptions{SigningKey: key})
 }
 
-// CreateToken creates a new token for a specific service and duration
-func (maker *JWTMaker) CreateToken(service string, duration time.Duration) (string, error) {
-	payload := NewPayload(service, duration)
+// NewJWTMakerWithOptions creates a JWTMaker with a set of keys told apart by their kid.
+// Tokens without a kid are verified with the key whose ID is empty.
+func NewJWTMakerWithOptions(opts JWTOptions) (*JWTMaker, error) {
+	maker := &JWTMaker{
+		issuer:   opts.Issuer,
+		audience: opts.Audience,
+		signing:  opts.SigningKey,
+		keys:     make(map[string]*Key),
+	}
 
-	jwtToken := jwt.NewWithClaims(jwt.SigningMethodHS256, payload)
-	token, err := jwtToken.SignedString([]byte(maker.secretKey))
-	if err != nil {
-		return "", fmt.Errorf("failed to sign token: %w", err)
+	keys := opts.VerifyKeys
+	if opts.SigningKey != nil {
+		if !opts.SigningKey.CanSign() {
+			return nil, fmt.Errorf("signing key %q holds no private key", opts.SigningKey.ID)
+		}
+		keys = append([]*Key{opts.SigningKey}, keys...)
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("at least one key is required")
+	}
+	for _, key := range keys {
+		if _, exists := maker.keys[key.ID]; exists {
+			return nil, fmt.Errorf("duplicate key ID %q", key.ID)
+		}
+		maker.keys[key.ID] = key
 	}
 
-	return token, nil
+	return maker, nil
 }
 
-// JWT Claims interface implementation for Payload
-func (payload *Payload) GetExpirationTime() (*jwt.NumericDate, error) {
-	return jwt.NewNumericDate(payload.ExpiredAt), nil
+// CreateToken creates a new token for a specific service and duration
+func (maker *JWTMaker) CreateToken(service string, duration time.Duration) (string, error) {
+	return maker.sign(NewPayload(service, duration))
 }
 
-func (payload *Payload) GetIssuedAt() (*jwt.NumericDate, error) {
-	return jwt.NewNumericDate(payload.IssuedAt), nil
+// CreateUserToken creates a new token for a service acting on behalf of a user
+func (maker *JWTMaker) CreateUserToken(service string, actor Actor, duration time.Duration) (string, error) {
+	if actor.UserID == "" || actor.Role == "" {
+		return "", fmt.Errorf("user ID and role are required")
+	}
+	return maker.sign(NewUserPayload(service, actor, duration))
 }
 
-func (payload *Payload) GetNotBefore() (*jwt.NumericDate, error) {
-	return nil, nil
-}
+// sign fills in the issuer and audience and signs the payload with the signing key
+func (maker *JWTMaker) sign(payload *Payload) (string, error) {
+	if maker.signing == nil {
+		return "", ErrNoSigningKey
+	}
 
-func (payload *Payload) GetIssuer() (string, error) {
-	return "", nil
-}
+	payload.Issuer = maker.issuer
+	if maker.audience != "" {
+		payload.Audience = jwt.ClaimStrings{maker.audience}
+	}
+
+	jwtToken := jwt.NewWithClaims(maker.signing.method, payload)
+	if maker.signing.ID != "" {
+		jwtToken.Header["kid"] = maker.signing.ID
+	}
+	token, err := jwtToken.SignedString(maker.signing.sign)
+	if err != nil {
+		return "", fmt.Errorf("failed to sign token: %w", err)
+	}
 
-func (payload *Payload) GetSubject() (string, error) {
-	return "", nil
+	return token, nil
 }
 
-func (payload *Payload) GetAudience() (jwt.ClaimStrings, error) {
-	return nil, nil
+// VerifyToken checks the signature and registered claims of a token and returns its payload
+func (maker *JWTMaker) VerifyToken(token string) (*Payload, error) {
+	keyFunc := func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		key, exists := maker.keys[kid]
+		if !exists || token.Method.Alg() != key.method.Alg() {
+			return nil, ErrInvalidToken
+		}
+		return key.verify, nil
+	}
+
+	var options []jwt.ParserOption
+	if maker.issuer != "" {
+		options = append(options, jwt.WithIssuer(maker.issuer))
+	}
+	if maker.audience != "" {
+		options = append(options, jwt.WithAudience(maker.audience))
+	}
+
+	payload := &Payload{}
+	if _, err := jwt.ParseWithClaims(token, payload, keyFunc, options...); err != nil {
+		if errors.Is(err, jwt.ErrTokenExpired) {
+			return nil, ErrExpiredToken
+		}
+		return nil, ErrInvalidToken
+	}
+
+	return payload, nil
 }
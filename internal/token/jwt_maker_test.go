@@ -0,0 +1,151 @@
+package token
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const testSecret = "token-test-secret-0123456789abcdef"
+
+// pemKeys returns the private and public PEM encodings of a key
+func pemKeys(t *testing.T, private, public interface{}) ([]byte, []byte) {
+	t.Helper()
+
+	privateDER, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicDER, err := x509.MarshalPKIXPublicKey(public)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privateDER}),
+		pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicDER})
+}
+
+func TestRegisteredClaims(t *testing.T) {
+	key, _ := NewHMACKey("k1", testSecret)
+	maker, err := NewJWTMakerWithOptions(JWTOptions{Issuer: "booking_client", Audience: "booking_api", SigningKey: key})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signed, err := maker.CreateToken("booking_client", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, _, err := jwt.NewParser().ParseUnverified(signed, &Payload{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed.Header["kid"] != "k1" {
+		t.Errorf("kid header is %v, want k1", parsed.Header["kid"])
+	}
+
+	payload, err := maker.VerifyToken(signed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if payload.Issuer != "booking_client" || payload.Subject != "booking_client" || payload.ID == "" ||
+		payload.NotBefore == nil || len(payload.Audience) != 1 || payload.Audience[0] != "booking_api" {
+		t.Errorf("registered claims not filled: %+v", payload.RegisteredClaims)
+	}
+
+	other, _ := NewJWTMakerWithOptions(JWTOptions{Issuer: "booking_client", Audience: "another_api", SigningKey: key})
+	if _, err := other.VerifyToken(signed); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("token for another audience: got %v, want ErrInvalidToken", err)
+	}
+}
+
+func TestAsymmetricKeysAndRotation(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaPrivate, rsaPublic := pemKeys(t, rsaKey, &rsaKey.PublicKey)
+	edPublicKey, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	edPrivate, edPublic := pemKeys(t, edKey, edPublicKey)
+
+	oldSigner, err := ParseKeyPEM("old", rsaPrivate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	newSigner, err := ParseKeyPEM("new", edPrivate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if oldSigner.Algorithm() != "RS256" || newSigner.Algorithm() != "EdDSA" {
+		t.Fatalf("algorithms are %s and %s", oldSigner.Algorithm(), newSigner.Algorithm())
+	}
+	oldMaker, _ := NewJWTMakerWithOptions(JWTOptions{SigningKey: oldSigner})
+	newMaker, _ := NewJWTMakerWithOptions(JWTOptions{SigningKey: newSigner})
+	oldToken, _ := oldMaker.CreateToken("booking_client", time.Minute)
+	newToken, _ := newMaker.CreateToken("booking_client", time.Minute)
+
+	// A verifier holding both public keys accepts tokens of either kid during the rotation
+	oldVerify, _ := ParseKeyPEM("old", rsaPublic)
+	newVerify, _ := ParseKeyPEM("new", edPublic)
+	verifier, err := NewJWTMakerWithOptions(JWTOptions{VerifyKeys: []*Key{oldVerify, newVerify}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, signed := range map[string]string{"old": oldToken, "new": newToken} {
+		if _, err := verifier.VerifyToken(signed); err != nil {
+			t.Errorf("%s token: %v", name, err)
+		}
+	}
+	if _, err := verifier.CreateToken("booking_client", time.Minute); !errors.Is(err, ErrNoSigningKey) {
+		t.Errorf("verifier created a token: %v", err)
+	}
+
+	// Once the old key is dropped, its tokens are rejected
+	rotated, _ := NewJWTMakerWithOptions(JWTOptions{VerifyKeys: []*Key{newVerify}})
+	if _, err := rotated.VerifyToken(oldToken); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("token of a dropped key: got %v, want ErrInvalidToken", err)
+	}
+
+	// A token whose header was swapped for another key's is rejected
+	forged := strings.Replace(newToken, strings.Split(newToken, ".")[0], strings.Split(oldToken, ".")[0], 1)
+	if _, err := verifier.VerifyToken(forged); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("token with a swapped header: got %v, want ErrInvalidToken", err)
+	}
+}
+
+func TestManagerCachesAndRefreshes(t *testing.T) {
+	maker, _ := NewJWTMaker(testSecret)
+	manager := NewManager(maker, ManagerOptions{Service: "booking_client", TTL: 10 * time.Minute})
+	now := time.Now()
+	manager.now = func() time.Time { return now }
+
+	first, err := manager.Token()
+	if err != nil {
+		t.Fatal(err)
+	}
+	now = now.Add(7 * time.Minute)
+	if second, _ := manager.Token(); second != first {
+		t.Error("token was created again before it was due for refresh")
+	}
+
+	now = now.Add(time.Minute)
+	if third, _ := manager.Token(); third == first {
+		t.Error("token was not refreshed shortly before expiry")
+	}
+
+	cached, _ := manager.Token()
+	manager.Invalidate()
+	if fresh, _ := manager.Token(); fresh == cached {
+		t.Error("token was not created again after Invalidate")
+	}
+}
@@ -0,0 +1,99 @@
+package token
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"os"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const minRSAKeyBits = 2048
+
+// Key signs or verifies tokens carrying its ID in the kid header.
+// Keys loaded from a public key only verify.
+type Key struct {
+	ID     string
+	method jwt.SigningMethod
+	sign   interface{}
+	verify interface{}
+}
+
+// NewHMACKey creates an HS256 key from a shared secret
+func NewHMACKey(id, secret string) (*Key, error) {
+	if len(secret) < minSecretKeySize {
+		return nil, fmt.Errorf("invalid key size: must be at least %d characters", minSecretKeySize)
+	}
+	return &Key{ID: id, method: jwt.SigningMethodHS256, sign: []byte(secret), verify: []byte(secret)}, nil
+}
+
+// LoadKeyFile loads an RSA (RS256) or Ed25519 (EdDSA) key from a PEM file
+func LoadKeyFile(id, path string) (*Key, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key file: %w", err)
+	}
+	key, err := ParseKeyPEM(id, data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid key file %s: %w", path, err)
+	}
+	return key, nil
+}
+
+// ParseKeyPEM parses a PKCS#8 or PKCS#1 private key, or a PKIX or PKCS#1 public key
+func ParseKeyPEM(id string, data []byte) (*Key, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM block found")
+	}
+
+	var parsed interface{}
+	var err error
+	switch block.Type {
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		parsed, err = x509.ParsePKIXPublicKey(block.Bytes)
+	case "RSA PUBLIC KEY":
+		parsed, err = x509.ParsePKCS1PublicKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported PEM block %q", block.Type)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", block.Type, err)
+	}
+
+	switch k := parsed.(type) {
+	case *rsa.PrivateKey:
+		if k.N.BitLen() < minRSAKeyBits {
+			return nil, fmt.Errorf("RSA key must have at least %d bits", minRSAKeyBits)
+		}
+		return &Key{ID: id, method: jwt.SigningMethodRS256, sign: k, verify: &k.PublicKey}, nil
+	case *rsa.PublicKey:
+		if k.N.BitLen() < minRSAKeyBits {
+			return nil, fmt.Errorf("RSA key must have at least %d bits", minRSAKeyBits)
+		}
+		return &Key{ID: id, method: jwt.SigningMethodRS256, verify: k}, nil
+	case ed25519.PrivateKey:
+		return &Key{ID: id, method: jwt.SigningMethodEdDSA, sign: k, verify: k.Public()}, nil
+	case ed25519.PublicKey:
+		return &Key{ID: id, method: jwt.SigningMethodEdDSA, verify: k}, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %T", parsed)
+	}
+}
+
+// Algorithm returns the JWT alg of the key
+func (k *Key) Algorithm() string {
+	return k.method.Alg()
+}
+
+// CanSign reports whether the key holds a secret or private key
+func (k *Key) CanSign() bool {
+	return k.sign != nil
+}
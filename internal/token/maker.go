@@ -1,9 +1,24 @@
 package token
 
-import "time"
+import (
+	"errors"
+	"time"
+)
+
+// Token verification errors
+var (
+	ErrInvalidToken = errors.New("token is invalid")
+	ErrExpiredToken = errors.New("token has expired")
+)
 
 // Maker is an interface for managing tokens
 type Maker interface {
 	// CreateToken creates a new token for a specific service and duration
 	CreateToken(service string, duration time.Duration) (string, error)
+
+	// CreateUserToken creates a new token for a service acting on behalf of a user
+	CreateUserToken(service string, actor Actor, duration time.Duration) (string, error)
+
+	// VerifyToken checks if the token is valid and returns its payload
+	VerifyToken(token string) (*Payload, error)
 }
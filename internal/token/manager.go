@@ -0,0 +1,115 @@
+package token
+
+import (
+	"sync"
+	"time"
+)
+
+// ManagerOptions configures a Manager
+type ManagerOptions struct {
+	Service       string        // Service the tokens are created for
+	TTL           time.Duration // Lifetime of each service token
+	UserTTL       time.Duration // Lifetime of each delegated user token, TTL when 0
+	RefreshBefore time.Duration // How long before expiry a new token is created, a fifth of the lifetime when 0
+}
+
+// cachedToken is a token with the time it is due to be replaced
+type cachedToken struct {
+	token     string
+	refreshAt time.Time
+}
+
+// Manager hands out cached service and delegated user tokens, creating new ones shortly
+// before they expire
+type Manager struct {
+	maker         Maker
+	service       string
+	ttl           time.Duration
+	userTTL       time.Duration
+	refreshBefore time.Duration
+
+	mu         sync.Mutex
+	cached     cachedToken
+	userTokens map[Actor]cachedToken
+	now        func() time.Time
+}
+
+// NewManager creates a token manager
+func NewManager(maker Maker, opts ManagerOptions) *Manager {
+	userTTL := opts.UserTTL
+	if userTTL <= 0 {
+		userTTL = opts.TTL
+	}
+	return &Manager{
+		maker:         maker,
+		service:       opts.Service,
+		ttl:           opts.TTL,
+		userTTL:       userTTL,
+		refreshBefore: opts.RefreshBefore,
+		userTokens:    make(map[Actor]cachedToken),
+		now:           time.Now,
+	}
+}
+
+// Token returns the cached service token, or a new one when the cached one is about to expire
+func (m *Manager) Token() (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+	if m.cached.token != "" && now.Before(m.cached.refreshAt) {
+		return m.cached.token, nil
+	}
+
+	token, err := m.maker.CreateToken(m.service, m.ttl)
+	if err != nil {
+		return "", err
+	}
+	m.cached = cachedToken{token: token, refreshAt: now.Add(m.ttl - m.refreshMargin(m.ttl))}
+	return token, nil
+}
+
+// UserToken returns the cached token acting for the user, or a new one when it is about to expire
+func (m *Manager) UserToken(actor Actor) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+	if cached, exists := m.userTokens[actor]; exists && now.Before(cached.refreshAt) {
+		return cached.token, nil
+	}
+
+	token, err := m.maker.CreateUserToken(m.service, actor, m.userTTL)
+	if err != nil {
+		return "", err
+	}
+	m.sweep(now)
+	m.userTokens[actor] = cachedToken{token: token, refreshAt: now.Add(m.userTTL - m.refreshMargin(m.userTTL))}
+	return token, nil
+}
+
+// Invalidate drops all cached tokens, e.g. after the API rejected one
+func (m *Manager) Invalidate() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.cached = cachedToken{}
+	m.userTokens = make(map[Actor]cachedToken)
+}
+
+// refreshMargin returns how long before expiry a token of the given lifetime is replaced
+func (m *Manager) refreshMargin(ttl time.Duration) time.Duration {
+	if m.refreshBefore <= 0 || m.refreshBefore >= ttl {
+		return ttl / 5
+	}
+	return m.refreshBefore
+}
+
+// sweep drops user tokens that are due to be replaced, keeping the cache to active users
+func (m *Manager) sweep(now time.Time) {
+	for actor, cached := range m.userTokens {
+		if !now.Before(cached.refreshAt) {
+			delete(m.userTokens, actor)
+		}
+	}
+}
@@ -1,19 +1,54 @@
 package token
 
-import "time"
+import (
+	"time"
 
-// Payload contains the token claims data
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+// Actor is the chat user a delegated token acts for
+type Actor struct {
+	UserID string
+	Role   string
+	ChatID int64
+}
+
+// Payload contains the token claims data: the calling service and the registered claims.
+// Delegated tokens also carry the role and chat of the user in sub.
 type Payload struct {
-	Service   string    `json:"service"`
-	IssuedAt  time.Time `json:"issued_at"`
-	ExpiredAt time.Time `json:"expired_at"`
+	Service string `json:"service"`
+	Role    string `json:"role,omitempty"`
+	ChatID  int64  `json:"chat_id,omitempty"`
+	jwt.RegisteredClaims
 }
 
-// NewPayload creates a new token payload with a specific service and duration
+// NewPayload creates a new token payload with a specific service and duration.
+// The service is the subject; issuer and audience are filled in by the maker.
 func NewPayload(service string, duration time.Duration) *Payload {
+	now := time.Now()
 	return &Payload{
-		Service:   service,
-		IssuedAt:  time.Now(),
-		ExpiredAt: time.Now().Add(duration),
+		Service: service,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.NewString(),
+			Subject:   service,
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(duration)),
+		},
 	}
 }
+
+// NewUserPayload creates a payload for a service acting on behalf of a user
+func NewUserPayload(service string, actor Actor, duration time.Duration) *Payload {
+	payload := NewPayload(service, duration)
+	payload.Subject = actor.UserID
+	payload.Role = actor.Role
+	payload.ChatID = actor.ChatID
+	return payload
+}
+
+// Delegated reports whether the token acts on behalf of a user rather than as the service
+func (payload *Payload) Delegated() bool {
+	return payload.Role != ""
+}
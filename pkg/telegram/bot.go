@@ -26,7 +26,11 @@ type Bot struct {
 	wg            sync.WaitGroup
 	updateHandler UpdateHandler
 	workers       int
-	updateChan    chan tgbotapi.Update
+	shards        []chan tgbotapi.Update
+	scheduler     *sendScheduler
+	polling       bool
+	webhookServer *http.Server
+	webhookConfig WebhookConfig
 }
 
 // NewBot creates a new Telegram bot instance
@@ -62,13 +66,24 @@ func NewBotWithWorkers(token string, logger *zerolog.Logger, workers int) (*Bot,
 
 	ctx, cancel := context.WithCancel(context.Background())
 
+	if workers < 1 {
+		workers = 1
+	}
+
+	// One bounded queue per worker; a chat always maps to the same queue
+	shards := make([]chan tgbotapi.Update, workers)
+	for i := range shards {
+		shards[i] = make(chan tgbotapi.Update, ShardQueueSize)
+	}
+
 	bot := &Bot{
-		api:        api,
-		logger:     logger,
-		ctx:        ctx,
-		cancel:     cancel,
-		workers:    workers,
-		updateChan: make(chan tgbotapi.Update, workers*10), // Buffer for workers
+		api:       api,
+		logger:    logger,
+		ctx:       ctx,
+		cancel:    cancel,
+		workers:   workers,
+		shards:    shards,
+		scheduler: newSendScheduler(logger),
 	}
 
 	logger.Info().Str("username", api.Self.UserName).Msg("Authorized on account")
@@ -77,10 +92,16 @@ func NewBotWithWorkers(token string, logger *zerolog.Logger, workers int) (*Bot,
 
 // Start starts the bot and begins polling for updates
 func (b *Bot) Start() error {
+	// getUpdates is rejected by Telegram while a webhook is registered
+	if _, err := b.api.Request(tgbotapi.DeleteWebhookConfig{}); err != nil {
+		return fmt.Errorf("failed to remove webhook before polling: %w", err)
+	}
+
 	u := tgbotapi.NewUpdate(0)
 	u.Timeout = 60
 
 	updates := b.api.GetUpdatesChan(u)
+	b.polling = true
 
 	// Start update receiver
 	b.wg.Add(1)
@@ -89,33 +110,44 @@ func (b *Bot) Start() error {
 		for {
 			select {
 			case <-b.ctx.Done():
-				close(b.updateChan)
 				return
-			case update := <-updates:
-				select {
-				case b.updateChan <- update:
-				case <-b.ctx.Done():
+			case update, ok := <-updates:
+				if !ok {
+					return
+				}
+				if !b.enqueue(update) {
 					return
 				}
 			}
 		}
 	}()
 
-	// Start worker pool
-	for i := 0; i < b.workers; i++ {
-		b.wg.Add(1)
-		go b.worker(i)
-	}
+	b.startWorkers()
 
 	b.logger.Info().
 		Int("workers", b.workers).
+		Str("mode", "polling").
 		Msg("Bot started with worker pool")
 
 	return nil
 }
 
+// startWorkers starts one worker per shard queue
+func (b *Bot) startWorkers() {
+	for i := 0; i < b.workers; i++ {
+		b.wg.Add(1)
+		go b.worker(i)
+	}
+}
+
 // Stop gracefully stops the bot
 func (b *Bot) Stop() {
+	if b.webhookServer != nil {
+		b.stopWebhook()
+	}
+	if b.polling {
+		b.api.StopReceivingUpdates()
+	}
 	b.cancel()
 	b.wg.Wait()
 }
@@ -125,7 +157,7 @@ func (b *Bot) SetUpdateHandler(handler UpdateHandler) {
 	b.updateHandler = handler
 }
 
-// worker processes updates in a separate goroutine
+// worker processes the updates of its shard in order
 func (b *Bot) worker(id int) {
 	defer b.wg.Done()
 
@@ -146,11 +178,7 @@ func (b *Bot) worker(id int) {
 		case <-b.ctx.Done():
 			b.logger.Debug().Int("worker_id", id).Msg("Worker stopped")
 			return
-		case update, ok := <-b.updateChan:
-			if !ok {
-				b.logger.Debug().Int("worker_id", id).Msg("Update channel closed, worker stopping")
-				return
-			}
+		case update := <-b.shards[id]:
 			b.handleUpdate(update)
 		}
 	}
@@ -170,8 +198,9 @@ func (b *Bot) handleUpdate(update tgbotapi.Update) {
 	if b.updateHandler != nil {
 		b.updateHandler.HandleUpdate(update)
 	} else if update.Message != nil {
+		// Without a handler nothing knows whether the text is sensitive, so only its length is logged
 		b.logger.Debug().
-			Str("message", update.Message.Text).
+			Int("message_length", len(update.Message.Text)).
 			Int64("user_id", update.Message.From.ID).
 			Msg("Received message")
 	}
@@ -180,14 +209,14 @@ func (b *Bot) handleUpdate(update tgbotapi.Update) {
 // SendMessage sends a message to a specific chat
 func (b *Bot) SendMessage(chatID int64, text string) error {
 	msg := tgbotapi.NewMessage(chatID, text)
-	_, err := b.api.Send(msg)
+	_, err := b.send(chatID, msg)
 	return err
 }
 
 // SendMessageWithID sends a message and returns the message ID
 func (b *Bot) SendMessageWithID(chatID int64, text string) (int, error) {
 	msg := tgbotapi.NewMessage(chatID, text)
-	sentMsg, err := b.api.Send(msg)
+	sentMsg, err := b.send(chatID, msg)
 	if err != nil {
 		return 0, err
 	}
@@ -198,7 +227,7 @@ func (b *Bot) SendMessageWithID(chatID int64, text string) (int, error) {
 func (b *Bot) SendMessageWithKeyboard(chatID int64, text string, keyboard tgbotapi.InlineKeyboardMarkup) error {
 	msg := tgbotapi.NewMessage(chatID, text)
 	msg.ReplyMarkup = keyboard
-	_, err := b.api.Send(msg)
+	_, err := b.send(chatID, msg)
 	return err
 }
 
@@ -206,7 +235,7 @@ func (b *Bot) SendMessageWithKeyboard(chatID int64, text string, keyboard tgbota
 func (b *Bot) SendMessageWithKeyboardAndID(chatID int64, text string, keyboard tgbotapi.InlineKeyboardMarkup) (int, error) {
 	msg := tgbotapi.NewMessage(chatID, text)
 	msg.ReplyMarkup = keyboard
-	sentMsg, err := b.api.Send(msg)
+	sentMsg, err := b.send(chatID, msg)
 	if err != nil {
 		return 0, err
 	}
@@ -216,7 +245,7 @@ func (b *Bot) SendMessageWithKeyboardAndID(chatID int64, text string, keyboard t
 // EditMessage edits an existing message
 func (b *Bot) EditMessage(chatID int64, messageID int, text string) error {
 	edit := tgbotapi.NewEditMessageText(chatID, messageID, text)
-	_, err := b.api.Send(edit)
+	_, err := b.send(chatID, edit)
 	return err
 }
 
@@ -224,20 +253,53 @@ func (b *Bot) EditMessage(chatID int64, messageID int, text string) error {
 func (b *Bot) EditMessageWithKeyboard(chatID int64, messageID int, text string, keyboard tgbotapi.InlineKeyboardMarkup) error {
 	edit := tgbotapi.NewEditMessageText(chatID, messageID, text)
 	edit.ReplyMarkup = &keyboard
-	_, err := b.api.Send(edit)
+	_, err := b.send(chatID, edit)
 	return err
 }
 
 // DeleteMessage deletes a message
 func (b *Bot) DeleteMessage(chatID int64, messageID int) error {
 	delete := tgbotapi.NewDeleteMessage(chatID, messageID)
-	_, err := b.api.Send(delete)
-	return err
+	// deleteMessage returns a bool result, so Request is used instead of Send.
+	// Deletions only count against the global limit.
+	return b.request(0, delete)
 }
 
-// GetAPI returns the underlying bot API for advanced operations
-func (b *Bot) GetAPI() *tgbotapi.BotAPI {
-	return b.api
+// send sends a message-producing request through the outbound scheduler
+func (b *Bot) send(chatID int64, c tgbotapi.Chattable) (tgbotapi.Message, error) {
+	var sentMsg tgbotapi.Message
+	err := b.scheduler.do(b.ctx, chatID, func() error {
+		var err error
+		sentMsg, err = b.api.Send(c)
+		return err
+	})
+	return sentMsg, err
+}
+
+// request sends a request without a message result through the outbound scheduler
+func (b *Bot) request(chatID int64, c tgbotapi.Chattable) error {
+	return b.scheduler.do(b.ctx, chatID, func() error {
+		_, err := b.api.Request(c)
+		return err
+	})
+}
+
+// AnswerCallbackQuery answers a callback query, optionally as an alert popup
+func (b *Bot) AnswerCallbackQuery(callbackQueryID string, text string, showAlert bool) error {
+	callback := tgbotapi.NewCallback(callbackQueryID, text)
+	callback.ShowAlert = showAlert
+	// Callback answers are not messages, so only the global limit applies
+	return b.request(0, callback)
+}
+
+// SetCommands publishes the command menu of a chat, or the default menu when chatID is 0
+func (b *Bot) SetCommands(chatID int64, commands []tgbotapi.BotCommand) error {
+	scope := tgbotapi.NewBotCommandScopeDefault()
+	if chatID != 0 {
+		scope = tgbotapi.NewBotCommandScopeChat(chatID)
+	}
+	// Menus are not messages, so only the global limit applies
+	return b.request(0, tgbotapi.NewSetMyCommandsWithScope(scope, commands...))
 }
 
 // GetLogger returns the logger instance
@@ -0,0 +1,31 @@
+package telegram
+
+import (
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Messenger is the set of outgoing Telegram operations handlers depend on.
+// Bot implements it against the real API; telegramtest.FakeMessenger records calls for tests.
+type Messenger interface {
+	// SendMessage sends a message to a specific chat
+	SendMessage(chatID int64, text string) error
+	// SendMessageWithID sends a message and returns the message ID
+	SendMessageWithID(chatID int64, text string) (int, error)
+	// SendMessageWithKeyboard sends a message with an inline keyboard
+	SendMessageWithKeyboard(chatID int64, text string, keyboard tgbotapi.InlineKeyboardMarkup) error
+	// SendMessageWithKeyboardAndID sends a message with an inline keyboard and returns the message ID
+	SendMessageWithKeyboardAndID(chatID int64, text string, keyboard tgbotapi.InlineKeyboardMarkup) (int, error)
+	// EditMessage edits the text of an existing message
+	EditMessage(chatID int64, messageID int, text string) error
+	// EditMessageWithKeyboard edits the text and inline keyboard of an existing message
+	EditMessageWithKeyboard(chatID int64, messageID int, text string, keyboard tgbotapi.InlineKeyboardMarkup) error
+	// DeleteMessage deletes a message
+	DeleteMessage(chatID int64, messageID int) error
+	// AnswerCallbackQuery answers a callback query, optionally as an alert popup
+	AnswerCallbackQuery(callbackQueryID string, text string, showAlert bool) error
+	// SetCommands publishes the command menu of a chat, or the default menu when chatID is 0
+	SetCommands(chatID int64, commands []tgbotapi.BotCommand) error
+}
+
+// Ensure Bot implements Messenger
+var _ Messenger = (*Bot)(nil)
@@ -0,0 +1,198 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/rs/zerolog"
+)
+
+// Telegram Bot API limits for outgoing messages
+// See https://core.telegram.org/bots/faq#my-bot-is-hitting-limits-how-do-i-avoid-this
+const (
+	GlobalMessagesPerSecond = 30
+	ChatMessagesPerSecond   = 1
+	ChatBurst               = 3
+
+	// maxSendAttempts bounds retries of a single call after 429 responses
+	maxSendAttempts = 5
+	// chatBucketPruneThreshold triggers removal of idle per-chat buckets
+	chatBucketPruneThreshold = 1000
+)
+
+// sendScheduler paces outgoing requests to stay within Telegram's global and
+// per-chat limits and retries requests rejected with retry_after
+type sendScheduler struct {
+	mu     sync.Mutex
+	global *tokenBucket
+	chats  map[int64]*tokenBucket
+	logger *zerolog.Logger
+}
+
+// newSendScheduler creates a scheduler with Telegram's default limits
+func newSendScheduler(logger *zerolog.Logger) *sendScheduler {
+	return &sendScheduler{
+		global: newTokenBucket(GlobalMessagesPerSecond, GlobalMessagesPerSecond),
+		chats:  make(map[int64]*tokenBucket),
+		logger: logger,
+	}
+}
+
+// do runs fn once the limits allow it. If Telegram answers with 429, the chat
+// is paused for retry_after and fn is retried instead of failing the call.
+// Per-chat limits are skipped for chatID 0 (e.g. callback answers).
+func (s *sendScheduler) do(ctx context.Context, chatID int64, fn func() error) error {
+	var err error
+	for attempt := 1; attempt <= maxSendAttempts; attempt++ {
+		if err := s.wait(ctx, chatID); err != nil {
+			return err
+		}
+
+		err = fn()
+		retryAfter, ok := retryAfterFromError(err)
+		if !ok {
+			return err
+		}
+
+		s.logger.Warn().
+			Int64("chat_id", chatID).
+			Int("attempt", attempt).
+			Dur("retry_after", retryAfter).
+			Msg("Telegram rate limit hit, retrying after delay")
+		s.pause(chatID, retryAfter)
+	}
+	return err
+}
+
+// wait blocks until both the global and the chat bucket have a token
+func (s *sendScheduler) wait(ctx context.Context, chatID int64) error {
+	delay := s.reserve(chatID, time.Now())
+	if delay <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// reserve takes a token from each applicable bucket and returns the required delay
+func (s *sendScheduler) reserve(chatID int64, now time.Time) time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delay := s.global.reserve(now)
+	if chatID == 0 {
+		return delay
+	}
+
+	bucket, exists := s.chats[chatID]
+	if !exists {
+		if len(s.chats) >= chatBucketPruneThreshold {
+			s.pruneIdle(now)
+		}
+		bucket = newTokenBucket(ChatMessagesPerSecond, ChatBurst)
+		s.chats[chatID] = bucket
+	}
+
+	if chatDelay := bucket.reserve(now); chatDelay > delay {
+		delay = chatDelay
+	}
+	return delay
+}
+
+// pause delays further requests for the chat (or all requests for chatID 0)
+func (s *sendScheduler) pause(chatID int64, d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	until := time.Now().Add(d)
+	if chatID == 0 {
+		s.global.pauseUntil(until)
+		return
+	}
+	if bucket, exists := s.chats[chatID]; exists {
+		bucket.pauseUntil(until)
+	}
+}
+
+// pruneIdle drops chat buckets that are full again and therefore carry no state
+func (s *sendScheduler) pruneIdle(now time.Time) {
+	for chatID, bucket := range s.chats {
+		if bucket.idle(now) {
+			delete(s.chats, chatID)
+		}
+	}
+}
+
+// retryAfterFromError extracts retry_after from a Telegram "Too Many Requests" error
+func retryAfterFromError(err error) (time.Duration, bool) {
+	var tgErr *tgbotapi.Error
+	if !errors.As(err, &tgErr) || tgErr.RetryAfter <= 0 {
+		return 0, false
+	}
+	return time.Duration(tgErr.RetryAfter) * time.Second, true
+}
+
+// tokenBucket is a reservation based token bucket: callers take a token
+// immediately and sleep for the returned delay if the bucket was empty
+type tokenBucket struct {
+	rate   float64 // tokens per second
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// newTokenBucket creates a full bucket
+func newTokenBucket(rate, burst float64) *tokenBucket {
+	return &tokenBucket{
+		rate:   rate,
+		burst:  burst,
+		tokens: burst,
+		last:   time.Now(),
+	}
+}
+
+// reserve takes one token and returns how long to wait before it may be used
+func (tb *tokenBucket) reserve(now time.Time) time.Duration {
+	tb.refill(now)
+	tb.tokens--
+	if tb.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-tb.tokens / tb.rate * float64(time.Second))
+}
+
+// pauseUntil empties the bucket so the next token is available at the given time
+func (tb *tokenBucket) pauseUntil(until time.Time) {
+	now := time.Now()
+	tb.refill(now)
+	tokens := -until.Sub(now).Seconds() * tb.rate
+	if tokens < tb.tokens {
+		tb.tokens = tokens
+	}
+}
+
+// idle reports whether the bucket has fully refilled
+func (tb *tokenBucket) idle(now time.Time) bool {
+	tb.refill(now)
+	return tb.tokens >= tb.burst
+}
+
+// refill adds the tokens accumulated since the last update
+func (tb *tokenBucket) refill(now time.Time) {
+	if now.After(tb.last) {
+		tb.tokens = math.Min(tb.burst, tb.tokens+now.Sub(tb.last).Seconds()*tb.rate)
+		tb.last = now
+	}
+}
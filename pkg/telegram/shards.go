@@ -0,0 +1,74 @@
+package telegram
+
+import (
+	"context"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// ShardQueueSize is the maximum number of updates buffered per shard.
+// When a shard is full, the receiver blocks until its worker catches up.
+const ShardQueueSize = 50
+
+// ShardStats describes the queue of a single shard
+type ShardStats struct {
+	Shard    int `json:"shard"`
+	Depth    int `json:"depth"`
+	Capacity int `json:"capacity"`
+}
+
+// QueueStats returns the current depth of every shard queue (for monitoring)
+func (b *Bot) QueueStats() []ShardStats {
+	stats := make([]ShardStats, len(b.shards))
+	for i, shard := range b.shards {
+		stats[i] = ShardStats{
+			Shard:    i,
+			Depth:    len(shard),
+			Capacity: cap(shard),
+		}
+	}
+	return stats
+}
+
+// enqueue places an update on the shard owned by its chat.
+// Returns false if the bot is stopping.
+func (b *Bot) enqueue(update tgbotapi.Update) bool {
+	return b.enqueueWithContext(context.Background(), update)
+}
+
+// enqueueWithContext places an update on its shard, giving up when ctx or the bot is done
+func (b *Bot) enqueueWithContext(ctx context.Context, update tgbotapi.Update) bool {
+	shard := b.shards[b.shardFor(updateChatID(update))]
+
+	select {
+	case shard <- update:
+		return true
+	case <-b.ctx.Done():
+		return false
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// shardFor maps a chat ID to a shard index, so updates of one chat are handled in order
+func (b *Bot) shardFor(chatID int64) int {
+	return int(uint64(chatID) % uint64(len(b.shards)))
+}
+
+// updateChatID extracts the chat an update belongs to (0 if unknown)
+func updateChatID(update tgbotapi.Update) int64 {
+	switch {
+	case update.Message != nil && update.Message.Chat != nil:
+		return update.Message.Chat.ID
+	case update.EditedMessage != nil && update.EditedMessage.Chat != nil:
+		return update.EditedMessage.Chat.ID
+	case update.CallbackQuery != nil:
+		if update.CallbackQuery.Message != nil && update.CallbackQuery.Message.Chat != nil {
+			return update.CallbackQuery.Message.Chat.ID
+		}
+		if update.CallbackQuery.From != nil {
+			return update.CallbackQuery.From.ID
+		}
+	}
+	return 0
+}
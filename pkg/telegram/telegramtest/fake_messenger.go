@@ -0,0 +1,240 @@
+// Package telegramtest provides an in-process fake of telegram.Messenger that
+// records every outgoing call, so conversations can be asserted without network.
+package telegramtest
+
+import (
+	"fmt"
+	"sync"
+
+	"booking_client/pkg/telegram"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Kind identifies the type of recorded call
+type Kind string
+
+const (
+	KindSend           Kind = "send"
+	KindEdit           Kind = "edit"
+	KindDelete         Kind = "delete"
+	KindCallbackAnswer Kind = "callback_answer"
+	KindCommands       Kind = "commands"
+)
+
+// Message is a single recorded outgoing call
+type Message struct {
+	Kind            Kind
+	ChatID          int64
+	MessageID       int
+	Text            string
+	Keyboard        *tgbotapi.InlineKeyboardMarkup
+	CallbackQueryID string
+	ShowAlert       bool
+	Commands        []tgbotapi.BotCommand
+}
+
+// Buttons returns the keyboard buttons in row order
+func (m Message) Buttons() []tgbotapi.InlineKeyboardButton {
+	if m.Keyboard == nil {
+		return nil
+	}
+	var buttons []tgbotapi.InlineKeyboardButton
+	for _, row := range m.Keyboard.InlineKeyboard {
+		buttons = append(buttons, row...)
+	}
+	return buttons
+}
+
+// ButtonTexts returns the texts of all keyboard buttons in row order
+func (m Message) ButtonTexts() []string {
+	var texts []string
+	for _, button := range m.Buttons() {
+		texts = append(texts, button.Text)
+	}
+	return texts
+}
+
+// CallbackData returns the callback data of the button with the given text
+func (m Message) CallbackData(buttonText string) (string, bool) {
+	for _, button := range m.Buttons() {
+		if button.Text == buttonText && button.CallbackData != nil {
+			return *button.CallbackData, true
+		}
+	}
+	return "", false
+}
+
+// FakeMessenger is a recording telegram.Messenger.
+// Sent messages get sequential message IDs starting at 1001.
+type FakeMessenger struct {
+	mu            sync.Mutex
+	nextMessageID int
+	messages      []Message
+	failures      []error
+}
+
+// Ensure FakeMessenger implements Messenger
+var _ telegram.Messenger = (*FakeMessenger)(nil)
+
+// NewFakeMessenger creates an empty fake messenger
+func NewFakeMessenger() *FakeMessenger {
+	return &FakeMessenger{nextMessageID: 1000}
+}
+
+// FailNext makes the next recorded call return err instead of succeeding
+func (f *FakeMessenger) FailNext(err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.failures = append(f.failures, err)
+}
+
+// Messages returns a copy of all recorded calls in order
+func (f *FakeMessenger) Messages() []Message {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]Message(nil), f.messages...)
+}
+
+// MessagesForChat returns the recorded calls of one chat, optionally filtered by kind
+func (f *FakeMessenger) MessagesForChat(chatID int64, kinds ...Kind) []Message {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var result []Message
+	for _, msg := range f.messages {
+		if msg.ChatID != chatID {
+			continue
+		}
+		if len(kinds) > 0 && !containsKind(kinds, msg.Kind) {
+			continue
+		}
+		result = append(result, msg)
+	}
+	return result
+}
+
+// CallbackAnswers returns the recorded callback query answers in order
+func (f *FakeMessenger) CallbackAnswers() []Message {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var result []Message
+	for _, msg := range f.messages {
+		if msg.Kind == KindCallbackAnswer {
+			result = append(result, msg)
+		}
+	}
+	return result
+}
+
+// LastSent returns the last message sent or edited in the chat
+func (f *FakeMessenger) LastSent(chatID int64) (Message, bool) {
+	sent := f.MessagesForChat(chatID, KindSend, KindEdit)
+	if len(sent) == 0 {
+		return Message{}, false
+	}
+	return sent[len(sent)-1], true
+}
+
+// Reset clears all recorded calls
+func (f *FakeMessenger) Reset() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.messages = nil
+	f.failures = nil
+}
+
+// SendMessage records a plain message
+func (f *FakeMessenger) SendMessage(chatID int64, text string) error {
+	_, err := f.record(Message{Kind: KindSend, ChatID: chatID, Text: text})
+	return err
+}
+
+// SendMessageWithID records a plain message and returns its ID
+func (f *FakeMessenger) SendMessageWithID(chatID int64, text string) (int, error) {
+	return f.record(Message{Kind: KindSend, ChatID: chatID, Text: text})
+}
+
+// SendMessageWithKeyboard records a message with keyboard
+func (f *FakeMessenger) SendMessageWithKeyboard(chatID int64, text string, keyboard tgbotapi.InlineKeyboardMarkup) error {
+	_, err := f.record(Message{Kind: KindSend, ChatID: chatID, Text: text, Keyboard: &keyboard})
+	return err
+}
+
+// SendMessageWithKeyboardAndID records a message with keyboard and returns its ID
+func (f *FakeMessenger) SendMessageWithKeyboardAndID(chatID int64, text string, keyboard tgbotapi.InlineKeyboardMarkup) (int, error) {
+	return f.record(Message{Kind: KindSend, ChatID: chatID, Text: text, Keyboard: &keyboard})
+}
+
+// EditMessage records a text edit
+func (f *FakeMessenger) EditMessage(chatID int64, messageID int, text string) error {
+	_, err := f.record(Message{Kind: KindEdit, ChatID: chatID, MessageID: messageID, Text: text})
+	return err
+}
+
+// EditMessageWithKeyboard records a text and keyboard edit
+func (f *FakeMessenger) EditMessageWithKeyboard(chatID int64, messageID int, text string, keyboard tgbotapi.InlineKeyboardMarkup) error {
+	_, err := f.record(Message{Kind: KindEdit, ChatID: chatID, MessageID: messageID, Text: text, Keyboard: &keyboard})
+	return err
+}
+
+// DeleteMessage records a deletion
+func (f *FakeMessenger) DeleteMessage(chatID int64, messageID int) error {
+	_, err := f.record(Message{Kind: KindDelete, ChatID: chatID, MessageID: messageID})
+	return err
+}
+
+// AnswerCallbackQuery records a callback answer
+func (f *FakeMessenger) AnswerCallbackQuery(callbackQueryID string, text string, showAlert bool) error {
+	_, err := f.record(Message{Kind: KindCallbackAnswer, CallbackQueryID: callbackQueryID, Text: text, ShowAlert: showAlert})
+	return err
+}
+
+// SetCommands records a command menu; chat ID 0 is the default menu
+func (f *FakeMessenger) SetCommands(chatID int64, commands []tgbotapi.BotCommand) error {
+	_, err := f.record(Message{Kind: KindCommands, ChatID: chatID, Commands: append([]tgbotapi.BotCommand(nil), commands...)})
+	return err
+}
+
+// Commands returns the command names of the last menu published for the chat, 0 for the default menu
+func (f *FakeMessenger) Commands(chatID int64) ([]string, bool) {
+	menus := f.MessagesForChat(chatID, KindCommands)
+	if len(menus) == 0 {
+		return nil, false
+	}
+	var names []string
+	for _, command := range menus[len(menus)-1].Commands {
+		names = append(names, command.Command)
+	}
+	return names, true
+}
+
+// record stores the call, assigning a new message ID to sends
+func (f *FakeMessenger) record(msg Message) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if len(f.failures) > 0 {
+		err := f.failures[0]
+		f.failures = f.failures[1:]
+		return 0, fmt.Errorf("fake messenger: %w", err)
+	}
+
+	if msg.Kind == KindSend {
+		f.nextMessageID++
+		msg.MessageID = f.nextMessageID
+	}
+	f.messages = append(f.messages, msg)
+	return msg.MessageID, nil
+}
+
+// containsKind reports whether kinds contains kind
+func containsKind(kinds []Kind, kind Kind) bool {
+	for _, k := range kinds {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
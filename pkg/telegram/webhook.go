@@ -0,0 +1,159 @@
+package telegram
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// secretTokenHeader is the header Telegram sets on every webhook request
+// when the webhook was registered with a secret_token
+const secretTokenHeader = "X-Telegram-Bot-Api-Secret-Token"
+
+// webhookShutdownTimeout bounds how long Stop waits for in-flight webhook requests
+const webhookShutdownTimeout = 10 * time.Second
+
+// WebhookConfig holds the settings for webhook update delivery
+type WebhookConfig struct {
+	// URL is the public HTTPS URL Telegram posts updates to.
+	// Its path is also the path the local server listens on.
+	URL string
+	// Port is the local port the HTTP server listens on
+	Port int
+	// SecretToken is sent by Telegram in the X-Telegram-Bot-Api-Secret-Token header
+	SecretToken string
+	// RemoveOnStop deletes the webhook registration when the bot stops
+	RemoveOnStop bool
+	// DropPendingUpdates drops updates queued on Telegram's side when registering
+	DropPendingUpdates bool
+}
+
+// StartWebhook registers the webhook with Telegram and starts an HTTP server
+// that feeds received updates into the worker pool
+func (b *Bot) StartWebhook(cfg WebhookConfig) error {
+	webhookURL, err := url.Parse(cfg.URL)
+	if err != nil {
+		return fmt.Errorf("invalid webhook URL: %w", err)
+	}
+	if cfg.SecretToken == "" {
+		return errors.New("webhook secret token is required")
+	}
+
+	path := webhookURL.Path
+	if path == "" {
+		path = "/"
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(path, b.handleWebhook)
+
+	listener, err := net.Listen("tcp", ":"+strconv.Itoa(cfg.Port))
+	if err != nil {
+		return fmt.Errorf("failed to listen on port %d: %w", cfg.Port, err)
+	}
+
+	b.webhookConfig = cfg
+	b.webhookServer = &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	b.startWorkers()
+
+	b.wg.Add(1)
+	go func() {
+		defer b.wg.Done()
+		if err := b.webhookServer.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			b.logger.Error().Err(err).Msg("Webhook server stopped unexpectedly")
+		}
+	}()
+
+	if err := b.registerWebhook(cfg); err != nil {
+		b.Stop()
+		return err
+	}
+
+	b.logger.Info().
+		Int("workers", b.workers).
+		Int("port", cfg.Port).
+		Str("path", path).
+		Str("mode", "webhook").
+		Msg("Bot started with worker pool")
+
+	return nil
+}
+
+// registerWebhook calls setWebhook with the configured URL and secret token
+func (b *Bot) registerWebhook(cfg WebhookConfig) error {
+	// tgbotapi.WebhookConfig has no secret_token field, so the request is built by hand
+	params := tgbotapi.Params{}
+	params["url"] = cfg.URL
+	params["secret_token"] = cfg.SecretToken
+	params.AddBool("drop_pending_updates", cfg.DropPendingUpdates)
+	if err := params.AddInterface("allowed_updates", []string{"message", "callback_query"}); err != nil {
+		return fmt.Errorf("failed to build webhook params: %w", err)
+	}
+
+	if _, err := b.api.MakeRequest("setWebhook", params); err != nil {
+		return fmt.Errorf("failed to register webhook: %w", err)
+	}
+
+	b.logger.Info().Msg("Webhook registered")
+	return nil
+}
+
+// stopWebhook shuts the webhook server down and removes the registration if configured
+func (b *Bot) stopWebhook() {
+	ctx, cancel := context.WithTimeout(context.Background(), webhookShutdownTimeout)
+	defer cancel()
+
+	if err := b.webhookServer.Shutdown(ctx); err != nil {
+		b.logger.Error().Err(err).Msg("Failed to shut down webhook server")
+	}
+
+	if b.webhookConfig.RemoveOnStop {
+		if _, err := b.api.Request(tgbotapi.DeleteWebhookConfig{}); err != nil {
+			b.logger.Error().Err(err).Msg("Failed to remove webhook")
+		} else {
+			b.logger.Info().Msg("Webhook removed")
+		}
+	}
+}
+
+// handleWebhook validates and decodes a webhook request and queues the update
+func (b *Bot) handleWebhook(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	token := r.Header.Get(secretTokenHeader)
+	if subtle.ConstantTimeCompare([]byte(token), []byte(b.webhookConfig.SecretToken)) != 1 {
+		b.logger.Warn().Str("remote_addr", r.RemoteAddr).Msg("Rejected webhook request with invalid secret token")
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var update tgbotapi.Update
+	if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+		b.logger.Error().Err(err).Msg("Failed to decode webhook update")
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+
+	if !b.enqueueWithContext(r.Context(), update) {
+		// Telegram retries non-2xx responses, so the update is not lost
+		http.Error(w, "shutting down", http.StatusServiceUnavailable)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}